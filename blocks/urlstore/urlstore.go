@@ -0,0 +1,330 @@
+// package urlstore implements a blockstore that keeps references to content
+// served over HTTP instead of the blocks built from it. Referenced blocks are
+// fetched from their URL, and rebuilt, when they are read.
+package urlstore
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	"github.com/ipfs/go-ipfs/importer/chunk"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dsq "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/query"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+var log = logging.Logger("urlstore")
+
+// refPrefix is the datastore key under which references are kept
+var refPrefix = ds.NewKey("/local/urlstore")
+
+// FetchTimeout is how long fetching a referenced block may take, as reads
+// of the blockstore can't be canceled
+var FetchTimeout = time.Minute
+
+// Ref locates the content of a leaf block at a URL
+type Ref struct {
+	URL    string
+	Offset int64
+	Length int64
+
+	// whether the leaf is a raw block, as in trickle dags, or a file
+	Raw bool
+}
+
+// URLStore is a blockstore that also serves the blocks it has references
+// for. Blocks are looked up in the wrapped blockstore first.
+type URLStore struct {
+	bstore.GCBlockstore
+	refs   ds.Datastore
+	client *http.Client
+}
+
+// NewURLStore returns a URLStore over 'bs', that keeps its references in
+// 'd'
+func NewURLStore(bs bstore.GCBlockstore, d ds.Datastore) *URLStore {
+	return &URLStore{
+		GCBlockstore: bs,
+		refs:         d,
+		client:       &http.Client{Timeout: FetchTimeout},
+	}
+}
+
+func refKey(k key.Key) ds.Key {
+	return refPrefix.ChildString(k.B58String())
+}
+
+// PutRef records where the content of the leaf 'k' can be fetched from
+func (s *URLStore) PutRef(k key.Key, r *Ref) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.refs.Put(refKey(k), b)
+}
+
+// GetRef returns the reference for 'k', or ds.ErrNotFound if there is none
+func (s *URLStore) GetRef(k key.Key) (*Ref, error) {
+	v, err := s.refs.Get(refKey(k))
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, bstore.ValueTypeMismatch
+	}
+
+	r := new(Ref)
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *URLStore) Has(k key.Key) (bool, error) {
+	has, err := s.GCBlockstore.Has(k)
+	if err != nil || has {
+		return has, err
+	}
+	return s.refs.Has(refKey(k))
+}
+
+func (s *URLStore) Get(k key.Key) (blocks.Block, error) {
+	b, err := s.GCBlockstore.Get(k)
+	if err != bstore.ErrNotFound {
+		return b, err
+	}
+
+	r, err := s.GetRef(k)
+	if err == ds.ErrNotFound {
+		return nil, bstore.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.fetch(k, r)
+}
+
+func (s *URLStore) DeleteBlock(k key.Key) error {
+	has, err := s.refs.Has(refKey(k))
+	if err != nil {
+		return err
+	}
+	if has {
+		return s.refs.Delete(refKey(k))
+	}
+	return s.GCBlockstore.DeleteBlock(k)
+}
+
+// AllKeysChan returns the keys of the wrapped blockstore, followed by those
+// there are references for
+func (s *URLStore) AllKeysChan(ctx context.Context) (<-chan key.Key, error) {
+	stored, err := s.GCBlockstore.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.refs.Query(dsq.Query{Prefix: refPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan key.Key, dsq.KeysOnlyBufSize)
+	go func() {
+		defer close(out)
+		defer res.Process().Close()
+
+		for k := range stored {
+			select {
+			case out <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for e := range res.Next() {
+			if e.Error != nil {
+				log.Debug("urlstore.AllKeysChan got err:", e.Error)
+				return
+			}
+
+			k := key.B58KeyDecode(ds.NewKey(e.Key).BaseNamespace())
+			if k == "" {
+				continue
+			}
+
+			select {
+			case out <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// fetch gets the content 'r' points to, and rebuilds the leaf 'k' from it
+func (s *URLStore) fetch(k key.Key, r *Ref) (blocks.Block, error) {
+	req, err := http.NewRequest("GET", r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server ignored the range
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, r.Offset); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to fetch %s: %s", r.URL, resp.Status)
+	}
+
+	data := make([]byte, r.Length)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, err
+	}
+
+	b, err := leafBlock(data, r.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if b.Key() != k {
+		return nil, fmt.Errorf("content of %s changed, %s no longer matches", r.URL, k)
+	}
+	return b, nil
+}
+
+// leafBlock returns the block of a leaf holding 'data', built the way the
+// importer builds it
+func leafBlock(data []byte, raw bool) (blocks.Block, error) {
+	fsn := &ft.FSNode{Type: ft.TFile, Data: data}
+	if raw {
+		fsn.Type = ft.TRaw
+	}
+
+	fsdata, err := fsn.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := dag.NodeWithData(fsdata).EncodeProtobuf(false)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.NewBlock(enc), nil
+}
+
+// Recorder is a blockstore that stores the leaves built from the content at
+// a URL as references to it. Other blocks are stored as usual. The content
+// has to be split by a splitter returned by Splitter.
+type Recorder struct {
+	*URLStore
+	url string
+
+	// offset of every chunk, by the hash of its content
+	offsets map[[sha256.Size]byte]int64
+	offset  int64
+}
+
+// NewRecorder returns a Recorder for the content at 'url'
+func (s *URLStore) NewRecorder(url string) *Recorder {
+	return &Recorder{
+		URLStore: s,
+		url:      url,
+		offsets:  make(map[[sha256.Size]byte]int64),
+	}
+}
+
+// Splitter returns a splitter that notes where the chunks 'spl' returns
+// start
+func (r *Recorder) Splitter(spl chunk.Splitter) chunk.Splitter {
+	return &recordingSplitter{spl, r}
+}
+
+type recordingSplitter struct {
+	chunk.Splitter
+	r *Recorder
+}
+
+func (rs *recordingSplitter) NextBytes() ([]byte, error) {
+	b, err := rs.Splitter.NextBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(b)
+	if _, ok := rs.r.offsets[sum]; !ok {
+		rs.r.offsets[sum] = rs.r.offset
+	}
+	rs.r.offset += int64(len(b))
+	return b, nil
+}
+
+func (r *Recorder) Put(b blocks.Block) error {
+	ref := r.ref(b)
+	if ref == nil {
+		return r.URLStore.Put(b)
+	}
+	return r.PutRef(b.Key(), ref)
+}
+
+func (r *Recorder) PutMany(bs []blocks.Block) error {
+	for _, b := range bs {
+		if err := r.Put(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ref returns the reference for 'b', or nil if it is not a leaf of the
+// recorded content
+func (r *Recorder) ref(b blocks.Block) *Ref {
+	nd, err := dag.DecodeProtobuf(b.Data())
+	if err != nil || len(nd.Links) > 0 {
+		return nil
+	}
+
+	fsn, err := ft.FSNodeFromBytes(nd.Data())
+	if err != nil || len(fsn.Data) == 0 || fsn.NumChildren() > 0 {
+		return nil
+	}
+	if fsn.Type != ft.TFile && fsn.Type != ft.TRaw {
+		return nil
+	}
+
+	off, ok := r.offsets[sha256.Sum256(fsn.Data)]
+	if !ok {
+		return nil
+	}
+
+	return &Ref{
+		URL:    r.url,
+		Offset: off,
+		Length: int64(len(fsn.Data)),
+		Raw:    fsn.Type == ft.TRaw,
+	}
+}
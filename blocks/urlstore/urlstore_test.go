@@ -0,0 +1,167 @@
+package urlstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	importer "github.com/ipfs/go-ipfs/importer"
+	"github.com/ipfs/go-ipfs/importer/chunk"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dssync "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/sync"
+	u "gx/ipfs/QmZNVWh8LLjAavuQ2JXuFmuYH3C11xo988vSgp7UQrTRj1/go-ipfs-util"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+type build func(dag.DAGService, chunk.Splitter) (*dag.Node, error)
+
+func serve(content *[]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(*content))
+	}))
+}
+
+func testRecord(t *testing.T, b build) {
+	data := make([]byte, 100000)
+	u.NewTimeSeededRand().Read(data)
+
+	srv := serve(&data)
+	defer srv.Close()
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	inner := bstore.NewBlockstore(d)
+	us := NewURLStore(inner, d)
+
+	rec := us.NewRecorder(srv.URL)
+	spl := rec.Splitter(chunk.NewSizeSplitter(bytes.NewReader(data), 4096))
+	nd, err := b(dag.NewDAGService(bserv.New(rec, offline.Exchange(rec))), spl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leaves must only be stored as references
+	refs := 0
+	for _, l := range nd.Links {
+		k := key.Key(l.Hash)
+		if has, _ := us.Has(k); !has {
+			t.Fatal("urlstore lost a block")
+		}
+		if _, err := us.GetRef(k); err == nil {
+			refs++
+			if has, _ := inner.Has(k); has {
+				t.Fatal("referenced block was stored")
+			}
+		}
+	}
+	if refs == 0 {
+		t.Fatal("no block was stored by reference")
+	}
+
+	dserv := dag.NewDAGService(bserv.New(us, offline.Exchange(us)))
+	r, err := uio.NewDagReader(context.Background(), nd, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("content read through references differs")
+	}
+
+	// once the content changes, the references can't be used anymore
+	data = append([]byte{1}, data...)
+	for _, l := range nd.Links {
+		if _, err := us.GetRef(key.Key(l.Hash)); err != nil {
+			continue
+		}
+		if _, err := us.Get(key.Key(l.Hash)); err == nil {
+			t.Fatal("expected changed content to be detected")
+		}
+	}
+}
+
+func TestRecordBalanced(t *testing.T) {
+	testRecord(t, importer.BuildDagFromReader)
+}
+
+func TestRecordTrickle(t *testing.T) {
+	testRecord(t, importer.BuildTrickleDagFromReader)
+}
+
+func TestDeleteRef(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	us := NewURLStore(bstore.NewBlockstore(d), d)
+
+	b, err := leafBlock([]byte("some data"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := us.PutRef(b.Key(), &Ref{URL: "http://localhost/", Length: 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := us.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k := range ch {
+		keys = append(keys, k.B58String())
+	}
+	if len(keys) != 1 || keys[0] != b.Key().B58String() {
+		t.Fatalf("expected only the referenced key, got %v", keys)
+	}
+
+	if err := us.DeleteBlock(b.Key()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := us.Has(b.Key()); has {
+		t.Fatal("reference was not deleted")
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer srv.Close()
+	defer close(hang)
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	us := NewURLStore(bstore.NewBlockstore(d), d)
+	us.client.Timeout = time.Millisecond * 100
+
+	b, err := leafBlock([]byte("some data"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := us.PutRef(b.Key(), &Ref{URL: srv.URL, Length: 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := us.Get(b.Key())
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("expected the fetch to fail")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("fetch of an unresponsive URL did not time out")
+	}
+}
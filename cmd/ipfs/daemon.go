@@ -352,6 +352,9 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return
 	}
 
+	// remove the pins that expired
+	sweepErrc := runPinSweep(req, node)
+
 	// initialize metrics collector
 	prometheus.MustRegisterOrGet(&corehttp.IpfsNodeCollector{Node: node})
 	prometheus.EnableCollectChecks(true)
@@ -359,7 +362,7 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 	fmt.Printf("Daemon is ready\n")
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesnt follow this pattern for graceful shutdown
-	for err := range merge(apiErrc, gwErrc, gcErrc) {
+	for err := range merge(apiErrc, gwErrc, gcErrc, sweepErrc) {
 		if err != nil {
 			log.Error(err)
 			res.SetError(err, cmds.ErrNormal)
@@ -497,6 +500,7 @@ func serveHTTPGateway(req cmds.Request) (error, <-chan error) {
 		corehttp.VersionOption(),
 		corehttp.IPNSHostnameOption(),
 		corehttp.GatewayOption("/ipfs", "/ipns"),
+		corehttp.PinServiceOption("/pinservice/v0"),
 	}
 
 	if len(cfg.Gateway.RootRedirect) > 0 {
@@ -570,6 +574,15 @@ func maybeRunGC(req cmds.Request, node *core.IpfsNode) (error, <-chan error) {
 	return nil, errc
 }
 
+func runPinSweep(req cmds.Request, node *core.IpfsNode) <-chan error {
+	errc := make(chan error)
+	go func() {
+		errc <- corerepo.PeriodicPinSweep(req.Context(), node)
+		close(errc)
+	}()
+	return errc
+}
+
 // merge does fan-in of multiple read-only error channels
 // taken from http://blog.golang.org/pipelines
 func merge(cs ...<-chan error) <-chan error {
@@ -6,9 +6,11 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 
 	homedir "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/mitchellh/go-homedir"
 	commands "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
 	core "github.com/ipfs/go-ipfs/core"
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
@@ -22,6 +24,24 @@ import (
 var http = flag.Bool("http", false, "expose IPFS HTTP API")
 var repoPath = flag.String("repo", os.Getenv("IPFS_PATH"), "IPFS_PATH to use")
 var watchPath = flag.String("path", ".", "the path to watch")
+var ignoreRulesPath = flag.String("ignore-rules-path", "", "file with patterns of files not to add")
+var excludes stringList
+
+func init() {
+	flag.Var(&excludes, "exclude", "pattern of files not to add, may be given several times")
+}
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
 
 func main() {
 	flag.Parse()
@@ -61,7 +81,17 @@ func run(ipfsPath, watchPath string) error {
 	}
 	defer watcher.Close()
 
-	if err := addTree(watcher, watchPath); err != nil {
+	filter, err := files.NewFilter(*ignoreRulesPath, excludes)
+	if err != nil {
+		return err
+	}
+	filter, err = filter.WithIgnoreFile(watchPath, "")
+	if err != nil {
+		return err
+	}
+	ign := &ignorer{root: watchPath, filters: map[string]*files.Filter{"": filter}}
+
+	if err := addTree(watcher, watchPath, ign); err != nil {
 		return err
 	}
 
@@ -114,6 +144,9 @@ func run(ipfsPath, watchPath string) error {
 			if err != nil {
 				continue
 			}
+			if ign.Ignored(e.Name, isDir) {
+				continue
+			}
 			switch e.Op {
 			case fsnotify.Remove:
 				if isDir {
@@ -127,7 +160,7 @@ func run(ipfsPath, watchPath string) error {
 				switch e.Op {
 				case fsnotify.Create:
 					if isDir {
-						addTree(watcher, e.Name)
+						addTree(watcher, e.Name, ign)
 					}
 				}
 				proc.Go(func(p process.Process) {
@@ -150,7 +183,7 @@ func run(ipfsPath, watchPath string) error {
 	return nil
 }
 
-func addTree(w *fsnotify.Watcher, root string) error {
+func addTree(w *fsnotify.Watcher, root string, ign *ignorer) error {
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		isDir, err := IsDirectory(path)
 		if err != nil {
@@ -158,7 +191,7 @@ func addTree(w *fsnotify.Watcher, root string) error {
 			return nil
 		}
 		switch {
-		case isDir && IsHidden(path):
+		case isDir && (IsHidden(path) || ign.Ignored(path, true)):
 			log.Println(path)
 			return filepath.SkipDir
 		case isDir:
@@ -177,6 +210,55 @@ func addTree(w *fsnotify.Watcher, root string) error {
 	return nil
 }
 
+// ignorer tracks the ignore rules of every watched directory
+type ignorer struct {
+	root    string
+	filters map[string]*files.Filter
+}
+
+// Ignored returns whether 'path' is left out by the ignore rules
+func (ig *ignorer) Ignored(path string, isDir bool) bool {
+	rel, err := filepath.Rel(ig.root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	filter, err := ig.filter(filepath.ToSlash(filepath.Dir(rel)))
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return filter.Excluded(rel, isDir)
+}
+
+// filter returns the filter for the directory at 'rel', loading the rules
+// of it and its parents as needed
+func (ig *ignorer) filter(rel string) (*files.Filter, error) {
+	if rel == "." {
+		rel = ""
+	}
+	if f, ok := ig.filters[rel]; ok {
+		return f, nil
+	}
+
+	parent := ""
+	if rel != "" {
+		parent = filepath.ToSlash(filepath.Dir(rel))
+	}
+	pf, err := ig.filter(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := pf.WithIgnoreFile(filepath.Join(ig.root, filepath.FromSlash(rel)), rel)
+	if err != nil {
+		return nil, err
+	}
+	ig.filters[rel] = f
+	return f, nil
+}
+
 func IsDirectory(path string) (bool, error) {
 	fileInfo, err := os.Stat(path)
 	return fileInfo.IsDir(), err
@@ -334,6 +334,11 @@ func optionText(cmd ...*cmds.Command) []string {
 
 	// add option types to output
 	for i, opt := range options {
+		if opt.Type() == cmds.Strings {
+			// may be given several times
+			lines[i] += " string..."
+			continue
+		}
 		lines[i] += " " + fmt.Sprintf("%v", opt.Type())
 	}
 	lines = align(lines)
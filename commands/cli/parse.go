@@ -56,6 +56,38 @@ func Parse(input []string, stdin *os.File, root *cmds.Command) (cmds.Request, *c
 		}
 	}
 
+	// commands that take ignore rules leave out the files they exclude
+	var filter *files.Filter
+	excludeOpt := req.Option("exclude")
+	if excludeOpt != nil {
+		excludes, _, err := excludeOpt.Strings()
+		if err != nil {
+			return req, nil, nil, u.ErrCast()
+		}
+
+		var rulesPath string
+		if rulesOpt := req.Option("ignore-rules-path"); rulesOpt != nil {
+			rulesPath, _, err = rulesOpt.String()
+			if err != nil {
+				return req, nil, nil, u.ErrCast()
+			}
+		}
+
+		filter, err = files.NewFilter(rulesPath, excludes)
+		if err != nil {
+			return req, cmd, path, err
+		}
+	}
+
+	// with '--from-url', file arguments are URLs to fetch instead of paths
+	fromURL := false
+	if urlOpt := req.Option("from-url"); urlOpt != nil {
+		fromURL, _, err = urlOpt.Bool()
+		if err != nil {
+			return req, nil, nil, u.ErrCast()
+		}
+	}
+
 	// This is an ugly hack to maintain our current CLI interface while fixing
 	// other stdin usage bugs. Let this serve as a warning, be careful about the
 	// choices you make, they will haunt you forever.
@@ -66,7 +98,7 @@ func Parse(input []string, stdin *os.File, root *cmds.Command) (cmds.Request, *c
 		}
 	}
 
-	stringArgs, fileArgs, err := parseArgs(stringVals, stdin, cmd.Arguments, recursive, hidden, root)
+	stringArgs, fileArgs, err := parseArgs(stringVals, stdin, cmd.Arguments, recursive, hidden, fromURL, filter, root)
 	if err != nil {
 		return req, cmd, path, err
 	}
@@ -102,11 +134,11 @@ func parseOpts(args []string, root *cmds.Command) (
 	// parseFlag checks that a flag is valid and saves it into opts
 	// Returns true if the optional second argument is used
 	parseFlag := func(name string, arg *string, mustUse bool) (bool, error) {
-		if _, ok := opts[name]; ok {
+		optDef, found := optDefs[name]
+		if _, ok := opts[name]; ok && !(found && optDef.Type() == cmds.Strings) {
 			return false, fmt.Errorf("Duplicate values for option '%s'", name)
 		}
 
-		optDef, found := optDefs[name]
 		if !found {
 			err = fmt.Errorf("Unrecognized option '%s'", name)
 			return false, err
@@ -137,6 +169,11 @@ func parseOpts(args []string, root *cmds.Command) (
 			if arg == nil {
 				return true, fmt.Errorf("Missing argument for option '%s'", name)
 			}
+			if optDef.Type() == cmds.Strings {
+				prev, _ := opts[name].([]string)
+				opts[name] = append(prev, *arg)
+				return true, nil
+			}
 			opts[name] = *arg
 			return true, nil
 		}
@@ -252,7 +289,7 @@ func parseOpts(args []string, root *cmds.Command) (
 
 const msgStdinInfo = "ipfs: Reading from %s; send Ctrl-d to stop.\n"
 
-func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursive, hidden bool, root *cmds.Command) ([]string, []files.File, error) {
+func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursive, hidden, fromURL bool, filter *files.Filter, root *cmds.Command) ([]string, []files.File, error) {
 	// ignore stdin on Windows
 	if runtime.GOOS == "windows" {
 		stdin = nil
@@ -322,8 +359,10 @@ func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursi
 						fpath = stdin.Name()
 						file = files.NewReaderFile("", fpath, stdin, nil)
 					}
+				} else if fromURL {
+					file = files.NewWebFile(fpath)
 				} else {
-					file, err = appendFile(fpath, argDef, recursive, hidden)
+					file, err = appendFile(fpath, argDef, recursive, hidden, filter)
 				}
 				if err != nil {
 					return nil, nil, err
@@ -388,7 +427,7 @@ func getArgDef(i int, argDefs []cmds.Argument) *cmds.Argument {
 const notRecursiveFmtStr = "'%s' is a directory, use the '-%s' flag to specify directories"
 const dirNotSupportedFmtStr = "Invalid path '%s', argument '%s' does not support directories"
 
-func appendFile(fpath string, argDef *cmds.Argument, recursive, hidden bool) (files.File, error) {
+func appendFile(fpath string, argDef *cmds.Argument, recursive, hidden bool, filter *files.Filter) (files.File, error) {
 	if fpath == "." {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -416,6 +455,9 @@ func appendFile(fpath string, argDef *cmds.Argument, recursive, hidden bool) (fi
 		}
 	}
 
+	if filter != nil {
+		return files.NewFilteredSerialFile(path.Base(fpath), fpath, hidden, filter, stat)
+	}
 	return files.NewSerialFile(path.Base(fpath), fpath, hidden, stat)
 }
 
@@ -4,6 +4,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -137,6 +138,28 @@ func TestOptionParsing(t *testing.T) {
 	testFail("foo test")
 }
 
+func TestStringsOptionParsing(t *testing.T) {
+	cmd := &commands.Command{
+		Options: []commands.Option{
+			commands.StringsOption("exclude", "e", "some patterns"),
+			commands.StringOption("string", "s", "a string"),
+		},
+	}
+
+	_, opts, _, _, err := parseOpts(strings.Split("-e a --exclude=b -e c", " "), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opts["exclude"], []string{"a", "b", "c"}) {
+		t.Fatalf("repeated option parsed as %v", opts["exclude"])
+	}
+
+	_, _, _, _, err = parseOpts(strings.Split("-s a -s b", " "), cmd)
+	if err == nil {
+		t.Fatal("repeating a string option should fail")
+	}
+}
+
 func TestArgumentParsing(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("stdin handling doesnt yet work on windows")
@@ -55,3 +55,32 @@ type SizeFile interface {
 
 	Size() (int64, error)
 }
+
+// XattrFile is a File that carries extended attributes
+type XattrFile interface {
+	File
+
+	Xattrs() (map[string][]byte, error)
+}
+
+// Hole is a range of a file that was never written to and reads as zeros
+type Hole struct {
+	Offset int64
+	Length int64
+}
+
+// SparseFile is a File that knows which of its ranges are holes
+type SparseFile interface {
+	File
+
+	Holes() ([]Hole, error)
+}
+
+// SourceFile is a File whose content is fetched from a URL
+type SourceFile interface {
+	File
+
+	// Source returns the URL the content comes from, and the entity tag
+	// the server gave it, if any
+	Source() (url, etag string, err error)
+}
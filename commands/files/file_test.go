@@ -4,6 +4,8 @@ import (
 	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -201,3 +203,46 @@ anotherfile
 		t.Fatal("expected link to point to anotherfile")
 	}
 }
+
+func TestWebFiles(t *testing.T) {
+	message := "beep boop"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dir/file.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(message))
+	}))
+	defer srv.Close()
+
+	wf := NewWebFile(srv.URL + "/dir/file.txt")
+	if wf.IsDirectory() {
+		t.Fatal("WebFile should never be a directory")
+	}
+	if wf.FileName() != "file.txt" {
+		t.Fatalf("unexpected file name %q", wf.FileName())
+	}
+
+	size, err := wf.Size()
+	if err != nil || size != int64(len(message)) {
+		t.Fatalf("expected size %d, got %d (%v)", len(message), size, err)
+	}
+	url, etag, err := wf.Source()
+	if err != nil || url != srv.URL+"/dir/file.txt" || etag != `"v1"` {
+		t.Fatalf("unexpected source %q %q (%v)", url, etag, err)
+	}
+
+	data, err := ioutil.ReadAll(wf)
+	if err != nil || string(data) != message {
+		t.Fatalf("expected %q, got %q (%v)", message, data, err)
+	}
+	if err := wf.Close(); err != nil {
+		t.Fatal("Should be able to close")
+	}
+
+	missing := NewWebFile(srv.URL + "/missing")
+	if _, err := missing.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected an error for a missing page")
+	}
+}
@@ -0,0 +1,168 @@
+package files
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the name of the files holding ignore rules for the
+// directory they are in and everything below it
+const IgnoreFileName = ".ipfsignore"
+
+// Filter decides which files are left out of a recursive add. Its rules use
+// the syntax of .gitignore files: '#' starts a comment, '!' re-includes
+// what an earlier rule excluded, a trailing '/' only matches directories,
+// rules containing a '/' are relative to the directory the rules came from
+// and '**' matches any number of directories. The last matching rule wins.
+type Filter struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	// directory the rule applies to, relative to the root of the walk
+	base    string
+	pattern []string
+	negate  bool
+	dirOnly bool
+}
+
+// NewFilter returns a filter with the rules read from the file at
+// 'rulesPath', if not empty, followed by the given exclude patterns. All of
+// them are relative to the root of the walk.
+func NewFilter(rulesPath string, excludes []string) (*Filter, error) {
+	f := new(Filter)
+	if rulesPath != "" {
+		fi, err := os.Open(rulesPath)
+		if err != nil {
+			return nil, err
+		}
+		defer fi.Close()
+
+		if err := f.readRules(fi, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ex := range excludes {
+		f.addRule(ex, "")
+	}
+	return f, nil
+}
+
+// WithIgnoreFile returns a filter that also holds the rules from the
+// IgnoreFileName file in the directory 'dir', if there is one. 'rel' is the
+// path of that directory relative to the root of the walk.
+func (f *Filter) WithIgnoreFile(dir, rel string) (*Filter, error) {
+	fi, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	nf := &Filter{rules: append([]ignoreRule(nil), f.rules...)}
+	if err := nf.readRules(fi, rel); err != nil {
+		return nil, err
+	}
+	return nf, nil
+}
+
+func (f *Filter) readRules(r io.Reader, base string) error {
+	scan := bufio.NewScanner(r)
+	for scan.Scan() {
+		f.addRule(scan.Text(), base)
+	}
+	return scan.Err()
+}
+
+func (f *Filter) addRule(line, base string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// escaped leading '#' or '!'
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// patterns without a slash match at any depth
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return
+	}
+
+	rule.pattern = strings.Split(line, "/")
+	f.rules = append(f.rules, rule)
+}
+
+// Excluded returns whether the file at 'rel', relative to the root of the
+// walk, is left out
+func (f *Filter) Excluded(rel string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+
+	rel = path.Clean(filepath.ToSlash(rel))
+	excluded := false
+	for _, r := range f.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		sub := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = rel[len(r.base)+1:]
+		}
+
+		if matchSegments(r.pattern, strings.Split(sub, "/")) {
+			excluded = !r.negate
+		}
+	}
+	return excluded
+}
+
+// matchSegments matches a path against a pattern, one path element at a
+// time. A '**' element matches zero or more path elements.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
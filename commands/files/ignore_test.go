@@ -0,0 +1,137 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFilterRules(t *testing.T) {
+	f, err := NewFilter("", []string{
+		"*.o",
+		"!keep.o",
+		"build/",
+		"/top.txt",
+		"docs/**/*.tmp",
+		"# a comment",
+		`\#hash`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"main.o", false, true},
+		{"src/deep/main.o", false, true},
+		{"src/keep.o", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"top.txt", false, true},
+		{"src/top.txt", false, false},
+		{"docs/a.tmp", false, true},
+		{"docs/x/y/a.tmp", false, true},
+		{"other/a.tmp", false, false},
+		{"#hash", false, true},
+		{"# a comment", false, false},
+		{"main.c", false, false},
+	}
+
+	for _, c := range cases {
+		if f.Excluded(c.path, c.isDir) != c.excluded {
+			t.Errorf("expected Excluded(%q, %v) to be %v", c.path, c.isDir, c.excluded)
+		}
+	}
+}
+
+func walkNames(t *testing.T, f File) []string {
+	var names []string
+	for {
+		child, err := f.NextFile()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, child.FileName())
+		if child.IsDirectory() {
+			names = append(names, walkNames(t, child)...)
+		}
+	}
+}
+
+func TestFilteredSerialFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tree := map[string]string{
+		"a.txt":            "a",
+		"a.o":              "obj",
+		"sub/b.txt":        "b",
+		"sub/b.log":        "log",
+		"sub/.ipfsignore":  "*.log\n",
+		"sub/deep/c.log":   "log",
+		"sub/deep/c.txt":   "c",
+		"vendor/d.txt":     "d",
+		"other/e.log":      "e",
+		"other/vendor.txt": "v",
+	}
+	for name, content := range tree {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter, err := NewFilter("", []string{"*.o", "/vendor/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sf, err := NewFilteredSerialFile("root", dir, false, filter, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := sf.(SizeFile).Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 5 {
+		t.Fatalf("expected the filtered size to be 5, got %d", size)
+	}
+
+	expected := []string{
+		"root/a.txt",
+		"root/other",
+		"root/other/e.log",
+		"root/other/vendor.txt",
+		"root/sub",
+		"root/sub/b.txt",
+		"root/sub/deep",
+		"root/sub/deep/c.txt",
+	}
+	if names := walkNames(t, sf); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("walked %v, expected %v", names, expected)
+	}
+}
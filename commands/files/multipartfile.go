@@ -1,11 +1,15 @@
 package files
 
 import (
+	"encoding/base64"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
 	"net/url"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -16,6 +20,10 @@ const (
 	applicationSymlink   = "application/symlink"
 
 	contentTypeHeader = "Content-Type"
+	XattrHeader       = "Xattr"
+	HolesHeader       = "Holes"
+	SourceHeader      = "Source-Url"
+	ETagHeader        = "Source-Etag"
 )
 
 // MultipartFile implements File, and is created from a `multipart.Part`.
@@ -92,6 +100,83 @@ func (f *MultipartFile) FullPath() string {
 	return f.FileName()
 }
 
+// Xattrs returns the extended attributes sent along with this part. Each
+// attribute is sent in its own header as "<escaped name>=<base64 value>".
+func (f *MultipartFile) Xattrs() (map[string][]byte, error) {
+	if f.Part == nil {
+		return nil, nil
+	}
+
+	hdrs := f.Part.Header[XattrHeader]
+	if len(hdrs) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string][]byte)
+	for _, h := range hdrs {
+		parts := strings.SplitN(h, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed xattr header: %q", h)
+		}
+
+		name, err := url.QueryUnescape(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		out[name] = val
+	}
+	return out, nil
+}
+
+// Holes returns the holes sent along with this part. They are sent as a
+// single header of comma separated "<offset>:<length>" pairs.
+func (f *MultipartFile) Holes() ([]Hole, error) {
+	if f.Part == nil {
+		return nil, nil
+	}
+
+	hdr := f.Part.Header.Get(HolesHeader)
+	if hdr == "" {
+		return nil, nil
+	}
+
+	var out []Hole
+	for _, h := range strings.Split(hdr, ",") {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed holes header: %q", hdr)
+		}
+
+		off, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, Hole{Offset: off, Length: length})
+	}
+	return out, nil
+}
+
+// Source returns the URL and entity tag sent along with this part, if its
+// content was fetched from a URL
+func (f *MultipartFile) Source() (string, string, error) {
+	if f.Part == nil {
+		return "", "", nil
+	}
+	return f.Part.Header.Get(SourceHeader), f.Part.Header.Get(ETagHeader), nil
+}
+
 func (f *MultipartFile) Read(p []byte) (int, error) {
 	if f.IsDirectory() {
 		return 0, ErrNotReader
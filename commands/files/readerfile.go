@@ -53,3 +53,17 @@ func (f *ReaderFile) Size() (int64, error) {
 	}
 	return f.stat.Size(), nil
 }
+
+func (f *ReaderFile) Xattrs() (map[string][]byte, error) {
+	if f.stat == nil {
+		return nil, nil
+	}
+	return readXattrs(f.fullpath)
+}
+
+func (f *ReaderFile) Holes() ([]Hole, error) {
+	if f.stat == nil {
+		return nil, nil
+	}
+	return readHoles(f.fullpath)
+}
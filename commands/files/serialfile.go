@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"syscall"
@@ -20,9 +21,27 @@ type serialFile struct {
 	stat              os.FileInfo
 	current           *File
 	handleHiddenFiles bool
+
+	// rules for leaving files out, and the path of this directory relative
+	// to the root of the walk they apply to
+	filter *Filter
+	rel    string
 }
 
 func NewSerialFile(name, path string, hidden bool, stat os.FileInfo) (File, error) {
+	return newSerialFile(name, path, "", hidden, nil, stat)
+}
+
+// NewFilteredSerialFile is like NewSerialFile, but leaves out files excluded
+// by 'filter' or by the IgnoreFileName files found in the walked directories.
+func NewFilteredSerialFile(name, path string, hidden bool, filter *Filter, stat os.FileInfo) (File, error) {
+	if filter == nil {
+		filter = new(Filter)
+	}
+	return newSerialFile(name, path, "", hidden, filter, stat)
+}
+
+func newSerialFile(name, path, rel string, hidden bool, filter *Filter, stat os.FileInfo) (File, error) {
 	switch mode := stat.Mode(); {
 	case mode.IsRegular():
 		file, err := os.Open(path)
@@ -37,7 +56,13 @@ func NewSerialFile(name, path string, hidden bool, stat os.FileInfo) (File, erro
 		if err != nil {
 			return nil, err
 		}
-		return &serialFile{name, path, contents, stat, nil, hidden}, nil
+		if filter != nil {
+			filter, err = filter.WithIgnoreFile(path, rel)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &serialFile{name, path, contents, stat, nil, hidden, filter, rel}, nil
 	case mode&os.ModeSymlink != 0:
 		target, err := os.Readlink(path)
 		if err != nil {
@@ -70,7 +95,7 @@ func (f *serialFile) NextFile() (File, error) {
 	stat := f.files[0]
 	f.files = f.files[1:]
 
-	for !f.handleHiddenFiles && strings.HasPrefix(stat.Name(), ".") {
+	for f.skip(stat) {
 		if len(f.files) == 0 {
 			return nil, io.EOF
 		}
@@ -86,7 +111,8 @@ func (f *serialFile) NextFile() (File, error) {
 	// recursively call the constructor on the next file
 	// if it's a regular file, we will open it as a ReaderFile
 	// if it's a directory, files in it will be opened serially
-	sf, err := NewSerialFile(fileName, filePath, f.handleHiddenFiles, stat)
+	rel := path.Join(f.rel, stat.Name())
+	sf, err := newSerialFile(fileName, filePath, rel, f.handleHiddenFiles, f.filter, stat)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +122,14 @@ func (f *serialFile) NextFile() (File, error) {
 	return sf, nil
 }
 
+// skip returns whether the directory entry 'stat' is left out of the walk
+func (f *serialFile) skip(stat os.FileInfo) bool {
+	if !f.handleHiddenFiles && strings.HasPrefix(stat.Name(), ".") {
+		return true
+	}
+	return f.filter.Excluded(path.Join(f.rel, stat.Name()), stat.IsDir())
+}
+
 func (f *serialFile) FileName() string {
 	return f.name
 }
@@ -125,11 +159,26 @@ func (f *serialFile) Stat() os.FileInfo {
 	return f.stat
 }
 
+func (f *serialFile) Xattrs() (map[string][]byte, error) {
+	return readXattrs(f.path)
+}
+
+func (f *serialFile) Holes() ([]Hole, error) {
+	if f.stat.IsDir() {
+		return nil, nil
+	}
+	return readHoles(f.path)
+}
+
 func (f *serialFile) Size() (int64, error) {
 	if !f.stat.IsDir() {
 		return f.stat.Size(), nil
 	}
 
+	if f.filter != nil {
+		return f.filteredSize()
+	}
+
 	var du int64
 	err := filepath.Walk(f.FullPath(), func(p string, fi os.FileInfo, err error) error {
 		if err != nil {
@@ -144,3 +193,31 @@ func (f *serialFile) Size() (int64, error) {
 
 	return du, err
 }
+
+// filteredSize returns the size of the files left in the walk by the filter
+func (f *serialFile) filteredSize() (int64, error) {
+	var du int64
+	for _, stat := range f.files {
+		if f.skip(stat) {
+			continue
+		}
+
+		switch {
+		case stat.IsDir():
+			rel := path.Join(f.rel, stat.Name())
+			sub, err := newSerialFile(stat.Name(), filepath.Join(f.path, stat.Name()), rel, f.handleHiddenFiles, f.filter, stat)
+			if err != nil {
+				return 0, err
+			}
+
+			s, err := sub.(*serialFile).filteredSize()
+			if err != nil {
+				return 0, err
+			}
+			du += s
+		case stat.Mode()&(os.ModeSymlink|os.ModeNamedPipe) == 0:
+			du += stat.Size()
+		}
+	}
+	return du, nil
+}
@@ -0,0 +1,59 @@
+// +build linux
+
+package files
+
+import (
+	"os"
+	"syscall"
+)
+
+// lseek whence values that are not exposed by the syscall package
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// readHoles returns the holes of the file at 'path'. Filesystems that do not
+// support SEEK_HOLE report no holes.
+func readHoles(path string) ([]Hole, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	st, err := fi.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := st.Size()
+	fd := int(fi.Fd())
+
+	var holes []Hole
+	var off int64
+	for off < size {
+		hole, err := syscall.Seek(fd, off, seekHole)
+		if err != nil {
+			if err == syscall.EINVAL {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if hole >= size {
+			break
+		}
+
+		data, err := syscall.Seek(fd, hole, seekData)
+		if err == syscall.ENXIO {
+			// the file ends in a hole
+			data = size
+		} else if err != nil {
+			return nil, err
+		}
+
+		holes = append(holes, Hole{Offset: hole, Length: data - hole})
+		off = data
+	}
+
+	return holes, nil
+}
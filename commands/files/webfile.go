@@ -0,0 +1,102 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// WebFile is a File whose content is the body of an HTTP(S) GET request.
+// The request is only made once the file is used.
+type WebFile struct {
+	url  string
+	body io.ReadCloser
+	etag string
+	size int64
+}
+
+func NewWebFile(url string) *WebFile {
+	return &WebFile{url: url, size: -1}
+}
+
+func (f *WebFile) start() error {
+	if f.body != nil {
+		return nil
+	}
+
+	resp, err := http.Get(f.url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return fmt.Errorf("failed to fetch %s: %s", f.url, resp.Status)
+	}
+
+	f.body = resp.Body
+	f.etag = resp.Header.Get("ETag")
+	f.size = resp.ContentLength
+	return nil
+}
+
+func (f *WebFile) IsDirectory() bool {
+	return false
+}
+
+func (f *WebFile) NextFile() (File, error) {
+	return nil, ErrNotDirectory
+}
+
+// FileName returns the last element of the path of the URL, or its host if
+// the path is empty
+func (f *WebFile) FileName() string {
+	u, err := url.Parse(f.url)
+	if err != nil {
+		return path.Base(f.url)
+	}
+
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return u.Host
+	}
+	return name
+}
+
+func (f *WebFile) FullPath() string {
+	return f.url
+}
+
+func (f *WebFile) Read(p []byte) (int, error) {
+	if err := f.start(); err != nil {
+		return 0, err
+	}
+	return f.body.Read(p)
+}
+
+func (f *WebFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	return f.body.Close()
+}
+
+// Size returns the length of the body, if the server sent it
+func (f *WebFile) Size() (int64, error) {
+	if err := f.start(); err != nil {
+		return 0, err
+	}
+	if f.size < 0 {
+		return 0, errors.New("File size unknown")
+	}
+	return f.size, nil
+}
+
+func (f *WebFile) Source() (string, string, error) {
+	if err := f.start(); err != nil {
+		return "", "", err
+	}
+	return f.url, f.etag, nil
+}
@@ -0,0 +1,57 @@
+// +build linux darwin
+
+package files
+
+import (
+	"bytes"
+	"syscall"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/syscallx"
+)
+
+// readXattrs returns the extended attributes of the file at 'path'.
+// Filesystems without xattr support yield an empty set.
+func readXattrs(path string) (map[string][]byte, error) {
+	sz, err := syscallx.Listxattr(path, nil)
+	if err != nil {
+		return nil, ignoreXattrErr(err)
+	}
+	if sz == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, sz)
+	sz, err = syscallx.Listxattr(path, buf)
+	if err != nil {
+		return nil, ignoreXattrErr(err)
+	}
+
+	out := make(map[string][]byte)
+	for _, name := range bytes.Split(buf[:sz], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		vsz, err := syscallx.Getxattr(path, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		val := make([]byte, vsz)
+		vsz, err = syscallx.Getxattr(path, string(name), val)
+		if err != nil {
+			return nil, err
+		}
+
+		out[string(name)] = val[:vsz]
+	}
+
+	return out, nil
+}
+
+func ignoreXattrErr(err error) error {
+	if err == syscall.ENOTSUP || err == syscall.EOPNOTSUPP {
+		return nil
+	}
+	return err
+}
@@ -76,6 +76,14 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 	if req.Files() != nil {
 		fileReader = NewMultiFileReader(req.Files(), true)
 		reader = fileReader
+
+		// only read extended attributes from disk if they were asked for
+		if xattrsOpt := req.Option("xattrs"); xattrsOpt != nil {
+			fileReader.Xattrs, _, _ = xattrsOpt.Bool()
+		}
+		if sparseOpt := req.Option("sparse"); sparseOpt != nil {
+			fileReader.Holes, _, _ = sparseOpt.Bool()
+		}
 	}
 
 	path := strings.Join(req.Path(), "/")
@@ -124,6 +132,12 @@ func getQuery(req cmds.Request) (string, error) {
 		if OptionSkipMap[k] {
 			continue
 		}
+		if strs, ok := v.([]string); ok {
+			for _, s := range strs {
+				query.Add(k, s)
+			}
+			continue
+		}
 		str := fmt.Sprintf("%v", v)
 		query.Set(k, str)
 	}
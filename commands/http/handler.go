@@ -45,6 +45,7 @@ const (
 	streamHeader             = "X-Stream-Output"
 	channelHeader            = "X-Chunked-Output"
 	extraContentLengthHeader = "X-Content-Length"
+	extraContentTypeHeader   = "X-Content-Type"
 	uaHeader                 = "User-Agent"
 	contentTypeHeader        = "Content-Type"
 	contentDispHeader        = "Content-Disposition"
@@ -55,7 +56,7 @@ const (
 	originHeader             = "origin"
 )
 
-var AllowedExposedHeadersArr = []string{streamHeader, channelHeader, extraContentLengthHeader}
+var AllowedExposedHeadersArr = []string{streamHeader, channelHeader, extraContentLengthHeader, extraContentTypeHeader}
 var AllowedExposedHeaders = strings.Join(AllowedExposedHeadersArr, ", ")
 
 const (
@@ -196,6 +197,12 @@ func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, r, res, req)
 }
 
+// mimeTyper is implemented by outputs which know the mime type of the data
+// they stream, such as unixfs readers for files wrapped in metadata
+type mimeTyper interface {
+	MimeType() string
+}
+
 func guessMimeType(res cmds.Response) (string, error) {
 	// Try to guess mimeType from the encoding option
 	enc, found, err := res.Request().Option(cmds.EncShort).String()
@@ -253,6 +260,11 @@ func sendResponse(w http.ResponseWriter, r *http.Request, res cmds.Response, req
 		// html pages on priveleged api ports
 		mime = "text/plain"
 		h.Set(streamHeader, "1")
+
+		// still let clients know the real type of the data, if it is known
+		if mt, ok := res.Output().(mimeTyper); ok && mt.MimeType() != "" {
+			h.Set(extraContentTypeHeader, mt.MimeType())
+		}
 	}
 
 	// if output is a channel and user requested streaming channels,
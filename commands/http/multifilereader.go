@@ -2,11 +2,13 @@ package http
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/textproto"
 	"net/url"
+	"strings"
 	"sync"
 
 	files "github.com/ipfs/go-ipfs/commands/files"
@@ -27,6 +29,12 @@ type MultiFileReader struct {
 	// if true, the data will be type 'multipart/form-data'
 	// if false, the data will be type 'multipart/mixed'
 	form bool
+
+	// if true, extended attributes of files are sent as part headers
+	Xattrs bool
+
+	// if true, the holes of sparse files are sent as part headers
+	Holes bool
 }
 
 // NewMultiFileReader constructs a MultiFileReader. `file` can be any `commands.File`.
@@ -96,6 +104,47 @@ func (mfr *MultiFileReader) Read(buf []byte) (written int, err error) {
 
 			header.Set("Content-Type", contentType)
 
+			if xf, ok := file.(files.XattrFile); ok && mfr.Xattrs {
+				xattrs, err := xf.Xattrs()
+				if err != nil {
+					return 0, err
+				}
+
+				for name, val := range xattrs {
+					v := url.QueryEscape(name) + "=" + base64.StdEncoding.EncodeToString(val)
+					header.Add(files.XattrHeader, v)
+				}
+			}
+
+			if sf, ok := file.(files.SparseFile); ok && mfr.Holes {
+				holes, err := sf.Holes()
+				if err != nil {
+					return 0, err
+				}
+
+				if len(holes) > 0 {
+					pairs := make([]string, len(holes))
+					for i, h := range holes {
+						pairs[i] = fmt.Sprintf("%d:%d", h.Offset, h.Length)
+					}
+					header.Set(files.HolesHeader, strings.Join(pairs, ","))
+				}
+			}
+
+			if sf, ok := file.(files.SourceFile); ok {
+				src, etag, err := sf.Source()
+				if err != nil {
+					return 0, err
+				}
+
+				if src != "" {
+					header.Set(files.SourceHeader, src)
+				}
+				if etag != "" {
+					header.Set(files.ETagHeader, etag)
+				}
+			}
+
 			_, err := mfr.mpWriter.CreatePart(header)
 			if err != nil {
 				return 0, err
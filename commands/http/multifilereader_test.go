@@ -112,3 +112,61 @@ func TestOutput(t *testing.T) {
 		t.Fatal("Expected to get (nil, io.EOF)")
 	}
 }
+
+type xattrReaderFile struct {
+	*files.ReaderFile
+	xattrs map[string][]byte
+}
+
+func (f *xattrReaderFile) Xattrs() (map[string][]byte, error) {
+	return f.xattrs, nil
+}
+
+func TestXattrOutput(t *testing.T) {
+	xattrs := map[string][]byte{
+		"user.a":     []byte("foo"),
+		"user.b=c d": []byte{0, 1, 2},
+	}
+	fileset := []files.File{
+		&xattrReaderFile{
+			ReaderFile: files.NewReaderFile("file.txt", "file.txt", ioutil.NopCloser(strings.NewReader("text")), nil),
+			xattrs:     xattrs,
+		},
+	}
+
+	for _, send := range []bool{false, true} {
+		mfr := NewMultiFileReader(files.NewSliceFile("", "", fileset), true)
+		mfr.Xattrs = send
+		mpReader := multipart.NewReader(mfr, mfr.Boundary())
+
+		part, err := mpReader.NextPart()
+		if part == nil || err != nil {
+			t.Fatal("Expected non-nil part, nil error")
+		}
+		mpf, err := files.NewFileFromPart(part)
+		if mpf == nil || err != nil {
+			t.Fatal("Expected non-nil MultipartFile, nil error")
+		}
+
+		out, err := mpf.(files.XattrFile).Xattrs()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !send {
+			if len(out) != 0 {
+				t.Fatal("Expected no xattrs to be sent")
+			}
+			continue
+		}
+
+		if len(out) != len(xattrs) {
+			t.Fatalf("Expected %d xattrs, got %d", len(xattrs), len(out))
+		}
+		for k, v := range xattrs {
+			if string(out[k]) != string(v) {
+				t.Fatalf("Wrong value for xattr %q: %v", k, out[k])
+			}
+		}
+	}
+}
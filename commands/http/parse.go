@@ -143,6 +143,9 @@ func parseOptions(r *http.Request) (map[string]interface{}, []string) {
 	for k, v := range query {
 		if k == "arg" {
 			args = v
+		} else if len(v) > 1 {
+			// options given several times
+			opts[k] = v
 		} else {
 			opts[k] = v[0]
 		}
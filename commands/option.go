@@ -16,6 +16,7 @@ const (
 	Uint    = reflect.Uint
 	Float   = reflect.Float64
 	String  = reflect.String
+	Strings = reflect.Slice
 )
 
 // Option is used to specify a field that will be provided by a consumer
@@ -105,6 +106,11 @@ func StringOption(names ...string) Option {
 	return NewOption(String, names...)
 }
 
+// StringsOption is a string option that may be given several times
+func StringsOption(names ...string) Option {
+	return NewOption(Strings, names...)
+}
+
 type OptionValue struct {
 	value interface{}
 	found bool
@@ -177,6 +183,17 @@ func (ov OptionValue) String() (value string, found bool, err error) {
 	return val, ov.found, err
 }
 
+func (ov OptionValue) Strings() (value []string, found bool, err error) {
+	if !ov.found && ov.value == nil {
+		return nil, false, nil
+	}
+	val, ok := ov.value.([]string)
+	if !ok {
+		err = util.ErrCast()
+	}
+	return val, ov.found, err
+}
+
 // Flag names
 const (
 	EncShort   = "enc"
@@ -310,6 +310,9 @@ var converters = map[reflect.Kind]converter{
 	Float: func(v string) (interface{}, error) {
 		return strconv.ParseFloat(v, 64)
 	},
+	Strings: func(v string) (interface{}, error) {
+		return []string{v}, nil
+	},
 }
 
 func (r *request) Values() map[string]interface{} {
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	manet "gx/ipfs/QmPpRcbNUXauP3zWZ1NJMLWpe4QnmEHrd2ba2D3yqWznw7/go-multiaddr-net"
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+	swarm "gx/ipfs/QmVCe3SNMjkcPgnpFhZs719dheq6xE7gJwjzV7aWcUM4Ms/go-libp2p/p2p/net/swarm"
+	ma "gx/ipfs/QmYzDkkgAEmrcNzFCiYo6L1dTX4EAG1gZkbtdbd9trL4vd/go-multiaddr"
+)
+
+// banFilter adds the addresses of the peers bitswap bans to the swarm
+// filters while they are banned, so that they cannot connect back. They
+// show in 'ipfs swarm filters'. Filters that were there already are left
+// alone.
+type banFilter struct {
+	snet *swarm.Network
+
+	lk sync.Mutex
+	// masks are the filters added for each banned peer
+	masks map[peer.ID][]*net.IPNet
+}
+
+func newBanFilter(snet *swarm.Network) *banFilter {
+	return &banFilter{
+		snet:  snet,
+		masks: make(map[peer.ID][]*net.IPNet),
+	}
+}
+
+func (f *banFilter) Banned(p peer.ID, until time.Time) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	existing := make(map[string]bool)
+	for _, m := range f.snet.Filters.Filters() {
+		existing[m.String()] = true
+	}
+	for _, c := range f.snet.ConnsToPeer(p) {
+		mask, err := ipMask(c.RemoteMultiaddr())
+		if err != nil {
+			log.Debugf("cannot filter banned %s: %s", p, err)
+			continue
+		}
+		if existing[mask.String()] {
+			continue
+		}
+		existing[mask.String()] = true
+		f.snet.Filters.AddDialFilter(mask)
+		f.masks[p] = append(f.masks[p], mask)
+		log.Infof("filtering %s of banned %s until %s", mask, p, until)
+	}
+}
+
+func (f *banFilter) Unbanned(p peer.ID) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for _, mask := range f.masks[p] {
+		f.snet.Filters.Remove(mask)
+	}
+	delete(f.masks, p)
+}
+
+// ipMask returns the filter of the single IP address of 'a'
+func ipMask(a ma.Multiaddr) (*net.IPNet, error) {
+	na, err := manet.ToNetAddr(a)
+	if err != nil {
+		return nil, err
+	}
+
+	var ip net.IP
+	switch na := na.(type) {
+	case *net.TCPAddr:
+		ip = na.IP
+	case *net.UDPAddr:
+		ip = na.IP
+	default:
+		return nil, fmt.Errorf("no IP address in %s", a)
+	}
+
+	bits := net.IPv6len * 8
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, net.IPv4len*8
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	urlstore "github.com/ipfs/go-ipfs/blocks/urlstore"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -39,6 +40,12 @@ type BuildCfg struct {
 	Repo    repo.Repo
 }
 
+// versionUpgrader is a repo that may have to be upgraded once its pins were
+// moved to the pin index
+type versionUpgrader interface {
+	UpgradeVersion(movePins func() error) error
+}
+
 func (cfg *BuildCfg) fillDefaults() error {
 	if cfg.Repo != nil && cfg.NilRepo {
 		return errors.New("cannot set a repo and specify nilrepo at the same time")
@@ -146,11 +153,15 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		opts.HasBloomFilterSize = 0
 	}
 
-	n.Blockstore, err = bstore.CachedBlockstore(bs, ctx, opts)
+	cbs, err := bstore.CachedBlockstore(bs, ctx, opts)
 	if err != nil {
 		return err
 	}
 
+	// blocks added by reference are kept out of the cache, as they are
+	// not in the datastore
+	n.Blockstore = urlstore.NewURLStore(cbs, n.Repo.Datastore())
+
 	rcfg, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -179,6 +190,14 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		// this is kinda sketchy and could cause data loss
 		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG)
 	}
+
+	// repos from before the pin index are upgraded once their pins are in
+	// it, as older programs would not see them there
+	if r, ok := n.Repo.(versionUpgrader); ok {
+		if err := r.UpgradeVersion(n.Pinning.Flush); err != nil {
+			return err
+		}
+	}
 	n.Resolver = &path.Resolver{DAG: n.DAG}
 
 	err = n.loadFilesRoot()
@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"text/tabwriter"
 
 	"github.com/ipfs/go-ipfs/core/coreunix"
 	"gx/ipfs/QmeWjRodbcZFKe5tMN7poEx3izym6osrLSnTLf9UjJZBbs/pb"
@@ -10,6 +12,7 @@ import (
 	cmds "github.com/ipfs/go-ipfs/commands"
 	files "github.com/ipfs/go-ipfs/commands/files"
 	core "github.com/ipfs/go-ipfs/core"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	ft "github.com/ipfs/go-ipfs/unixfs"
@@ -20,15 +23,30 @@ import (
 var ErrDepthLimitExceeded = fmt.Errorf("depth limit exceeded")
 
 const (
-	quietOptionName    = "quiet"
-	silentOptionName   = "silent"
-	progressOptionName = "progress"
-	trickleOptionName  = "trickle"
-	wrapOptionName     = "wrap-with-directory"
-	hiddenOptionName   = "hidden"
-	onlyHashOptionName = "only-hash"
-	chunkerOptionName  = "chunker"
-	pinOptionName      = "pin"
+	quietOptionName     = "quiet"
+	silentOptionName    = "silent"
+	progressOptionName  = "progress"
+	trickleOptionName   = "trickle"
+	wrapOptionName      = "wrap-with-directory"
+	hiddenOptionName    = "hidden"
+	onlyHashOptionName  = "only-hash"
+	chunkerOptionName   = "chunker"
+	pinOptionName       = "pin"
+	xattrsOptionName    = "xattrs"
+	mimeOptionName      = "detect-mime"
+	sparseOptionName    = "sparse"
+	jobsOptionName      = "jobs"
+	excludeOptionName   = "exclude"
+	ignoreOptionName    = "ignore-rules-path"
+	dryRunOptionName    = "dry-run"
+	resumeOptionName    = "resume"
+	maxLinksOptionName  = "max-links"
+	depthOptionName     = "trickle-depth"
+	paramsOutOptionName = "params-out"
+	paramsInOptionName  = "params-in"
+	fromURLOptionName   = "from-url"
+	noCopyOptionName    = "nocopy"
+	reportOptionName    = "report"
 )
 
 var AddCmd = &cmds.Command{
@@ -58,6 +76,74 @@ its filename. For example:
 You can now refer to the added file in a gateway, like so:
 
   /ipfs/QmaG4FuMqEBnQNn3C8XJ5bpW8kLs7zq2ZXgHptJHbKDDVx/example.jpg
+
+The chunker option, '-s', picks how files are split into blocks:
+'size-<bytes>' splits into fixed size blocks, while 'rabin-<min>-<avg>-<max>'
+and the faster 'buzhash-<min>-<avg>-<max>' split on content, so that
+shifted data still produces the same blocks. 'buzhash' alone stands for
+'buzhash-87381-262144-393216'. 'tar-<bytes>', or 'tar' for 256KB blocks,
+splits tar and zip archives at the start of each file they hold, so that
+the same file gives the same blocks in any archive. The same chunker and
+sizes always give the same hashes.
+
+With '--detect-mime', the start of each file is inspected to guess its mime
+type, and the file is wrapped in a metadata object recording that type. The
+gateway serves such files with the stored Content-Type, and 'ipfs file stat'
+shows it.
+
+With '--sparse', holes in sparse files are stored by their size only, and
+read back as zeros. Holes are only found on filesystems that support
+SEEK_HOLE, elsewhere the file is added as usual.
+
+With '--jobs', that many files of a directory are chunked and hashed at
+once. The resulting hashes and output are the same as with a single job.
+Files sent to a running daemon arrive one after the other, so only those
+of up to 1MiB are imported at once there, as they are held in memory.
+
+Recursive adds leave out files matching the patterns in '.ipfsignore'
+files, which apply to the directory they are in and everything below it,
+in the file given with '--ignore-rules-path' and in each '--exclude'
+option. Patterns follow the .gitignore syntax:
+
+  > ipfs add -r --exclude '*.o' --exclude 'build/' project
+
+'--dry-run' lists the files that would be added, without adding them.
+
+With '--resume', the blocks stored so far are recorded in the repo while
+a file is added. If the add is interrupted, running it again with
+'--resume' skips the part of each file that was already stored, and
+produces the same hashes. The skipped part is read and checked against the stored blocks,
+so a file that changed in the meantime is only resumed up to the first
+change. Adds with the 'tar' chunkers cannot be resumed.
+
+The shape of the dag can be tuned with '--max-links', the number of links
+of each intermediate node, and for trickle dags with '--trickle-depth', the
+number of subtrees of each depth. Fewer links and subtrees suit streaming,
+more suit random access. '--params-out' writes these settings, along with
+the chunker, hash function and the '-w', '--sparse', '--detect-mime' and
+'--xattrs' options, to a file. Adding the same data again with
+'--params-in' set to that file gives the same hashes:
+
+  > ipfs add -t --max-links 64 --params-out params.json video.mp4
+  > ipfs add --params-in params.json video.mp4
+
+With '--from-url', the arguments are HTTP(S) URLs, whose content is
+chunked as it is downloaded. The URL and ETag are recorded in a metadata
+object wrapping each file. Adding '--nocopy' keeps only references to the
+URL instead of the file's blocks, which are then fetched from it whenever
+they are read. They can no longer be read once the content at the URL
+changes:
+
+  > ipfs add --from-url --nocopy https://example.com/dataset.csv
+
+'--report' prints, once all files are added, their total size and number
+of blocks, how many of the blocks were already stored, and how much new
+space the rest takes, for the whole add and for each file. The dedup
+ratio of a file is the share of its blocks' size that takes no new space.
+Combined with '--only-hash', nothing is written, so the report tells how
+much an add would store. Use '--encoding=json' for a JSON report:
+
+  > ipfs add -r -n --report dataset
 `,
 	},
 
@@ -75,8 +161,27 @@ You can now refer to the added file in a gateway, like so:
 		cmds.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add.").Default(false),
 		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm to use."),
 		cmds.BoolOption(pinOptionName, "Pin this object when adding.").Default(true),
+		cmds.BoolOption(xattrsOptionName, "Preserve extended attributes of added files.").Default(false),
+		cmds.BoolOption(mimeOptionName, "Detect the mime type of added files and store it with them.").Default(false),
+		cmds.BoolOption(sparseOptionName, "Store holes in sparse files without their zeros.").Default(false),
+		cmds.IntOption(jobsOptionName, "j", "Number of files to import at once on recursive add.").Default(1),
+		cmds.StringsOption(excludeOptionName, "Leave out files matching this pattern on recursive add. May be given several times."),
+		cmds.StringOption(ignoreOptionName, "Read patterns of files to leave out from this file."),
+		cmds.BoolOption(dryRunOptionName, "List the files that would be added without adding them.").Default(false),
+		cmds.BoolOption(resumeOptionName, "Record the progress of the add, and continue an interrupted one of the same files.").Default(false),
+		cmds.IntOption(maxLinksOptionName, "Maximum number of links of intermediate nodes."),
+		cmds.IntOption(depthOptionName, "Number of subtrees of each depth in trickle dags."),
+		cmds.StringOption(paramsOutOptionName, "Write the settings that decide the hashes to this file."),
+		cmds.StringOption(paramsInOptionName, "Read the settings that decide the hashes from this file."),
+		cmds.BoolOption(fromURLOptionName, "Add the content at the given URLs.").Default(false),
+		cmds.BoolOption(noCopyOptionName, "Store references to the URLs instead of the content. Requires --from-url.").Default(false),
+		cmds.BoolOption(reportOptionName, "Report how much of the added content was already stored.").Default(false),
 	},
 	PreRun: func(req cmds.Request) error {
+		if err := applyParams(req); err != nil {
+			return err
+		}
+
 		if quiet, _, _ := req.Option(quietOptionName).Bool(); quiet {
 			return nil
 		}
@@ -132,6 +237,46 @@ You can now refer to the added file in a gateway, like so:
 		silent, _, _ := req.Option(silentOptionName).Bool()
 		chunker, _, _ := req.Option(chunkerOptionName).String()
 		dopin, _, _ := req.Option(pinOptionName).Bool()
+		xattrs, _, _ := req.Option(xattrsOptionName).Bool()
+		detectMime, _, _ := req.Option(mimeOptionName).Bool()
+		sparse, _, _ := req.Option(sparseOptionName).Bool()
+		jobs, _, _ := req.Option(jobsOptionName).Int()
+		dryRun, _, _ := req.Option(dryRunOptionName).Bool()
+		resume, _, _ := req.Option(resumeOptionName).Bool()
+		maxLinks, _, _ := req.Option(maxLinksOptionName).Int()
+		depth, depthFound, _ := req.Option(depthOptionName).Int()
+		fromURL, _, _ := req.Option(fromURLOptionName).Bool()
+		nocopy, _, _ := req.Option(noCopyOptionName).Bool()
+		report, _, _ := req.Option(reportOptionName).Bool()
+
+		if jobs < 1 {
+			res.SetError(fmt.Errorf("jobs must be at least 1"), cmds.ErrClient)
+			return
+		}
+
+		if depthFound && !trickle {
+			res.SetError(fmt.Errorf("--%s requires --%s", depthOptionName, trickleOptionName), cmds.ErrClient)
+			return
+		}
+
+		if resume && !chunk.Resumable(chunker) {
+			res.SetError(fmt.Errorf("--%s cannot be used with the %s chunker", resumeOptionName, chunker), cmds.ErrClient)
+			return
+		}
+
+		if nocopy && !fromURL {
+			res.SetError(fmt.Errorf("--%s requires --%s", noCopyOptionName, fromURLOptionName), cmds.ErrClient)
+			return
+		}
+
+		params, err := coreunix.NewParams(chunker, trickle, maxLinks, depth)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		// blocks are looked up in the repo even when nothing is written to it
+		local := n.Blockstore
 
 		if hash {
 			nilnode, err := core.NewNode(n.Context(), &core.BuildCfg{
@@ -163,6 +308,18 @@ You can now refer to the added file in a gateway, like so:
 		fileAdder.Wrap = wrap
 		fileAdder.Pin = dopin
 		fileAdder.Silent = silent
+		fileAdder.Xattrs = xattrs
+		fileAdder.DetectMime = detectMime
+		fileAdder.Sparse = sparse
+		fileAdder.Jobs = jobs
+		fileAdder.Resume = resume
+		fileAdder.MaxLinks = params.MaxLinks
+		if trickle {
+			fileAdder.LayerRepeat = params.TrickleDepth
+		}
+		if report {
+			fileAdder.Report = coreunix.NewReport(local)
+		}
 
 		if hash {
 			md := dagtest.Mock()
@@ -173,6 +330,11 @@ You can now refer to the added file in a gateway, like so:
 			}
 
 			fileAdder.SetMfsRoot(mr)
+		} else {
+			if resume {
+				fileAdder.Checkpoints = n.Repo.Datastore()
+			}
+			fileAdder.NoCopy = nocopy
 		}
 
 		addAllAndPin := func(f files.File) error {
@@ -185,19 +347,33 @@ You can now refer to the added file in a gateway, like so:
 					// Finished the list of files.
 					break
 				} else if err != nil {
+					fileAdder.Abort()
 					return err
 				}
-				if err := fileAdder.AddFile(file); err != nil {
+				if dryRun {
+					err = fileAdder.DryRun(file)
+				} else {
+					err = fileAdder.AddFile(file)
+				}
+				if err != nil {
 					return err
 				}
 			}
 
+			if dryRun {
+				return nil
+			}
+
 			// copy intermediary nodes from editor to our actual dagservice
 			_, err := fileAdder.Finalize()
 			if err != nil {
 				return err
 			}
 
+			if fileAdder.Report != nil {
+				outChan <- &coreunix.AddedObject{Report: fileAdder.Report}
+			}
+
 			if hash {
 				return nil
 			}
@@ -225,6 +401,13 @@ You can now refer to the added file in a gateway, like so:
 		}
 		res.SetOutput(nil)
 
+		if dryRun, _, _ := req.Option(dryRunOptionName).Bool(); dryRun {
+			for out := range outChan {
+				fmt.Fprintln(res.Stdout(), out.(*coreunix.AddedObject).Name)
+			}
+			return
+		}
+
 		quiet, _, err := req.Option("quiet").Bool()
 		if err != nil {
 			res.SetError(u.ErrCast(), cmds.ErrNormal)
@@ -265,6 +448,7 @@ You can now refer to the added file in a gateway, like so:
 
 		lastFile := ""
 		var totalProgress, prevFiles, lastBytes int64
+		var report *coreunix.Report
 
 	LOOP:
 		for {
@@ -274,7 +458,9 @@ You can now refer to the added file in a gateway, like so:
 					break LOOP
 				}
 				output := out.(*coreunix.AddedObject)
-				if len(output.Hash) > 0 {
+				if output.Report != nil {
+					report = output.Report
+				} else if len(output.Hash) > 0 {
 					if progress {
 						// clear progress bar line before we print "added x" output
 						fmt.Fprintf(res.Stderr(), "\033[2K\r")
@@ -319,6 +505,109 @@ You can now refer to the added file in a gateway, like so:
 				return
 			}
 		}
+
+		if report != nil {
+			if progress {
+				fmt.Fprintf(res.Stderr(), "\033[2K\r")
+			}
+			if err := printReport(req, res.Stdout(), report); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		if err := saveParams(req); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+		}
 	},
 	Type: coreunix.AddedObject{},
 }
+
+// printReport writes 'r' to 'w' in the encoding of the request
+func printReport(req cmds.Request, w io.Writer, r *coreunix.Report) error {
+	enc, _, _ := req.Option(cmds.EncLong).String()
+	if cmds.EncodingType(enc) == cmds.JSON {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tBYTES\tBLOCKS\tEXISTING\tNEW BYTES\tDEDUP")
+	for _, f := range r.Files {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.1f%%\n", f.Name, f.Bytes, f.Blocks, f.Existing, f.NewBytes, f.Dedup*100)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "total: %d bytes in %d blocks, %d already stored, %d new bytes\n",
+		r.Bytes, r.Blocks, r.Existing, r.NewBytes)
+	return err
+}
+
+// paramsOptions are the options recorded in Params, which can't be given
+// along with the params-in option
+var paramsOptions = []string{
+	chunkerOptionName, trickleOptionName, maxLinksOptionName, depthOptionName,
+	wrapOptionName, sparseOptionName, mimeOptionName, xattrsOptionName,
+}
+
+// applyParams sets the options given by the file of the params-in option.
+// It runs on the client, so that the file is read from there.
+func applyParams(req cmds.Request) error {
+	path, found, err := req.Option(paramsInOptionName).String()
+	if err != nil || !found {
+		return err
+	}
+
+	p, err := coreunix.LoadParams(path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range paramsOptions {
+		if req.Option(name).Found() {
+			return fmt.Errorf("--%s can not be combined with --%s", paramsInOptionName, name)
+		}
+	}
+
+	req.SetOption(chunkerOptionName, p.Chunker)
+	req.SetOption(trickleOptionName, p.Layout == coreunix.LayoutTrickle)
+	req.SetOption(maxLinksOptionName, p.MaxLinks)
+	if p.TrickleDepth > 0 {
+		req.SetOption(depthOptionName, p.TrickleDepth)
+	}
+	req.SetOption(wrapOptionName, p.Wrap)
+	req.SetOption(sparseOptionName, p.Sparse)
+	req.SetOption(mimeOptionName, p.DetectMime)
+	req.SetOption(xattrsOptionName, p.Xattrs)
+	return nil
+}
+
+// saveParams writes the settings of this add to the file of the params-out
+// option, if given
+func saveParams(req cmds.Request) error {
+	path, found, err := req.Option(paramsOutOptionName).String()
+	if err != nil || !found {
+		return err
+	}
+
+	chunker, _, _ := req.Option(chunkerOptionName).String()
+	trickle, _, _ := req.Option(trickleOptionName).Bool()
+	maxLinks, _, _ := req.Option(maxLinksOptionName).Int()
+	depth, _, _ := req.Option(depthOptionName).Int()
+
+	p, err := coreunix.NewParams(chunker, trickle, maxLinks, depth)
+	if err != nil {
+		return err
+	}
+	p.Wrap, _, _ = req.Option(wrapOptionName).Bool()
+	p.Sparse, _, _ = req.Option(sparseOptionName).Bool()
+	p.DetectMime, _, _ = req.Option(mimeOptionName).Bool()
+	p.Xattrs, _, _ = req.Option(xattrsOptionName).Bool()
+	return p.Save(path)
+}
@@ -2,14 +2,19 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"time"
 
 	"gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
+	reputation "github.com/ipfs/go-ipfs/exchange/bitswap/reputation"
 	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
 	u "gx/ipfs/QmZNVWh8LLjAavuQ2JXuFmuYH3C11xo988vSgp7UQrTRj1/go-ipfs-util"
 )
@@ -20,9 +25,12 @@ var BitswapCmd = &cmds.Command{
 		ShortDescription: ``,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"wantlist": showWantlistCmd,
-		"stat":     bitswapStatCmd,
-		"unwant":   unwantCmd,
+		"wantlist":   showWantlistCmd,
+		"stat":       bitswapStatCmd,
+		"unwant":     unwantCmd,
+		"limit":      bitswapLimitCmd,
+		"ledger":     bitswapLedgerCmd,
+		"reputation": bitswapReputationCmd,
 	},
 }
 
@@ -158,7 +166,12 @@ var bitswapStatCmd = &cmds.Command{
 			fmt.Fprintf(buf, "\tprovides buffer: %d / %d\n", out.ProvideBufLen, bitswap.HasBlockBufferSize)
 			fmt.Fprintf(buf, "\tblocks received: %d\n", out.BlocksReceived)
 			fmt.Fprintf(buf, "\tdup blocks received: %d\n", out.DupBlksReceived)
+			fmt.Fprintf(buf, "\tdata received: %s\n", humanize.Bytes(out.DataReceived))
 			fmt.Fprintf(buf, "\tdup data received: %s\n", humanize.Bytes(out.DupDataReceived))
+			fmt.Fprintf(buf, "\thaves received: %d\n", out.HavesReceived)
+			fmt.Fprintf(buf, "\tdont haves received: %d\n", out.DontHavesReceived)
+			fmt.Fprintf(buf, "\trate in: %s/s\n", humanize.Bytes(out.RateIn))
+			fmt.Fprintf(buf, "\trate out: %s/s\n", humanize.Bytes(out.RateOut))
 			fmt.Fprintf(buf, "\twantlist [%d keys]\n", len(out.Wantlist))
 			for _, k := range out.Wantlist {
 				fmt.Fprintf(buf, "\t\t%s\n", k.B58String())
@@ -171,3 +184,361 @@ var bitswapStatCmd = &cmds.Command{
 		},
 	},
 }
+
+var bitswapLimitCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show or change the bandwidth limits of bitswap.",
+		ShortDescription: `
+Limits the bandwidth bitswap uses, in total and for each peer. Rates are
+per second, like '1MB'. '0' or 'unlimited' removes a limit.
+`,
+		LongDescription: `
+Limits the bandwidth bitswap uses, in total and for each peer. Rates are
+per second, like '1MB'. '0' or 'unlimited' removes a limit.
+
+Blocks sent over the outbound limits wait their turn. As peers only send
+the blocks we want, the inbound limits hold back our wants instead.
+
+The limits changed last until the daemon stops. To keep them, set
+Bitswap.MaxOutRate, Bitswap.MaxInRate, Bitswap.MaxPeerOutRate and
+Bitswap.MaxPeerInRate in the config.
+
+Without options, shows the current limits.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("out", "Limit of the blocks sent."),
+		cmds.StringOption("in", "Limit of the blocks received."),
+		cmds.StringOption("peer-out", "Limit of the blocks sent to each peer."),
+		cmds.StringOption("peer-in", "Limit of the blocks received from each peer."),
+	},
+	Type: bitswap.Limits{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		if !ok {
+			res.SetError(u.ErrCast(), cmds.ErrNormal)
+			return
+		}
+
+		limits := bs.Limits()
+		changed := false
+		for _, o := range []struct {
+			name string
+			rate *uint64
+		}{
+			{"out", &limits.Out},
+			{"in", &limits.In},
+			{"peer-out", &limits.PeerOut},
+			{"peer-in", &limits.PeerIn},
+		} {
+			s, found, err := req.Option(o.name).String()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if !found {
+				continue
+			}
+			*o.rate, err = bitswap.ParseRate(s)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			changed = true
+		}
+		if changed {
+			bs.SetLimits(limits)
+		}
+
+		res.SetOutput(&limits)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*bitswap.Limits)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "out: %s\n", rateString(out.Out))
+			fmt.Fprintf(buf, "in: %s\n", rateString(out.In))
+			fmt.Fprintf(buf, "peer out: %s\n", rateString(out.PeerOut))
+			fmt.Fprintf(buf, "peer in: %s\n", rateString(out.PeerIn))
+			return buf, nil
+		},
+	},
+}
+
+func rateString(r uint64) string {
+	if r == 0 {
+		return "unlimited"
+	}
+	return humanize.Bytes(r) + "/s"
+}
+
+// BitswapLedger is what was exchanged with a peer
+type BitswapLedger struct {
+	Peer         string
+	DebtRatio    float64
+	Exchanged    uint64
+	BytesSent    uint64
+	BytesRecv    uint64
+	BlocksSent   uint64
+	BlocksRecv   uint64
+	WantlistSize int
+}
+
+type BitswapLedgerList struct {
+	Ledgers []BitswapLedger
+}
+
+var bitswapLedgerCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show what was exchanged with peers.",
+		ShortDescription: `
+Shows the bitswap ledger of a peer, or with --all of every peer we exchanged
+with: the debt ratio of the peer, the bytes sent to it over those received
+from it, the bytes and blocks exchanged, and how many blocks it wants.
+
+Ledgers are kept across restarts when Bitswap.PersistLedgers is set in the
+config.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", false, false, "The ID of the peer to show the ledger of."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("all", "a", "Show the ledgers of all peers.").Default(false),
+	},
+	Type: BitswapLedgerList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		if !ok {
+			res.SetError(u.ErrCast(), cmds.ErrNormal)
+			return
+		}
+
+		all, _, err := req.Option("all").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		args := req.Arguments()
+		if all == (len(args) > 0) {
+			res.SetError(errors.New("specify either a peer or --all"), cmds.ErrClient)
+			return
+		}
+
+		var receipts []decision.Receipt
+		if all {
+			receipts = bs.Ledgers()
+		} else {
+			pid, err := peer.IDB58Decode(args[0])
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			receipts = append(receipts, bs.LedgerForPeer(pid))
+		}
+
+		out := &BitswapLedgerList{Ledgers: []BitswapLedger{}}
+		for _, r := range receipts {
+			out.Ledgers = append(out.Ledgers, BitswapLedger{
+				Peer:         r.Peer.Pretty(),
+				DebtRatio:    r.Value,
+				Exchanged:    r.Exchanged,
+				BytesSent:    r.Sent,
+				BytesRecv:    r.Recv,
+				BlocksSent:   r.BlocksSent,
+				BlocksRecv:   r.BlocksRecv,
+				WantlistSize: r.WantlistSize,
+			})
+		}
+		sort.Sort(ledgersByPeer(out.Ledgers))
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*BitswapLedgerList)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			for i, l := range out.Ledgers {
+				if i > 0 {
+					fmt.Fprintln(buf)
+				}
+				fmt.Fprintf(buf, "Ledger for %s\n", l.Peer)
+				fmt.Fprintf(buf, "Debt ratio:\t%f\n", l.DebtRatio)
+				fmt.Fprintf(buf, "Exchanges:\t%d\n", l.Exchanged)
+				fmt.Fprintf(buf, "Bytes sent:\t%d\n", l.BytesSent)
+				fmt.Fprintf(buf, "Bytes received:\t%d\n", l.BytesRecv)
+				fmt.Fprintf(buf, "Blocks sent:\t%d\n", l.BlocksSent)
+				fmt.Fprintf(buf, "Blocks received:\t%d\n", l.BlocksRecv)
+				fmt.Fprintf(buf, "Wantlist size:\t%d\n", l.WantlistSize)
+			}
+			return buf, nil
+		},
+	},
+}
+
+type ledgersByPeer []BitswapLedger
+
+func (ls ledgersByPeer) Len() int           { return len(ls) }
+func (ls ledgersByPeer) Swap(i, j int)      { ls[i], ls[j] = ls[j], ls[i] }
+func (ls ledgersByPeer) Less(i, j int) bool { return ls[i].Peer < ls[j].Peer }
+
+// BitswapReputation is how a peer behaved
+type BitswapReputation struct {
+	Peer              string
+	Score             float64
+	UnwantedBlocks    int
+	MalformedMessages int
+	WantFloods        int
+	BannedUntil       string // empty when not banned
+}
+
+type BitswapReputationList struct {
+	Peers []BitswapReputation
+}
+
+var bitswapReputationCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the reputation of peers.",
+		ShortDescription: `
+Shows the reputation of a peer, or of every peer that misbehaved and whose
+score has not recovered yet: its score, and how many blocks it sent that we
+did not ask for, how many messages it sent that could not be decoded, and how
+many times it wanted too many blocks. Peers whose score recovered are
+forgotten, along with their counts.
+
+Peers whose score reaches Bitswap.BanThreshold in the config are banned for
+Bitswap.BanDuration. Their messages are ignored, and they are disconnected.
+With Bitswap.BanFilter set, their addresses are in 'ipfs swarm filters' while
+they are banned.
+
+--unban ends the ban of a peer early.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", false, false, "The ID of the peer to show the reputation of."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("unban", "Unban the peer.").Default(false),
+	},
+	Type: BitswapReputationList{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		if !ok {
+			res.SetError(u.ErrCast(), cmds.ErrNormal)
+			return
+		}
+
+		unban, _, err := req.Option("unban").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		args := req.Arguments()
+		if unban && len(args) == 0 {
+			res.SetError(errors.New("specify the peer to unban"), cmds.ErrClient)
+			return
+		}
+
+		var infos []reputation.Info
+		if len(args) == 0 {
+			infos = bs.Reputation().Peers()
+		} else {
+			pid, err := peer.IDB58Decode(args[0])
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if unban && !bs.Reputation().Unban(pid) {
+				res.SetError(fmt.Errorf("%s is not banned", args[0]), cmds.ErrNormal)
+				return
+			}
+			infos = append(infos, bs.Reputation().Peer(pid))
+		}
+
+		out := &BitswapReputationList{Peers: []BitswapReputation{}}
+		for _, info := range infos {
+			r := BitswapReputation{
+				Peer:              info.Peer.Pretty(),
+				Score:             info.Score,
+				UnwantedBlocks:    info.UnwantedBlocks,
+				MalformedMessages: info.MalformedMessages,
+				WantFloods:        info.WantFloods,
+			}
+			if !info.BannedUntil.IsZero() {
+				r.BannedUntil = info.BannedUntil.Format(time.RFC3339)
+			}
+			out.Peers = append(out.Peers, r)
+		}
+		sort.Sort(reputationsByPeer(out.Peers))
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*BitswapReputationList)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			for i, r := range out.Peers {
+				if i > 0 {
+					fmt.Fprintln(buf)
+				}
+				fmt.Fprintf(buf, "Reputation of %s\n", r.Peer)
+				fmt.Fprintf(buf, "Score:\t%.2f\n", r.Score)
+				fmt.Fprintf(buf, "Unwanted blocks:\t%d\n", r.UnwantedBlocks)
+				fmt.Fprintf(buf, "Malformed messages:\t%d\n", r.MalformedMessages)
+				fmt.Fprintf(buf, "Want floods:\t%d\n", r.WantFloods)
+				if r.BannedUntil != "" {
+					fmt.Fprintf(buf, "Banned until:\t%s\n", r.BannedUntil)
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+type reputationsByPeer []BitswapReputation
+
+func (rs reputationsByPeer) Len() int           { return len(rs) }
+func (rs reputationsByPeer) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+func (rs reputationsByPeer) Less(i, j int) bool { return rs[i].Peer < rs[j].Peer }
@@ -48,6 +48,13 @@ var CatCmd = &cmds.Command{
 		}
 		res.SetLength(length)
 
+		// a single file is passed through as is, so that the mime type
+		// it may carry is kept
+		if len(readers) == 1 {
+			res.SetOutput(readers[0])
+			return
+		}
+
 		reader := io.MultiReader(readers...)
 		res.SetOutput(reader)
 	},
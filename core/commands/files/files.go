@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	gopath "path"
+	"sort"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -15,6 +16,7 @@ import (
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	path "github.com/ipfs/go-ipfs/path"
 	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
@@ -43,15 +45,20 @@ operations.
 		cmds.BoolOption("flush", "f", "Flush target and ancestors after write. Default: true."),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  FilesReadCmd,
-		"write": FilesWriteCmd,
-		"mv":    FilesMvCmd,
-		"cp":    FilesCpCmd,
-		"ls":    FilesLsCmd,
-		"mkdir": FilesMkdirCmd,
-		"stat":  FilesStatCmd,
-		"rm":    FilesRmCmd,
-		"flush": FilesFlushCmd,
+		"read":      FilesReadCmd,
+		"write":     FilesWriteCmd,
+		"cut":       FilesCutCmd,
+		"mv":        FilesMvCmd,
+		"cp":        FilesCpCmd,
+		"ls":        FilesLsCmd,
+		"mkdir":     FilesMkdirCmd,
+		"stat":      FilesStatCmd,
+		"rm":        FilesRmCmd,
+		"flush":     FilesFlushCmd,
+		"setxattr":  FilesSetxattrCmd,
+		"getxattr":  FilesGetxattrCmd,
+		"listxattr": FilesListxattrCmd,
+		"rmxattr":   FilesRmxattrCmd,
 	},
 }
 
@@ -67,7 +74,7 @@ var FilesStatCmd = &cmds.Command{
 	},
 	Options: []cmds.Option{
 		cmds.StringOption("format", "Print statistics in given format. Allowed tokens: "+
-			"<hash> <size> <cumulsize> <type> <childs>. Conflicts with other format options.").Default(
+			"<hash> <size> <cumulsize> <type> <childs> <holes>. Conflicts with other format options.").Default(
 			`<hash>
 Size: <size>
 CumulativeSize: <cumulsize>
@@ -75,6 +82,7 @@ ChildBlocks: <childs>
 Type: <type>`),
 		cmds.BoolOption("hash", "Print only hash. Implies '--format=<hash>'. Conflicts with other format options.").Default(false),
 		cmds.BoolOption("size", "Print only size. Implies '--format=<cumulsize>'. Conflicts with other format options.").Default(false),
+		cmds.BoolOption("holes", "Compute how many bytes of the file are holes. Reads the whole file.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
@@ -107,6 +115,21 @@ Type: <type>`),
 			return
 		}
 
+		holes, _, _ := req.Option("holes").Bool()
+		if holes && fsn.Type() == mfs.TFile {
+			nd, err := fsn.GetNode()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			o.Holes, err = uio.HoleSize(req.Context(), nd, node.DAG)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
 		res.SetOutput(o)
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -121,6 +144,7 @@ Type: <type>`),
 			s = strings.Replace(s, "<cumulsize>", fmt.Sprintf("%d", out.CumulativeSize), -1)
 			s = strings.Replace(s, "<childs>", fmt.Sprintf("%d", out.Blocks), -1)
 			s = strings.Replace(s, "<type>", out.Type, -1)
+			s = strings.Replace(s, "<holes>", fmt.Sprintf("%d", out.Holes), -1)
 
 			fmt.Fprintln(buf, s)
 			return buf, nil
@@ -137,6 +161,7 @@ func statGetFormatOptions(req cmds.Request) (string, error) {
 
 	hash, _, _ := req.Option("hash").Bool()
 	size, _, _ := req.Option("size").Bool()
+	holes, _, _ := req.Option("holes").Bool()
 	format, found, _ := req.Option("format").String()
 
 	if moreThanOne(hash, size, found) {
@@ -147,6 +172,8 @@ func statGetFormatOptions(req cmds.Request) (string, error) {
 		return "<hash>", nil
 	} else if size {
 		return "<cumulsize>", nil
+	} else if holes && !found {
+		return format + "\nHoles: <holes>", nil
 	} else {
 		return format, nil
 	}
@@ -276,6 +303,7 @@ type Object struct {
 	CumulativeSize uint64
 	Blocks         int
 	Type           string
+	Holes          uint64 `json:",omitempty"`
 }
 
 type FilesLsOutput struct {
@@ -550,6 +578,10 @@ a beginning offset to write to. The entire length of the input will be written.
 If the '--create' option is specified, the file will be created if it does not
 exist. Nonexistant intermediate directories will not be created.
 
+If the '--insert' option is specified, the input is inserted at the given
+offset and the existing data after it is moved back, instead of being
+overwritten.
+
 If the '--flush' option is set to false, changes will not be propogated to the
 merkledag root. This can make operations much faster when doing a large number
 of writes to a deeper directory structure.
@@ -558,6 +590,7 @@ EXAMPLE:
 
     echo "hello world" | ipfs files write --create /myfs/a/b/file
     echo "hello world" | ipfs files write --truncate /myfs/a/b/file
+    echo "hello world" | ipfs files write --insert -o 5 /myfs/a/b/file
 
 WARNING:
 
@@ -574,6 +607,7 @@ stat' on the file or any of its ancestors.
 		cmds.IntOption("offset", "o", "Byte offset to begin writing at."),
 		cmds.BoolOption("create", "e", "Create the file if it does not exist."),
 		cmds.BoolOption("truncate", "t", "Truncate the file to size zero before writing."),
+		cmds.BoolOption("insert", "i", "Insert data at the offset instead of overwriting."),
 		cmds.IntOption("count", "n", "Maximum number of bytes to read."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -585,6 +619,7 @@ stat' on the file or any of its ancestors.
 
 		create, _, _ := req.Option("create").Bool()
 		trunc, _, _ := req.Option("truncate").Bool()
+		insert, _, _ := req.Option("insert").Bool()
 		flush, fset, _ := req.Option("flush").Bool()
 		if !fset {
 			flush = true
@@ -637,6 +672,28 @@ stat' on the file or any of its ancestors.
 			return
 		}
 
+		input, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var r io.Reader = input
+		if countfound {
+			r = io.LimitReader(r, int64(count))
+		}
+
+		if insert {
+			n, err := wfd.InsertFrom(r, int64(offset))
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			log.Debugf("inserted %d bytes into %s", n, path)
+			return
+		}
+
 		_, err = wfd.Seek(int64(offset), os.SEEK_SET)
 		if err != nil {
 			log.Error("seekfail: ", err)
@@ -644,24 +701,109 @@ stat' on the file or any of its ancestors.
 			return
 		}
 
-		input, err := req.Files().NextFile()
+		n, err := io.Copy(wfd, input)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		var r io.Reader = input
-		if countfound {
-			r = io.LimitReader(r, int64(count))
+		log.Debugf("wrote %d bytes to %s", n, path)
+	},
+}
+
+var FilesCutCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a range of bytes from a file in a given filesystem.",
+		ShortDescription: `
+Remove '--count' bytes starting at '--offset' from a file, moving the data
+after the range back. If '--count' is not given, everything from the offset to
+the end of the file is removed.
+
+EXAMPLE:
+
+    ipfs files cut -o 5 -n 6 /myfs/a/b/file
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the file to cut."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("offset", "o", "Byte offset to begin removing at."),
+		cmds.IntOption("count", "n", "Number of bytes to remove."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
 		}
 
-		n, err := io.Copy(wfd, input)
+		flush, fset, _ := req.Option("flush").Bool()
+		if !fset {
+			flush = true
+		}
+
+		nd, err := req.InvocContext().GetNode()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		log.Debugf("wrote %d bytes to %s", n, path)
+		offset, _, err := req.Option("offset").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if offset < 0 {
+			res.SetError(fmt.Errorf("cannot have negative offset"), cmds.ErrNormal)
+			return
+		}
+
+		count, countfound, err := req.Option("count").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if countfound && count < 0 {
+			res.SetError(fmt.Errorf("cannot have negative byte count"), cmds.ErrNormal)
+			return
+		}
+
+		fi, err := getFileHandle(nd.FilesRoot, path, false)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		wfd, err := fi.Open(mfs.OpenWriteOnly, flush)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		defer wfd.Close()
+
+		filen, err := wfd.Size()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if int64(offset) > filen {
+			res.SetError(fmt.Errorf("Offset was past end of file (%d > %d).", offset, filen), cmds.ErrNormal)
+			return
+		}
+
+		length := filen - int64(offset)
+		if countfound && int64(count) < length {
+			length = int64(count)
+		}
+
+		err = wfd.RemoveRange(int64(offset), length)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
 	},
 }
 
@@ -849,6 +991,202 @@ Remove files or directories.
 	},
 }
 
+var FilesSetxattrCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set an extended attribute on a file or directory.",
+		ShortDescription: `
+Set the extended attribute <name> of the file or directory at <path> to
+<value>. Any previous value of the attribute is replaced.
+
+Examples:
+
+    $ ipfs files setxattr /test/hello user.mime text/plain
+    $ ipfs files getxattr /test/hello user.mime
+    text/plain
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path to the file or directory."),
+		cmds.StringArg("name", true, false, "Name of the attribute."),
+		cmds.StringArg("value", true, false, "Value of the attribute."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		flush, found, _ := req.Option("flush").Bool()
+		if !found {
+			flush = true
+		}
+
+		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name, value := req.Arguments()[1], req.Arguments()[2]
+		err = fsn.SetXattr(name, []byte(value), flush)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+var FilesGetxattrCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print the value of an extended attribute.",
+		ShortDescription: `
+Print the value of the extended attribute <name> of the file or directory at
+<path>.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path to the file or directory."),
+		cmds.StringArg("name", true, false, "Name of the attribute."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		xattrs, err := fsn.Xattrs()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		value, ok := xattrs[req.Arguments()[1]]
+		if !ok {
+			res.SetError(ft.ErrNoXattr, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(bytes.NewReader(value))
+	},
+}
+
+type FilesListxattrOutput struct {
+	Names []string
+}
+
+var FilesListxattrCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the extended attributes of a file or directory.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path to the file or directory."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		xattrs, err := fsn.Xattrs()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		names := make([]string, 0, len(xattrs))
+		for name := range xattrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		res.SetOutput(&FilesListxattrOutput{names})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*FilesListxattrOutput)
+			buf := new(bytes.Buffer)
+			for _, name := range out.Names {
+				fmt.Fprintln(buf, name)
+			}
+			return buf, nil
+		},
+	},
+	Type: FilesListxattrOutput{},
+}
+
+var FilesRmxattrCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove an extended attribute from a file or directory.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path to the file or directory."),
+		cmds.StringArg("name", true, false, "Name of the attribute."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		flush, found, _ := req.Option("flush").Bool()
+		if !found {
+			flush = true
+		}
+
+		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		err = fsn.RemoveXattr(req.Arguments()[1], flush)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
 func getFileHandle(r *mfs.Root, path string, create bool) (*mfs.File, error) {
 
 	target, err := mfs.Lookup(r, path)
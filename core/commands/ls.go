@@ -19,6 +19,7 @@ type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfspb.Data_DataType
+	MimeType   string `json:",omitempty"`
 }
 
 type LsObject struct {
@@ -47,6 +48,7 @@ format:
 	Options: []cmds.Option{
 		cmds.BoolOption("headers", "v", "Print table headers (Hash, Size, Name).").Default(false),
 		cmds.BoolOption("resolve-type", "Resolve linked objects to find out their types.").Default(true),
+		cmds.BoolOption("mime", "m", "Print the mime type of files that have one stored.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		node, err := req.InvocContext().GetNode()
@@ -109,6 +111,7 @@ format:
 						return
 					}
 				}
+				var mimeType string
 				if linkNode != nil {
 					d, err := unixfs.FromBytes(linkNode.Data())
 					if err != nil {
@@ -117,12 +120,25 @@ format:
 					}
 
 					t = d.GetType()
+
+					// metadata objects only ever wrap files
+					if t == unixfspb.Data_Metadata {
+						md, err := unixfs.MetadataFromBytes(linkNode.Data())
+						if err != nil {
+							res.SetError(err, cmds.ErrNormal)
+							return
+						}
+
+						t = unixfspb.Data_File
+						mimeType = md.MimeType
+					}
 				}
 				output[i].Links[j] = LsLink{
-					Name: link.Name,
-					Hash: link.Hash.B58String(),
-					Size: link.Size,
-					Type: t,
+					Name:     link.Name,
+					Hash:     link.Hash.B58String(),
+					Size:     link.Size,
+					Type:     t,
+					MimeType: mimeType,
 				}
 			}
 		}
@@ -133,6 +149,7 @@ format:
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
 
 			headers, _, _ := res.Request().Option("headers").Bool()
+			mime, _, _ := res.Request().Option("mime").Bool()
 			output := res.Output().(*LsOutput)
 			buf := new(bytes.Buffer)
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
@@ -141,13 +158,21 @@ format:
 					fmt.Fprintf(w, "%s:\n", object.Hash)
 				}
 				if headers {
-					fmt.Fprintln(w, "Hash\tSize\tName")
+					if mime {
+						fmt.Fprintln(w, "Hash\tSize\tName\tType")
+					} else {
+						fmt.Fprintln(w, "Hash\tSize\tName")
+					}
 				}
 				for _, link := range object.Links {
 					if link.Type == unixfspb.Data_Directory {
 						link.Name += "/"
 					}
-					fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
+					if mime {
+						fmt.Fprintf(w, "%s\t%v\t%s\t%s\n", link.Hash, link.Size, link.Name, link.MimeType)
+					} else {
+						fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
+					}
 				}
 				if len(output.Objects) > 1 {
 					fmt.Fprintln(w)
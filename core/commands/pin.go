@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -12,6 +14,7 @@ import (
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	u "gx/ipfs/QmZNVWh8LLjAavuQ2JXuFmuYH3C11xo988vSgp7UQrTRj1/go-ipfs-util"
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 )
@@ -22,20 +25,50 @@ var PinCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"add": addPinCmd,
-		"rm":  rmPinCmd,
-		"ls":  listPinCmd,
+		"add":    addPinCmd,
+		"rm":     rmPinCmd,
+		"ls":     listPinCmd,
+		"update": updatePinCmd,
+		"verify": verifyPinCmd,
+		"remote": remotePinCmd,
 	},
 }
 
 type PinOutput struct {
 	Pins []key.Key
+
+	// nodes fetched so far, and their size, with 'pin add --progress'
+	Progress int    `json:",omitempty"`
+	Bytes    uint64 `json:",omitempty"`
 }
 
 var addPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Pins objects to local storage.",
 		ShortDescription: "Stores an IPFS object(s) from a given path locally to disk.",
+		LongDescription: `
+Stores an IPFS object(s) from a given path locally to disk.
+
+With '--name', the pin is given a name, and with '--owner' the owner it
+belongs to. An object pinned by name stays pinned until every pin on it is
+removed, so applications pinning the same object don't unpin each other's
+data. Named pins are removed with 'ipfs pin rm --name', with the same name
+and owner:
+
+  > ipfs pin add --name=photos --owner=backup-app <hash>
+  > ipfs pin add --name=gallery --owner=web-app <hash>
+  > ipfs pin rm --name=photos --owner=backup-app <hash>
+
+With '--expires-in', the pin expires after the given duration, such as
+'72h'. Expired pins are ignored by garbage collection, and removed by the
+daemon every Datastore.PinSweepPeriod. Pinning an object again extends its
+expiry, and pinning it without '--expires-in' makes the pin permanent.
+Objects that are already pinned permanently stay so.
+
+With '--progress', the number of nodes fetched so far, and their size, is
+written while the objects are fetched. Canceling the command before all of
+them are fetched pins none.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -43,6 +76,10 @@ var addPinCmd = &cmds.Command{
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s).").Default(true),
+		cmds.StringOption("name", "Name of the pin."),
+		cmds.StringOption("owner", "Owner of the named pin."),
+		cmds.StringOption("expires-in", "Duration after which the pin expires, e.g. 72h."),
+		cmds.BoolOption("progress", "Show progress while fetching the objects.").Default(false),
 	},
 	Type: PinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -52,8 +89,6 @@ var addPinCmd = &cmds.Command{
 			return
 		}
 
-		defer n.Blockstore.PinLock().Unlock()
-
 		// set recursive flag
 		recursive, _, err := req.Option("recursive").Bool()
 		if err != nil {
@@ -61,44 +96,168 @@ var addPinCmd = &cmds.Command{
 			return
 		}
 
-		added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+		name, owner, err := pinName(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		expiresIn, expiring, err := req.Option("expires-in").String()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		var expiry time.Duration
+		if expiring {
+			if name != "" {
+				res.SetError(fmt.Errorf("named pins can not expire"), cmds.ErrClient)
+				return
+			}
+			expiry, err = time.ParseDuration(expiresIn)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			if expiry <= 0 {
+				res.SetError(fmt.Errorf("--expires-in must be positive"), cmds.ErrClient)
+				return
+			}
+		}
 
-		res.SetOutput(&PinOutput{added})
-	},
-	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			added, ok := res.Output().(*PinOutput)
-			if !ok {
-				return nil, u.ErrCast()
+		showProgress, _, err := req.Option("progress").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		pinPaths := func(ctx context.Context) ([]key.Key, error) {
+			switch {
+			case name != "":
+				return corerepo.PinNamed(n, ctx, req.Arguments(), recursive, name, owner)
+			case expiring:
+				return corerepo.PinExpiring(n, ctx, req.Arguments(), recursive, time.Now().Add(expiry))
+			default:
+				return corerepo.Pin(n, ctx, req.Arguments(), recursive)
 			}
+		}
 
-			var pintype string
-			rec, found, _ := res.Request().Option("recursive").Bool()
-			if rec || !found {
-				pintype = "recursively"
-			} else {
-				pintype = "directly"
+		unlocker := n.Blockstore.PinLock()
+		if !showProgress {
+			defer unlocker.Unlock()
+
+			added, err := pinPaths(req.Context())
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
 			}
 
-			buf := new(bytes.Buffer)
-			for _, k := range added.Pins {
-				fmt.Fprintf(buf, "pinned %s %s\n", k, pintype)
+			res.SetOutput(&PinOutput{Pins: added})
+			return
+		}
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+			defer unlocker.Unlock()
+
+			type pinResult struct {
+				pins []key.Key
+				err  error
+			}
+			v := new(dag.ProgressTracker)
+			done := make(chan pinResult, 1)
+			go func() {
+				added, err := pinPaths(v.DeriveContext(req.Context()))
+				done <- pinResult{added, err}
+			}()
+
+			send := func(out *PinOutput) {
+				select {
+				case outChan <- out:
+				case <-req.Context().Done():
+				}
+			}
+
+			ticker := time.NewTicker(500 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case r := <-done:
+					if r.err != nil {
+						res.SetError(r.err, cmds.ErrNormal)
+						return
+					}
+					nodes, bytes := v.Value()
+					send(&PinOutput{Progress: nodes, Bytes: bytes})
+					send(&PinOutput{Pins: r.pins})
+					return
+				case <-ticker.C:
+					nodes, bytes := v.Value()
+					send(&PinOutput{Progress: nodes, Bytes: bytes})
+				}
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			switch out := res.Output().(type) {
+			case *PinOutput:
+				return pinAddText(res, out), nil
+			case <-chan interface{}:
+				marshal := func(v interface{}) (io.Reader, error) {
+					out, ok := v.(*PinOutput)
+					if !ok {
+						return nil, u.ErrCast()
+					}
+					if out.Pins == nil {
+						return strings.NewReader(fmt.Sprintf("\033[2K\rFetched %d nodes (%s)", out.Progress, humanize.Bytes(out.Bytes))), nil
+					}
+					return io.MultiReader(strings.NewReader("\033[2K\r"), pinAddText(res, out)), nil
+				}
+				return &cmds.ChannelMarshaler{
+					Channel:   out,
+					Marshaler: marshal,
+					Res:       res,
+				}, nil
+			default:
+				return nil, u.ErrCast()
 			}
-			return buf, nil
 		},
 	},
 }
 
+// pinAddText writes the keys that were pinned
+func pinAddText(res cmds.Response, added *PinOutput) io.Reader {
+	var pintype string
+	rec, found, _ := res.Request().Option("recursive").Bool()
+	if rec || !found {
+		pintype = "recursively"
+	} else {
+		pintype = "directly"
+	}
+
+	buf := new(bytes.Buffer)
+	for _, k := range added.Pins {
+		fmt.Fprintf(buf, "pinned %s %s\n", k, pintype)
+	}
+	return buf
+}
+
 var rmPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Removes the pinned object from local storage.",
 		ShortDescription: `
 Removes the pin from the given object allowing it to be garbage
 collected if needed. (By default, recursively. Use -r=false for direct pins)
+`,
+		LongDescription: `
+Removes the pin from the given object allowing it to be garbage
+collected if needed. (By default, recursively. Use -r=false for direct pins)
+
+With '--name', and '--owner' if the pin has one, the named pin is removed
+instead. The object stays pinned if other pins on it remain.
 `,
 	},
 
@@ -107,6 +266,8 @@ collected if needed. (By default, recursively. Use -r=false for direct pins)
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively unpin the object linked to by the specified object(s).").Default(true),
+		cmds.StringOption("name", "Name of the pin to remove."),
+		cmds.StringOption("owner", "Owner of the named pin to remove."),
 	},
 	Type: PinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -123,13 +284,24 @@ collected if needed. (By default, recursively. Use -r=false for direct pins)
 			return
 		}
 
-		removed, err := corerepo.Unpin(n, req.Context(), req.Arguments(), recursive)
+		name, owner, err := pinName(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		var removed []key.Key
+		if name != "" {
+			removed, err = corerepo.UnpinNamed(n, req.Context(), req.Arguments(), name, owner)
+		} else {
+			removed, err = corerepo.Unpin(n, req.Context(), req.Arguments(), recursive)
+		}
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		res.SetOutput(&PinOutput{removed})
+		res.SetOutput(&PinOutput{Pins: removed})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -174,6 +346,12 @@ With arguments, the command fails if any of the arguments is not a pinned
 object. And if --type=<type> is additionally used, the command will also fail
 if any of the arguments is not of the specified type.
 
+Use --name=<name> and --owner=<owner> to list only the objects with named
+pins of that name or owner, along with the names and owners of these pins.
+
+Pins that expire are listed with the time remaining until they do. Expired
+pins are not listed anymore, even before the daemon removes them.
+
 Example:
 	$ echo "hello" | ipfs add -q
 	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN
@@ -197,6 +375,8 @@ Example:
 	Options: []cmds.Option{
 		cmds.StringOption("type", "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").Default("all"),
 		cmds.BoolOption("quiet", "q", "Write just hashes of objects.").Default(false),
+		cmds.StringOption("name", "List only the objects with named pins of this name."),
+		cmds.StringOption("owner", "List only the objects with named pins of this owner."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -219,9 +399,18 @@ Example:
 			return
 		}
 
+		name, nameFound, _ := req.Option("name").String()
+		owner, ownerFound, _ := req.Option("owner").String()
+
 		var keys map[string]RefKeyObject
 
-		if len(req.Arguments()) > 0 {
+		if nameFound || ownerFound {
+			if len(req.Arguments()) > 0 {
+				res.SetError(fmt.Errorf("--name and --owner can not be used with paths"), cmds.ErrClient)
+				return
+			}
+			keys = pinLsNamed(typeStr, name, nameFound, owner, ownerFound, n)
+		} else if len(req.Arguments()) > 0 {
 			keys, err = pinLsKeys(req.Arguments(), typeStr, req.Context(), n)
 		} else {
 			keys, err = pinLsAll(typeStr, req.Context(), n)
@@ -229,9 +418,19 @@ Example:
 
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
-		} else {
-			res.SetOutput(&RefKeyList{Keys: keys})
+			return
 		}
+
+		for k, obj := range keys {
+			if len(obj.Named) > 0 || (obj.Type != "direct" && obj.Type != "recursive") {
+				continue
+			}
+			if t, ok := n.Pinning.Expiry(key.B58KeyDecode(k)); ok {
+				obj.Expires = &t
+				keys[k] = obj
+			}
+		}
+		res.SetOutput(&RefKeyList{Keys: keys})
 	},
 	Type: RefKeyList{},
 	Marshalers: cmds.MarshalerMap{
@@ -249,8 +448,22 @@ Example:
 			for k, v := range keys.Keys {
 				if quiet {
 					fmt.Fprintf(out, "%s\n", k)
-				} else {
-					fmt.Fprintf(out, "%s %s\n", k, v.Type)
+					continue
+				}
+				if len(v.Named) == 0 {
+					fmt.Fprintf(out, "%s %s", k, v.Type)
+					if v.Expires != nil {
+						left := v.Expires.Sub(time.Now())
+						fmt.Fprintf(out, " expires in %s", left-left%time.Second)
+					}
+					fmt.Fprintln(out)
+				}
+				for _, np := range v.Named {
+					fmt.Fprintf(out, "%s %s %s", k, v.Type, np.Name)
+					if np.Owner != "" {
+						fmt.Fprintf(out, " %s", np.Owner)
+					}
+					fmt.Fprintln(out)
 				}
 			}
 			return out, nil
@@ -258,8 +471,193 @@ Example:
 	},
 }
 
+var updatePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Update a recursive pin.",
+		ShortDescription: `
+Updates the recursive pin of <from-path> to <to-path>, only fetching the
+parts of <to-path> that are not shared with <from-path>. This is faster
+than pinning <to-path> and then unpinning <from-path>, for example when
+updating a website to a new version.
+
+<from-path> stays pinned if <to-path> can't be fetched. Use --unpin=false
+to keep both pinned.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("from-path", true, false, "Path to the old object."),
+		cmds.StringArg("to-path", true, false, "Path to the new object to be pinned."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("unpin", "Remove the old pin.").Default(true),
+	},
+	Type: PinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		defer n.Blockstore.PinLock().Unlock()
+
+		unpin, _, err := req.Option("unpin").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		keys, err := corerepo.PinUpdate(n, req.Context(), req.Arguments()[0], req.Arguments()[1], unpin)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&PinOutput{Pins: keys})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			updated, ok := res.Output().(*PinOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(fmt.Sprintf("updated %s to %s\n", updated.Pins[0], updated.Pins[1])), nil
+		},
+	},
+}
+
+var verifyPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify that recursive pins are complete.",
+		ShortDescription: `
+Walks the graph of every recursive pin, checking that each of its blocks is
+stored and matches its hash. Pins with missing or corrupt blocks are listed
+with the keys of these blocks.
+
+With --repair, missing and corrupt blocks are fetched again from the
+network. Use --verbose to also list the pins that are complete.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("verbose", "Also write the pins that are complete.").Default(false),
+		cmds.BoolOption("repair", "Fetch missing and corrupt blocks again.").Default(false),
+		cmds.BoolOption("quiet", "q", "Write just hashes of broken pins.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		verbose, _, _ := req.Option("verbose").Bool()
+		repair, _, _ := req.Option("repair").Bool()
+
+		unlocker := n.Blockstore.PinLock()
+		statuses := pin.Verify(req.Context(), n.Pinning, n.Blockstore, n.DAG, repair)
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+			defer unlocker.Unlock()
+			for st := range statuses {
+				if st.Ok() && len(st.Repaired) == 0 && !verbose {
+					continue
+				}
+
+				out := &PinVerifyRes{
+					Key: st.Key.B58String(),
+					Ok:  st.Ok(),
+				}
+				for _, bn := range st.BadNodes {
+					out.BadNodes = append(out.BadNodes, BadNode{Key: bn.Key.B58String(), Err: bn.Err})
+				}
+				for _, k := range st.Repaired {
+					out.Repaired = append(out.Repaired, k.B58String())
+				}
+
+				select {
+				case outChan <- out:
+				case <-req.Context().Done():
+					return
+				}
+			}
+		}()
+	},
+	Type: PinVerifyRes{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			quiet, _, _ := res.Request().Option("quiet").Bool()
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				obj, ok := v.(*PinVerifyRes)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				buf := new(bytes.Buffer)
+				if quiet {
+					if !obj.Ok {
+						fmt.Fprintln(buf, obj.Key)
+					}
+					return buf, nil
+				}
+
+				if obj.Ok {
+					fmt.Fprintf(buf, "%s ok\n", obj.Key)
+				} else {
+					fmt.Fprintf(buf, "%s broken\n", obj.Key)
+				}
+				for _, bn := range obj.BadNodes {
+					fmt.Fprintf(buf, "  %s %s\n", bn.Key, bn.Err)
+				}
+				for _, k := range obj.Repaired {
+					fmt.Fprintf(buf, "  %s repaired\n", k)
+				}
+				return buf, nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
+
+// PinVerifyRes is the result of the verification of a recursive pin
+type PinVerifyRes struct {
+	Key      string
+	Ok       bool
+	BadNodes []BadNode `json:",omitempty"`
+	Repaired []string  `json:",omitempty"`
+}
+
+// BadNode is a missing or corrupt block of a pinned graph
+type BadNode struct {
+	Key string
+	Err string
+}
+
 type RefKeyObject struct {
-	Type string
+	Type    string
+	Named   []PinName  `json:",omitempty"`
+	Expires *time.Time `json:",omitempty"`
+}
+
+// PinName is the name and owner of a named pin
+type PinName struct {
+	Name  string
+	Owner string `json:",omitempty"`
 }
 
 type RefKeyList struct {
@@ -343,3 +741,45 @@ func pinLsAll(typeStr string, ctx context.Context, n *core.IpfsNode) (map[string
 
 	return keys, nil
 }
+
+// pinName returns the name and owner of the pin a request is about
+func pinName(req cmds.Request) (string, string, error) {
+	name, _, err := req.Option("name").String()
+	if err != nil {
+		return "", "", err
+	}
+	owner, ownerFound, err := req.Option("owner").String()
+	if err != nil {
+		return "", "", err
+	}
+
+	if ownerFound && name == "" {
+		return "", "", fmt.Errorf("--owner requires --name")
+	}
+	return name, owner, nil
+}
+
+// pinLsNamed lists the objects with named pins that match the name and
+// owner that were given
+func pinLsNamed(typeStr, name string, nameFound bool, owner string, ownerFound bool, n *core.IpfsNode) map[string]RefKeyObject {
+	keys := make(map[string]RefKeyObject)
+	for _, np := range n.Pinning.NamedPins() {
+		if (nameFound && np.Name != name) || (ownerFound && np.Owner != owner) {
+			continue
+		}
+
+		pinType := "direct"
+		if np.Recursive {
+			pinType = "recursive"
+		}
+		if typeStr != "all" && typeStr != pinType {
+			continue
+		}
+
+		obj := keys[np.Key.B58String()]
+		obj.Type = pinType
+		obj.Named = append(obj.Named, PinName{Name: np.Name, Owner: np.Owner})
+		keys[np.Key.B58String()] = obj
+	}
+	return keys
+}
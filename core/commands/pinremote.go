@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
+	ps "github.com/ipfs/go-ipfs/pin/pinservice"
+	u "gx/ipfs/QmZNVWh8LLjAavuQ2JXuFmuYH3C11xo988vSgp7UQrTRj1/go-ipfs-util"
+)
+
+// how often 'pin remote add --wait' polls the status of the pin
+const remotePinPollInterval = 500 * time.Millisecond
+
+var remotePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Pin objects on remote pin services.",
+		ShortDescription: `
+Asks other nodes to pin content, through the pin services configured in
+Pinning.RemoteServices. A node serves as a pin service for the clients in
+Pinning.Service.Tokens when Pinning.Service.Enabled is set.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add": addRemotePinCmd,
+		"ls":  listRemotePinCmd,
+		"rm":  rmRemotePinCmd,
+	},
+}
+
+type RemotePinOutput struct {
+	Pins []*ps.PinStatus
+}
+
+var remoteServiceOption = cmds.StringOption("service", "Name of the remote pin service to use.")
+
+// remotePinClient returns a client of the pin service of the '--service'
+// option of 'req'
+func remotePinClient(req cmds.Request) (*ps.Client, error) {
+	name, found, err := req.Option("service").String()
+	if err != nil {
+		return nil, err
+	}
+	if !found || name == "" {
+		return nil, fmt.Errorf("a pin service must be given with --service")
+	}
+
+	cfg, err := req.InvocContext().GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	svc, ok := cfg.Pinning.RemoteServices[name]
+	if !ok {
+		return nil, fmt.Errorf("no pin service named %s in Pinning.RemoteServices", name)
+	}
+	return ps.NewClient(svc.Endpoint, svc.Token), nil
+}
+
+var addRemotePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Pin an object on a remote pin service.",
+		ShortDescription: `
+Asks a pin service to pin <ipfs-path> recursively, under --name. The pin
+service fetches the content from the network, so it should be reachable
+from there, for example because this node provides it.
+
+The pin service pins in the background: use --wait to wait until it is
+done, or 'ipfs pin remote ls' to check on it.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "Path to the object to be pinned."),
+	},
+	Options: []cmds.Option{
+		remoteServiceOption,
+		cmds.StringOption("name", "Name of the pin on the pin service. Defaults to the hash of the object."),
+		cmds.BoolOption("wait", "Wait until the object is pinned.").Default(false),
+	},
+	Type: RemotePinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		client, err := remotePinClient(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		k, err := core.ResolveToKey(req.Context(), n, p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name, _, _ := req.Option("name").String()
+		wait, _, _ := req.Option("wait").Bool()
+
+		st, err := client.Add(req.Context(), k, name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if wait {
+			st, err = client.Wait(req.Context(), st.Name, remotePinPollInterval)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if st.Status == ps.Failed {
+				res.SetError(fmt.Errorf("pin service failed to pin %s: %s", st.Key, st.Error), cmds.ErrNormal)
+				return
+			}
+		}
+
+		res.SetOutput(&RemotePinOutput{Pins: []*ps.PinStatus{st}})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: remotePinText,
+	},
+}
+
+var listRemotePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the objects pinned on a remote pin service.",
+		ShortDescription: `
+Lists the pins this node asked the pin service for, with their status:
+queued, pinning, pinned or failed. Pins that failed are listed for an hour.
+`,
+	},
+
+	Options: []cmds.Option{
+		remoteServiceOption,
+	},
+	Type: RemotePinOutput{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		client, err := remotePinClient(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		sts, err := client.Ls(req.Context())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&RemotePinOutput{Pins: sts})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: remotePinText,
+	},
+}
+
+var rmRemotePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a pin from a remote pin service.",
+		ShortDescription: `
+Asks the pin service to remove the pin named <name>. Pins that are not
+pinned yet are cancelled.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the pin to remove."),
+	},
+	Options: []cmds.Option{
+		remoteServiceOption,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		client, err := remotePinClient(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		name := req.Arguments()[0]
+		if err := client.Rm(req.Context(), name); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&MessageOutput{fmt.Sprintf("removed %s\n", name)})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: MessageTextMarshaler,
+	},
+	Type: MessageOutput{},
+}
+
+func remotePinText(res cmds.Response) (io.Reader, error) {
+	out, ok := res.Output().(*RemotePinOutput)
+	if !ok {
+		return nil, u.ErrCast()
+	}
+
+	buf := new(bytes.Buffer)
+	for _, st := range out.Pins {
+		fmt.Fprintf(buf, "%s %s %s", st.Key, st.Status, st.Name)
+		if st.Error != "" {
+			fmt.Fprintf(buf, ": %s", st.Error)
+		}
+		fmt.Fprintln(buf)
+	}
+	return buf, nil
+}
@@ -43,6 +43,7 @@ ADVANCED COMMANDS
   dns           Resolve DNS links
   pin           Pin objects to local storage
   repo          Manipulate the IPFS repository
+  urlstore      Add content by reference to its URL
 
 NETWORK COMMANDS
   id            Show info about ipfs peers
@@ -108,6 +109,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"tour":      tourCmd,
 	"file":      unixfs.UnixFSCmd,
 	"update":    ExternalBinary(),
+	"urlstore":  UrlStoreCmd,
 	"version":   VersionCmd,
 	"bitswap":   BitswapCmd,
 }
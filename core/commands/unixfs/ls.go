@@ -19,13 +19,15 @@ type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       string
+	MimeType   string `json:",omitempty"`
 }
 
 type LsObject struct {
-	Hash  string
-	Size  uint64
-	Type  string
-	Links []LsLink
+	Hash     string
+	Size     uint64
+	Type     string
+	MimeType string `json:",omitempty"`
+	Links    []LsLink
 }
 
 type LsOutput struct {
@@ -107,12 +109,17 @@ Example:
 				return
 			}
 
-			t := unixFSNode.GetType()
+			t, mimeType, err := fileType(merkleNode, unixFSNode)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
 
 			output.Objects[hash] = &LsObject{
-				Hash: key.String(),
-				Type: t.String(),
-				Size: unixFSNode.GetFilesize(),
+				Hash:     key.String(),
+				Type:     t.String(),
+				MimeType: mimeType,
+				Size:     unixFSNode.GetFilesize(),
 			}
 
 			switch t {
@@ -133,11 +140,16 @@ Example:
 						res.SetError(err, cmds.ErrNormal)
 						return
 					}
-					t := d.GetType()
+					t, mimeType, err := fileType(linkNode, d)
+					if err != nil {
+						res.SetError(err, cmds.ErrNormal)
+						return
+					}
 					lsLink := LsLink{
-						Name: link.Name,
-						Hash: link.Hash.B58String(),
-						Type: t.String(),
+						Name:     link.Name,
+						Hash:     link.Hash.B58String(),
+						Type:     t.String(),
+						MimeType: mimeType,
 					}
 					if t == unixfspb.Data_File {
 						lsLink.Size = d.GetFilesize()
@@ -215,3 +227,18 @@ Example:
 	},
 	Type: LsOutput{},
 }
+
+// fileType returns the type of a unixfs node. Files wrapped in metadata
+// objects are reported as files, together with their stored mime type.
+func fileType(nd *merkledag.Node, pbd *unixfspb.Data) (unixfspb.Data_DataType, string, error) {
+	t := pbd.GetType()
+	if t != unixfspb.Data_Metadata {
+		return t, "", nil
+	}
+
+	md, err := unixfs.MetadataFromBytes(nd.Data())
+	if err != nil {
+		return t, "", err
+	}
+	return unixfspb.Data_File, md.MimeType, nil
+}
@@ -0,0 +1,120 @@
+package unixfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	unixfspb "github.com/ipfs/go-ipfs/unixfs/pb"
+)
+
+type StatOutput struct {
+	Hash     string
+	Size     uint64
+	Type     string
+	MimeType string `json:",omitempty"`
+	Source   string `json:",omitempty"`
+	ETag     string `json:",omitempty"`
+}
+
+var StatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Display information about a Unix filesystem object.",
+		ShortDescription: `
+Displays the hash, size and type of the IPFS or IPNS object at the given
+path. If a mime type was recorded for a file when it was added, it is shown
+as well.
+`,
+		LongDescription: `
+Displays the hash, size and type of the IPFS or IPNS object at the given
+path. If a mime type was recorded for a file when it was added, it is shown
+as well, and so are the URL and ETag of files added from a URL.
+
+Example:
+
+    > ipfs file stat QmW2WQi7j6c7UgJTarActp7tDNikE4B2qXtFCfLPdsgaTQ/cat.jpg
+    QmPAB7fEtqUFazEsR2vT2FdDGwsACrB3Qt1tYWeaj3mfhw
+    Size: 443230
+    Type: File
+    MimeType: image/jpeg
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path to the IPFS object to stat.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		node, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		merkleNode, err := core.Resolve(req.Context(), node, path.Path(req.Arguments()[0]))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		key, err := merkleNode.Key()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		unixFSNode, err := unixfs.FromBytes(merkleNode.Data())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		t, mimeType, err := fileType(merkleNode, unixFSNode)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &StatOutput{
+			Hash:     key.B58String(),
+			Size:     unixFSNode.GetFilesize(),
+			Type:     t.String(),
+			MimeType: mimeType,
+		}
+
+		if unixFSNode.GetType() == unixfspb.Data_Metadata {
+			md, err := unixfs.MetadataFromBytes(merkleNode.Data())
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			out.Source = md.SourceURL
+			out.ETag = md.ETag
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*StatOutput)
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "%s\n", out.Hash)
+			fmt.Fprintf(buf, "Size: %d\n", out.Size)
+			fmt.Fprintf(buf, "Type: %s\n", out.Type)
+			if out.MimeType != "" {
+				fmt.Fprintf(buf, "MimeType: %s\n", out.MimeType)
+			}
+			if out.Source != "" {
+				fmt.Fprintf(buf, "Source: %s\n", out.Source)
+			}
+			if out.ETag != "" {
+				fmt.Fprintf(buf, "ETag: %s\n", out.ETag)
+			}
+			return buf, nil
+		},
+	},
+	Type: StatOutput{},
+}
@@ -18,6 +18,7 @@ objects (e.g. fanout and chunking).
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"ls": LsCmd,
+		"ls":   LsCmd,
+		"stat": StatCmd,
 	},
 }
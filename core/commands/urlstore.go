@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+)
+
+var UrlStoreCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Interact with the urlstore.",
+		ShortDescription: `
+The urlstore keeps references to content served over HTTP(S) instead of
+the blocks built from it. Those blocks are fetched from the URL when they
+are read.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": urlAdd,
+	},
+}
+
+var urlAdd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add a URL via urlstore.",
+		ShortDescription: `
+'ipfs urlstore add' downloads the content at <url> and adds it to ipfs,
+storing references to the URL instead of the file's blocks. The URL and
+its ETag are recorded in a metadata object wrapping the file.
+
+The content must not change: once it does, the blocks referring to it can
+no longer be read. It is the same as 'ipfs add --from-url --nocopy', except
+that the content is downloaded by the daemon rather than sent to it.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("url", true, false, "URL to add to IPFS."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(trickleOptionName, "t", "Use trickle-dag format for dag generation.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		trickle, _, _ := req.Option(trickleOptionName).Bool()
+		url := req.Arguments()[0]
+
+		fileAdder, err := coreunix.NewAdder(req.Context(), n.Pinning, n.Blockstore, n.DAG)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		fileAdder.Trickle = trickle
+		fileAdder.NoCopy = true
+		fileAdder.Silent = true
+
+		file := files.NewWebFile(url)
+		defer file.Close()
+
+		if err := fileAdder.AddFile(file); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		nd, err := fileAdder.Finalize()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := fileAdder.PinRoot(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		k, err := nd.Key()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&coreunix.AddedObject{
+			Name: file.FileName(),
+			Hash: k.B58String(),
+		})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*coreunix.AddedObject)
+			return strings.NewReader(out.Hash + "\n"), nil
+		},
+	},
+	Type: coreunix.AddedObject{},
+}
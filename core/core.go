@@ -46,7 +46,9 @@ import (
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	exchange "github.com/ipfs/go-ipfs/exchange"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
+	reputation "github.com/ipfs/go-ipfs/exchange/bitswap/reputation"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 
@@ -224,9 +226,30 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	n.PeerHost = rhost.Wrap(host, n.Routing)
 
 	// setup exchange service
-	const alwaysSendToPeer = true // use YesManStrategy
+	strategy, err := n.bitswapStrategy()
+	if err != nil {
+		return err
+	}
 	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
-	n.Exchange = bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer)
+	limits, err := n.bitswapLimits()
+	if err != nil {
+		return err
+	}
+	bs := bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, strategy).(*bitswap.Bitswap)
+	bs.SetLimits(limits)
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	if cfg.Bitswap.PersistLedgers {
+		if err := bs.PersistLedgers(n.Repo.Datastore()); err != nil {
+			return fmt.Errorf("cannot load bitswap ledgers: %s", err)
+		}
+	}
+	if err := n.setupBitswapBanning(bs); err != nil {
+		return err
+	}
+	n.Exchange = bs
 
 	size, err := n.getCacheSize()
 	if err != nil {
@@ -245,6 +268,104 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	return nil
 }
 
+// bitswapStrategy returns the strategy of the Bitswap config section
+func (n *IpfsNode) bitswapStrategy() (decision.Strategy, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Bitswap.Strategy {
+	case "", decision.StrategyServeAll:
+		return decision.ServeAll, nil
+	case decision.StrategyReciprocal:
+		ratio := cfg.Bitswap.MaxDebtRatio
+		if ratio == 0 {
+			ratio = decision.DefaultMaxDebtRatio
+		}
+		if ratio < 0 {
+			return nil, fmt.Errorf("cannot specify negative Bitswap.MaxDebtRatio")
+		}
+		return decision.Reciprocal(ratio), nil
+	case decision.StrategyAllowlistOnly:
+		var allowed []peer.ID
+		for _, s := range cfg.Bitswap.Allowlist {
+			p, err := peer.IDB58Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer ID in Bitswap.Allowlist: %s", s)
+			}
+			allowed = append(allowed, p)
+		}
+		return decision.AllowlistOnly(allowed), nil
+	default:
+		return nil, fmt.Errorf("unknown Bitswap.Strategy: %s", cfg.Bitswap.Strategy)
+	}
+}
+
+// bitswapLimits returns the bandwidth limits of the Bitswap config section
+func (n *IpfsNode) bitswapLimits() (bitswap.Limits, error) {
+	var limits bitswap.Limits
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return limits, err
+	}
+
+	for _, l := range []struct {
+		name  string
+		value string
+		rate  *uint64
+	}{
+		{"MaxOutRate", cfg.Bitswap.MaxOutRate, &limits.Out},
+		{"MaxInRate", cfg.Bitswap.MaxInRate, &limits.In},
+		{"MaxPeerOutRate", cfg.Bitswap.MaxPeerOutRate, &limits.PeerOut},
+		{"MaxPeerInRate", cfg.Bitswap.MaxPeerInRate, &limits.PeerIn},
+	} {
+		*l.rate, err = bitswap.ParseRate(l.value)
+		if err != nil {
+			return limits, fmt.Errorf("invalid Bitswap.%s: %s", l.name, err)
+		}
+	}
+	return limits, nil
+}
+
+// setupBitswapBanning applies the ban settings of the Bitswap config section
+// to 'bs'
+func (n *IpfsNode) setupBitswapBanning(bs *bitswap.Bitswap) error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	threshold := cfg.Bitswap.BanThreshold
+	if threshold == 0 {
+		threshold = reputation.DefaultThreshold
+	}
+	if threshold > 0 {
+		return fmt.Errorf("cannot specify positive Bitswap.BanThreshold")
+	}
+	if cfg.Bitswap.DisableBanning {
+		threshold = 0
+	}
+
+	banFor := reputation.DefaultBanDuration
+	if cfg.Bitswap.BanDuration != "" {
+		banFor, err = time.ParseDuration(cfg.Bitswap.BanDuration)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Bitswap.BanDuration: %s", err)
+		}
+	}
+	bs.Reputation().SetBanning(threshold, banFor)
+
+	if cfg.Bitswap.BanFilter {
+		snet, ok := n.PeerHost.Network().(*swarm.Network)
+		if !ok {
+			return fmt.Errorf("cannot filter banned peers: not a swarm network")
+		}
+		bs.Reputation().Notify(newBanFilter(snet))
+	}
+	return nil
+}
+
 // getCacheSize returns cache life and cache size
 func (n *IpfsNode) getCacheSize() (int, error) {
 	cfg, err := n.Repo.Config()
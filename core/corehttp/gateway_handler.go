@@ -15,6 +15,7 @@ import (
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	core "github.com/ipfs/go-ipfs/core"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	"github.com/ipfs/go-ipfs/importer"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -113,6 +114,10 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 	// the hour is a hard fallback, we don't expect it to happen, but just in case
 	defer cancel()
 
+	// the blocks of a request are fetched in one session, from the peers
+	// that had its first blocks
+	ctx = exchange.WithSession(ctx)
+
 	if cn, ok := w.(http.CloseNotifier); ok {
 		clientGone := cn.CloseNotify()
 		go func() {
@@ -212,6 +217,11 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 
 	if err == nil {
 		defer dr.Close()
+		// prefer a mime type stored alongside the file over guessing it
+		// from the extension
+		if mt := dr.MimeType(); mt != "" {
+			w.Header().Set("Content-Type", mt)
+		}
 		name := gopath.Base(urlPath)
 		http.ServeContent(w, r, name, modtime, dr)
 		return
@@ -246,6 +256,10 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 			}
 			defer dr.Close()
 
+			if mt := dr.MimeType(); mt != "" {
+				w.Header().Set("Content-Type", mt)
+			}
+
 			// write to request
 			http.ServeContent(w, r, "index.html", modtime, dr)
 			break
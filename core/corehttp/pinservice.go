@@ -0,0 +1,346 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	ps "github.com/ipfs/go-ipfs/pin/pinservice"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// pinServiceOwner is the owner of the named pins of the clients of the pin
+// service, before the name of the client
+const pinServiceOwner = "pinservice:"
+
+// failedRequestTTL is how long the status of a pin that failed is kept
+var failedRequestTTL = time.Hour
+
+// PinServiceOption serves the pin service at 'path', if the config enables
+// it. Content is pinned as named pins owned by the client that requested it.
+func PinServiceOption(path string) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		if !cfg.Pinning.Service.Enabled {
+			return mux, nil
+		}
+
+		mux.Handle(path+"/", &pinServiceHandler{
+			node:     n,
+			prefix:   path + "/pins",
+			clients:  cfg.Pinning.Service.Tokens,
+			requests: make(map[string]*pinRequest),
+		})
+		return mux, nil
+	}
+}
+
+// pinRequest is a pin that is not pinned yet, or that failed
+type pinRequest struct {
+	owner  string
+	status ps.PinStatus
+	cancel func()
+}
+
+type pinServiceHandler struct {
+	node   *core.IpfsNode
+	prefix string
+
+	// names of the clients, by their token
+	clients map[string]string
+
+	mu sync.Mutex
+	// pin requests not pinned yet, by owner and name
+	requests map[string]*pinRequest
+}
+
+func requestID(owner, name string) string {
+	return owner + "/" + name
+}
+
+func (h *pinServiceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.auth(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == h.prefix:
+		switch r.Method {
+		case "GET":
+			h.ls(w, owner)
+		case "POST":
+			h.add(w, r, owner)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case strings.HasPrefix(r.URL.Path, h.prefix+"/"):
+		name := strings.TrimPrefix(r.URL.Path, h.prefix+"/")
+		switch r.Method {
+		case "GET":
+			h.status(w, owner, name)
+		case "DELETE":
+			h.rm(w, owner, name)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// auth returns the owner of the pins of the client the request comes from
+func (h *pinServiceHandler) auth(r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	client, ok := h.clients[token]
+	if token == "" || !ok {
+		return "", false
+	}
+	return pinServiceOwner + client, true
+}
+
+func (h *pinServiceHandler) add(w http.ResponseWriter, r *http.Request, owner string) {
+	var req ps.PinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	k := key.B58KeyDecode(req.Key)
+	if k == "" {
+		http.Error(w, fmt.Sprintf("invalid key %q", req.Key), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.Key
+	}
+
+	st := h.lookup(owner, req.Name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// another request for the name may have come meanwhile
+	if pr, ok := h.requests[requestID(owner, req.Name)]; ok && pr.status.Status != ps.Failed {
+		prst := pr.status
+		st = &prst
+	}
+	if st != nil && st.Status != ps.Failed {
+		if st.Key != req.Key {
+			http.Error(w, fmt.Sprintf("%s already pins %s", req.Name, st.Key), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr := &pinRequest{
+		owner: owner,
+		status: ps.PinStatus{
+			Name:   req.Name,
+			Key:    req.Key,
+			Status: ps.Queued,
+		},
+		cancel: cancel,
+	}
+	h.requests[requestID(owner, req.Name)] = pr
+	go h.pin(ctx, pr, k)
+
+	writeJSON(w, http.StatusAccepted, &pr.status)
+}
+
+// pin pins 'k' for the request 'pr'
+func (h *pinServiceHandler) pin(ctx context.Context, pr *pinRequest, k key.Key) {
+	defer pr.cancel()
+
+	n := h.node
+	defer n.Blockstore.PinLock().Unlock()
+
+	h.setStatus(pr, ps.Pinning, nil)
+	err := func() error {
+		nd, err := n.DAG.Get(ctx, k)
+		if err != nil {
+			return err
+		}
+		// fetched before pinning, so that the pinner isn't locked while
+		// the graph comes from the network
+		if err := dag.FetchGraph(ctx, nd, n.DAG); err != nil {
+			return err
+		}
+		ctx := pin.WithFetchedGraphs(ctx)
+		if err := n.Pinning.PinNamed(ctx, nd, true, pr.status.Name, pr.owner); err != nil {
+			return err
+		}
+		return n.Pinning.Flush()
+	}()
+	if err != nil {
+		log.Debugf("pin service: failed to pin %s: %s", k, err)
+		h.setStatus(pr, ps.Failed, err)
+		time.AfterFunc(failedRequestTTL, func() {
+			h.forget(pr)
+		})
+		return
+	}
+
+	// the pin is a named pin now
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := requestID(pr.owner, pr.status.Name)
+	if h.requests[id] == pr {
+		delete(h.requests, id)
+		return
+	}
+
+	// the pin was removed while it was pinned
+	if err := n.Pinning.UnpinNamed(k, pr.status.Name, pr.owner); err != nil {
+		log.Error(err)
+		return
+	}
+	if err := n.Pinning.Flush(); err != nil {
+		log.Error(err)
+	}
+}
+
+func (h *pinServiceHandler) setStatus(pr *pinRequest, status string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pr.status.Status = status
+	if err != nil {
+		pr.status.Error = err.Error()
+	}
+}
+
+// forget drops the request 'pr', unless another one replaced it
+func (h *pinServiceHandler) forget(pr *pinRequest) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := requestID(pr.owner, pr.status.Name)
+	if h.requests[id] == pr {
+		delete(h.requests, id)
+	}
+}
+
+// lookup returns the status of the pin of 'owner' named 'name', or nil if
+// there is none. It must be called without the lock held, as the pinner is
+// asked for its named pins.
+func (h *pinServiceHandler) lookup(owner, name string) *ps.PinStatus {
+	h.mu.Lock()
+	pr, ok := h.requests[requestID(owner, name)]
+	var st ps.PinStatus
+	if ok {
+		st = pr.status
+	}
+	h.mu.Unlock()
+	if ok {
+		return &st
+	}
+
+	for _, np := range h.node.Pinning.NamedPins() {
+		if np.Owner == owner && np.Name == name {
+			return &ps.PinStatus{
+				Name:   name,
+				Key:    np.Key.B58String(),
+				Status: ps.Pinned,
+			}
+		}
+	}
+	return nil
+}
+
+func (h *pinServiceHandler) status(w http.ResponseWriter, owner, name string) {
+	st := h.lookup(owner, name)
+	if st == nil {
+		http.Error(w, fmt.Sprintf("no pin named %s", name), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+type pinStatuses []*ps.PinStatus
+
+func (s pinStatuses) Len() int           { return len(s) }
+func (s pinStatuses) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s pinStatuses) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+func (h *pinServiceHandler) ls(w http.ResponseWriter, owner string) {
+	sts := pinStatuses{}
+
+	h.mu.Lock()
+	for _, pr := range h.requests {
+		if pr.owner == owner {
+			st := pr.status
+			sts = append(sts, &st)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, np := range h.node.Pinning.NamedPins() {
+		if np.Owner == owner {
+			sts = append(sts, &ps.PinStatus{
+				Name:   np.Name,
+				Key:    np.Key.B58String(),
+				Status: ps.Pinned,
+			})
+		}
+	}
+
+	sort.Sort(sts)
+	writeJSON(w, http.StatusOK, sts)
+}
+
+func (h *pinServiceHandler) rm(w http.ResponseWriter, owner, name string) {
+	h.mu.Lock()
+	pr, ok := h.requests[requestID(owner, name)]
+	if ok {
+		delete(h.requests, requestID(owner, name))
+		pr.cancel()
+	}
+	h.mu.Unlock()
+	if ok {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	n := h.node
+	defer n.Blockstore.PinLock().Unlock()
+
+	for _, np := range n.Pinning.NamedPins() {
+		if np.Owner != owner || np.Name != name {
+			continue
+		}
+		if err := n.Pinning.UnpinNamed(np.Key, name, owner); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := n.Pinning.Flush(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Error(w, fmt.Sprintf("no pin named %s", name), http.StatusNotFound)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debugf("pin service: failed to write response: %s", err)
+	}
+}
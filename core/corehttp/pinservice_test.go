@@ -0,0 +1,150 @@
+package corehttp
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	ps "github.com/ipfs/go-ipfs/pin/pinservice"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+func TestPinService(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Pinning.Service.Enabled = true
+	cfg.Pinning.Service.Tokens = map[string]string{"secret": "alice"}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, PinServiceOption("/pinservice/v0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := coreunix.Add(n, strings.NewReader("pin me"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := key.B58KeyDecode(s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := ps.NewClient(ts.URL+"/pinservice/v0", "secret")
+	if _, err := client.Add(ctx, k, "thing"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := client.Wait(ctx, "thing", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != ps.Pinned || st.Key != k.B58String() {
+		t.Fatalf("unexpected status: %#v", st)
+	}
+
+	found := false
+	for _, np := range n.Pinning.NamedPins() {
+		if np.Key == k && np.Name == "thing" && np.Owner == "pinservice:alice" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("pin service did not pin the key")
+	}
+
+	// the same pin again is fine, another key under the name is not
+	if _, err := client.Add(ctx, k, "thing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Add(ctx, "other", "thing"); err == nil {
+		t.Fatal("expected a conflict")
+	}
+
+	sts, err := client.Ls(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sts) != 1 || sts[0].Name != "thing" {
+		t.Fatalf("unexpected pins: %v", sts)
+	}
+
+	if _, err := ps.NewClient(ts.URL+"/pinservice/v0", "wrong").Ls(ctx); err == nil {
+		t.Fatal("expected a bad token to fail")
+	}
+
+	if err := client.Rm(ctx, "thing"); err != nil {
+		t.Fatal(err)
+	}
+	for _, np := range n.Pinning.NamedPins() {
+		if np.Owner == "pinservice:alice" {
+			t.Fatal("pin service did not unpin the key")
+		}
+	}
+	if _, err := client.Status(ctx, "thing"); err == nil {
+		t.Fatal("expected the pin to be gone")
+	}
+}
+
+func TestPinServiceForgetsFailedPins(t *testing.T) {
+	prev := failedRequestTTL
+	failedRequestTTL = 50 * time.Millisecond
+	defer func() { failedRequestTTL = prev }()
+
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Pinning.Service.Enabled = true
+	cfg.Pinning.Service.Tokens = map[string]string{"secret": "alice"}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, PinServiceOption("/pinservice/v0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the node is offline, so content it doesn't have can't be pinned
+	missing := key.B58KeyDecode("QmcBw6JFGxwY4Lq6SqVF8zpr8rDx8x79odHXDHfLpSeB7f")
+	client := ps.NewClient(ts.URL+"/pinservice/v0", "secret")
+	if _, err := client.Add(ctx, missing, "missing"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := client.Wait(ctx, "missing", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != ps.Failed {
+		t.Fatalf("expected the pin to fail, got %#v", st)
+	}
+
+	for {
+		if _, err := client.Status(ctx, "missing"); err != nil {
+			break
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("the failed pin was never forgotten")
+		}
+	}
+}
@@ -15,6 +15,7 @@ package corerepo
 
 import (
 	"fmt"
+	"time"
 
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 
@@ -22,9 +23,34 @@ import (
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
+	"github.com/ipfs/go-ipfs/pin"
 )
 
 func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]key.Key, error) {
+	return pinPaths(n, ctx, paths, recursive, func(ctx context.Context, nd *merkledag.Node) error {
+		return n.Pinning.Pin(ctx, nd, recursive)
+	})
+}
+
+// PinNamed pins the objects at 'paths' under 'name', on behalf of 'owner'
+func PinNamed(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool, name, owner string) ([]key.Key, error) {
+	return pinPaths(n, ctx, paths, recursive, func(ctx context.Context, nd *merkledag.Node) error {
+		return n.Pinning.PinNamed(ctx, nd, recursive, name, owner)
+	})
+}
+
+// PinExpiring pins the objects at 'paths' until 'expires'
+func PinExpiring(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool, expires time.Time) ([]key.Key, error) {
+	return pinPaths(n, ctx, paths, recursive, func(ctx context.Context, nd *merkledag.Node) error {
+		return n.Pinning.PinExpiring(ctx, nd, recursive, expires)
+	})
+}
+
+// pinPaths pins the objects at 'paths' with 'pinNode'. The graphs of recursive
+// pins are all fetched before any is pinned, so that canceling 'ctx' while
+// they are fetched leaves none pinned. The nodes fetched are counted by the
+// ProgressTracker of 'ctx', if it has one.
+func pinPaths(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool, pinNode func(context.Context, *merkledag.Node) error) ([]key.Key, error) {
 	dagnodes := make([]*merkledag.Node, 0)
 	for _, fpath := range paths {
 		dagnode, err := core.Resolve(ctx, n, path.Path(fpath))
@@ -34,6 +60,17 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		dagnodes = append(dagnodes, dagnode)
 	}
 
+	if recursive {
+		for _, dagnode := range dagnodes {
+			if err := merkledag.FetchGraph(ctx, dagnode, n.DAG); err != nil {
+				return nil, fmt.Errorf("pin: %s", err)
+			}
+		}
+
+		// the graphs are local now, and the callers hold the pin lock
+		ctx = pin.WithFetchedGraphs(ctx)
+	}
+
 	var out []key.Key
 	for _, dagnode := range dagnodes {
 		k, err := dagnode.Key()
@@ -43,7 +80,7 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
-		err = n.Pinning.Pin(ctx, dagnode, recursive)
+		err = pinNode(ctx, dagnode)
 		if err != nil {
 			return nil, fmt.Errorf("pin: %s", err)
 		}
@@ -59,6 +96,20 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 }
 
 func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]key.Key, error) {
+	return unpinPaths(n, ctx, paths, func(ctx context.Context, k key.Key) error {
+		return n.Pinning.Unpin(ctx, k, recursive)
+	})
+}
+
+// UnpinNamed removes the pins named 'name' that belong to 'owner' from the
+// objects at 'paths'
+func UnpinNamed(n *core.IpfsNode, ctx context.Context, paths []string, name, owner string) ([]key.Key, error) {
+	return unpinPaths(n, ctx, paths, func(ctx context.Context, k key.Key) error {
+		return n.Pinning.UnpinNamed(k, name, owner)
+	})
+}
+
+func unpinPaths(n *core.IpfsNode, ctx context.Context, paths []string, unpin func(context.Context, key.Key) error) ([]key.Key, error) {
 
 	var unpinned []key.Key
 	for _, p := range paths {
@@ -74,7 +125,7 @@ func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool
 
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
-		err = n.Pinning.Unpin(ctx, k, recursive)
+		err = unpin(ctx, k)
 		if err != nil {
 			return nil, err
 		}
@@ -87,3 +138,90 @@ func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool
 	}
 	return unpinned, nil
 }
+
+// PinUpdate moves the recursive pin of the object at 'from' to the object at
+// 'to', keeping the pin of 'from' if 'unpin' is false.
+func PinUpdate(n *core.IpfsNode, ctx context.Context, from, to string, unpin bool) ([]key.Key, error) {
+	var keys []key.Key
+	for _, p := range []string{from, to} {
+		p, err := path.ParsePath(p)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := core.ResolveToKey(ctx, n, p)
+		if err != nil {
+			return nil, fmt.Errorf("pin: %s", err)
+		}
+		keys = append(keys, k)
+	}
+
+	if err := n.Pinning.Update(ctx, keys[0], keys[1], unpin); err != nil {
+		return nil, fmt.Errorf("pin: %s", err)
+	}
+
+	if err := n.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RemoveExpiredPins removes the pins that expired, and returns their keys
+func RemoveExpiredPins(n *core.IpfsNode) ([]key.Key, error) {
+	defer n.Blockstore.PinLock().Unlock()
+
+	removed := n.Pinning.RemoveExpired(time.Now())
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	if err := n.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// PeriodicPinSweep removes the pins that expired every
+// Datastore.PinSweepPeriod, and runs a garbage collection after removing
+// some if Datastore.PinSweepGC is set.
+func PeriodicPinSweep(ctx context.Context, node *core.IpfsNode) error {
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Datastore.PinSweepPeriod == "" {
+		cfg.Datastore.PinSweepPeriod = "1m"
+	}
+
+	period, err := time.ParseDuration(cfg.Datastore.PinSweepPeriod)
+	if err != nil {
+		return err
+	}
+	if int64(period) == 0 {
+		// if duration is 0, it means the sweep is disabled.
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(period):
+			removed, err := RemoveExpiredPins(node)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			if len(removed) == 0 {
+				continue
+			}
+			log.Infof("removed %d expired pins", len(removed))
+
+			if cfg.Datastore.PinSweepGC {
+				if err := GarbageCollect(node, ctx); err != nil {
+					log.Error(err)
+				}
+			}
+		}
+	}
+}
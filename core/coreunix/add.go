@@ -1,6 +1,7 @@
 package coreunix
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -9,10 +10,13 @@ import (
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	urlstore "github.com/ipfs/go-ipfs/blocks/urlstore"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	"github.com/ipfs/go-ipfs/exchange/offline"
-	importer "github.com/ipfs/go-ipfs/importer"
+	balanced "github.com/ipfs/go-ipfs/importer/balanced"
 	"github.com/ipfs/go-ipfs/importer/chunk"
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+	trickle "github.com/ipfs/go-ipfs/importer/trickle"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	"github.com/ipfs/go-ipfs/pin"
 	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
@@ -59,9 +63,10 @@ func (e *ignoreFileError) Error() string {
 }
 
 type AddedObject struct {
-	Name  string
-	Hash  string `json:",omitempty"`
-	Bytes int64  `json:",omitempty"`
+	Name   string
+	Hash   string  `json:",omitempty"`
+	Bytes  int64   `json:",omitempty"`
+	Report *Report `json:",omitempty"`
 }
 
 func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCBlockstore, ds dag.DAGService) (*Adder, error) {
@@ -71,62 +76,96 @@ func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCBlockstore, ds dag.
 	}
 
 	return &Adder{
-		mr:         mr,
-		ctx:        ctx,
-		pinning:    p,
-		blockstore: bs,
-		dagService: ds,
-		Progress:   false,
-		Hidden:     true,
-		Pin:        true,
-		Trickle:    false,
-		Wrap:       false,
-		Chunker:    "",
+		mr:          mr,
+		ctx:         ctx,
+		pinning:     p,
+		blockstore:  bs,
+		dagService:  ds,
+		Progress:    false,
+		Hidden:      true,
+		Pin:         true,
+		Trickle:     false,
+		Wrap:        false,
+		Chunker:     "",
+		MaxLinks:    h.DefaultLinksPerBlock,
+		LayerRepeat: h.DefaultLayerRepeat,
 	}, nil
 
 }
 
 // Internal structure for holding the switches passed to the `add` call
 type Adder struct {
-	ctx        context.Context
-	pinning    pin.Pinner
-	blockstore bstore.GCBlockstore
-	dagService dag.DAGService
-	Out        chan interface{}
-	Progress   bool
-	Hidden     bool
-	Pin        bool
-	Trickle    bool
-	Silent     bool
-	Wrap       bool
-	Chunker    string
-	root       *dag.Node
-	mr         *mfs.Root
-	unlocker   bs.Unlocker
-	tempRoot   key.Key
+	ctx         context.Context
+	pinning     pin.Pinner
+	blockstore  bstore.GCBlockstore
+	dagService  dag.DAGService
+	Out         chan interface{}
+	Progress    bool
+	Hidden      bool
+	Pin         bool
+	Trickle     bool
+	Silent      bool
+	Wrap        bool
+	Xattrs      bool
+	DetectMime  bool
+	Sparse      bool
+	Chunker     string
+	MaxLinks    int
+	LayerRepeat int
+	Jobs        int
+	Resume      bool
+	NoCopy      bool
+	Checkpoints ds.Datastore
+	Report      *Report
+	jobs        []*importJob
+	root        *dag.Node
+	mr          *mfs.Root
+	unlocker    bs.Unlocker
+	tempRoot    key.Key
 }
 
 func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 	adder.mr = r
 }
 
-// Perform the actual add & pin locally, outputting results to reader
-func (adder Adder) add(reader io.Reader) (*dag.Node, error) {
+// Perform the actual add & pin locally, outputting results to reader.
+// The given holes of the input are stored by size only.
+// If 'cp' is not nil, stored leaves are recorded in it, and those it loaded
+// are used instead of the start of the input.
+// If 'rec' is not nil, leaves are stored as references to the input's URL.
+// If 'observe' is not nil, it is called with every node before it is stored.
+func (adder *Adder) add(reader io.Reader, holes []chunk.Hole, cp *h.Checkpoint, rec *urlstore.Recorder, observe func(*dag.Node) error) (*dag.Node, error) {
 	chnk, err := chunk.FromString(reader, adder.Chunker)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(holes) > 0 {
+		chnk = chunk.NewSparseSplitter(reader, holes, func(r io.Reader) chunk.Splitter {
+			// the chunker string was validated above
+			spl, _ := chunk.FromString(r, adder.Chunker)
+			return spl
+		})
+	}
+
+	dserv := adder.dagService
+	if rec != nil {
+		chnk = rec.Splitter(chnk)
+		dserv = dag.NewDAGService(bserv.New(rec, offline.Exchange(rec)))
+	}
+
+	dbp := h.DagBuilderParams{
+		Dagserv:     dserv,
+		Maxlinks:    adder.MaxLinks,
+		LayerRepeat: adder.LayerRepeat,
+		Checkpoint:  cp,
+		Observe:     observe,
+	}
+
 	if adder.Trickle {
-		return importer.BuildTrickleDagFromReader(
-			adder.dagService,
-			chnk,
-		)
+		return trickle.TrickleLayout(dbp.New(chnk))
 	}
-	return importer.BuildDagFromReader(
-		adder.dagService,
-		chnk,
-	)
+	return balanced.BalancedLayout(dbp.New(chnk))
 }
 
 func (adder *Adder) RootNode() (*dag.Node, error) {
@@ -179,6 +218,12 @@ func (adder *Adder) PinRoot() error {
 }
 
 func (adder *Adder) Finalize() (*dag.Node, error) {
+	if err := adder.waitJobs(); err != nil {
+		adder.Abort()
+		return nil, err
+	}
+	adder.unlock()
+
 	root := adder.mr.GetValue()
 
 	// cant just call adder.RootNode() here as we need the name for printing
@@ -212,6 +257,10 @@ func (adder *Adder) Finalize() (*dag.Node, error) {
 		return nil, err
 	}
 
+	if adder.Report != nil {
+		adder.Report.finish()
+	}
+
 	return root.GetNode()
 }
 
@@ -253,7 +302,7 @@ func Add(n *core.IpfsNode, r io.Reader) (string, error) {
 		return "", err
 	}
 
-	node, err := fileAdder.add(r)
+	node, err := fileAdder.add(r, nil, nil, nil, nil)
 	if err != nil {
 		return "", err
 	}
@@ -363,14 +412,66 @@ func (adder *Adder) addNode(node *dag.Node, path string) error {
 	return nil
 }
 
-// Add the given file while respecting the adder.
+// Add the given file while respecting the adder. With several jobs, files
+// may still be imported when it returns, so that those of the next call are
+// imported along with them. Finalize waits for them.
 func (adder *Adder) AddFile(file files.File) error {
-	adder.unlocker = adder.blockstore.PinLock()
-	defer func() {
+	if adder.unlocker == nil {
+		adder.unlocker = adder.blockstore.PinLock()
+	}
+
+	if err := adder.addFile(file); err != nil {
+		adder.Abort()
+		return err
+	}
+
+	// the pin lock may only be released once every queued file is stored
+	if len(adder.jobs) == 0 {
+		adder.unlock()
+	}
+	return nil
+}
+
+// Abort stops an add that won't be finalized, dropping the files still
+// being imported
+func (adder *Adder) Abort() {
+	adder.abortJobs()
+	adder.unlock()
+}
+
+func (adder *Adder) unlock() {
+	if adder.unlocker != nil {
 		adder.unlocker.Unlock()
-	}()
+		adder.unlocker = nil
+	}
+}
+
+// DryRun walks 'file' the way AddFile would, but only reports the names of
+// the files and directories that would be added.
+func (adder *Adder) DryRun(file files.File) error {
+	if file.IsDirectory() {
+		for {
+			child, err := file.NextFile()
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if child == nil {
+				break
+			}
 
-	return adder.addFile(file)
+			if files.IsHidden(child) && !adder.Hidden {
+				continue
+			}
+			if err := adder.DryRun(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	if adder.Out != nil {
+		adder.Out <- &AddedObject{Name: file.FileName()}
+	}
+	return nil
 }
 
 func (adder *Adder) addFile(file files.File) error {
@@ -400,6 +501,24 @@ func (adder *Adder) addFile(file files.File) error {
 	}
 
 	// case for regular file
+	if adder.Jobs > 1 {
+		queue := true
+		if isStreamed(file) {
+			file, queue, err = bufferFile(file)
+			if err != nil {
+				return err
+			}
+		}
+		if queue {
+			return adder.queueFile(file)
+		}
+
+		// the files queued before it are reported first
+		if err := adder.waitJobs(); err != nil {
+			return err
+		}
+	}
+
 	// if the progress flag was specified, wrap the file so that we can send
 	// progress updates to the client (over the output channel)
 	var reader io.Reader = file
@@ -407,7 +526,7 @@ func (adder *Adder) addFile(file files.File) error {
 		reader = &progressReader{file: file, out: adder.Out}
 	}
 
-	dagnode, err := adder.add(reader)
+	dagnode, err := adder.importFile(file, reader)
 	if err != nil {
 		return err
 	}
@@ -416,6 +535,141 @@ func (adder *Adder) addFile(file files.File) error {
 	return adder.addNode(dagnode, file.FileName())
 }
 
+// importFile chunks and stores the content of the regular file 'file', read
+// through 'reader'. It does not touch the mfs root, so it is safe to call from
+// several goroutines at once.
+func (adder *Adder) importFile(file files.File, reader io.Reader) (*dag.Node, error) {
+	// sniff the start of the content before it is chunked
+	var mimeType string
+	if adder.DetectMime {
+		br := bufio.NewReaderSize(reader, sniffLen)
+		head, err := br.Peek(sniffLen)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		mimeType = DetectMimeType(file.FileName(), head)
+		reader = br
+	}
+
+	holes, err := adder.fileHoles(file)
+	if err != nil {
+		return nil, err
+	}
+
+	url, etag, err := fileSource(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec *urlstore.Recorder
+	if adder.NoCopy {
+		rec, err = adder.recorder(file, url)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var cp *h.Checkpoint
+	if rec == nil {
+		// references are recorded by their offset in the whole input
+		cp, err = adder.checkpoint(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cp != nil {
+		if adder.Resume {
+			var skip uint64
+			reader, holes, skip, err = adder.resume(cp, reader, holes)
+			if skip > 0 {
+				log.Infof("resuming import of %s after %d bytes", file.FileName(), skip)
+			}
+		} else {
+			// leftovers of an earlier run must not be mixed with this one
+			err = cp.Clear()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var fr *FileReport
+	var observe func(*dag.Node) error
+	if adder.Report != nil {
+		fr = adder.Report.file(file.FileName())
+		observe = adder.Report.observe(fr)
+	}
+
+	dagnode, err := adder.add(reader, holes, cp, rec, observe)
+	if err != nil {
+		return nil, err
+	}
+
+	if fr != nil {
+		fr.Bytes, err = unixfs.DataSize(dagnode.Data())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cp != nil {
+		if err := cp.Clear(); err != nil {
+			return nil, err
+		}
+	}
+
+	if adder.Xattrs {
+		dagnode, err = adder.addXattrs(dagnode, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if mimeType != "" || url != "" {
+		size, err := unixfs.DataSize(dagnode.Data())
+		if err != nil {
+			return nil, err
+		}
+
+		m := &unixfs.Metadata{
+			MimeType:  mimeType,
+			Size:      size,
+			SourceURL: url,
+			ETag:      etag,
+		}
+		dagnode, err = wrapWithMetadata(adder.dagService, dagnode, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dagnode, nil
+}
+
+// fileSource returns the URL 'file' was fetched from, and its ETag, if any
+func fileSource(file files.File) (string, string, error) {
+	sf, ok := file.(files.SourceFile)
+	if !ok {
+		return "", "", nil
+	}
+	return sf.Source()
+}
+
+// recorder returns the recorder storing the leaves of 'file', fetched from
+// 'url', by reference
+func (adder *Adder) recorder(file files.File, url string) (*urlstore.Recorder, error) {
+	us, ok := adder.blockstore.(*urlstore.URLStore)
+	if !ok {
+		return nil, fmt.Errorf("blockstore can not store references")
+	}
+	if url == "" {
+		return nil, fmt.Errorf("%s was not fetched from a url, it can not be stored by reference", file.FileName())
+	}
+	return us.NewRecorder(url), nil
+}
+
 func (adder *Adder) addDir(dir files.File) error {
 	log.Infof("adding directory: %s", dir.FileName())
 
@@ -424,6 +678,26 @@ func (adder *Adder) addDir(dir files.File) error {
 		return err
 	}
 
+	if xf, ok := dir.(files.XattrFile); ok && adder.Xattrs {
+		xattrs, err := xf.Xattrs()
+		if err != nil {
+			return err
+		}
+
+		if len(xattrs) > 0 {
+			fsn, err := mfs.Lookup(adder.mr, dir.FileName())
+			if err != nil {
+				return err
+			}
+
+			for name, val := range xattrs {
+				if err := fsn.SetXattr(name, val, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
 	for {
 		file, err := dir.NextFile()
 		if err != nil && err != io.EOF {
@@ -447,9 +721,66 @@ func (adder *Adder) addDir(dir files.File) error {
 	return nil
 }
 
+// fileHoles returns the holes of 'file' if sparse files are to be preserved
+func (adder *Adder) fileHoles(file files.File) ([]chunk.Hole, error) {
+	sf, ok := file.(files.SparseFile)
+	if !adder.Sparse || !ok {
+		return nil, nil
+	}
+
+	fholes, err := sf.Holes()
+	if err != nil {
+		return nil, err
+	}
+
+	holes := make([]chunk.Hole, len(fholes))
+	for i, h := range fholes {
+		holes[i] = chunk.Hole{Offset: h.Offset, Length: h.Length}
+	}
+	return holes, nil
+}
+
+// addXattrs stores the extended attributes of 'file' in the root of its dag
+func (adder *Adder) addXattrs(nd *dag.Node, file files.File) (*dag.Node, error) {
+	xf, ok := file.(files.XattrFile)
+	if !ok {
+		return nd, nil
+	}
+
+	xattrs, err := xf.Xattrs()
+	if err != nil {
+		return nil, err
+	}
+	if len(xattrs) == 0 {
+		return nd, nil
+	}
+
+	data := nd.Data()
+	for name, val := range xattrs {
+		data, err = unixfs.SetXattr(data, name, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nnd := nd.Copy()
+	nnd.SetData(data)
+	_, err = adder.dagService.Add(nnd)
+	if err != nil {
+		return nil, err
+	}
+	return nnd, nil
+}
+
 func (adder *Adder) maybePauseForGC() error {
 	if adder.blockstore.GCRequested() {
-		err := adder.PinRoot()
+		// files still being imported are not reachable from the root yet
+		err := adder.waitJobs()
+		if err != nil {
+			return err
+		}
+
+		err = adder.PinRoot()
 		if err != nil {
 			return err
 		}
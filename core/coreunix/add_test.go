@@ -2,8 +2,14 @@ package coreunix
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
 	"testing"
 	"time"
 
@@ -15,6 +21,8 @@ import (
 	"github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/config"
 	"github.com/ipfs/go-ipfs/thirdparty/testutil"
+	"github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	"gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 )
 
@@ -160,3 +168,354 @@ func TestAddGCLive(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestAddDetectMime(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.DetectMime = true
+	adder.Wrap = true
+
+	page := "<html><body>hello</body></html>"
+	data := ioutil.NopCloser(bytes.NewBufferString(page))
+	err = adder.AddFile(files.NewReaderFile("index", "index", data, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := root.Links[0].GetNode(context.Background(), node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := unixfs.MetadataFromBytes(nd.Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.MimeType != "text/html; charset=utf-8" {
+		t.Fatalf("wrong mime type stored: '%s'", md.MimeType)
+	}
+	if md.Size != uint64(len(page)) {
+		t.Fatalf("wrong size stored: %d", md.Size)
+	}
+
+	dr, err := uio.NewDagReader(context.Background(), nd, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != page {
+		t.Fatal("read incorrect data")
+	}
+}
+
+// testTree builds a directory of files of varying sizes, with a
+// subdirectory, from the same seed every time.
+func testTree() files.File {
+	rnd := rand.New(rand.NewSource(1))
+
+	mkfiles := func(dir string, n int) []files.File {
+		var out []files.File
+		for i := 0; i < n; i++ {
+			buf := make([]byte, rnd.Intn(1024*600))
+			rnd.Read(buf)
+
+			name := fmt.Sprintf("%s/file%d", dir, i)
+			out = append(out, files.NewReaderFile(name, name, ioutil.NopCloser(bytes.NewReader(buf)), nil))
+		}
+		return out
+	}
+
+	sub := files.NewSliceFile("tree/sub", "tree/sub", mkfiles("tree/sub", 5))
+	return files.NewSliceFile("tree", "tree", append(mkfiles("tree", 20), sub))
+}
+
+func addTree(t *testing.T, jobs int) (string, []AddedObject) {
+	return runAdd(t, jobs, func(adder *Adder) error {
+		return adder.AddFile(testTree())
+	})
+}
+
+// multipartTree returns testTree as the daemon receives it, each file and
+// directory in a part of its own
+func multipartTree(t *testing.T) files.File {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	var write func(f files.File)
+	write = func(f files.File) {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf("form-data; name=\"file\"; filename=\"%s\"", url.QueryEscape(f.FileName())))
+		if f.IsDirectory() {
+			h.Set("Content-Type", "application/x-directory")
+		} else {
+			h.Set("Content-Type", "application/octet-stream")
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !f.IsDirectory() {
+			if _, err := io.Copy(pw, f); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+		for {
+			child, err := f.NextFile()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			write(child)
+		}
+	}
+	write(testTree())
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &files.MultipartFile{
+		Mediatype: "multipart/form-data",
+		Reader:    multipart.NewReader(&buf, w.Boundary()),
+	}
+}
+
+func runAdd(t *testing.T, jobs int, add func(*Adder) error) (string, []AddedObject) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(chan interface{})
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Out = out
+	adder.Progress = true
+	adder.Jobs = jobs
+
+	var outputs []AddedObject
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for o := range out {
+			outputs = append(outputs, *o.(*AddedObject))
+		}
+	}()
+
+	err = add(adder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	<-collected
+
+	k, err := root.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k.B58String(), outputs
+}
+
+// splitOutputs separates the added objects from the progress updates, and
+// returns the order files reported progress in along with their final sizes.
+func splitOutputs(outs []AddedObject) (added []AddedObject, order []string, sizes map[string]int64) {
+	sizes = make(map[string]int64)
+	for _, o := range outs {
+		if o.Hash != "" {
+			added = append(added, o)
+			continue
+		}
+
+		if len(order) == 0 || order[len(order)-1] != o.Name {
+			order = append(order, o.Name)
+		}
+		sizes[o.Name] = o.Bytes
+	}
+	return added, order, sizes
+}
+
+func TestAddParallelMatchesSerial(t *testing.T) {
+	serialRoot, serialOut := addTree(t, 1)
+	serialAdded, serialOrder, serialSizes := splitOutputs(serialOut)
+
+	for _, jobs := range []int{2, 8} {
+		root, out := addTree(t, jobs)
+		if root != serialRoot {
+			t.Fatalf("root with %d jobs is %s, serial add gave %s", jobs, root, serialRoot)
+		}
+
+		// progress updates may be coalesced, but have to come in the same
+		// order and add up to the same sizes
+		added, order, sizes := splitOutputs(out)
+		if !reflect.DeepEqual(added, serialAdded) {
+			t.Fatalf("added objects with %d jobs differ from the serial add", jobs)
+		}
+		if !reflect.DeepEqual(order, serialOrder) {
+			t.Fatalf("progress with %d jobs is out of order: %v", jobs, order)
+		}
+		if !reflect.DeepEqual(sizes, serialSizes) {
+			t.Fatalf("progress with %d jobs reports wrong sizes", jobs)
+		}
+	}
+}
+
+func TestAddParallelMultipart(t *testing.T) {
+	serialRoot, serialOut := addTree(t, 1)
+	serialAdded, serialOrder, serialSizes := splitOutputs(serialOut)
+
+	// some of the files are too large to be buffered
+	prev := maxBufferedJob
+	maxBufferedJob = 1024 * 300
+	defer func() { maxBufferedJob = prev }()
+
+	var overlapped bool
+	root, out := runAdd(t, 4, func(adder *Adder) error {
+		// as the add command does, each part is added on its own
+		mf := multipartTree(t)
+		for {
+			f, err := mf.NextFile()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := adder.AddFile(f); err != nil {
+				return err
+			}
+			if len(adder.jobs) > 1 {
+				overlapped = true
+			}
+		}
+	})
+	if root != serialRoot {
+		t.Fatalf("root of the multipart add is %s, serial add gave %s", root, serialRoot)
+	}
+	if !overlapped {
+		t.Fatal("files of the multipart add were not imported at once")
+	}
+
+	added, order, sizes := splitOutputs(out)
+	if !reflect.DeepEqual(added, serialAdded) {
+		t.Fatal("added objects of the multipart add differ from the serial add")
+	}
+	if !reflect.DeepEqual(order, serialOrder) {
+		t.Fatalf("progress of the multipart add is out of order: %v", order)
+	}
+	if !reflect.DeepEqual(sizes, serialSizes) {
+		t.Fatal("progress of the multipart add reports wrong sizes")
+	}
+}
+
+func TestAddReport(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 1024*700)
+	rnd.Read(data)
+	other := make([]byte, 1024*300)
+	rnd.Read(other)
+
+	addWithReport := func(fs ...files.File) *Report {
+		adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder.Wrap = true
+		adder.Report = NewReport(node.Blockstore)
+
+		for _, f := range fs {
+			if err := adder.AddFile(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := adder.Finalize(); err != nil {
+			t.Fatal(err)
+		}
+		return adder.Report
+	}
+	file := func(name string, data []byte) files.File {
+		return files.NewReaderFile(name, name, ioutil.NopCloser(bytes.NewReader(data)), nil)
+	}
+
+	first := addWithReport(file("a", data))
+	a := first.Files[0]
+	if a.Name != "a" || a.Bytes != uint64(len(data)) || a.Existing != 0 || a.Dedup != 0 {
+		t.Fatalf("unexpected report of a new file: %#v", a)
+	}
+	if a.Blocks < 3 || a.NewBytes <= a.Bytes {
+		t.Fatalf("expected the blocks of a new file to be counted: %#v", a)
+	}
+
+	second := addWithReport(file("c", other), file("b", data))
+	if len(second.Files) != 2 {
+		t.Fatalf("expected two files, got %d", len(second.Files))
+	}
+
+	b, c := second.Files[0], second.Files[1]
+	if b.Name != "b" || b.Existing != b.Blocks || b.NewBytes != 0 || b.Dedup != 1 {
+		t.Fatalf("a stored file should be fully deduplicated: %#v", b)
+	}
+	if c.Name != "c" || c.Existing != 0 || c.Dedup != 0 {
+		t.Fatalf("unexpected report of a new file: %#v", c)
+	}
+
+	if second.Bytes != b.Bytes+c.Bytes || second.Blocks != b.Blocks+c.Blocks ||
+		second.Existing != b.Existing || second.NewBytes != c.NewBytes {
+		t.Fatalf("totals don't add up: %#v", second)
+	}
+}
@@ -1,12 +1,20 @@
 package coreunix
 
 import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	core "github.com/ipfs/go-ipfs/core"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 )
 
+// sniffLen is the number of bytes DetectMimeType looks at
+const sniffLen = 512
+
 func AddMetadataTo(n *core.IpfsNode, skey string, m *ft.Metadata) (string, error) {
 	ukey := key.B58KeyDecode(skey)
 
@@ -15,18 +23,12 @@ func AddMetadataTo(n *core.IpfsNode, skey string, m *ft.Metadata) (string, error
 		return "", err
 	}
 
-	mdnode := new(dag.Node)
-	mdata, err := ft.BytesForMetadata(m)
+	mdnode, err := wrapWithMetadata(n.DAG, nd, m)
 	if err != nil {
 		return "", err
 	}
 
-	mdnode.SetData(mdata)
-	if err := mdnode.AddNodeLinkClean("file", nd); err != nil {
-		return "", err
-	}
-
-	nk, err := n.DAG.Add(mdnode)
+	nk, err := mdnode.Key()
 	if err != nil {
 		return "", err
 	}
@@ -44,3 +46,45 @@ func Metadata(n *core.IpfsNode, skey string) (*ft.Metadata, error) {
 
 	return ft.MetadataFromBytes(nd.Data())
 }
+
+// wrapWithMetadata creates and stores a metadata node pointing to 'nd'
+func wrapWithMetadata(ds dag.DAGService, nd *dag.Node, m *ft.Metadata) (*dag.Node, error) {
+	mdnode := new(dag.Node)
+	mdata, err := ft.BytesForMetadata(m)
+	if err != nil {
+		return nil, err
+	}
+
+	mdnode.SetData(mdata)
+	if err := mdnode.AddNodeLinkClean("file", nd); err != nil {
+		return nil, err
+	}
+
+	_, err = ds.Add(mdnode)
+	if err != nil {
+		return nil, err
+	}
+
+	return mdnode, nil
+}
+
+// DetectMimeType guesses the mime type of a file from the first bytes of its
+// content. If sniffing only yields a generic type, the extension of 'name' is
+// used as a hint instead.
+func DetectMimeType(name string, head []byte) string {
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+
+	sniffed := http.DetectContentType(head)
+	generic := sniffed == "application/octet-stream" ||
+		strings.HasPrefix(sniffed, "text/plain")
+	if !generic {
+		return sniffed
+	}
+
+	if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+		return byExt
+	}
+	return sniffed
+}
@@ -83,4 +83,28 @@ func TestMetadata(t *testing.T) {
 	if !bytes.Equal(out, data) {
 		t.Fatal("read incorrect data")
 	}
+
+	if ndr.MimeType() != m.MimeType {
+		t.Fatalf("reader reported wrong mime type: '%s'", ndr.MimeType())
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	cases := []struct {
+		name string
+		head []byte
+		exp  string
+	}{
+		{"page", []byte("<!DOCTYPE html><html><body>hi</body></html>"), "text/html; charset=utf-8"},
+		{"image.jpg", []byte("GIF89a......"), "image/gif"},
+		{"notes.txt", []byte("just some text"), "text/plain; charset=utf-8"},
+		{"style.css", []byte("body { color: red; }"), "text/css; charset=utf-8"},
+		{"blob", []byte{0, 1, 2, 3}, "application/octet-stream"},
+	}
+
+	for _, c := range cases {
+		if mt := DetectMimeType(c.name, c.head); mt != c.exp {
+			t.Errorf("%s: expected '%s', got '%s'", c.name, c.exp, mt)
+		}
+	}
 }
@@ -0,0 +1,176 @@
+package coreunix
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/ipfs/go-ipfs/commands/files"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+// importJob is a regular file being chunked and stored by a worker while the
+// adder keeps walking the files after it.
+type importJob struct {
+	file files.File
+
+	// bytes read so far, accessed atomically. notify is signaled when
+	// enough progress was made to be worth reporting.
+	bytes  int64
+	notify chan struct{}
+
+	done chan struct{}
+	node *dag.Node
+	err  error
+}
+
+// maxBufferedJob is the size up to which streamed files are read into
+// memory, to be imported while the walk goes on
+var maxBufferedJob int64 = 1 << 20
+
+// isStreamed returns whether reading past 'file' invalidates it, as is the
+// case for the parts of a multipart request. Such files can't be imported
+// while the walk goes on, unless they are buffered first.
+func isStreamed(file files.File) bool {
+	_, ok := file.(*files.MultipartFile)
+	return ok
+}
+
+// bufferedFile is a streamed file whose content, or its start, was read into
+// memory. The headers of its part stay valid once the walk moves past it.
+type bufferedFile struct {
+	*files.MultipartFile
+	reader io.Reader
+}
+
+func (f *bufferedFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+// bufferFile reads the streamed 'file' into memory, and returns whether it
+// fit within maxBufferedJob. If it didn't, the file returned reads the rest
+// of it after what was buffered.
+func bufferFile(file files.File) (files.File, bool, error) {
+	mf := file.(*files.MultipartFile)
+	buf, err := ioutil.ReadAll(io.LimitReader(mf, maxBufferedJob+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if int64(len(buf)) <= maxBufferedJob {
+		return &bufferedFile{mf, bytes.NewReader(buf)}, true, nil
+	}
+	return &bufferedFile{mf, io.MultiReader(bytes.NewReader(buf), mf)}, false, nil
+}
+
+// queueFile starts importing 'file' in the background. At most adder.Jobs
+// files are imported at once, when that many are queued the oldest one has
+// to be finished first.
+func (adder *Adder) queueFile(file files.File) error {
+	for len(adder.jobs) >= adder.Jobs {
+		if err := adder.finishJob(); err != nil {
+			return err
+		}
+	}
+
+	job := &importJob{
+		file:   file,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	adder.jobs = append(adder.jobs, job)
+
+	go func() {
+		defer close(job.done)
+
+		var reader io.Reader = file
+		if adder.Progress {
+			reader = &jobProgressReader{file: file, job: job}
+		}
+		job.node, job.err = adder.importFile(file, reader)
+	}()
+
+	return nil
+}
+
+// finishJob waits for the oldest queued file, then patches it into the root
+// and reports it. Doing this in the order the files were queued keeps the
+// output the same as that of a serial add.
+func (adder *Adder) finishJob() error {
+	job := adder.jobs[0]
+	adder.jobs = adder.jobs[1:]
+
+	reported := int64(-1)
+	report := func() {
+		b := atomic.LoadInt64(&job.bytes)
+		if adder.Progress && b != reported {
+			reported = b
+			adder.Out <- &AddedObject{
+				Name:  job.file.FileName(),
+				Bytes: b,
+			}
+		}
+	}
+
+	for running := true; running; {
+		select {
+		case <-job.notify:
+			report()
+		case <-job.done:
+			running = false
+		}
+	}
+
+	if job.err != nil {
+		adder.abortJobs()
+		return job.err
+	}
+	report()
+
+	return adder.addNode(job.node, job.file.FileName())
+}
+
+// waitJobs finishes every queued file
+func (adder *Adder) waitJobs() error {
+	for len(adder.jobs) > 0 {
+		if err := adder.finishJob(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abortJobs waits for the queued files to stop and drops their results
+func (adder *Adder) abortJobs() {
+	for _, job := range adder.jobs {
+		<-job.done
+	}
+	adder.jobs = nil
+}
+
+// jobProgressReader counts the bytes read for an importJob. Unlike
+// progressReader it does not send updates itself, as those have to be
+// reported in order.
+type jobProgressReader struct {
+	file         files.File
+	job          *importJob
+	bytes        int64
+	lastProgress int64
+}
+
+func (i *jobProgressReader) Read(p []byte) (int, error) {
+	n, err := i.file.Read(p)
+
+	i.bytes += int64(n)
+	atomic.StoreInt64(&i.job.bytes, i.bytes)
+	if i.bytes-i.lastProgress >= progressReaderIncrement || err == io.EOF {
+		i.lastProgress = i.bytes
+		select {
+		case i.job.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,140 @@
+package coreunix
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ipfs/go-ipfs/importer/chunk"
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+)
+
+// ParamsVersion is the version of the Params format written by this code.
+// Version 1 did not record the options that change the nodes.
+const ParamsVersion = 2
+
+// ParamsHash is the only hash function adds use
+const ParamsHash = "sha2-256"
+
+// Layouts of the dags built by an add
+const (
+	LayoutBalanced = "balanced"
+	LayoutTrickle  = "trickle"
+)
+
+// Params are the settings that decide the hashes produced by an add. Adding
+// the same data with the same Params always gives the same root.
+type Params struct {
+	Version      int
+	Chunker      string
+	Layout       string
+	MaxLinks     int
+	TrickleDepth int `json:",omitempty"`
+	Hash         string
+
+	// the options of an add that change its nodes
+	Wrap       bool `json:",omitempty"`
+	Sparse     bool `json:",omitempty"`
+	DetectMime bool `json:",omitempty"`
+	Xattrs     bool `json:",omitempty"`
+}
+
+// NewParams returns the Params of an add with the given settings. Zero
+// values stand for the defaults, which are filled in, and the chunker is
+// spelled out with all of its sizes.
+func NewParams(chunker string, trickle bool, maxLinks, layerRepeat int) (*Params, error) {
+	spl, err := chunk.FromString(nil, chunker)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := spl.(fmt.Stringer); ok {
+		chunker = s.String()
+	}
+
+	if maxLinks == 0 {
+		maxLinks = h.DefaultLinksPerBlock
+	}
+
+	p := &Params{
+		Version:  ParamsVersion,
+		Chunker:  chunker,
+		Layout:   LayoutBalanced,
+		MaxLinks: maxLinks,
+		Hash:     ParamsHash,
+	}
+
+	if trickle {
+		if layerRepeat == 0 {
+			layerRepeat = h.DefaultLayerRepeat
+		}
+		p.Layout = LayoutTrickle
+		p.TrickleDepth = layerRepeat
+	}
+
+	return p, p.Check()
+}
+
+// Check returns an error if these Params can't be used by this version
+func (p *Params) Check() error {
+	if p.Version < 1 || p.Version > ParamsVersion {
+		return fmt.Errorf("unsupported params version: %d", p.Version)
+	}
+	if p.Hash != ParamsHash {
+		return fmt.Errorf("unsupported hash function: %s", p.Hash)
+	}
+	if _, err := chunk.FromString(nil, p.Chunker); err != nil {
+		return err
+	}
+
+	switch p.Layout {
+	case LayoutBalanced:
+		if p.TrickleDepth != 0 {
+			return fmt.Errorf("trickle depth given for a balanced layout")
+		}
+	case LayoutTrickle:
+		if p.TrickleDepth < 1 {
+			return fmt.Errorf("trickle depth must be at least 1")
+		}
+	default:
+		return fmt.Errorf("unrecognized layout: %s", p.Layout)
+	}
+
+	if p.MaxLinks < 2 {
+		return fmt.Errorf("max links must be at least 2")
+	}
+	return nil
+}
+
+// LoadParams reads Params written by Save from the file at 'path'
+func LoadParams(path string) (*Params, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	p := new(Params)
+	if err := json.NewDecoder(fi).Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to read params from %s: %s", path, err)
+	}
+	return p, p.Check()
+}
+
+// Save writes these Params to the file at 'path'
+func (p *Params) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fi.Write(append(b, '\n')); err != nil {
+		fi.Close()
+		return err
+	}
+	return fi.Close()
+}
@@ -0,0 +1,95 @@
+package coreunix
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+)
+
+func TestParamsRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "params")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := NewParams("buzhash", true, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Wrap = true
+	p.Sparse = true
+
+	exp := &Params{
+		Version:      ParamsVersion,
+		Chunker:      "buzhash-87381-262144-393216",
+		Layout:       LayoutTrickle,
+		MaxLinks:     h.DefaultLinksPerBlock,
+		TrickleDepth: h.DefaultLayerRepeat,
+		Hash:         ParamsHash,
+		Wrap:         true,
+		Sparse:       true,
+	}
+	if !reflect.DeepEqual(p, exp) {
+		t.Fatalf("expected %#v, got %#v", exp, p)
+	}
+
+	path := filepath.Join(dir, "params.json")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadParams(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, p) {
+		t.Fatalf("expected %#v, got %#v", p, loaded)
+	}
+}
+
+func TestParamsCheck(t *testing.T) {
+	if _, err := NewParams("", false, 1, 0); err == nil {
+		t.Fatal("expected a single link per node to be rejected")
+	}
+	if _, err := NewParams("size-x", false, 0, 0); err == nil {
+		t.Fatal("expected a bad chunker to be rejected")
+	}
+
+	p, err := NewParams("", false, 16, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TrickleDepth != 0 {
+		t.Fatalf("balanced layout recorded a trickle depth of %d", p.TrickleDepth)
+	}
+
+	bad := []func(p Params) Params{
+		func(p Params) Params { p.Version = ParamsVersion + 1; return p },
+		func(p Params) Params { p.Hash = "sha3-256"; return p },
+		func(p Params) Params { p.Layout = "flat"; return p },
+		func(p Params) Params { p.TrickleDepth = 3; return p },
+		func(p Params) Params { p.Layout = LayoutTrickle; return p },
+	}
+	for i, change := range bad {
+		c := change(*p)
+		if err := c.Check(); err == nil {
+			t.Fatalf("expected change %d to be rejected", i)
+		}
+	}
+}
+
+func TestParamsVersion1(t *testing.T) {
+	p, err := NewParams("", false, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Version = 1
+	if err := p.Check(); err != nil {
+		t.Fatalf("params of version 1 were rejected: %s", err)
+	}
+}
@@ -0,0 +1,124 @@
+package coreunix
+
+import (
+	"sort"
+	"sync"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+// Report tells how much of the content of an add is already stored. Only
+// the blocks holding the content of files are counted, not those of
+// directories.
+type Report struct {
+	Files []*FileReport
+
+	// total size of the added files
+	Bytes uint64
+	// number of blocks, and how many of them were already stored
+	Blocks   int
+	Existing int
+	// size of the blocks that were neither stored yet nor seen before in
+	// this add
+	NewBytes uint64
+
+	mu    sync.Mutex
+	local bstore.Blockstore
+	seen  map[key.Key]bool
+}
+
+// FileReport is the part of a Report about a single file
+type FileReport struct {
+	Name     string
+	Bytes    uint64
+	Blocks   int
+	Existing int
+	NewBytes uint64
+
+	// share of the size of the file's blocks that takes no new space
+	Dedup float64
+
+	blockBytes uint64
+}
+
+// NewReport returns an empty report, checking for blocks in 'local'
+func NewReport(local bstore.Blockstore) *Report {
+	return &Report{
+		local: local,
+		seen:  make(map[key.Key]bool),
+	}
+}
+
+// file starts the report of the file 'name'
+func (r *Report) file(name string) *FileReport {
+	fr := &FileReport{Name: name}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files = append(r.Files, fr)
+	return fr
+}
+
+// observe returns a function counting the nodes stored for 'fr'
+func (r *Report) observe(fr *FileReport) func(*dag.Node) error {
+	return func(nd *dag.Node) error {
+		enc, err := nd.EncodeProtobuf(false)
+		if err != nil {
+			return err
+		}
+		k, err := nd.Key()
+		if err != nil {
+			return err
+		}
+		size := uint64(len(enc))
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		fr.Blocks++
+		fr.blockBytes += size
+		if r.seen[k] {
+			return nil
+		}
+		r.seen[k] = true
+
+		has, err := r.local.Has(k)
+		if err != nil {
+			return err
+		}
+		if has {
+			fr.Existing++
+			return nil
+		}
+		fr.NewBytes += size
+		return nil
+	}
+}
+
+type fileReports []*FileReport
+
+func (fr fileReports) Len() int           { return len(fr) }
+func (fr fileReports) Swap(i, j int)      { fr[i], fr[j] = fr[j], fr[i] }
+func (fr fileReports) Less(i, j int) bool { return fr[i].Name < fr[j].Name }
+
+// finish sums up the reports of the files, once all are added
+func (r *Report) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Sort(fileReports(r.Files))
+
+	r.Bytes, r.Blocks, r.Existing, r.NewBytes = 0, 0, 0, 0
+	for _, fr := range r.Files {
+		if fr.blockBytes > 0 {
+			fr.Dedup = 1 - float64(fr.NewBytes)/float64(fr.blockBytes)
+		}
+
+		r.Bytes += fr.Bytes
+		r.Blocks += fr.Blocks
+		r.Existing += fr.Existing
+		r.NewBytes += fr.NewBytes
+	}
+}
@@ -0,0 +1,84 @@
+package coreunix
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	"github.com/ipfs/go-ipfs/commands/files"
+	"github.com/ipfs/go-ipfs/importer/chunk"
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+)
+
+// checkpoint returns the checkpoint of the import of 'file', or nil if no
+// checkpoints are kept. Imports are told apart by the path and size of the
+// file, and by everything that changes how its dag is built. The content is
+// checked when resuming.
+func (adder *Adder) checkpoint(file files.File) (*h.Checkpoint, error) {
+	if adder.Checkpoints == nil {
+		return nil, nil
+	}
+	if !chunk.Resumable(adder.Chunker) {
+		if adder.Resume {
+			return nil, fmt.Errorf("adds with the %s chunker cannot be resumed", adder.Chunker)
+		}
+		return nil, nil
+	}
+
+	id := sha256.New()
+	fmt.Fprintf(id, "%s\x00%s\x00%t\x00%d\x00%d\x00%t\x00", file.FullPath(), adder.Chunker,
+		adder.Trickle, adder.MaxLinks, adder.LayerRepeat, adder.Sparse)
+
+	if sf, ok := file.(files.SizeFile); ok {
+		size, err := sf.Size()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(id, "%d\x00", size)
+	}
+	if sf, ok := file.(files.StatFile); ok && sf.Stat() != nil {
+		fmt.Fprintf(id, "%d\x00", sf.Stat().ModTime().UnixNano())
+	}
+
+	return h.NewCheckpoint(adder.Checkpoints, hex.EncodeToString(id.Sum(nil))), nil
+}
+
+// resume loads what a previous run stored of the import of 'cp', and skips
+// the input it covers, as far as it still matches. Returns the rest of the
+// input, with the holes moved to match it, and how many bytes were skipped.
+func (adder *Adder) resume(cp *h.Checkpoint, reader io.Reader, holes []chunk.Hole) (io.Reader, []chunk.Hole, uint64, error) {
+	stored, err := cp.Load(func(k key.Key) (bool, error) {
+		return adder.blockstore.Has(k)
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if stored == 0 {
+		return reader, holes, 0, nil
+	}
+
+	reader, skip, err := cp.Skip(reader)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if skip < stored {
+		log.Infof("input changed after %d of the %d bytes stored before", skip, stored)
+	}
+
+	var rest []chunk.Hole
+	for _, hl := range holes {
+		if hl.Offset+hl.Length <= int64(skip) {
+			continue
+		}
+
+		hl.Offset -= int64(skip)
+		if hl.Offset < 0 {
+			hl.Length += hl.Offset
+			hl.Offset = 0
+		}
+		rest = append(rest, hl)
+	}
+	return reader, rest, skip, nil
+}
@@ -0,0 +1,162 @@
+package coreunix
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/commands/files"
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/config"
+	"github.com/ipfs/go-ipfs/thirdparty/testutil"
+	"gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// leafSize is the size of the leaves of the default chunker
+const leafSize = 256 * 1024
+
+func resumeTestNode(t *testing.T) *core.IpfsNode {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+// resumeTestAdder returns an adder keeping checkpoints, with segments of 4
+// leaves
+func resumeTestAdder(t *testing.T, node *core.IpfsNode) *Adder {
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.MaxLinks = 4
+	adder.Checkpoints = node.Repo.Datastore()
+	adder.Resume = true
+	return adder
+}
+
+func resumeTestFile(name string, data []byte) files.File {
+	return files.NewReaderFile(name, name, ioutil.NopCloser(bytes.NewReader(data)), nil)
+}
+
+// interruptAdd stores the first 'n' bytes of 'data' as the file 'name', and
+// leaves its checkpoint behind, as an add interrupted there would
+func interruptAdd(t *testing.T, node *core.IpfsNode, name string, data []byte, n int) {
+	adder := resumeTestAdder(t, node)
+	cp, err := adder.checkpoint(resumeTestFile(name, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adder.add(bytes.NewReader(data[:n]), nil, cp, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resumeAdd resumes the add of 'data' as the file 'name'. Returns the key of
+// the file and how many bytes were skipped.
+func resumeAdd(t *testing.T, node *core.IpfsNode, name string, data []byte) (string, uint64) {
+	adder := resumeTestAdder(t, node)
+	file := resumeTestFile(name, data)
+	cp, err := adder.checkpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, holes, skip, err := adder.resume(cp, file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := adder.add(reader, holes, cp, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := nd.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k.B58String(), skip
+}
+
+// plainAdd adds 'data' without checkpoints, and returns its key
+func plainAdd(t *testing.T, data []byte) string {
+	adder, err := NewAdder(context.Background(), nil, nil, resumeTestNode(t).DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.MaxLinks = 4
+
+	nd, err := adder.add(bytes.NewReader(data), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := nd.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k.B58String()
+}
+
+func TestResumeInterruptedAdd(t *testing.T) {
+	node := resumeTestNode(t)
+
+	data := make([]byte, leafSize*12+100)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	// leaves are recorded 4 at a time, so the last 2 stored are not
+	interruptAdd(t, node, "file", data, leafSize*10+100)
+
+	k, skip := resumeAdd(t, node, "file", data)
+	if skip != leafSize*8 {
+		t.Fatalf("expected to skip %d bytes, skipped %d", leafSize*8, skip)
+	}
+	if exp := plainAdd(t, data); k != exp {
+		t.Fatalf("resumed add gave %s, expected %s", k, exp)
+	}
+
+	// an add that completes clears its checkpoint
+	adder := resumeTestAdder(t, node)
+	if err := adder.AddFile(resumeTestFile("file", data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, skip := resumeAdd(t, node, "file", data); skip != 0 {
+		t.Fatalf("completed add still skips %d bytes", skip)
+	}
+}
+
+func TestResumeChangedFile(t *testing.T) {
+	node := resumeTestNode(t)
+
+	data := make([]byte, leafSize*12+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	interruptAdd(t, node, "file", data, leafSize*10+100)
+
+	// a file of the same name, that differs in its seventh leaf
+	changed := append([]byte(nil), data...)
+	changed[leafSize*6+10]++
+
+	k, skip := resumeAdd(t, node, "file", changed)
+	if skip != leafSize*6 {
+		t.Fatalf("expected to skip the %d bytes before the change, skipped %d", leafSize*6, skip)
+	}
+	if exp := plainAdd(t, changed); k != exp {
+		t.Fatalf("resumed add of a changed file gave %s, expected %s", k, exp)
+	}
+
+	// the checkpoint now follows the changed file, a shorter file only keeps
+	// the leaves it holds
+	if _, skip := resumeAdd(t, node, "file", changed[:leafSize*3+5]); skip != leafSize*3 {
+		t.Fatalf("expected to skip %d bytes of a shorter file, skipped %d", leafSize*3, skip)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
 	notifications "github.com/ipfs/go-ipfs/exchange/bitswap/notifications"
+	reputation "github.com/ipfs/go-ipfs/exchange/bitswap/reputation"
 	wantlist "github.com/ipfs/go-ipfs/exchange/bitswap/wantlist"
 	flags "github.com/ipfs/go-ipfs/flags"
 	"github.com/ipfs/go-ipfs/thirdparty/delay"
@@ -62,10 +63,10 @@ var rebroadcastDelay = delay.Fixed(time.Second * 10)
 
 // New initializes a BitSwap instance that communicates over the provided
 // BitSwapNetwork. This function registers the returned instance as the network
-// delegate.
+// delegate. It sends blocks to the peers 'strategy' decides to serve.
 // Runs until context is cancelled.
 func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
-	bstore blockstore.Blockstore, nice bool) exchange.Interface {
+	bstore blockstore.Blockstore, strategy decision.Strategy) exchange.Interface {
 
 	// important to use provided parent context (since it may include important
 	// loggable data). It's probably not a good idea to allow bitswap to be
@@ -86,13 +87,15 @@ func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 		self:          p,
 		blockstore:    bstore,
 		notifications: notif,
-		engine:        decision.NewEngine(ctx, bstore), // TODO close the engine with Close() method
+		engine:        decision.NewEngine(ctx, bstore, strategy), // TODO close the engine with Close() method
 		network:       network,
 		findKeys:      make(chan *wantlist.Entry, sizeBatchRequestChan),
 		process:       px,
 		newBlocks:     make(chan blocks.Block, HasBlockBufferSize),
 		provideKeys:   make(chan key.Key, provideKeysBufferSize),
 		wm:            NewWantManager(ctx, network),
+		sessions:      make(map[uint64]*session),
+		reputation:    reputation.New(reputation.DefaultThreshold, reputation.DefaultBanDuration),
 	}
 	go bs.wm.Run()
 	network.SetDelegate(bs)
@@ -141,10 +144,21 @@ type Bitswap struct {
 
 	provideKeys chan key.Key
 
+	// sessions of the requests in progress, by the ID of their
+	// exchange.Session
+	sessLk   sync.Mutex
+	sessions map[uint64]*session
+
+	// reputation scores the peers that misbehave, and bans them
+	reputation *reputation.Tracker
+
 	counterLk      sync.Mutex
 	blocksRecvd    int
 	dupBlocksRecvd int
+	dataRecvd      uint64
 	dupDataRecvd   uint64
+	havesRecvd     int
+	dontHavesRecvd int
 }
 
 type blockRequest struct {
@@ -212,6 +226,9 @@ func (bs *Bitswap) WantlistForPeer(p peer.ID) []key.Key {
 // NB: Your request remains open until the context expires. To conserve
 // resources, provide a context with a reasonably short deadline (ie. not one
 // that lasts throughout the lifetime of the server)
+//
+// When the context belongs to an exchange.Session, the peers that delivered
+// the blocks of the session are asked first.
 func (bs *Bitswap) GetBlocks(ctx context.Context, keys []key.Key) (<-chan blocks.Block, error) {
 	if len(keys) == 0 {
 		out := make(chan blocks.Block)
@@ -230,6 +247,20 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []key.Key) (<-chan blocks
 		log.Event(ctx, "Bitswap.GetBlockRequest.Start", &k)
 	}
 
+	if s := bs.sessionFor(ctx); s != nil {
+		if err := bs.wantForSession(ctx, s, keys); err != nil {
+			return nil, err
+		}
+		return promise, nil
+	}
+	if err := bs.wantFromAll(ctx, keys); err != nil {
+		return nil, err
+	}
+	return promise, nil
+}
+
+// wantFromAll asks every peer for 'keys', and searches for providers
+func (bs *Bitswap) wantFromAll(ctx context.Context, keys []key.Key) error {
 	bs.wm.WantBlocks(ctx, keys)
 
 	// NB: Optimization. Assumes that providers of key[0] are likely to
@@ -241,9 +272,9 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []key.Key) (<-chan blocks
 	}
 	select {
 	case bs.findKeys <- req:
-		return promise, nil
+		return nil
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return ctx.Err()
 	}
 }
 
@@ -293,11 +324,29 @@ func (bs *Bitswap) tryPutBlock(blk blocks.Block, attempts int) error {
 }
 
 func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.BitSwapMessage) {
-	// This call records changes to wantlists, blocks received,
-	// and number of bytes transfered.
+	if bs.reputation.Banned(p) {
+		log.Debugf("ignoring message from banned %s", p)
+		return
+	}
+
+	// This call records changes to wantlists. The blocks we wanted are
+	// accounted below.
 	bs.engine.MessageReceived(p, incoming)
-	// TODO: this is bad, and could be easily abused.
-	// Should only track *useful* messages in ledger
+	bs.checkWants(p, incoming)
+
+	haves, dontHaves := incoming.Haves(), incoming.DontHaves()
+	if len(haves) > 0 || len(dontHaves) > 0 {
+		bs.counterLk.Lock()
+		bs.havesRecvd += len(haves)
+		bs.dontHavesRecvd += len(dontHaves)
+		bs.counterLk.Unlock()
+	}
+	for _, k := range haves {
+		bs.sessionsPresence(p, k, true)
+	}
+	for _, k := range dontHaves {
+		bs.sessionsPresence(p, k, false)
+	}
 
 	iblocks := incoming.Blocks()
 
@@ -307,17 +356,33 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 
 	// quickly send out cancels, reduces chances of duplicate block receives
 	var keys []key.Key
+	var wanted, kept []blocks.Block
 	for _, block := range iblocks {
+		bs.wm.inbound.Record(p, len(block.Data()))
+		bs.sessionsReceived(p, block.Key())
 		if _, found := bs.wm.wl.Contains(block.Key()); !found {
 			log.Infof("received un-asked-for %s from %s", block, p)
+			// blocks we have may just be wanted from several peers, and
+			// those we stopped wanting may have been sent before the
+			// peer got the cancel
+			if bs.wm.recentlyCancelled(block.Key()) {
+				kept = append(kept, block)
+				continue
+			}
+			if has, err := bs.blockstore.Has(block.Key()); err == nil && !has {
+				bs.misbehaved(p, reputation.UnwantedBlock)
+			}
+			// neither stored nor announced
 			continue
 		}
 		keys = append(keys, block.Key())
+		wanted = append(wanted, block)
 	}
 	bs.wm.CancelWants(keys)
+	bs.engine.BlocksReceived(p, wanted)
 
 	wg := sync.WaitGroup{}
-	for _, block := range iblocks {
+	for _, block := range append(kept, wanted...) {
 		wg.Add(1)
 		go func(b blocks.Block) {
 			defer wg.Done()
@@ -344,6 +409,7 @@ func (bs *Bitswap) updateReceiveCounters(b blocks.Block) error {
 	bs.counterLk.Lock()
 	defer bs.counterLk.Unlock()
 	bs.blocksRecvd++
+	bs.dataRecvd += uint64(len(b.Data()))
 	has, err := bs.blockstore.Has(b.Key())
 	if err != nil {
 		log.Infof("blockstore.Has error: %s", err)
@@ -369,10 +435,15 @@ func (bs *Bitswap) PeerConnected(p peer.ID) {
 func (bs *Bitswap) PeerDisconnected(p peer.ID) {
 	bs.wm.Disconnected(p)
 	bs.engine.PeerDisconnected(p)
+	bs.wm.inbound.Forget(p)
+	bs.reputation.Forget(p)
 }
 
 func (bs *Bitswap) ReceiveError(err error) {
 	log.Infof("Bitswap ReceiveError: %s", err)
+	if merr, ok := err.(*bsnet.MalformedMessageError); ok {
+		bs.misbehaved(merr.Peer, reputation.MalformedMessage)
+	}
 	// TODO log the network error
 	// TODO bubble the network error up to the parent context/error logger
 }
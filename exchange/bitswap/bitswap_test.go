@@ -2,6 +2,7 @@ package bitswap
 
 import (
 	"bytes"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -14,9 +15,13 @@ import (
 	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
 	tn "github.com/ipfs/go-ipfs/exchange/bitswap/testnet"
 	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
 	delay "github.com/ipfs/go-ipfs/thirdparty/delay"
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
 	p2ptestutil "gx/ipfs/QmVCe3SNMjkcPgnpFhZs719dheq6xE7gJwjzV7aWcUM4Ms/go-libp2p/p2p/test/util"
 )
 
@@ -375,3 +380,318 @@ func TestDoubleGet(t *testing.T) {
 		}
 	}
 }
+
+func dupBlocks(t *testing.T, inst Instance) int {
+	st, err := inst.Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.DupBlksReceived
+}
+
+func TestSessionWantsFromDeliveringPeers(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	prev := provSearchDelay.Set(time.Minute)
+	defer provSearchDelay.Set(prev)
+
+	instances := sg.Instances(3)
+	a, b, c := instances[0], instances[1], instances[2]
+	blks := bg.Blocks(4)
+	for _, blk := range blks {
+		if err := b.Exchange.HasBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Exchange.HasBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = exchange.WithSession(ctx)
+
+	// the first block of the session is asked from everyone
+	if _, err := a.Exchange.GetBlock(ctx, blks[0].Key()); err != nil {
+		t.Fatal(err)
+	}
+	// let the duplicate arrive
+	time.Sleep(time.Millisecond * 100)
+	dups := dupBlocks(t, a)
+
+	// the next ones only from the peer that delivered it
+	for _, blk := range blks[1:] {
+		if _, err := a.Exchange.GetBlock(ctx, blk.Key()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Millisecond * 100)
+	if d := dupBlocks(t, a); d != dups {
+		t.Fatalf("received %d duplicate blocks in the session", d-dups)
+	}
+}
+
+func wants(inst Instance, from peer.ID, k key.Key) bool {
+	for _, wk := range inst.Exchange.WantlistForPeer(from) {
+		if wk == k {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSessionWantsFromSessionPeersFirst(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	prev := provSearchDelay.Set(time.Second)
+	defer provSearchDelay.Set(prev)
+
+	instances := sg.Instances(3)
+	a, b, c := instances[0], instances[1], instances[2]
+	blks := []blocks.Block{
+		blocks.NewBlock(bytes.Repeat([]byte("a"), 100)),
+		bg.Next(),
+	}
+	for _, blk := range blks {
+		if err := b.Exchange.HasBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// once it sent the first block, b is over its limit for long and
+	// neither sends the next one nor tells anything of it, so only the
+	// delay makes the session ask everyone
+	b.Exchange.SetLimits(Limits{PeerOut: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = exchange.WithSession(ctx)
+
+	// the first block of the session is asked from everyone
+	if _, err := a.Exchange.GetBlock(ctx, blks[0].Key()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next ones only from the peer that delivered it, for a while
+	if _, err := a.Exchange.GetBlocks(ctx, []key.Key{blks[1].Key()}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if !wants(b, a.Peer, blks[1].Key()) {
+		t.Fatal("the peer of the session was not asked for the block")
+	}
+	if wants(c, a.Peer, blks[1].Key()) {
+		t.Fatal("a peer out of the session was asked for the block")
+	}
+
+	// then from everyone
+	time.Sleep(time.Second)
+	if !wants(c, a.Peer, blks[1].Key()) {
+		t.Fatal("the block was not asked from every peer after the session peers failed")
+	}
+}
+
+func TestSessionDontHaves(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	// only peers telling they don't have blocks make the session ask
+	// everyone in time
+	prev := provSearchDelay.Set(time.Minute)
+	defer provSearchDelay.Set(prev)
+
+	instances := sg.Instances(3)
+	a, b, c := instances[0], instances[1], instances[2]
+	blks := bg.Blocks(5)
+	if err := b.Exchange.HasBlock(blks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Exchange.HasBlock(blks[1]); err != nil {
+		t.Fatal(err)
+	}
+	for _, blk := range blks[2:] {
+		if err := b.Exchange.HasBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Exchange.HasBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = exchange.WithSession(ctx)
+
+	for _, blk := range blks[:2] {
+		if _, err := a.Exchange.GetBlock(ctx, blk.Key()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// both peers are in the session now, one is asked for the blocks and
+	// the other whether it has them
+	var ks []key.Key
+	for _, blk := range blks[2:] {
+		ks = append(ks, blk.Key())
+	}
+	out, err := a.Exchange.GetBlocks(ctx, ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ks {
+		select {
+		case <-out:
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	st, err := a.Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.DupBlksReceived != 0 {
+		t.Fatalf("received %d duplicate blocks", st.DupBlksReceived)
+	}
+	if st.DontHavesReceived == 0 {
+		t.Fatal("expected dont-haves for the block the session peer did not have")
+	}
+	if st.HavesReceived != len(ks) {
+		t.Fatalf("expected %d haves, got %d", len(ks), st.HavesReceived)
+	}
+}
+
+func TestBanPeerSendingUnwantedBlocks(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := sg.Instances(2)
+	a, b := instances[0], instances[1]
+	a.Exchange.Reputation().SetBanning(-2.5, time.Hour)
+
+	ctx := context.Background()
+	blks := bg.Blocks(3)
+	for _, blk := range blks {
+		msg := bsmsg.New(false)
+		msg.AddBlock(blk)
+		a.Exchange.ReceiveMessage(ctx, b.Peer, msg)
+	}
+	if !a.Exchange.Reputation().Banned(b.Peer) {
+		t.Fatal("peer sending unwanted blocks not banned")
+	}
+	for _, blk := range blks {
+		if has, _ := a.Blockstore().Has(blk.Key()); has {
+			t.Fatal("unwanted block was stored")
+		}
+	}
+	if n := a.Exchange.Reputation().Peer(b.Peer).UnwantedBlocks; n != 3 {
+		t.Fatalf("expected 3 unwanted blocks, got %d", n)
+	}
+
+	// the messages of banned peers are ignored
+	msg := bsmsg.New(false)
+	msg.AddEntry(bg.Next().Key(), 1)
+	a.Exchange.ReceiveMessage(ctx, b.Peer, msg)
+	if wl := a.Exchange.WantlistForPeer(b.Peer); len(wl) != 0 {
+		t.Fatalf("wants of a banned peer recorded: %v", wl)
+	}
+}
+
+func TestNoBanForBlocksOfCancelledWants(t *testing.T) {
+	prev := cancelGrace
+	cancelGrace = time.Second
+	defer func() { cancelGrace = prev }()
+
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := sg.Instances(2)
+	a, b := instances[0], instances[1]
+	a.Exchange.Reputation().SetBanning(-2.5, time.Hour)
+
+	blks := bg.Blocks(6)
+	var keys []key.Key
+	for _, blk := range blks {
+		keys = append(keys, blk.Key())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := a.Exchange.GetBlocks(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	a.Exchange.CancelWants(keys)
+	for len(a.Exchange.GetWantlist()) > 0 {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// blocks already sent when the wants were cancelled still arrive
+	deliver := func(blks []blocks.Block) {
+		for _, blk := range blks {
+			msg := bsmsg.New(false)
+			msg.AddBlock(blk)
+			a.Exchange.ReceiveMessage(context.Background(), b.Peer, msg)
+		}
+	}
+	deliver(blks[:3])
+	if n := a.Exchange.Reputation().Peer(b.Peer).UnwantedBlocks; n != 0 {
+		t.Fatalf("blocks of cancelled wants counted as unwanted: %d", n)
+	}
+
+	time.Sleep(cancelGrace + time.Millisecond*100)
+	deliver(blks[3:])
+	if !a.Exchange.Reputation().Banned(b.Peer) {
+		t.Fatal("peer sending blocks long after the cancel not banned")
+	}
+}
+
+func TestBanPeerMisbehaving(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	prev := MaxPeerWants
+	MaxPeerWants = 2
+	defer func() { MaxPeerWants = prev }()
+
+	instances := sg.Instances(3)
+	a, flooding, malformed := instances[0], instances[1], instances[2]
+	a.Exchange.Reputation().SetBanning(-35, time.Hour)
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		if a.Exchange.Reputation().Banned(flooding.Peer) {
+			t.Fatal("peer banned too early")
+		}
+		msg := bsmsg.New(false)
+		for _, blk := range bg.Blocks(3) {
+			msg.AddEntry(blk.Key(), 1)
+		}
+		a.Exchange.ReceiveMessage(ctx, flooding.Peer, msg)
+	}
+	if !a.Exchange.Reputation().Banned(flooding.Peer) {
+		t.Fatal("peer flooding wants not banned")
+	}
+
+	a.Exchange.ReceiveError(&bsnet.MalformedMessageError{Peer: malformed.Peer, Err: errors.New("garbage")})
+	if a.Exchange.Reputation().Banned(malformed.Peer) {
+		t.Fatal("peer banned too early")
+	}
+	a.Exchange.ReceiveError(&bsnet.MalformedMessageError{Peer: malformed.Peer, Err: errors.New("garbage")})
+	if !a.Exchange.Reputation().Banned(malformed.Peer) {
+		t.Fatal("peer sending malformed messages not banned")
+	}
+}
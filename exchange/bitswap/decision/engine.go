@@ -7,6 +7,8 @@ import (
 
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	limiter "github.com/ipfs/go-ipfs/exchange/bitswap/limiter"
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
 	wl "github.com/ipfs/go-ipfs/exchange/bitswap/wantlist"
 	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
@@ -58,9 +60,15 @@ type Envelope struct {
 	// Peer is the intended recipient
 	Peer peer.ID
 
-	// Block is the payload
+	// Block is the payload, nil when the envelope only tells which blocks
+	// we have
 	Block blocks.Block
 
+	// Haves and DontHaves answer the wants of the peer that asked whether we
+	// have blocks
+	Haves     []key.Key
+	DontHaves []key.Key
+
 	// A callback to notify the decision queue that the task is complete
 	Sent func()
 }
@@ -84,18 +92,32 @@ type Engine struct {
 
 	bs bstore.Blockstore
 
+	// strategy decides which peers are sent blocks, and which first
+	strategy Strategy
+
+	// outbound limits the bandwidth of the blocks sent
+	outbound *limiter.Limiter
+
 	lock sync.Mutex // protects the fields immediatly below
 	// ledgerMap lists Ledgers by their Partner key.
 	ledgerMap map[peer.ID]*ledger
+	// presences are the haves and dont-haves to send, by peer. They are
+	// cheap, so they are sent before the blocks of the peerRequestQueue.
+	presences map[peer.ID]*Envelope
 
 	ticker *time.Ticker
 }
 
-func NewEngine(ctx context.Context, bs bstore.Blockstore) *Engine {
+// NewEngine returns an engine that sends the blocks of 'bs' to the peers
+// 'strategy' decides to serve
+func NewEngine(ctx context.Context, bs bstore.Blockstore, strategy Strategy) *Engine {
 	e := &Engine{
 		ledgerMap:        make(map[peer.ID]*ledger),
+		presences:        make(map[peer.ID]*Envelope),
 		bs:               bs,
-		peerRequestQueue: newPRQ(),
+		strategy:         strategy,
+		outbound:         limiter.New(0, 0),
+		peerRequestQueue: newPRQ(strategy),
 		outbox:           make(chan (<-chan *Envelope), outboxChanBuffer),
 		workSignal:       make(chan struct{}, 1),
 		ticker:           time.NewTicker(time.Millisecond * 100),
@@ -104,6 +126,11 @@ func NewEngine(ctx context.Context, bs bstore.Blockstore) *Engine {
 	return e
 }
 
+// Outbound returns the limiter of the bandwidth used to send blocks
+func (e *Engine) Outbound() *limiter.Limiter {
+	return e.outbound
+}
+
 func (e *Engine) WantlistForPeer(p peer.ID) (out []wl.Entry) {
 	e.lock.Lock()
 	partner, ok := e.ledgerMap[p]
@@ -139,17 +166,43 @@ func (e *Engine) taskWorker(ctx context.Context) {
 // context is cancelled before the next Envelope can be created.
 func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 	for {
+		if env := e.nextPresences(); env != nil {
+			return env, nil
+		}
+
+		// the total limit holds up every peer alike
+		if d := e.outbound.TotalDelay(); d > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(d):
+			}
+			continue
+		}
+
 		nextTask := e.peerRequestQueue.Pop()
-		for nextTask == nil {
+		if nextTask == nil {
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
 			case <-e.workSignal:
-				nextTask = e.peerRequestQueue.Pop()
 			case <-e.ticker.C:
 				e.peerRequestQueue.thawRound()
-				nextTask = e.peerRequestQueue.Pop()
 			}
+			continue
+		}
+
+		// the peer may have used up what the strategy allows since
+		if !e.shouldServe(nextTask.Target) {
+			nextTask.Done()
+			continue
+		}
+
+		// peers over their own limit are served again once they are within
+		// it, the others meanwhile
+		if d := e.outbound.PeerDelay(nextTask.Target); d > 0 {
+			e.peerRequestQueue.throttle(nextTask, time.Now().Add(d))
+			continue
 		}
 
 		// with a task in hand, we're ready to prepare the envelope...
@@ -162,6 +215,9 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 			continue
 		}
 
+		// the block goes out now, the limits hold back those after it
+		e.outbound.Record(nextTask.Target, len(block.Data()))
+
 		return &Envelope{
 			Peer:  nextTask.Target,
 			Block: block,
@@ -178,11 +234,85 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 	}
 }
 
+// shouldServe tells whether the strategy serves 'p' now, and records that
+// its wants were refused if not
+func (e *Engine) shouldServe(p peer.ID) bool {
+	l := e.findOrCreate(p)
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	if !e.strategy.ShouldServe(l.Receipt()) {
+		l.refused = true
+		return false
+	}
+	return true
+}
+
+// nextPresences returns an envelope of the haves and dont-haves to send to a
+// peer, if there are any
+func (e *Engine) nextPresences() *Envelope {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for p, env := range e.presences {
+		delete(e.presences, p)
+		return env
+	}
+	return nil
+}
+
+func (e *Engine) addPresences(p peer.ID, haves, dontHaves []key.Key) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	env, ok := e.presences[p]
+	if !ok {
+		env = &Envelope{Peer: p, Sent: func() {}}
+		e.presences[p] = env
+	}
+	env.Haves = append(env.Haves, haves...)
+	env.DontHaves = append(env.DontHaves, dontHaves...)
+}
+
 // Outbox returns a channel of one-time use Envelope channels.
 func (e *Engine) Outbox() <-chan (<-chan *Envelope) {
 	return e.outbox
 }
 
+// LedgerForPeer returns the receipt of the ledger of 'p', empty if we have
+// not exchanged with it
+func (e *Engine) LedgerForPeer(p peer.ID) Receipt {
+	e.lock.Lock()
+	l, ok := e.ledgerMap[p]
+	e.lock.Unlock()
+	if !ok {
+		return Receipt{Peer: p}
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.Receipt()
+}
+
+// Ledgers returns the receipts of the ledgers of all peers
+func (e *Engine) Ledgers() []Receipt {
+	var out []Receipt
+	for _, l := range e.ledgers() {
+		l.lk.Lock()
+		out = append(out, l.Receipt())
+		l.lk.Unlock()
+	}
+	return out
+}
+
+// ledgers returns the ledgers of all peers
+func (e *Engine) ledgers() []*ledger {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	out := make([]*ledger, 0, len(e.ledgerMap))
+	for _, l := range e.ledgerMap {
+		out = append(out, l)
+	}
+	return out
+}
+
 // Returns a slice of Peers with whom the local node has active sessions
 func (e *Engine) Peers() []peer.ID {
 	e.lock.Lock()
@@ -198,7 +328,7 @@ func (e *Engine) Peers() []peer.ID {
 // MessageReceived performs book-keeping. Returns error if passed invalid
 // arguments.
 func (e *Engine) MessageReceived(p peer.ID, m bsmsg.BitSwapMessage) error {
-	if len(m.Wantlist()) == 0 && len(m.Blocks()) == 0 {
+	if m.Empty() {
 		log.Debugf("received empty message from %s", p)
 	}
 
@@ -216,26 +346,110 @@ func (e *Engine) MessageReceived(p peer.ID, m bsmsg.BitSwapMessage) error {
 		l.wantList = wl.New()
 	}
 
+	defer e.peerRequestQueue.UpdateReceipt(l.Receipt())
+
+	serve := e.strategy.ShouldServe(l.Receipt())
+	if serve && e.serveRefused(p, l) {
+		newWorkExists = true
+	}
+
+	var haves, dontHaves []key.Key
 	for _, entry := range m.Wantlist() {
 		if entry.Cancel {
 			log.Debugf("cancel %s", entry.Key)
 			l.CancelWant(entry.Key)
 			e.peerRequestQueue.Remove(entry.Key, p)
-		} else {
-			log.Debugf("wants %s - %d", entry.Key, entry.Priority)
-			l.Wants(entry.Key, entry.Priority)
-			if exists, err := e.bs.Has(entry.Key); err == nil && exists {
-				e.peerRequestQueue.Push(entry.Entry, p)
-				newWorkExists = true
+			continue
+		}
+
+		if !serve {
+			if entry.WantType == bsmsg.WantBlock {
+				l.Wants(entry.Key, entry.Priority)
+				l.refused = true
 			}
+			continue
 		}
+
+		exists, err := e.bs.Has(entry.Key)
+		if err != nil {
+			log.Debugf("blockstore.Has error: %s", err)
+			exists = false
+		}
+		if entry.WantType == bsmsg.WantHave {
+			// answered right away, and not kept in the wantlist
+			log.Debugf("wants to know if we have %s", entry.Key)
+			switch {
+			case exists:
+				haves = append(haves, entry.Key)
+			case entry.SendDontHave:
+				dontHaves = append(dontHaves, entry.Key)
+			}
+			continue
+		}
+
+		log.Debugf("wants %s - %d", entry.Key, entry.Priority)
+		l.Wants(entry.Key, entry.Priority)
+		switch {
+		case exists:
+			e.peerRequestQueue.Push(entry.Entry, p)
+			newWorkExists = true
+		case entry.SendDontHave:
+			dontHaves = append(dontHaves, entry.Key)
+		}
+	}
+	if len(haves) > 0 || len(dontHaves) > 0 {
+		e.addPresences(p, haves, dontHaves)
+		newWorkExists = true
 	}
+	return nil
+}
 
-	for _, block := range m.Blocks() {
+// BlocksReceived credits the ledger of 'p' with the blocks it sent us. Only
+// blocks we wanted must be passed, so that a peer can't settle its debt by
+// pushing blocks nobody asked for.
+func (e *Engine) BlocksReceived(p peer.ID, blks []blocks.Block) {
+	if len(blks) == 0 {
+		return
+	}
+
+	newWorkExists := false
+	defer func() {
+		if newWorkExists {
+			e.signalNewWork()
+		}
+	}()
+
+	l := e.findOrCreate(p)
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	for _, block := range blks {
 		log.Debugf("got block %s %d bytes", block.Key(), len(block.Data()))
 		l.ReceivedBytes(len(block.Data()))
 	}
-	return nil
+	e.peerRequestQueue.UpdateReceipt(l.Receipt())
+
+	if e.strategy.ShouldServe(l.Receipt()) && e.serveRefused(p, l) {
+		newWorkExists = true
+	}
+}
+
+// serveRefused queues what 'p' wanted while the strategy refused to serve
+// it, and reports whether any work was queued. l.lk must be held.
+func (e *Engine) serveRefused(p peer.ID, l *ledger) bool {
+	if !l.refused {
+		return false
+	}
+	l.refused = false
+
+	work := false
+	for _, entry := range l.wantList.Entries() {
+		if exists, err := e.bs.Has(entry.Key); err == nil && exists {
+			e.peerRequestQueue.Push(entry, p)
+			work = true
+		}
+	}
+	return work
 }
 
 func (e *Engine) addBlock(block blocks.Block) {
@@ -244,8 +458,12 @@ func (e *Engine) addBlock(block blocks.Block) {
 	for _, l := range e.ledgerMap {
 		l.lk.Lock()
 		if entry, ok := l.WantListContains(block.Key()); ok {
-			e.peerRequestQueue.Push(entry, l.Partner)
-			work = true
+			if e.strategy.ShouldServe(l.Receipt()) {
+				e.peerRequestQueue.Push(entry, l.Partner)
+				work = true
+			} else {
+				l.refused = true
+			}
 		}
 		l.lk.Unlock()
 	}
@@ -269,18 +487,28 @@ func (e *Engine) AddBlock(block blocks.Block) {
 // send happen atomically
 
 func (e *Engine) MessageSent(p peer.ID, m bsmsg.BitSwapMessage) error {
-	l := e.findOrCreate(p)
 	for _, block := range m.Blocks() {
-		l.SentBytes(len(block.Data()))
-		l.wantList.Remove(block.Key())
-		e.peerRequestQueue.Remove(block.Key(), p)
+		e.BlockSent(p, block)
 	}
-
 	return nil
 }
 
+// BlockSent records that 'block' was sent to 'p'
+func (e *Engine) BlockSent(p peer.ID, block blocks.Block) {
+	l := e.findOrCreate(p)
+	l.lk.Lock()
+	l.SentBytes(len(block.Data()))
+	l.wantList.Remove(block.Key())
+	r := l.Receipt()
+	l.lk.Unlock()
+
+	e.peerRequestQueue.Remove(block.Key(), p)
+	e.peerRequestQueue.UpdateReceipt(r)
+}
+
 func (e *Engine) PeerDisconnected(p peer.ID) {
 	// TODO: release ledger
+	e.outbound.Forget(p)
 }
 
 func (e *Engine) numBytesSentTo(p peer.ID) uint64 {
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
@@ -28,7 +29,7 @@ func newEngine(ctx context.Context, idStr string) peerAndEngine {
 		Peer: peer.ID(idStr),
 		//Strategy: New(true),
 		Engine: NewEngine(ctx,
-			blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))),
+			blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore())), ServeAll),
 	}
 }
 
@@ -47,6 +48,7 @@ func TestConsistentAccounting(t *testing.T) {
 
 		sender.Engine.MessageSent(receiver.Peer, m)
 		receiver.Engine.MessageReceived(sender.Peer, m)
+		receiver.Engine.BlocksReceived(sender.Peer, m.Blocks())
 	}
 
 	// Ensure sender records the change
@@ -102,7 +104,7 @@ func peerIsPartner(p peer.ID, e *Engine) bool {
 
 func TestOutboxClosedWhenEngineClosed(t *testing.T) {
 	t.SkipNow() // TODO implement *Engine.Close
-	e := NewEngine(context.Background(), blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore())))
+	e := NewEngine(context.Background(), blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore())), ServeAll)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -150,7 +152,7 @@ func TestPartnerWantsThenCancels(t *testing.T) {
 			cancels := testcase[1]
 			keeps := stringsComplement(set, cancels)
 
-			e := NewEngine(context.Background(), bs)
+			e := NewEngine(context.Background(), bs, ServeAll)
 			partner := testutil.RandPeerIDFatal(t)
 
 			partnerWants(e, set, partner)
@@ -207,3 +209,90 @@ func stringsComplement(set, subset []string) []string {
 	}
 	return complement
 }
+
+func TestPartnerWantsHaves(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	have := blocks.NewBlock([]byte("have"))
+	if err := bs.Put(have); err != nil {
+		t.Fatal(err)
+	}
+	missing := blocks.NewBlock([]byte("missing"))
+	missingQuiet := blocks.NewBlock([]byte("missing quietly"))
+
+	e := NewEngine(ctx, bs, ServeAll)
+	partner := testutil.RandPeerIDFatal(t)
+
+	m := message.New(false)
+	m.AddWant(have.Key(), 3, message.WantHave, true)
+	m.AddWant(missing.Key(), 2, message.WantBlock, true)
+	m.AddWant(missingQuiet.Key(), 1, message.WantHave, false)
+	e.MessageReceived(partner, m)
+
+	envelope := <-<-e.Outbox()
+	if envelope.Peer != partner {
+		t.Fatal("envelope for the wrong peer")
+	}
+	if envelope.Block != nil {
+		t.Fatal("a want-have should not be answered with the block")
+	}
+	if len(envelope.Haves) != 1 || envelope.Haves[0] != have.Key() {
+		t.Fatalf("expected a have for %s, got %v", have.Key(), envelope.Haves)
+	}
+	if len(envelope.DontHaves) != 1 || envelope.DontHaves[0] != missing.Key() {
+		t.Fatalf("expected a dont-have for %s, got %v", missing.Key(), envelope.DontHaves)
+	}
+
+	// want-haves are not kept in the wantlist, unlike want-blocks
+	wl := e.WantlistForPeer(partner)
+	if len(wl) != 1 || wl[0].Key != missing.Key() {
+		t.Fatalf("expected only %s in the wantlist, got %v", missing.Key(), wl)
+	}
+}
+
+func TestPeerOverOutboundLimitDoesNotHoldUpOthers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	var keys []string
+	for _, s := range []string{"first", "second", "third"} {
+		if err := bs.Put(blocks.NewBlock([]byte(s))); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, s)
+	}
+
+	e := NewEngine(ctx, bs, ServeAll)
+	e.Outbound().SetLimits(0, 1)
+	greedy := testutil.RandPeerIDFatal(t)
+	other := testutil.RandPeerIDFatal(t)
+	partnerWants(e, keys[:2], greedy)
+
+	envelope := <-<-e.Outbox()
+	if envelope.Peer != greedy {
+		t.Fatal("first block not sent right away")
+	}
+	envelope.Sent()
+
+	// the greedy peer is now over its limit for seconds
+	next := <-e.Outbox()
+	partnerWants(e, keys[2:], other)
+	select {
+	case envelope = <-next:
+	case <-time.After(time.Second):
+		t.Fatal("other peer held up by the peer over its limit")
+	}
+	if envelope.Peer != other {
+		t.Fatal("peer over its limit sent another block")
+	}
+	envelope.Sent()
+
+	select {
+	case <-<-e.Outbox():
+		t.Fatal("peer over its limit sent another block")
+	case <-time.After(time.Millisecond * 300):
+	}
+}
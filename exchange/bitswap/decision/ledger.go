@@ -39,6 +39,13 @@ type ledger struct {
 	// exchangeCount is the number of exchanges with this peer
 	exchangeCount uint64
 
+	// blocksSent and blocksRecv count the blocks exchanged with this peer
+	blocksSent uint64
+	blocksRecv uint64
+
+	// savedCount is the exchangeCount when the ledger was last saved
+	savedCount uint64
+
 	// wantList is a (bounded, small) set of keys that Partner desires.
 	wantList *wl.Wantlist
 
@@ -46,6 +53,10 @@ type ledger struct {
 	// to a given peer
 	sentToPeer map[key.Key]time.Time
 
+	// refused is whether wants of Partner were not served, as the strategy
+	// refused to
+	refused bool
+
 	lk sync.Mutex
 }
 
@@ -58,16 +69,44 @@ func (dr *debtRatio) Value() float64 {
 	return float64(dr.BytesSent) / float64(dr.BytesRecv+1)
 }
 
+// Receipt summarizes the ledger of a peer
+type Receipt struct {
+	Peer         peer.ID
+	Value        float64 // the debt ratio of the peer
+	Sent         uint64  // bytes sent to the peer
+	Recv         uint64  // bytes received from the peer
+	Exchanged    uint64  // number of exchanges with the peer
+	BlocksSent   uint64  // blocks sent to the peer
+	BlocksRecv   uint64  // blocks received from the peer
+	WantlistSize int     // number of blocks the peer wants
+}
+
+func (l *ledger) Receipt() Receipt {
+	return Receipt{
+		Peer:      l.Partner,
+		Value:     l.Accounting.Value(),
+		Sent:      l.Accounting.BytesSent,
+		Recv:      l.Accounting.BytesRecv,
+		Exchanged: l.exchangeCount,
+
+		BlocksSent:   l.blocksSent,
+		BlocksRecv:   l.blocksRecv,
+		WantlistSize: l.wantList.Len(),
+	}
+}
+
 func (l *ledger) SentBytes(n int) {
 	l.exchangeCount++
 	l.lastExchange = time.Now()
 	l.Accounting.BytesSent += uint64(n)
+	l.blocksSent++
 }
 
 func (l *ledger) ReceivedBytes(n int) {
 	l.exchangeCount++
 	l.lastExchange = time.Now()
 	l.Accounting.BytesRecv += uint64(n)
+	l.blocksRecv++
 }
 
 func (l *ledger) Wants(k key.Key, priority int) {
@@ -0,0 +1,106 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dsq "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/query"
+)
+
+// Ledgers can be kept in the datastore, so that what peers owe us outlives
+// restarts, with an entry for each peer:
+//
+//	/local/bitswap/ledgers/<peer>     the accounting of the ledger, as JSON
+//
+// Wantlists are not kept, peers send them again when they reconnect.
+var ledgersPrefix = ds.NewKey("/local/bitswap/ledgers")
+
+// ledgerRecord is the accounting of a ledger, as kept in the datastore
+type ledgerRecord struct {
+	BytesSent  uint64
+	BytesRecv  uint64
+	BlocksSent uint64
+	BlocksRecv uint64
+	Exchanged  uint64
+}
+
+func ledgerKey(p peer.ID) ds.Key {
+	return ledgersPrefix.ChildString(p.Pretty())
+}
+
+// LoadLedgers restores the ledgers kept in 'd'
+func (e *Engine) LoadLedgers(d ds.Datastore) error {
+	res, err := d.Query(dsq.Query{Prefix: ledgersPrefix.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Process().Close()
+
+	for r := range res.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		p, err := peer.IDB58Decode(ds.NewKey(r.Key).BaseNamespace())
+		if err != nil {
+			return fmt.Errorf("invalid ledger entry %s", r.Key)
+		}
+		b, ok := r.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("ledger entry %s was not bytes", r.Key)
+		}
+		var rec ledgerRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return fmt.Errorf("invalid ledger entry %s: %s", r.Key, err)
+		}
+
+		l := e.findOrCreate(p)
+		l.lk.Lock()
+		fresh := l.exchangeCount == 0
+		l.Accounting.BytesSent += rec.BytesSent
+		l.Accounting.BytesRecv += rec.BytesRecv
+		l.blocksSent += rec.BlocksSent
+		l.blocksRecv += rec.BlocksRecv
+		l.exchangeCount += rec.Exchanged
+		if fresh {
+			// nothing new to save
+			l.savedCount = l.exchangeCount
+		}
+		l.lk.Unlock()
+	}
+	return nil
+}
+
+// SaveLedgers keeps the ledgers that changed since last saved in 'd'
+func (e *Engine) SaveLedgers(d ds.Datastore) error {
+	for _, l := range e.ledgers() {
+		l.lk.Lock()
+		if l.exchangeCount == l.savedCount {
+			l.lk.Unlock()
+			continue
+		}
+		rec := ledgerRecord{
+			BytesSent:  l.Accounting.BytesSent,
+			BytesRecv:  l.Accounting.BytesRecv,
+			BlocksSent: l.blocksSent,
+			BlocksRecv: l.blocksRecv,
+			Exchanged:  l.exchangeCount,
+		}
+		count := l.exchangeCount
+		l.lk.Unlock()
+
+		b, err := json.Marshal(&rec)
+		if err != nil {
+			return err
+		}
+		if err := d.Put(ledgerKey(l.Partner), b); err != nil {
+			return err
+		}
+
+		l.lk.Lock()
+		l.savedCount = count
+		l.lk.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package decision
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	message "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dssync "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/sync"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+func TestLedgerReceipts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := NewEngine(ctx, blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore())), ServeAll)
+	partner := testutil.RandPeerIDFatal(t)
+
+	if r := e.LedgerForPeer(partner); r.Peer != partner || r.Exchanged != 0 {
+		t.Fatalf("expected an empty ledger for an unknown peer, got %+v", r)
+	}
+	if len(e.Ledgers()) != 0 {
+		t.Fatal("expected no ledgers yet")
+	}
+
+	m := message.New(false)
+	m.AddBlock(blocks.NewBlock([]byte("received")))
+	m.AddEntry(blocks.NewBlock([]byte("wanted")).Key(), 1)
+	e.MessageReceived(partner, m)
+	e.BlocksReceived(partner, m.Blocks())
+	e.BlockSent(partner, blocks.NewBlock([]byte("sent block")))
+
+	r := e.LedgerForPeer(partner)
+	if r.Sent != 10 || r.Recv != 8 {
+		t.Fatalf("expected 10 bytes sent and 8 received, got %d and %d", r.Sent, r.Recv)
+	}
+	if r.BlocksSent != 1 || r.BlocksRecv != 1 || r.Exchanged != 2 {
+		t.Fatalf("expected a block each way, got %+v", r)
+	}
+	if r.WantlistSize != 1 {
+		t.Fatalf("expected a wantlist of 1, got %d", r.WantlistSize)
+	}
+
+	ls := e.Ledgers()
+	if len(ls) != 1 || ls[0] != r {
+		t.Fatalf("expected the ledger of %s, got %v", partner, ls)
+	}
+}
+
+func TestSaveAndLoadLedgers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	partner := testutil.RandPeerIDFatal(t)
+
+	e := NewEngine(ctx, bs, ServeAll)
+	m := message.New(false)
+	m.AddBlock(blocks.NewBlock([]byte("received")))
+	e.MessageReceived(partner, m)
+	e.BlocksReceived(partner, m.Blocks())
+	e.BlockSent(partner, blocks.NewBlock([]byte("sent block")))
+	before := e.LedgerForPeer(partner)
+	if err := e.SaveLedgers(d); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewEngine(ctx, bs, ServeAll)
+	if err := restarted.LoadLedgers(d); err != nil {
+		t.Fatal(err)
+	}
+	after := restarted.LedgerForPeer(partner)
+	if after.Sent != before.Sent || after.Recv != before.Recv ||
+		after.BlocksSent != before.BlocksSent || after.BlocksRecv != before.BlocksRecv ||
+		after.Exchanged != before.Exchanged {
+		t.Fatalf("expected the restored ledger %+v, got %+v", before, after)
+	}
+
+	// unchanged ledgers are not saved again
+	if err := d.Delete(ledgerKey(partner)); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.SaveLedgers(d); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := d.Has(ledgerKey(partner)); has {
+		t.Fatal("unchanged ledger saved again")
+	}
+
+	restarted.BlockSent(partner, blocks.NewBlock([]byte("another")))
+	if err := restarted.SaveLedgers(d); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := d.Has(ledgerKey(partner)); !has {
+		t.Fatal("changed ledger not saved")
+	}
+}
@@ -20,21 +20,22 @@ type peerRequestQueue interface {
 	// may exist. These trashed elements should not contribute to the count.
 }
 
-func newPRQ() *prq {
-	return &prq{
-		taskMap:  make(map[string]*peerRequestTask),
-		partners: make(map[peer.ID]*activePartner),
-		frozen:   make(map[peer.ID]*activePartner),
-		pQueue:   pq.New(partnerCompare),
+func newPRQ(strategy Strategy) *prq {
+	tl := &prq{
+		taskMap:   make(map[string]*peerRequestTask),
+		partners:  make(map[peer.ID]*activePartner),
+		frozen:    make(map[peer.ID]*activePartner),
+		throttled: make(map[peer.ID]*activePartner),
+		strategy:  strategy,
 	}
+	tl.pQueue = pq.New(tl.partnerCompare)
+	return tl
 }
 
 // verify interface implementation
 var _ peerRequestQueue = &prq{}
 
-// TODO: at some point, the strategy needs to plug in here
-// to help decide how to sort tasks (on add) and how to select
-// tasks (on getnext). For now, we are assuming a dumb/nice strategy.
+// prq serves the partners the strategy prefers first, and the others in turn.
 type prq struct {
 	lock     sync.Mutex
 	pQueue   pq.PQ
@@ -42,22 +43,46 @@ type prq struct {
 	partners map[peer.ID]*activePartner
 
 	frozen map[peer.ID]*activePartner
+
+	// partners over their bandwidth limit, served again once thawed after
+	// their throttledUntil
+	throttled map[peer.ID]*activePartner
+
+	strategy Strategy
 }
 
-// Push currently adds a new peerRequestTask to the end of the list
-func (tl *prq) Push(entry wantlist.Entry, to peer.ID) {
-	tl.lock.Lock()
-	defer tl.lock.Unlock()
-	partner, ok := tl.partners[to]
+// partner returns the activePartner of 'p', adding it if needed. NB: the
+// lock must be held.
+func (tl *prq) partner(p peer.ID) *activePartner {
+	partner, ok := tl.partners[p]
 	if !ok {
 		partner = newActivePartner()
+		partner.receipt.Peer = p
 		tl.pQueue.Push(partner)
-		tl.partners[to] = partner
+		tl.partners[p] = partner
 	}
+	return partner
+}
+
+// UpdateReceipt records the receipt of the ledger of a partner, which the
+// strategy orders partners by
+func (tl *prq) UpdateReceipt(r Receipt) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	partner := tl.partner(r.Peer)
+	partner.receipt = r
+	tl.pQueue.Update(partner.Index())
+}
+
+// Push currently adds a new peerRequestTask to the end of the list
+func (tl *prq) Push(entry wantlist.Entry, to peer.ID) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	partner := tl.partner(to)
 
 	partner.activelk.Lock()
 	defer partner.activelk.Unlock()
-	_, ok = partner.activeBlocks[entry.Key]
+	_, ok := partner.activeBlocks[entry.Key]
 	if ok {
 		return
 	}
@@ -96,7 +121,7 @@ func (tl *prq) Pop() *peerRequestTask {
 	partner := tl.pQueue.Pop().(*activePartner)
 
 	var out *peerRequestTask
-	for partner.taskQueue.Len() > 0 && partner.freezeVal == 0 {
+	for partner.taskQueue.Len() > 0 && partner.freezeVal == 0 && !partner.throttled {
 		out = partner.taskQueue.Pop().(*peerRequestTask)
 		delete(tl.taskMap, out.Key())
 		if out.trash {
@@ -141,6 +166,24 @@ func (tl *prq) Remove(k key.Key, p peer.ID) {
 	tl.lock.Unlock()
 }
 
+// throttle puts the popped 'task' back, and holds off its partner until
+// 'until', while other partners are served
+func (tl *prq) throttle(task *peerRequestTask, until time.Time) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	partner := tl.partners[task.Target]
+	partner.TaskDone(task.Entry.Key)
+
+	partner.taskQueue.Push(task)
+	tl.taskMap[task.Key()] = task
+	partner.requests++
+
+	partner.throttled = true
+	partner.throttledUntil = until
+	tl.throttled[task.Target] = partner
+	tl.pQueue.Update(partner.index)
+}
+
 func (tl *prq) fullThaw() {
 	tl.lock.Lock()
 	defer tl.lock.Unlock()
@@ -156,6 +199,16 @@ func (tl *prq) thawRound() {
 	tl.lock.Lock()
 	defer tl.lock.Unlock()
 
+	now := time.Now()
+	for id, partner := range tl.throttled {
+		if now.Before(partner.throttledUntil) {
+			continue
+		}
+		partner.throttled = false
+		delete(tl.throttled, id)
+		tl.pQueue.Update(partner.index)
+	}
+
 	for id, partner := range tl.frozen {
 		partner.freezeVal -= (partner.freezeVal + 1) / 2
 		if partner.freezeVal <= 0 {
@@ -238,8 +291,15 @@ type activePartner struct {
 
 	freezeVal int
 
+	// whether this peer is over its bandwidth limit, and until when
+	throttled      bool
+	throttledUntil time.Time
+
 	// priority queue of tasks belonging to this peer
 	taskQueue pq.PQ
+
+	// the last receipt of the ledger of this peer
+	receipt Receipt
 }
 
 func newActivePartner() *activePartner {
@@ -251,7 +311,7 @@ func newActivePartner() *activePartner {
 
 // partnerCompare implements pq.ElemComparator
 // returns true if peer 'a' has higher priority than peer 'b'
-func partnerCompare(a, b pq.Elem) bool {
+func (tl *prq) partnerCompare(a, b pq.Elem) bool {
 	pa := a.(*activePartner)
 	pb := b.(*activePartner)
 
@@ -264,6 +324,10 @@ func partnerCompare(a, b pq.Elem) bool {
 		return true
 	}
 
+	if pa.throttled != pb.throttled {
+		return pb.throttled
+	}
+
 	if pa.freezeVal > pb.freezeVal {
 		return false
 	}
@@ -271,6 +335,13 @@ func partnerCompare(a, b pq.Elem) bool {
 		return true
 	}
 
+	if tl.strategy.Prefer(pa.receipt, pb.receipt) {
+		return true
+	}
+	if tl.strategy.Prefer(pb.receipt, pa.receipt) {
+		return false
+	}
+
 	if pa.active == pb.active {
 		// sorting by taskQueue.Len() aids in cleaning out trash entries faster
 		// if we sorted instead by requests, one peer could potentially build up
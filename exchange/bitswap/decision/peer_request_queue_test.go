@@ -13,7 +13,7 @@ import (
 )
 
 func TestPushPop(t *testing.T) {
-	prq := newPRQ()
+	prq := newPRQ(ServeAll)
 	partner := testutil.RandPeerIDFatal(t)
 	alphabet := strings.Split("abcdefghijklmnopqrstuvwxyz", "")
 	vowels := strings.Split("aeiou", "")
@@ -69,7 +69,7 @@ func TestPushPop(t *testing.T) {
 
 // This test checks that peers wont starve out other peers
 func TestPeerRepeats(t *testing.T) {
-	prq := newPRQ()
+	prq := newPRQ(ServeAll)
 	a := testutil.RandPeerIDFatal(t)
 	b := testutil.RandPeerIDFatal(t)
 	c := testutil.RandPeerIDFatal(t)
@@ -0,0 +1,82 @@
+package decision
+
+import (
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+)
+
+// Names of the strategies, as in the Bitswap.Strategy config setting
+const (
+	StrategyServeAll      = "serve-all"
+	StrategyReciprocal    = "reciprocal"
+	StrategyAllowlistOnly = "allowlist-only"
+)
+
+// Strategy decides which peers the engine sends blocks to, and which of them
+// it serves first, from their ledgers.
+type Strategy interface {
+	// ShouldServe tells whether to send blocks to the peer of 'r'
+	ShouldServe(r Receipt) bool
+
+	// Prefer tells whether to serve the peer of 'a' before the one of 'b',
+	// when both want blocks. Peers neither is preferred to are served in
+	// turn.
+	Prefer(a, b Receipt) bool
+}
+
+// ServeAll sends blocks to every peer, in turn.
+var ServeAll Strategy = serveAll{}
+
+type serveAll struct{}
+
+func (serveAll) ShouldServe(r Receipt) bool {
+	return true
+}
+
+func (serveAll) Prefer(a, b Receipt) bool {
+	return false
+}
+
+// DefaultMaxDebtRatio is the debt ratio above which the Reciprocal strategy
+// stops sending blocks to peers, by default
+const DefaultMaxDebtRatio = 1.0
+
+// Reciprocal only sends blocks to peers while their debt ratio, the bytes
+// sent to them over the bytes of wanted blocks received from them, is at most
+// 'maxDebtRatio', and serves the peers with the lowest one first.
+func Reciprocal(maxDebtRatio float64) Strategy {
+	return reciprocal{maxDebtRatio: maxDebtRatio}
+}
+
+type reciprocal struct {
+	maxDebtRatio float64
+}
+
+func (s reciprocal) ShouldServe(r Receipt) bool {
+	return r.Value <= s.maxDebtRatio
+}
+
+func (s reciprocal) Prefer(a, b Receipt) bool {
+	return a.Value < b.Value
+}
+
+// AllowlistOnly only sends blocks to the peers of 'allowed', in turn.
+func AllowlistOnly(allowed []peer.ID) Strategy {
+	s := allowlistOnly{allowed: make(map[peer.ID]struct{})}
+	for _, p := range allowed {
+		s.allowed[p] = struct{}{}
+	}
+	return s
+}
+
+type allowlistOnly struct {
+	allowed map[peer.ID]struct{}
+}
+
+func (s allowlistOnly) ShouldServe(r Receipt) bool {
+	_, ok := s.allowed[r.Peer]
+	return ok
+}
+
+func (s allowlistOnly) Prefer(a, b Receipt) bool {
+	return false
+}
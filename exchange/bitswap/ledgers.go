@@ -0,0 +1,50 @@
+package bitswap
+
+import (
+	"time"
+
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
+	process "gx/ipfs/QmQopLATEYMNg7dVqZRNDfeE2S1yKy8zrRh5xnYiuqeZBn/goprocess"
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+)
+
+// ledgerSaveInterval is how often persisted ledgers are saved
+var ledgerSaveInterval = time.Minute
+
+// LedgerForPeer returns the ledger of 'p'
+func (bs *Bitswap) LedgerForPeer(p peer.ID) decision.Receipt {
+	return bs.engine.LedgerForPeer(p)
+}
+
+// Ledgers returns the ledgers of all the peers we exchanged with
+func (bs *Bitswap) Ledgers() []decision.Receipt {
+	return bs.engine.Ledgers()
+}
+
+// PersistLedgers restores the ledgers kept in 'd', and keeps them there every
+// so often and when bitswap closes, so that they outlive restarts
+func (bs *Bitswap) PersistLedgers(d ds.Datastore) error {
+	if err := bs.engine.LoadLedgers(d); err != nil {
+		return err
+	}
+
+	bs.process.Go(func(px process.Process) {
+		tick := time.NewTicker(ledgerSaveInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+			case <-px.Closing():
+				if err := bs.engine.SaveLedgers(d); err != nil {
+					log.Warningf("cannot save ledgers: %s", err)
+				}
+				return
+			}
+			if err := bs.engine.SaveLedgers(d); err != nil {
+				log.Warningf("cannot save ledgers: %s", err)
+			}
+		}
+	})
+	return nil
+}
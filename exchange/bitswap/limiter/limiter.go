@@ -0,0 +1,196 @@
+// package limiter caps the bandwidth bitswap uses, in total and per peer.
+package limiter
+
+import (
+	"sync"
+	"time"
+
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+)
+
+// rateWeight is how much the bytes of the last second weigh in the measured
+// rate, against those of the seconds before
+const rateWeight = 0.5
+
+// Limiter limits one direction of bitswap traffic, in bytes per second, both
+// in total and for each peer. A limit of 0 means no limit.
+//
+// Bytes are recorded as they are reserved, which may take the bucket of a
+// limit below zero; the bytes that follow then wait until it refills. A
+// block larger than a limit is thus still sent, only later.
+type Limiter struct {
+	lk sync.Mutex
+
+	total   uint64
+	perPeer uint64
+
+	all   bucket
+	peers map[peer.ID]*bucket
+
+	rate meter
+
+	// now is replaced by tests
+	now func() time.Time
+}
+
+// New returns a limiter of 'total' bytes per second overall and 'perPeer'
+// bytes per second for each peer
+func New(total, perPeer uint64) *Limiter {
+	l := &Limiter{
+		total:   total,
+		perPeer: perPeer,
+		peers:   make(map[peer.ID]*bucket),
+		now:     time.Now,
+	}
+	now := l.now()
+	l.all.last = now
+	l.rate.last = now
+	return l
+}
+
+// SetLimits changes the limits, in bytes per second
+func (l *Limiter) SetLimits(total, perPeer uint64) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	l.total = total
+	l.perPeer = perPeer
+}
+
+// Limits returns the total and per peer limits, in bytes per second
+func (l *Limiter) Limits() (total, perPeer uint64) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.total, l.perPeer
+}
+
+// Record accounts 'n' bytes exchanged with 'p'
+func (l *Limiter) Record(p peer.ID, n int) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	now := l.now()
+	l.rate.add(now, n)
+	l.all.take(now, l.total, n)
+	l.peer(p, now).take(now, l.perPeer, n)
+}
+
+// Reserve accounts 'n' bytes to exchange with 'p', and returns how long to
+// wait before exchanging them to stay within the limits
+func (l *Limiter) Reserve(p peer.ID, n int) time.Duration {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	now := l.now()
+	l.rate.add(now, n)
+	d := l.all.take(now, l.total, n)
+	if pd := l.peer(p, now).take(now, l.perPeer, n); pd > d {
+		d = pd
+	}
+	return d
+}
+
+// Delay returns how long to wait before exchanging more bytes with 'p', for
+// those recorded so far to be within the limits
+func (l *Limiter) Delay(p peer.ID) time.Duration {
+	return l.Reserve(p, 0)
+}
+
+// TotalDelay returns how long to wait before exchanging more bytes with any
+// peer, for those recorded so far to be within the total limit
+func (l *Limiter) TotalDelay() time.Duration {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.all.take(l.now(), l.total, 0)
+}
+
+// PeerDelay returns how long to wait before exchanging more bytes with 'p',
+// for those recorded so far to be within the limit of each peer
+func (l *Limiter) PeerDelay(p peer.ID) time.Duration {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	now := l.now()
+	return l.peer(p, now).take(now, l.perPeer, 0)
+}
+
+// Rate returns the bytes exchanged per second over the last seconds
+func (l *Limiter) Rate() uint64 {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return uint64(l.rate.get(l.now()))
+}
+
+// Forget drops the accounting of 'p', when it disconnects
+func (l *Limiter) Forget(p peer.ID) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	delete(l.peers, p)
+}
+
+func (l *Limiter) peer(p peer.ID, now time.Time) *bucket {
+	b, ok := l.peers[p]
+	if !ok {
+		b = &bucket{last: now}
+		l.peers[p] = b
+	}
+	return b
+}
+
+// bucket is a token bucket holding up to a second of bytes. Its tokens go
+// negative when more is taken than it holds.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take takes 'n' bytes at 'limit' bytes per second, and returns how long it
+// takes the bucket to get back to zero
+func (b *bucket) take(now time.Time, limit uint64, n int) time.Duration {
+	elapsed := now.Sub(b.last)
+	b.last = now
+	if limit == 0 {
+		b.tokens = 0
+		return 0
+	}
+
+	rate := float64(limit)
+	b.tokens += elapsed.Seconds() * rate
+	if b.tokens > rate {
+		b.tokens = rate
+	}
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// meter measures a rate in bytes per second, as a moving average over
+// seconds
+type meter struct {
+	rate    float64
+	pending float64
+	last    time.Time
+}
+
+func (m *meter) add(now time.Time, n int) {
+	m.tick(now)
+	m.pending += float64(n)
+}
+
+func (m *meter) get(now time.Time) float64 {
+	m.tick(now)
+	return m.rate
+}
+
+// tick folds the bytes of the seconds past into the rate
+func (m *meter) tick(now time.Time) {
+	for i := 0; now.Sub(m.last) >= time.Second; i++ {
+		if i == 60 {
+			// idle long enough for the rate to be gone
+			m.rate = 0
+			m.last = now
+			break
+		}
+		m.rate = rateWeight*m.pending + (1-rateWeight)*m.rate
+		m.pending = 0
+		m.last = m.last.Add(time.Second)
+	}
+}
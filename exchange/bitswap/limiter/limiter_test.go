@@ -0,0 +1,145 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+)
+
+// clock is a fake time for the limiter
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestLimiter(total, perPeer uint64) (*Limiter, *clock) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := New(total, perPeer)
+	l.now = c.now
+	l.all.last = c.t
+	l.rate.last = c.t
+	return l, c
+}
+
+func TestUnlimited(t *testing.T) {
+	l, _ := newTestLimiter(0, 0)
+	for i := 0; i < 10; i++ {
+		if d := l.Reserve(peer.ID("a"), 1<<20); d != 0 {
+			t.Fatalf("unlimited reservation delayed by %s", d)
+		}
+	}
+}
+
+func TestTotalLimit(t *testing.T) {
+	l, c := newTestLimiter(1000, 0)
+
+	// the bucket starts empty
+	if d := l.Reserve(peer.ID("a"), 500); d != 500*time.Millisecond {
+		t.Fatalf("expected a delay of 500ms, got %s", d)
+	}
+	// other peers share the total
+	if d := l.Reserve(peer.ID("b"), 500); d != time.Second {
+		t.Fatalf("expected a delay of 1s, got %s", d)
+	}
+	if d := l.Delay(peer.ID("c")); d != time.Second {
+		t.Fatalf("expected a delay of 1s, got %s", d)
+	}
+
+	c.add(time.Second)
+	if d := l.Delay(peer.ID("a")); d != 0 {
+		t.Fatalf("expected no delay after refilling, got %s", d)
+	}
+
+	// the bucket holds at most a second of bytes
+	c.add(time.Minute)
+	if d := l.Reserve(peer.ID("a"), 1000); d != 0 {
+		t.Fatalf("expected no delay, got %s", d)
+	}
+	if d := l.Reserve(peer.ID("a"), 1000); d != time.Second {
+		t.Fatalf("expected a delay of 1s, got %s", d)
+	}
+}
+
+func TestPeerLimit(t *testing.T) {
+	l, _ := newTestLimiter(0, 1000)
+
+	if d := l.Reserve(peer.ID("a"), 2000); d != 2*time.Second {
+		t.Fatalf("expected a delay of 2s, got %s", d)
+	}
+	if d := l.Reserve(peer.ID("b"), 1000); d != time.Second {
+		t.Fatalf("other peers should have their own limit, got a delay of %s", d)
+	}
+
+	l.Forget(peer.ID("a"))
+	if d := l.Delay(peer.ID("a")); d != 0 {
+		t.Fatalf("forgotten peer still delayed by %s", d)
+	}
+}
+
+func TestSeparateDelays(t *testing.T) {
+	l, c := newTestLimiter(1000, 100)
+
+	l.Record(peer.ID("a"), 200)
+	if d := l.PeerDelay(peer.ID("a")); d != 2*time.Second {
+		t.Fatalf("expected a peer delay of 2s, got %s", d)
+	}
+	if d := l.PeerDelay(peer.ID("b")); d != 0 {
+		t.Fatalf("other peers should only wait for their own limit, got %s", d)
+	}
+	if d := l.TotalDelay(); d != 200*time.Millisecond {
+		t.Fatalf("expected a total delay of 200ms, got %s", d)
+	}
+
+	c.add(time.Second)
+	if d := l.TotalDelay(); d != 0 {
+		t.Fatalf("expected no total delay after refilling, got %s", d)
+	}
+	if d := l.PeerDelay(peer.ID("a")); d != time.Second {
+		t.Fatalf("expected a peer delay of 1s, got %s", d)
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	l, _ := newTestLimiter(0, 0)
+	l.SetLimits(100, 10)
+	total, perPeer := l.Limits()
+	if total != 100 || perPeer != 10 {
+		t.Fatalf("expected limits 100 and 10, got %d and %d", total, perPeer)
+	}
+	if d := l.Reserve(peer.ID("a"), 100); d != 10*time.Second {
+		t.Fatalf("expected the peer limit to delay by 10s, got %s", d)
+	}
+
+	l.SetLimits(0, 0)
+	if d := l.Reserve(peer.ID("a"), 100); d != 0 {
+		t.Fatalf("expected no delay once unlimited, got %s", d)
+	}
+}
+
+func TestRate(t *testing.T) {
+	l, c := newTestLimiter(0, 0)
+	if r := l.Rate(); r != 0 {
+		t.Fatalf("expected no rate yet, got %d", r)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.Record(peer.ID("a"), 1000)
+		c.add(time.Second)
+	}
+	if r := l.Rate(); r < 990 || r > 1000 {
+		t.Fatalf("expected a rate close to 1000, got %d", r)
+	}
+
+	c.add(time.Hour)
+	if r := l.Rate(); r != 0 {
+		t.Fatalf("expected the rate to be gone after idling, got %d", r)
+	}
+}
@@ -0,0 +1,49 @@
+package bitswap
+
+import (
+	"fmt"
+	"strings"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+)
+
+// Limits are the bandwidth limits of bitswap, in bytes per second. A limit of
+// 0 means no limit.
+type Limits struct {
+	// Out limits the blocks sent, in total and to each peer
+	Out     uint64
+	PeerOut uint64
+
+	// In limits the blocks received, in total and from each peer. As peers
+	// send the blocks we want, it is enforced by holding back our wants.
+	In     uint64
+	PeerIn uint64
+}
+
+// Limits returns the current bandwidth limits
+func (bs *Bitswap) Limits() Limits {
+	var l Limits
+	l.Out, l.PeerOut = bs.engine.Outbound().Limits()
+	l.In, l.PeerIn = bs.wm.Inbound().Limits()
+	return l
+}
+
+// SetLimits changes the bandwidth limits, taking effect right away
+func (bs *Bitswap) SetLimits(l Limits) {
+	bs.engine.Outbound().SetLimits(l.Out, l.PeerOut)
+	bs.wm.Inbound().SetLimits(l.In, l.PeerIn)
+}
+
+// ParseRate parses a limit like "1MB" or "1MB/s" into bytes per second. An
+// empty string, "0" or "unlimited" is no limit.
+func ParseRate(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "unlimited" {
+		return 0, nil
+	}
+	r, err := humanize.ParseBytes(strings.TrimSuffix(s, "/s"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %s", s, err)
+	}
+	return r, nil
+}
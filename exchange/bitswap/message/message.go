@@ -27,6 +27,11 @@ type BitSwapMessage interface {
 	// AddEntry adds an entry to the Wantlist.
 	AddEntry(key key.Key, priority int)
 
+	// AddWant adds an entry of the given type to the Wantlist. With
+	// sendDontHave, the peer is asked to tell when it does not have the
+	// block.
+	AddWant(key key.Key, priority int, wantType WantType, sendDontHave bool)
+
 	Cancel(key key.Key)
 
 	Empty() bool
@@ -35,6 +40,18 @@ type BitSwapMessage interface {
 	Full() bool
 
 	AddBlock(blocks.Block)
+
+	// Haves returns the keys of the blocks the sender has, in answer to
+	// wants
+	Haves() []key.Key
+
+	// DontHaves returns the keys of the blocks the sender does not have, in
+	// answer to wants
+	DontHaves() []key.Key
+
+	AddHave(key.Key)
+	AddDontHave(key.Key)
+
 	Exportable
 
 	Loggable() map[string]interface{}
@@ -43,12 +60,18 @@ type BitSwapMessage interface {
 type Exportable interface {
 	ToProto() *pb.Message
 	ToNet(w io.Writer) error
+
+	// ToProtoV0 and ToNetV0 export the message for peers that only speak
+	// the first version of the protocol, which has no haves and dont-haves.
+	ToProtoV0() *pb.Message
+	ToNetV0(w io.Writer) error
 }
 
 type impl struct {
-	full     bool
-	wantlist map[key.Key]Entry
-	blocks   map[key.Key]blocks.Block
+	full      bool
+	wantlist  map[key.Key]Entry
+	blocks    map[key.Key]blocks.Block
+	presences map[key.Key]pb.Message_BlockPresenceType
 }
 
 func New(full bool) BitSwapMessage {
@@ -57,26 +80,45 @@ func New(full bool) BitSwapMessage {
 
 func newMsg(full bool) *impl {
 	return &impl{
-		blocks:   make(map[key.Key]blocks.Block),
-		wantlist: make(map[key.Key]Entry),
-		full:     full,
+		blocks:    make(map[key.Key]blocks.Block),
+		wantlist:  make(map[key.Key]Entry),
+		presences: make(map[key.Key]pb.Message_BlockPresenceType),
+		full:      full,
 	}
 }
 
+// WantType tells whether an entry wants a block, or only to know whether
+// the peer has it
+type WantType int
+
+const (
+	WantBlock WantType = iota
+	WantHave
+)
+
 type Entry struct {
 	wantlist.Entry
-	Cancel bool
+	Cancel       bool
+	WantType     WantType
+	SendDontHave bool
 }
 
 func newMessageFromProto(pbm pb.Message) BitSwapMessage {
 	m := newMsg(pbm.GetWantlist().GetFull())
 	for _, e := range pbm.GetWantlist().GetEntries() {
-		m.addEntry(key.Key(e.GetBlock()), int(e.GetPriority()), e.GetCancel())
+		wt := WantBlock
+		if e.GetWantType() == pb.Message_Wantlist_Have {
+			wt = WantHave
+		}
+		m.addEntry(key.Key(e.GetBlock()), int(e.GetPriority()), e.GetCancel(), wt, e.GetSendDontHave())
 	}
 	for _, d := range pbm.GetBlocks() {
 		b := blocks.NewBlock(d)
 		m.AddBlock(b)
 	}
+	for _, bp := range pbm.GetBlockPresences() {
+		m.presences[key.Key(bp.GetBlock())] = bp.GetType()
+	}
 	return m
 }
 
@@ -85,7 +127,7 @@ func (m *impl) Full() bool {
 }
 
 func (m *impl) Empty() bool {
-	return len(m.blocks) == 0 && len(m.wantlist) == 0
+	return len(m.blocks) == 0 && len(m.wantlist) == 0 && len(m.presences) == 0
 }
 
 func (m *impl) Wantlist() []Entry {
@@ -104,35 +146,75 @@ func (m *impl) Blocks() []blocks.Block {
 	return bs
 }
 
+func (m *impl) Haves() []key.Key {
+	return m.presencesOf(pb.Message_Have)
+}
+
+func (m *impl) DontHaves() []key.Key {
+	return m.presencesOf(pb.Message_DontHave)
+}
+
+func (m *impl) presencesOf(t pb.Message_BlockPresenceType) []key.Key {
+	var out []key.Key
+	for k, pt := range m.presences {
+		if pt == t {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
 func (m *impl) Cancel(k key.Key) {
 	delete(m.wantlist, k)
-	m.addEntry(k, 0, true)
+	m.addEntry(k, 0, true, WantBlock, false)
 }
 
 func (m *impl) AddEntry(k key.Key, priority int) {
-	m.addEntry(k, priority, false)
+	m.addEntry(k, priority, false, WantBlock, false)
+}
+
+func (m *impl) AddWant(k key.Key, priority int, wantType WantType, sendDontHave bool) {
+	m.addEntry(k, priority, false, wantType, sendDontHave)
 }
 
-func (m *impl) addEntry(k key.Key, priority int, cancel bool) {
+func (m *impl) addEntry(k key.Key, priority int, cancel bool, wantType WantType, sendDontHave bool) {
 	e, exists := m.wantlist[k]
-	if exists {
-		e.Priority = priority
-		e.Cancel = cancel
-	} else {
-		m.wantlist[k] = Entry{
-			Entry: wantlist.Entry{
-				Key:      k,
-				Priority: priority,
-			},
-			Cancel: cancel,
-		}
+	if exists && !cancel && !e.Cancel && e.WantType == WantBlock && wantType == WantHave {
+		// the block is wanted already, which tells whether the peer has it
+		e.SendDontHave = e.SendDontHave || sendDontHave
+		m.wantlist[k] = e
+		return
+	}
+	m.wantlist[k] = Entry{
+		Entry: wantlist.Entry{
+			Key:      k,
+			Priority: priority,
+		},
+		Cancel:       cancel,
+		WantType:     wantType,
+		SendDontHave: sendDontHave,
 	}
 }
 
 func (m *impl) AddBlock(b blocks.Block) {
+	delete(m.presences, b.Key())
 	m.blocks[b.Key()] = b
 }
 
+func (m *impl) AddHave(k key.Key) {
+	if _, ok := m.blocks[k]; ok {
+		return
+	}
+	m.presences[k] = pb.Message_Have
+}
+
+func (m *impl) AddDontHave(k key.Key) {
+	if _, ok := m.blocks[k]; ok {
+		return
+	}
+	m.presences[k] = pb.Message_DontHave
+}
+
 func FromNet(r io.Reader) (BitSwapMessage, error) {
 	pbr := ggio.NewDelimitedReader(r, inet.MessageSizeMax)
 	return FromPBReader(pbr)
@@ -152,6 +234,39 @@ func (m *impl) ToProto() *pb.Message {
 	pbm := new(pb.Message)
 	pbm.Wantlist = new(pb.Message_Wantlist)
 	for _, e := range m.wantlist {
+		pbe := &pb.Message_Wantlist_Entry{
+			Block:    proto.String(string(e.Key)),
+			Priority: proto.Int32(int32(e.Priority)),
+			Cancel:   proto.Bool(e.Cancel),
+		}
+		if e.WantType == WantHave {
+			pbe.WantType = pb.Message_Wantlist_Have.Enum()
+		}
+		if e.SendDontHave {
+			pbe.SendDontHave = proto.Bool(true)
+		}
+		pbm.Wantlist.Entries = append(pbm.Wantlist.Entries, pbe)
+	}
+	for _, b := range m.Blocks() {
+		pbm.Blocks = append(pbm.Blocks, b.Data())
+	}
+	for k, t := range m.presences {
+		pbm.BlockPresences = append(pbm.BlockPresences, &pb.Message_BlockPresence{
+			Block: proto.String(string(k)),
+			Type:  t.Enum(),
+		})
+	}
+	return pbm
+}
+
+func (m *impl) ToProtoV0() *pb.Message {
+	pbm := new(pb.Message)
+	pbm.Wantlist = new(pb.Message_Wantlist)
+	for _, e := range m.wantlist {
+		if e.WantType == WantHave && !e.Cancel {
+			// would be taken for a want of the block
+			continue
+		}
 		pbm.Wantlist.Entries = append(pbm.Wantlist.Entries, &pb.Message_Wantlist_Entry{
 			Block:    proto.String(string(e.Key)),
 			Priority: proto.Int32(int32(e.Priority)),
@@ -173,13 +288,24 @@ func (m *impl) ToNet(w io.Writer) error {
 	return nil
 }
 
+func (m *impl) ToNetV0(w io.Writer) error {
+	pbw := ggio.NewDelimitedWriter(w)
+
+	if err := pbw.WriteMsg(m.ToProtoV0()); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (m *impl) Loggable() map[string]interface{} {
 	var blocks []string
 	for _, v := range m.blocks {
 		blocks = append(blocks, v.Key().B58String())
 	}
 	return map[string]interface{}{
-		"blocks": blocks,
-		"wants":  m.Wantlist(),
+		"blocks":    blocks,
+		"wants":     m.Wantlist(),
+		"haves":     m.Haves(),
+		"dontHaves": m.DontHaves(),
 	}
 }
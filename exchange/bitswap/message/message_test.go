@@ -186,3 +186,72 @@ func TestDuplicates(t *testing.T) {
 		t.Fatal("Duplicate in BitSwapMessage")
 	}
 }
+
+func TestToAndFromNetPresences(t *testing.T) {
+	original := New(false)
+	original.AddWant(key.Key("wanthave"), 1, WantHave, true)
+	original.AddWant(key.Key("wantblock"), 1, WantBlock, true)
+	original.AddHave(key.Key("have"))
+	original.AddDontHave(key.Key("donthave"))
+
+	buf := new(bytes.Buffer)
+	if err := original.ToNet(buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromNet(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make(map[key.Key]Entry)
+	for _, e := range m.Wantlist() {
+		entries[e.Key] = e
+	}
+	if e := entries["wanthave"]; e.WantType != WantHave || !e.SendDontHave {
+		t.Fatalf("want-have entry not preserved: %+v", e)
+	}
+	if e := entries["wantblock"]; e.WantType != WantBlock || !e.SendDontHave {
+		t.Fatalf("want-block entry not preserved: %+v", e)
+	}
+	if h := m.Haves(); len(h) != 1 || h[0] != "have" {
+		t.Fatalf("haves not preserved: %v", h)
+	}
+	if dh := m.DontHaves(); len(dh) != 1 || dh[0] != "donthave" {
+		t.Fatalf("dont-haves not preserved: %v", dh)
+	}
+}
+
+func TestToNetV0DropsPresences(t *testing.T) {
+	original := New(false)
+	original.AddWant(key.Key("wanthave"), 1, WantHave, true)
+	original.AddEntry(key.Key("wantblock"), 1)
+	original.AddHave(key.Key("have"))
+
+	buf := new(bytes.Buffer)
+	if err := original.ToNetV0(buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromNet(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wl := m.Wantlist()
+	if len(wl) != 1 || wl[0].Key != "wantblock" {
+		t.Fatalf("expected only the want-block entry, got %v", wl)
+	}
+	if len(m.Haves()) != 0 {
+		t.Fatal("haves should not be sent to first version peers")
+	}
+}
+
+func TestWantHaveDoesNotReplaceWantBlock(t *testing.T) {
+	msg := New(false)
+	msg.AddEntry(key.Key("foo"), 1)
+	msg.AddWant(key.Key("foo"), 1, WantHave, true)
+
+	wl := msg.Wantlist()
+	if len(wl) != 1 || wl[0].WantType != WantBlock {
+		t.Fatalf("want-block was replaced: %v", wl)
+	}
+}
@@ -20,10 +20,77 @@ import math "math"
 var _ = proto.Marshal
 var _ = math.Inf
 
+type Message_BlockPresenceType int32
+
+const (
+	Message_Have     Message_BlockPresenceType = 0
+	Message_DontHave Message_BlockPresenceType = 1
+)
+
+var Message_BlockPresenceType_name = map[int32]string{
+	0: "Have",
+	1: "DontHave",
+}
+var Message_BlockPresenceType_value = map[string]int32{
+	"Have":     0,
+	"DontHave": 1,
+}
+
+func (x Message_BlockPresenceType) Enum() *Message_BlockPresenceType {
+	p := new(Message_BlockPresenceType)
+	*p = x
+	return p
+}
+func (x Message_BlockPresenceType) String() string {
+	return proto.EnumName(Message_BlockPresenceType_name, int32(x))
+}
+func (x *Message_BlockPresenceType) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Message_BlockPresenceType_value, data, "Message_BlockPresenceType")
+	if err != nil {
+		return err
+	}
+	*x = Message_BlockPresenceType(value)
+	return nil
+}
+
+type Message_Wantlist_WantType int32
+
+const (
+	Message_Wantlist_Block Message_Wantlist_WantType = 0
+	Message_Wantlist_Have  Message_Wantlist_WantType = 1
+)
+
+var Message_Wantlist_WantType_name = map[int32]string{
+	0: "Block",
+	1: "Have",
+}
+var Message_Wantlist_WantType_value = map[string]int32{
+	"Block": 0,
+	"Have":  1,
+}
+
+func (x Message_Wantlist_WantType) Enum() *Message_Wantlist_WantType {
+	p := new(Message_Wantlist_WantType)
+	*p = x
+	return p
+}
+func (x Message_Wantlist_WantType) String() string {
+	return proto.EnumName(Message_Wantlist_WantType_name, int32(x))
+}
+func (x *Message_Wantlist_WantType) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Message_Wantlist_WantType_value, data, "Message_Wantlist_WantType")
+	if err != nil {
+		return err
+	}
+	*x = Message_Wantlist_WantType(value)
+	return nil
+}
+
 type Message struct {
-	Wantlist         *Message_Wantlist `protobuf:"bytes,1,opt,name=wantlist" json:"wantlist,omitempty"`
-	Blocks           [][]byte          `protobuf:"bytes,2,rep,name=blocks" json:"blocks,omitempty"`
-	XXX_unrecognized []byte            `json:"-"`
+	Wantlist         *Message_Wantlist        `protobuf:"bytes,1,opt,name=wantlist" json:"wantlist,omitempty"`
+	Blocks           [][]byte                 `protobuf:"bytes,2,rep,name=blocks" json:"blocks,omitempty"`
+	BlockPresences   []*Message_BlockPresence `protobuf:"bytes,4,rep,name=blockPresences" json:"blockPresences,omitempty"`
+	XXX_unrecognized []byte                   `json:"-"`
 }
 
 func (m *Message) Reset()         { *m = Message{} }
@@ -44,6 +111,13 @@ func (m *Message) GetBlocks() [][]byte {
 	return nil
 }
 
+func (m *Message) GetBlockPresences() []*Message_BlockPresence {
+	if m != nil {
+		return m.BlockPresences
+	}
+	return nil
+}
+
 type Message_Wantlist struct {
 	Entries          []*Message_Wantlist_Entry `protobuf:"bytes,1,rep,name=entries" json:"entries,omitempty"`
 	Full             *bool                     `protobuf:"varint,2,opt,name=full" json:"full,omitempty"`
@@ -69,10 +143,12 @@ func (m *Message_Wantlist) GetFull() bool {
 }
 
 type Message_Wantlist_Entry struct {
-	Block            *string `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
-	Priority         *int32  `protobuf:"varint,2,opt,name=priority" json:"priority,omitempty"`
-	Cancel           *bool   `protobuf:"varint,3,opt,name=cancel" json:"cancel,omitempty"`
-	XXX_unrecognized []byte  `json:"-"`
+	Block            *string                    `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+	Priority         *int32                     `protobuf:"varint,2,opt,name=priority" json:"priority,omitempty"`
+	Cancel           *bool                      `protobuf:"varint,3,opt,name=cancel" json:"cancel,omitempty"`
+	WantType         *Message_Wantlist_WantType `protobuf:"varint,4,opt,name=wantType,enum=bitswap.message.pb.Message_Wantlist_WantType" json:"wantType,omitempty"`
+	SendDontHave     *bool                      `protobuf:"varint,5,opt,name=sendDontHave" json:"sendDontHave,omitempty"`
+	XXX_unrecognized []byte                     `json:"-"`
 }
 
 func (m *Message_Wantlist_Entry) Reset()         { *m = Message_Wantlist_Entry{} }
@@ -100,5 +176,45 @@ func (m *Message_Wantlist_Entry) GetCancel() bool {
 	return false
 }
 
+func (m *Message_Wantlist_Entry) GetWantType() Message_Wantlist_WantType {
+	if m != nil && m.WantType != nil {
+		return *m.WantType
+	}
+	return Message_Wantlist_Block
+}
+
+func (m *Message_Wantlist_Entry) GetSendDontHave() bool {
+	if m != nil && m.SendDontHave != nil {
+		return *m.SendDontHave
+	}
+	return false
+}
+
+type Message_BlockPresence struct {
+	Block            *string                    `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+	Type             *Message_BlockPresenceType `protobuf:"varint,2,opt,name=type,enum=bitswap.message.pb.Message_BlockPresenceType" json:"type,omitempty"`
+	XXX_unrecognized []byte                     `json:"-"`
+}
+
+func (m *Message_BlockPresence) Reset()         { *m = Message_BlockPresence{} }
+func (m *Message_BlockPresence) String() string { return proto.CompactTextString(m) }
+func (*Message_BlockPresence) ProtoMessage()    {}
+
+func (m *Message_BlockPresence) GetBlock() string {
+	if m != nil && m.Block != nil {
+		return *m.Block
+	}
+	return ""
+}
+
+func (m *Message_BlockPresence) GetType() Message_BlockPresenceType {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Message_Have
+}
+
 func init() {
+	proto.RegisterEnum("bitswap.message.pb.Message_BlockPresenceType", Message_BlockPresenceType_name, Message_BlockPresenceType_value)
+	proto.RegisterEnum("bitswap.message.pb.Message_Wantlist_WantType", Message_Wantlist_WantType_name, Message_Wantlist_WantType_value)
 }
@@ -1,6 +1,8 @@
 package network
 
 import (
+	"fmt"
+
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
 	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
@@ -8,7 +10,13 @@ import (
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 )
 
-var ProtocolBitswap protocol.ID = "/ipfs/bitswap"
+var (
+	// ProtocolBitswap is the protocol of bitswap with haves and dont-haves
+	ProtocolBitswap protocol.ID = "/ipfs/bitswap/1.2.0"
+	// ProtocolBitswapNoVers is the first protocol of bitswap, which peers
+	// that don't speak ProtocolBitswap fall back to
+	ProtocolBitswapNoVers protocol.ID = "/ipfs/bitswap"
+)
 
 // BitSwapNetwork provides network connectivity for BitSwap sessions
 type BitSwapNetwork interface {
@@ -25,6 +33,9 @@ type BitSwapNetwork interface {
 
 	ConnectTo(context.Context, peer.ID) error
 
+	// DisconnectFrom closes the connections to a peer
+	DisconnectFrom(context.Context, peer.ID) error
+
 	NewMessageSender(context.Context, peer.ID) (MessageSender, error)
 
 	Routing
@@ -49,6 +60,17 @@ type Receiver interface {
 	PeerDisconnected(peer.ID)
 }
 
+// MalformedMessageError is passed to Receiver.ReceiveError for a message a
+// peer sent in full, but that could not be decoded
+type MalformedMessageError struct {
+	Peer peer.ID
+	Err  error
+}
+
+func (e *MalformedMessageError) Error() string {
+	return fmt.Sprintf("malformed message from %s: %s", e.Peer, e.Err)
+}
+
 type Routing interface {
 	// FindProvidersAsync returns a channel of providers for the given key
 	FindProvidersAsync(context.Context, key.Key, int) <-chan peer.ID
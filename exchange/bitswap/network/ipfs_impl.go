@@ -2,6 +2,7 @@ package network
 
 import (
 	"io"
+	"sync"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
@@ -12,6 +13,7 @@ import (
 	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
 	host "gx/ipfs/QmVCe3SNMjkcPgnpFhZs719dheq6xE7gJwjzV7aWcUM4Ms/go-libp2p/p2p/host"
 	inet "gx/ipfs/QmVCe3SNMjkcPgnpFhZs719dheq6xE7gJwjzV7aWcUM4Ms/go-libp2p/p2p/net"
+	protocol "gx/ipfs/QmVCe3SNMjkcPgnpFhZs719dheq6xE7gJwjzV7aWcUM4Ms/go-libp2p/p2p/protocol"
 	ma "gx/ipfs/QmYzDkkgAEmrcNzFCiYo6L1dTX4EAG1gZkbtdbd9trL4vd/go-multiaddr"
 	ggio "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/io"
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
@@ -24,8 +26,10 @@ func NewFromIpfsHost(host host.Host, r routing.IpfsRouting) BitSwapNetwork {
 	bitswapNetwork := impl{
 		host:    host,
 		routing: r,
+		noVers:  make(map[peer.ID]struct{}),
 	}
 	host.SetStreamHandler(ProtocolBitswap, bitswapNetwork.handleNewStream)
+	host.SetStreamHandler(ProtocolBitswapNoVers, bitswapNetwork.handleNewStream)
 	host.Network().Notify((*netNotifiee)(&bitswapNetwork))
 	// TODO: StopNotify.
 
@@ -40,10 +44,15 @@ type impl struct {
 
 	// inbound messages from the network are forwarded to the receiver
 	receiver Receiver
+
+	// peers that only speak ProtocolBitswapNoVers, while connected
+	noVersLk sync.Mutex
+	noVers   map[peer.ID]struct{}
 }
 
 type streamMessageSender struct {
-	s inet.Stream
+	s     inet.Stream
+	proto protocol.ID
 }
 
 func (s *streamMessageSender) Close() error {
@@ -51,28 +60,58 @@ func (s *streamMessageSender) Close() error {
 }
 
 func (s *streamMessageSender) SendMsg(msg bsmsg.BitSwapMessage) error {
-	return msg.ToNet(s.s)
+	return msgToStream(s.s, s.proto, msg)
+}
+
+// msgToStream writes 'msg' in the version of the protocol of the stream
+func msgToStream(s inet.Stream, proto protocol.ID, msg bsmsg.BitSwapMessage) error {
+	if proto == ProtocolBitswapNoVers {
+		return msg.ToNetV0(s)
+	}
+	return msg.ToNet(s)
 }
 
 func (bsnet *impl) NewMessageSender(ctx context.Context, p peer.ID) (MessageSender, error) {
-	s, err := bsnet.newStreamToPeer(ctx, p)
+	s, proto, err := bsnet.newStreamToPeer(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
-	return &streamMessageSender{s: s}, nil
+	return &streamMessageSender{s: s, proto: proto}, nil
 }
 
-func (bsnet *impl) newStreamToPeer(ctx context.Context, p peer.ID) (inet.Stream, error) {
+// newStreamToPeer opens a stream of the latest protocol 'p' speaks, and
+// returns it with that protocol
+func (bsnet *impl) newStreamToPeer(ctx context.Context, p peer.ID) (inet.Stream, protocol.ID, error) {
 
 	// first, make sure we're connected.
 	// if this fails, we cannot connect to given peer.
 	//TODO(jbenet) move this into host.NewStream?
 	if err := bsnet.host.Connect(ctx, pstore.PeerInfo{ID: p}); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return bsnet.host.NewStream(ctx, ProtocolBitswap, p)
+	bsnet.noVersLk.Lock()
+	_, noVers := bsnet.noVers[p]
+	bsnet.noVersLk.Unlock()
+	if !noVers {
+		s, err := bsnet.host.NewStream(ctx, ProtocolBitswap, p)
+		if err == nil {
+			return s, ProtocolBitswap, nil
+		}
+		log.Debugf("%s does not speak %s: %s", p, ProtocolBitswap, err)
+	}
+
+	s, err := bsnet.host.NewStream(ctx, ProtocolBitswapNoVers, p)
+	if err != nil {
+		return nil, "", err
+	}
+	if !noVers {
+		bsnet.noVersLk.Lock()
+		bsnet.noVers[p] = struct{}{}
+		bsnet.noVersLk.Unlock()
+	}
+	return s, ProtocolBitswapNoVers, nil
 }
 
 func (bsnet *impl) SendMessage(
@@ -80,13 +119,13 @@ func (bsnet *impl) SendMessage(
 	p peer.ID,
 	outgoing bsmsg.BitSwapMessage) error {
 
-	s, err := bsnet.newStreamToPeer(ctx, p)
+	s, proto, err := bsnet.newStreamToPeer(ctx, p)
 	if err != nil {
 		return err
 	}
 	defer s.Close()
 
-	if err := outgoing.ToNet(s); err != nil {
+	if err := msgToStream(s, proto, outgoing); err != nil {
 		log.Debugf("error: %s", err)
 		return err
 	}
@@ -99,13 +138,13 @@ func (bsnet *impl) SendRequest(
 	p peer.ID,
 	outgoing bsmsg.BitSwapMessage) (bsmsg.BitSwapMessage, error) {
 
-	s, err := bsnet.newStreamToPeer(ctx, p)
+	s, proto, err := bsnet.newStreamToPeer(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 	defer s.Close()
 
-	if err := outgoing.ToNet(s); err != nil {
+	if err := msgToStream(s, proto, outgoing); err != nil {
 		log.Debugf("error: %s", err)
 		return nil, err
 	}
@@ -127,6 +166,10 @@ func (bsnet *impl) ConnectTo(ctx context.Context, p peer.ID) error {
 	return bsnet.host.Connect(ctx, pstore.PeerInfo{ID: p})
 }
 
+func (bsnet *impl) DisconnectFrom(ctx context.Context, p peer.ID) error {
+	return bsnet.host.Network().ClosePeer(p)
+}
+
 // FindProvidersAsync returns a channel of providers for the given key
 func (bsnet *impl) FindProvidersAsync(ctx context.Context, k key.Key, max int) <-chan peer.ID {
 
@@ -176,24 +219,44 @@ func (bsnet *impl) handleNewStream(s inet.Stream) {
 		return
 	}
 
-	reader := ggio.NewDelimitedReader(s, inet.MessageSizeMax)
+	p := s.Conn().RemotePeer()
+	sr := &streamReader{r: s}
+	reader := ggio.NewDelimitedReader(sr, inet.MessageSizeMax)
 	for {
 		received, err := bsmsg.FromPBReader(reader)
 		if err != nil {
 			if err != io.EOF {
+				if sr.err == nil {
+					// the stream was fine, the message was not
+					err = &MalformedMessageError{Peer: p, Err: err}
+				}
 				go bsnet.receiver.ReceiveError(err)
-				log.Debugf("bitswap net handleNewStream from %s error: %s", s.Conn().RemotePeer(), err)
+				log.Debugf("bitswap net handleNewStream from %s error: %s", p, err)
 			}
 			return
 		}
 
-		p := s.Conn().RemotePeer()
 		ctx := context.Background()
 		log.Debugf("bitswap net handleNewStream from %s", s.Conn().RemotePeer())
 		bsnet.receiver.ReceiveMessage(ctx, p, received)
 	}
 }
 
+// streamReader remembers the error reading a stream, to tell it from the
+// errors decoding the messages read
+type streamReader struct {
+	r   io.Reader
+	err error
+}
+
+func (sr *streamReader) Read(b []byte) (int, error) {
+	n, err := sr.r.Read(b)
+	if err != nil {
+		sr.err = err
+	}
+	return n, err
+}
+
 type netNotifiee impl
 
 func (nn *netNotifiee) impl() *impl {
@@ -205,6 +268,11 @@ func (nn *netNotifiee) Connected(n inet.Network, v inet.Conn) {
 }
 
 func (nn *netNotifiee) Disconnected(n inet.Network, v inet.Conn) {
+	// the peer may speak another protocol when it comes back
+	nn.impl().noVersLk.Lock()
+	delete(nn.impl().noVers, v.RemotePeer())
+	nn.impl().noVersLk.Unlock()
+
 	nn.impl().receiver.PeerDisconnected(v.RemotePeer())
 }
 
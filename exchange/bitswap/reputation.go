@@ -0,0 +1,47 @@
+package bitswap
+
+import (
+	"time"
+
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	reputation "github.com/ipfs/go-ipfs/exchange/bitswap/reputation"
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// MaxPeerWants is how many blocks a peer may want at once, or in a message,
+// before it counts as flooding us with wants
+var MaxPeerWants = 16384
+
+// Reputation returns the tracker of the peers that misbehave
+func (bs *Bitswap) Reputation() *reputation.Tracker {
+	return bs.reputation
+}
+
+// misbehaved records the event 'e' of 'p', and disconnects from 'p' if it
+// got banned. While banned, its messages are ignored.
+func (bs *Bitswap) misbehaved(p peer.ID, e reputation.Event) {
+	if !bs.reputation.Record(p, e) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := bs.network.DisconnectFrom(ctx, p); err != nil {
+			log.Infof("cannot disconnect from banned %s: %s", p, err)
+		}
+	}()
+}
+
+// checkWants records whether 'p' floods us with wants, once the engine
+// recorded those of 'incoming'
+func (bs *Bitswap) checkWants(p peer.ID, incoming bsmsg.BitSwapMessage) {
+	wants := len(incoming.Wantlist())
+	if wants == 0 {
+		return
+	}
+	if wants > MaxPeerWants || bs.engine.LedgerForPeer(p).WantlistSize > MaxPeerWants {
+		log.Infof("%s wants too many blocks", p)
+		bs.misbehaved(p, reputation.WantFlood)
+	}
+}
@@ -0,0 +1,279 @@
+// package reputation keeps track of the bitswap peers that misbehave, and
+// bans them for a while when they keep at it.
+package reputation
+
+import (
+	"sync"
+	"time"
+
+	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+)
+
+var log = logging.Logger("bitswap/reputation")
+
+const (
+	// DefaultThreshold is the score at which peers are banned
+	DefaultThreshold = -100.0
+	// DefaultBanDuration is how long peers are banned for
+	DefaultBanDuration = time.Minute * 10
+
+	// recoveryRate is how many points a score recovers per second, up to 0,
+	// so that peers that seldom misbehave are never banned
+	recoveryRate = 0.1
+)
+
+// Event is a misbehaviour of a peer
+type Event int
+
+const (
+	// UnwantedBlock is a block we never asked for
+	UnwantedBlock Event = iota
+	// MalformedMessage is a message that could not be decoded
+	MalformedMessage
+	// WantFlood is a message that took the wants of a peer over the limit
+	WantFlood
+
+	numEvents
+)
+
+// penalties are the points each event takes off the score of a peer
+var penalties = [numEvents]float64{
+	UnwantedBlock:    1,
+	MalformedMessage: 25,
+	WantFlood:        10,
+}
+
+// Notifiee is told when peers are banned, and when their ban ends
+type Notifiee interface {
+	Banned(p peer.ID, until time.Time)
+	Unbanned(p peer.ID)
+}
+
+// Info is the reputation of a peer
+type Info struct {
+	Peer              peer.ID
+	Score             float64
+	UnwantedBlocks    int
+	MalformedMessages int
+	WantFloods        int
+	BannedUntil       time.Time // zero when not banned
+}
+
+// Tracker scores peers on their misbehaviour. Scores start at 0 and go down
+// with each event, recovering over time. Peers whose score reaches the
+// threshold are banned for a while, after which they start over.
+type Tracker struct {
+	lk        sync.Mutex
+	threshold float64
+	banFor    time.Duration
+	records   map[peer.ID]*record
+	notifiees []Notifiee
+
+	// now is replaced by tests
+	now func() time.Time
+}
+
+type record struct {
+	score       float64
+	updated     time.Time
+	events      [numEvents]int
+	bannedUntil time.Time
+}
+
+// New returns a tracker that bans peers whose score reaches 'threshold' for
+// 'banFor'. A threshold of 0 bans nobody.
+func New(threshold float64, banFor time.Duration) *Tracker {
+	return &Tracker{
+		threshold: threshold,
+		banFor:    banFor,
+		records:   make(map[peer.ID]*record),
+		now:       time.Now,
+	}
+}
+
+// SetBanning changes the score at which peers are banned, and for how long.
+// Bans in progress keep their end.
+func (t *Tracker) SetBanning(threshold float64, banFor time.Duration) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.threshold = threshold
+	t.banFor = banFor
+}
+
+// Notify registers 'n' to be told about bans
+func (t *Tracker) Notify(n Notifiee) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.notifiees = append(t.notifiees, n)
+}
+
+// Record scores the event 'e' of 'p', and bans 'p' if it reaches the
+// threshold. Returns whether 'p' got banned.
+func (t *Tracker) Record(p peer.ID, e Event) bool {
+	t.lk.Lock()
+	now := t.now()
+	r := t.record(p, now)
+	r.events[e]++
+	if t.threshold == 0 || r.banned(now) {
+		t.lk.Unlock()
+		return false
+	}
+
+	r.score -= penalties[e]
+	if r.score > t.threshold {
+		t.lk.Unlock()
+		return false
+	}
+
+	until := now.Add(t.banFor)
+	r.bannedUntil = until
+	score, banFor := r.score, t.banFor
+	notifiees := append([]Notifiee(nil), t.notifiees...)
+	t.lk.Unlock()
+
+	log.Warningf("banning %s until %s, its score reached %.2f", p, until, score)
+	for _, n := range notifiees {
+		n.Banned(p, until)
+	}
+	time.AfterFunc(banFor, func() {
+		t.expire(p, until)
+	})
+	return true
+}
+
+// Banned returns whether 'p' is banned
+func (t *Tracker) Banned(p peer.ID) bool {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	r, ok := t.records[p]
+	return ok && r.banned(t.now())
+}
+
+// Unban ends the ban of 'p' early, and returns whether it was banned
+func (t *Tracker) Unban(p peer.ID) bool {
+	t.lk.Lock()
+	r, ok := t.records[p]
+	if !ok || !r.banned(t.now()) {
+		t.lk.Unlock()
+		return false
+	}
+	until := r.bannedUntil
+	t.lk.Unlock()
+	return t.expire(p, until)
+}
+
+// expire ends the ban of 'p' that lasts until 'until', unless it already
+// ended
+func (t *Tracker) expire(p peer.ID, until time.Time) bool {
+	t.lk.Lock()
+	r, ok := t.records[p]
+	if !ok || !r.bannedUntil.Equal(until) {
+		t.lk.Unlock()
+		return false
+	}
+	r.bannedUntil = time.Time{}
+	r.score = 0
+	r.updated = t.now()
+	notifiees := append([]Notifiee(nil), t.notifiees...)
+	t.lk.Unlock()
+
+	log.Infof("ban of %s ended", p)
+	for _, n := range notifiees {
+		n.Unbanned(p)
+	}
+	return true
+}
+
+// Peer returns the reputation of 'p'
+func (t *Tracker) Peer(p peer.ID) Info {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	r, ok := t.records[p]
+	if !ok {
+		return Info{Peer: p}
+	}
+	return r.info(p, t.now())
+}
+
+// Peers returns the reputation of the peers that misbehaved and have not
+// recovered yet. The records of those that recovered are dropped.
+func (t *Tracker) Peers() []Info {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	now := t.now()
+	out := make([]Info, 0, len(t.records))
+	for p, r := range t.records {
+		info := r.info(p, now)
+		if r.clean(now) {
+			delete(t.records, p)
+			continue
+		}
+		out = append(out, info)
+	}
+	return out
+}
+
+// Forget drops the record of 'p' once its score recovered and it is not
+// banned, so that records don't pile up for every peer we ever met. It is
+// meant to be called when 'p' disconnects.
+func (t *Tracker) Forget(p peer.ID) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	r, ok := t.records[p]
+	if !ok {
+		return
+	}
+	now := t.now()
+	r.recover(now)
+	if r.clean(now) {
+		delete(t.records, p)
+	}
+}
+
+// record returns the record of 'p', with its score recovered up to 'now'.
+// NB: t.lk must be held.
+func (t *Tracker) record(p peer.ID, now time.Time) *record {
+	r, ok := t.records[p]
+	if !ok {
+		r = &record{updated: now}
+		t.records[p] = r
+	}
+	r.recover(now)
+	return r
+}
+
+func (r *record) banned(now time.Time) bool {
+	return now.Before(r.bannedUntil)
+}
+
+// clean returns whether the record is back to a fresh one, apart from its
+// event counts. The score must be recovered up to 'now'.
+func (r *record) clean(now time.Time) bool {
+	return r.score == 0 && !r.banned(now)
+}
+
+func (r *record) recover(now time.Time) {
+	if !r.banned(now) {
+		r.score += now.Sub(r.updated).Seconds() * recoveryRate
+		if r.score > 0 {
+			r.score = 0
+		}
+	}
+	r.updated = now
+}
+
+func (r *record) info(p peer.ID, now time.Time) Info {
+	r.recover(now)
+	info := Info{
+		Peer:              p,
+		Score:             r.score,
+		UnwantedBlocks:    r.events[UnwantedBlock],
+		MalformedMessages: r.events[MalformedMessage],
+		WantFloods:        r.events[WantFlood],
+	}
+	if r.banned(now) {
+		info.BannedUntil = r.bannedUntil
+	}
+	return info
+}
@@ -0,0 +1,206 @@
+package reputation
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+)
+
+// clock is a fake time for the tracker
+type clock struct {
+	lk sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestTracker(threshold float64, banFor time.Duration) (*Tracker, *clock) {
+	c := &clock{t: time.Unix(0, 0)}
+	t := New(threshold, banFor)
+	t.now = c.now
+	return t, c
+}
+
+// notifiee collects the bans it is told about
+type notifiee struct {
+	banned   chan peer.ID
+	unbanned chan peer.ID
+}
+
+func newNotifiee() *notifiee {
+	return &notifiee{
+		banned:   make(chan peer.ID, 10),
+		unbanned: make(chan peer.ID, 10),
+	}
+}
+
+func (n *notifiee) Banned(p peer.ID, until time.Time) { n.banned <- p }
+func (n *notifiee) Unbanned(p peer.ID)                { n.unbanned <- p }
+
+func TestScores(t *testing.T) {
+	tr, c := newTestTracker(-100, time.Hour)
+	p := peer.ID("misbehaving")
+
+	if info := tr.Peer(p); info.Score != 0 || info.Peer != p {
+		t.Fatalf("expected a clean reputation, got %+v", info)
+	}
+
+	tr.Record(p, UnwantedBlock)
+	tr.Record(p, WantFlood)
+	tr.Record(p, MalformedMessage)
+	info := tr.Peer(p)
+	if info.Score != -36 {
+		t.Fatalf("expected a score of -36, got %f", info.Score)
+	}
+	if info.UnwantedBlocks != 1 || info.WantFloods != 1 || info.MalformedMessages != 1 {
+		t.Fatalf("expected an event of each, got %+v", info)
+	}
+	if !info.BannedUntil.IsZero() || tr.Banned(p) {
+		t.Fatal("peer above the threshold banned")
+	}
+
+	// scores recover over time, up to 0
+	c.add(time.Minute)
+	if info := tr.Peer(p); info.Score != -30 {
+		t.Fatalf("expected a score of -30, got %f", info.Score)
+	}
+	c.add(time.Hour)
+	if info := tr.Peer(p); info.Score != 0 {
+		t.Fatalf("expected a score of 0, got %f", info.Score)
+	}
+
+	// recovered peers are forgotten
+	if len(tr.Peers()) != 0 {
+		t.Fatalf("expected no reputation of recovered peers, got %v", tr.Peers())
+	}
+	if info := tr.Peer(p); info.UnwantedBlocks != 0 {
+		t.Fatalf("record of a recovered peer kept: %+v", info)
+	}
+}
+
+func TestForget(t *testing.T) {
+	tr, c := newTestTracker(-10, time.Hour)
+	p := peer.ID("misbehaving")
+
+	tr.Record(p, UnwantedBlock)
+	tr.Forget(p)
+	if tr.Peer(p).UnwantedBlocks != 1 {
+		t.Fatal("forgot a peer that did not recover yet")
+	}
+
+	c.add(time.Minute)
+	tr.Forget(p)
+	if tr.Peer(p).UnwantedBlocks != 0 {
+		t.Fatal("record of a recovered peer kept")
+	}
+
+	tr.Record(p, WantFlood)
+	if !tr.Banned(p) {
+		t.Fatal("peer not banned")
+	}
+	tr.Forget(p)
+	if !tr.Banned(p) {
+		t.Fatal("forgot a banned peer")
+	}
+}
+
+func TestBan(t *testing.T) {
+	tr, c := newTestTracker(-50, time.Hour)
+	n := newNotifiee()
+	tr.Notify(n)
+	p := peer.ID("misbehaving")
+
+	if tr.Record(p, MalformedMessage) || tr.Banned(p) {
+		t.Fatal("banned too early")
+	}
+	if !tr.Record(p, MalformedMessage) || !tr.Banned(p) {
+		t.Fatal("peer at the threshold not banned")
+	}
+	if banned := <-n.banned; banned != p {
+		t.Fatalf("told about the ban of %s instead of %s", banned, p)
+	}
+	if until := tr.Peer(p).BannedUntil; !until.Equal(c.now().Add(time.Hour)) {
+		t.Fatalf("expected a ban for an hour, until %s", until)
+	}
+
+	// events while banned do not extend the ban
+	if tr.Record(p, MalformedMessage) {
+		t.Fatal("banned again while banned")
+	}
+	select {
+	case <-n.banned:
+		t.Fatal("banned again while banned")
+	default:
+	}
+	if info := tr.Peer(p); info.MalformedMessages != 3 {
+		t.Fatalf("expected 3 malformed messages, got %d", info.MalformedMessages)
+	}
+
+	c.add(time.Hour)
+	if tr.Banned(p) {
+		t.Fatal("ban did not end")
+	}
+}
+
+func TestUnban(t *testing.T) {
+	tr, _ := newTestTracker(-10, time.Hour)
+	n := newNotifiee()
+	tr.Notify(n)
+	p := peer.ID("misbehaving")
+
+	if tr.Unban(p) {
+		t.Fatal("unbanned a peer that was not banned")
+	}
+	tr.Record(p, WantFlood)
+	<-n.banned
+	if !tr.Unban(p) {
+		t.Fatal("banned peer not unbanned")
+	}
+	if unbanned := <-n.unbanned; unbanned != p {
+		t.Fatalf("told about the end of the ban of %s instead of %s", unbanned, p)
+	}
+	if tr.Banned(p) || tr.Peer(p).Score != 0 {
+		t.Fatalf("expected a clean slate after the ban, got %+v", tr.Peer(p))
+	}
+}
+
+func TestBanExpires(t *testing.T) {
+	tr := New(-10, time.Millisecond*50)
+	n := newNotifiee()
+	tr.Notify(n)
+	p := peer.ID("misbehaving")
+
+	tr.Record(p, WantFlood)
+	<-n.banned
+	select {
+	case <-n.unbanned:
+	case <-time.After(time.Second * 5):
+		t.Fatal("ban did not end")
+	}
+	if tr.Banned(p) {
+		t.Fatal("still banned after the ban ended")
+	}
+}
+
+func TestNoThreshold(t *testing.T) {
+	tr, _ := newTestTracker(0, time.Hour)
+	p := peer.ID("misbehaving")
+	for i := 0; i < 100; i++ {
+		tr.Record(p, MalformedMessage)
+	}
+	if tr.Banned(p) {
+		t.Fatal("banned without a threshold")
+	}
+}
@@ -0,0 +1,239 @@
+package bitswap
+
+import (
+	"sync"
+	"time"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	delay "github.com/ipfs/go-ipfs/thirdparty/delay"
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// provSearchDelay is how long the wants of a session are only sent to the
+// peers that delivered its blocks, before they are broadcast and providers
+// are searched for. Peers that tell they don't have the blocks cut it short.
+var provSearchDelay = delay.Fixed(time.Second)
+
+// session tracks the peers that delivered the blocks of an
+// exchange.Session, so that it asks them for the next ones
+type session struct {
+	lk sync.Mutex
+	// peers that delivered or have blocks, in the order they first did
+	peers []peer.ID
+	known map[peer.ID]struct{}
+	// blocks wanted and not received yet
+	wants map[key.Key]*sessionWant
+}
+
+// sessionWant is the want of a block of a session
+type sessionWant struct {
+	ctx context.Context
+	// the peer the block is wanted from, none once it did not have it
+	from peer.ID
+	// the peers asked for the block, that did not answer yet
+	asked map[peer.ID]struct{}
+	// the peers that have the block, which it is not wanted from yet
+	haves []peer.ID
+	// whether the block is wanted from all peers
+	everyone bool
+}
+
+func newSession() *session {
+	return &session{
+		known: make(map[peer.ID]struct{}),
+		wants: make(map[key.Key]*sessionWant),
+	}
+}
+
+func (s *session) addPeer(p peer.ID) {
+	if _, ok := s.known[p]; !ok {
+		s.known[p] = struct{}{}
+		s.peers = append(s.peers, p)
+	}
+}
+
+// want records that the session wants 'ks', and returns the peer to want
+// them from, and the other peers to ask whether they have them. There are
+// none before peers delivered blocks of the session.
+func (s *session) want(ctx context.Context, ks []key.Key) (from peer.ID, others []peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if len(s.peers) > 0 {
+		from = s.peers[0]
+		others = append(others, s.peers[1:]...)
+	}
+	for _, k := range ks {
+		sw := &sessionWant{
+			ctx:      ctx,
+			from:     from,
+			asked:    make(map[peer.ID]struct{}),
+			everyone: from == "",
+		}
+		for _, p := range s.peers {
+			sw.asked[p] = struct{}{}
+		}
+		s.wants[k] = sw
+	}
+	return from, others
+}
+
+// toEveryone returns the keys of 'ks' the session did not receive yet, and
+// that are not wanted from all peers yet, and records that they are
+func (s *session) toEveryone(ks []key.Key) []key.Key {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	var out []key.Key
+	for _, k := range ks {
+		if sw, ok := s.wants[k]; ok && !sw.everyone {
+			sw.everyone = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// receivedFrom records that 'p' delivered 'k', if the session wants it
+func (s *session) receivedFrom(p peer.ID, k key.Key) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if _, ok := s.wants[k]; !ok {
+		return
+	}
+	delete(s.wants, k)
+	s.addPeer(p)
+}
+
+// presence records whether 'p' has 'k', if the session wants it. It returns
+// the peer to want 'k' from now, or whether to want it from all peers, as no
+// peer asked has it.
+func (s *session) presence(p peer.ID, k key.Key, have bool) (ctx context.Context, from peer.ID, everyone bool) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	sw, ok := s.wants[k]
+	if !ok || sw.everyone {
+		return nil, "", false
+	}
+	delete(sw.asked, p)
+
+	if have {
+		s.addPeer(p)
+		if sw.from == "" {
+			sw.from = p
+			return sw.ctx, p, false
+		}
+		sw.haves = append(sw.haves, p)
+		return nil, "", false
+	}
+
+	if p == sw.from {
+		sw.from = ""
+		if len(sw.haves) > 0 {
+			sw.from, sw.haves = sw.haves[0], sw.haves[1:]
+			return sw.ctx, sw.from, false
+		}
+	}
+	if sw.from == "" && len(sw.asked) == 0 {
+		sw.everyone = true
+		return sw.ctx, "", true
+	}
+	return nil, "", false
+}
+
+// sessionFor returns the session the requests of 'ctx' belong to, if any
+func (bs *Bitswap) sessionFor(ctx context.Context) *session {
+	es, ok := exchange.SessionFromContext(ctx)
+	if !ok || es.Done() == nil {
+		// a session that never ends would never be forgotten
+		return nil
+	}
+
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+	s, ok := bs.sessions[es.ID()]
+	if ok {
+		return s
+	}
+
+	s = newSession()
+	bs.sessions[es.ID()] = s
+	go func() {
+		select {
+		case <-es.Done():
+		case <-bs.process.Closing():
+		}
+		bs.sessLk.Lock()
+		delete(bs.sessions, es.ID())
+		bs.sessLk.Unlock()
+	}()
+	return s
+}
+
+// sessionsReceived records that 'p' delivered 'k' in the sessions that want
+// it
+func (bs *Bitswap) sessionsReceived(p peer.ID, k key.Key) {
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+	for _, s := range bs.sessions {
+		s.receivedFrom(p, k)
+	}
+}
+
+// sessionsPresence records whether 'p' has 'k' in the sessions that want
+// it, and wants 'k' from the peers that have it
+func (bs *Bitswap) sessionsPresence(p peer.ID, k key.Key, have bool) {
+	bs.sessLk.Lock()
+	sessions := make([]*session, 0, len(bs.sessions))
+	for _, s := range bs.sessions {
+		sessions = append(sessions, s)
+	}
+	bs.sessLk.Unlock()
+
+	for _, s := range sessions {
+		ctx, from, everyone := s.presence(p, k, have)
+		switch {
+		case from != "":
+			bs.wm.WantBlocksFrom(ctx, []key.Key{k}, []peer.ID{from})
+		case everyone:
+			log.Debugf("session peers do not have %s, asking everyone", k)
+			go func() {
+				if err := bs.wantFromAll(ctx, []key.Key{k}); err != nil {
+					log.Debug(err)
+				}
+			}()
+		}
+	}
+}
+
+// wantForSession wants 'ks' from a peer that delivered blocks of 's', and
+// asks the others whether they have them, in case it does not. The keys
+// still missing after provSearchDelay are asked for like those out of
+// sessions.
+func (bs *Bitswap) wantForSession(ctx context.Context, s *session, ks []key.Key) error {
+	from, others := s.want(ctx, ks)
+	if from == "" {
+		return bs.wantFromAll(ctx, ks)
+	}
+
+	bs.wm.WantBlocksFrom(ctx, ks, []peer.ID{from})
+	if len(others) > 0 {
+		bs.wm.WantHaves(ctx, ks, others)
+	}
+	go func() {
+		select {
+		case <-time.After(provSearchDelay.Get()):
+		case <-ctx.Done():
+			return
+		}
+		missing := s.toEveryone(ks)
+		if len(missing) == 0 {
+			return
+		}
+		log.Debugf("session peers did not deliver %d blocks, asking everyone", len(missing))
+		if err := bs.wantFromAll(ctx, missing); err != nil {
+			log.Debug(err)
+		}
+	}()
+	return nil
+}
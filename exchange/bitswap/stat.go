@@ -6,12 +6,17 @@ import (
 )
 
 type Stat struct {
-	ProvideBufLen   int
-	Wantlist        []key.Key
-	Peers           []string
-	BlocksReceived  int
-	DupBlksReceived int
-	DupDataReceived uint64
+	ProvideBufLen     int
+	Wantlist          []key.Key
+	Peers             []string
+	BlocksReceived    int
+	DupBlksReceived   int
+	DataReceived      uint64
+	DupDataReceived   uint64
+	HavesReceived     int
+	DontHavesReceived int
+	RateIn            uint64
+	RateOut           uint64
 }
 
 func (bs *Bitswap) Stat() (*Stat, error) {
@@ -21,8 +26,13 @@ func (bs *Bitswap) Stat() (*Stat, error) {
 	bs.counterLk.Lock()
 	st.BlocksReceived = bs.blocksRecvd
 	st.DupBlksReceived = bs.dupBlocksRecvd
+	st.DataReceived = bs.dataRecvd
 	st.DupDataReceived = bs.dupDataRecvd
+	st.HavesReceived = bs.havesRecvd
+	st.DontHavesReceived = bs.dontHavesRecvd
 	bs.counterLk.Unlock()
+	st.RateIn = bs.wm.Inbound().Rate()
+	st.RateOut = bs.engine.Outbound().Rate()
 
 	for _, p := range bs.engine.Peers() {
 		st.Peers = append(st.Peers, p.Pretty())
@@ -0,0 +1,157 @@
+package bitswap_test
+
+import (
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	tn "github.com/ipfs/go-ipfs/exchange/bitswap/testnet"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	delay "github.com/ipfs/go-ipfs/thirdparty/delay"
+	peer "gx/ipfs/QmRBqJF7hb8ZSpRcMwUt8hNhydWcxGEhtk81HKq6oUwKvs/go-libp2p-peer"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+func connect(a, b bitswap.Instance) {
+	a.Exchange.PeerConnected(b.Peer)
+	b.Exchange.PeerConnected(a.Peer)
+}
+
+// fetch gets 'blk' from the peers of 'inst' within 'timeout'
+func fetch(inst bitswap.Instance, blk blocks.Block, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := inst.Exchange.GetBlock(ctx, blk.Key())
+	return err
+}
+
+// waitSent waits until 'server' has accounted a block sent to 'client'
+func waitSent(t *testing.T, server, client bitswap.Instance) {
+	deadline := time.Now().Add(time.Second)
+	for server.Exchange.LedgerForPeer(client.Peer).Sent == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the server did not account the block it sent")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestServeAllStrategy(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
+	g := bitswap.NewTestSessionGenerator(net)
+	defer g.Close()
+
+	server := g.NextWithStrategy(decision.ServeAll)
+	client := g.Next()
+	connect(server, client)
+
+	blk := blocks.NewBlock([]byte("block"))
+	if err := server.Exchange.HasBlock(blk); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(client, blk, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllowlistOnlyStrategy(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
+	g := bitswap.NewTestSessionGenerator(net)
+	defer g.Close()
+
+	allowed := g.Next()
+	other := g.Next()
+	server := g.NextWithStrategy(decision.AllowlistOnly([]peer.ID{allowed.Peer}))
+	connect(server, allowed)
+	connect(server, other)
+
+	blk := blocks.NewBlock([]byte("block"))
+	if err := server.Exchange.HasBlock(blk); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(other, blk, time.Millisecond*300); err != context.DeadlineExceeded {
+		t.Fatalf("a peer out of the allowlist was served: %v", err)
+	}
+	if err := fetch(allowed, blk, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReciprocalStrategy(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
+	g := bitswap.NewTestSessionGenerator(net)
+	defer g.Close()
+
+	server := g.NextWithStrategy(decision.Reciprocal(1))
+	client := g.Next()
+	connect(server, client)
+
+	// blocks of the same size, so that one block received pays for one sent
+	b1 := blocks.NewBlock([]byte("block 1"))
+	b2 := blocks.NewBlock([]byte("block 2"))
+	b3 := blocks.NewBlock([]byte("block 3"))
+	for _, blk := range []blocks.Block{b1, b2} {
+		if err := server.Exchange.HasBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Exchange.HasBlock(b3); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client owes nothing yet
+	if err := fetch(client, b1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	waitSent(t, server, client)
+
+	if err := fetch(client, b2, time.Millisecond*300); err != context.DeadlineExceeded {
+		t.Fatalf("a peer in debt was served: %v", err)
+	}
+
+	// the client pays its debt back
+	if err := fetch(server, b3, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetch(client, b2, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReciprocalIgnoresUnwantedBlocks(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
+	g := bitswap.NewTestSessionGenerator(net)
+	defer g.Close()
+
+	server := g.NextWithStrategy(decision.Reciprocal(1))
+	client := g.Next()
+	connect(server, client)
+
+	b1 := blocks.NewBlock([]byte("block 1"))
+	b2 := blocks.NewBlock([]byte("block 2"))
+	for _, blk := range []blocks.Block{b1, b2} {
+		if err := server.Exchange.HasBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fetch(client, b1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	waitSent(t, server, client)
+
+	// blocks the server never asked for don't pay anything back
+	junk := bsmsg.New(false)
+	junk.AddBlock(blocks.NewBlock([]byte("junk 1")))
+	junk.AddBlock(blocks.NewBlock([]byte("junk 2")))
+	server.Exchange.ReceiveMessage(context.Background(), client.Peer, junk)
+	if r := server.Exchange.LedgerForPeer(client.Peer); r.Recv != 0 {
+		t.Fatalf("unwanted blocks were credited: %d bytes", r.Recv)
+	}
+
+	if err := fetch(client, b2, time.Millisecond*300); err != context.DeadlineExceeded {
+		t.Fatalf("a peer in debt was served after pushing unwanted blocks: %v", err)
+	}
+}
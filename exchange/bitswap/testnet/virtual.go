@@ -153,3 +153,12 @@ func (nc *networkClient) ConnectTo(_ context.Context, p peer.ID) error {
 	nc.Receiver.PeerConnected(p)
 	return nil
 }
+
+func (nc *networkClient) DisconnectFrom(_ context.Context, p peer.ID) error {
+	if !nc.network.HasPeer(p) {
+		return errors.New("no such peer in network")
+	}
+	nc.network.clients[p].PeerDisconnected(nc.local)
+	nc.Receiver.PeerDisconnected(p)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
 	tn "github.com/ipfs/go-ipfs/exchange/bitswap/testnet"
 	datastore2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
 	delay "github.com/ipfs/go-ipfs/thirdparty/delay"
@@ -41,12 +42,17 @@ func (g *SessionGenerator) Close() error {
 }
 
 func (g *SessionGenerator) Next() Instance {
+	return g.NextWithStrategy(decision.ServeAll)
+}
+
+// NextWithStrategy returns an instance that serves peers with 'strategy'
+func (g *SessionGenerator) NextWithStrategy(strategy decision.Strategy) Instance {
 	g.seq++
 	p, err := p2ptestutil.RandTestBogusIdentity()
 	if err != nil {
 		panic("FIXME") // TODO change signature
 	}
-	return Session(g.ctx, g.net, p)
+	return SessionWithStrategy(g.ctx, g.net, p, strategy)
 }
 
 func (g *SessionGenerator) Instances(n int) []Instance {
@@ -86,6 +92,12 @@ func (i *Instance) SetBlockstoreLatency(t time.Duration) time.Duration {
 // sessions. To safeguard, use the SessionGenerator to generate sessions. It's
 // just a much better idea.
 func Session(ctx context.Context, net tn.Network, p testutil.Identity) Instance {
+	return SessionWithStrategy(ctx, net, p, decision.ServeAll)
+}
+
+// SessionWithStrategy creates a test bitswap session that serves peers with
+// 'strategy'
+func SessionWithStrategy(ctx context.Context, net tn.Network, p testutil.Identity, strategy decision.Strategy) Instance {
 	bsdelay := delay.Fixed(0)
 	const bloomSize = 512
 	const writeCacheElems = 100
@@ -99,9 +111,7 @@ func Session(ctx context.Context, net tn.Network, p testutil.Identity) Instance
 		panic(err.Error()) // FIXME perhaps change signature and return error.
 	}
 
-	const alwaysSendToPeer = true
-
-	bs := New(ctx, p.ID(), adapter, bstore, alwaysSendToPeer).(*Bitswap)
+	bs := New(ctx, p.ID(), adapter, bstore, strategy).(*Bitswap)
 
 	return Instance{
 		Peer:            p.ID(),
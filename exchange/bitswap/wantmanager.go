@@ -6,6 +6,7 @@ import (
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	engine "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
+	limiter "github.com/ipfs/go-ipfs/exchange/bitswap/limiter"
 	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
 	wantlist "github.com/ipfs/go-ipfs/exchange/bitswap/wantlist"
@@ -15,7 +16,7 @@ import (
 
 type WantManager struct {
 	// sync channels for Run loop
-	incoming   chan []*bsmsg.Entry
+	incoming   chan *wantSet
 	connect    chan peer.ID        // notification channel for new peers connecting
 	disconnect chan peer.ID        // notification channel for peers disconnecting
 	peerReqs   chan chan []peer.ID // channel to request connected peers on
@@ -23,6 +24,16 @@ type WantManager struct {
 	// synchronized by Run loop, only touch inside there
 	peers map[peer.ID]*msgQueue
 	wl    *wantlist.ThreadSafe
+	// the peers wanted keys are sent to, for those not sent to all
+	targets map[key.Key][]peer.ID
+
+	// inbound limits the bandwidth of the blocks received, by holding back
+	// our wants while over it
+	inbound *limiter.Limiter
+
+	// keys that stopped being wanted, and when
+	cancelLk  sync.Mutex
+	cancelled map[key.Key]time.Time
 
 	network bsnet.BitSwapNetwork
 	ctx     context.Context
@@ -32,18 +43,28 @@ type WantManager struct {
 func NewWantManager(ctx context.Context, network bsnet.BitSwapNetwork) *WantManager {
 	ctx, cancel := context.WithCancel(ctx)
 	return &WantManager{
-		incoming:   make(chan []*bsmsg.Entry, 10),
+		incoming:   make(chan *wantSet, 10),
 		connect:    make(chan peer.ID, 10),
 		disconnect: make(chan peer.ID, 10),
 		peerReqs:   make(chan chan []peer.ID),
 		peers:      make(map[peer.ID]*msgQueue),
 		wl:         wantlist.NewThreadSafe(),
+		targets:    make(map[key.Key][]peer.ID),
+		inbound:    limiter.New(0, 0),
+		cancelled:  make(map[key.Key]time.Time),
 		network:    network,
 		ctx:        ctx,
 		cancel:     cancel,
 	}
 }
 
+// wantSet is a change to the wantlist, to send to 'targets', or to all
+// peers if there are none
+type wantSet struct {
+	entries []*bsmsg.Entry
+	targets []peer.ID
+}
+
 type msgPair struct {
 	to  peer.ID
 	msg bsmsg.BitSwapMessage
@@ -63,6 +84,8 @@ type msgQueue struct {
 
 	sender bsnet.MessageSender
 
+	inbound *limiter.Limiter
+
 	refcnt int
 
 	work chan struct{}
@@ -71,18 +94,35 @@ type msgQueue struct {
 
 func (pm *WantManager) WantBlocks(ctx context.Context, ks []key.Key) {
 	log.Infof("want blocks: %s", ks)
-	pm.addEntries(ctx, ks, false)
+	pm.addEntries(ctx, ks, nil, false, bsmsg.WantBlock)
+}
+
+// WantBlocksFrom only sends the wants for 'ks' to 'peers', until they are
+// wanted from all peers. The peers tell when they don't have the blocks.
+func (pm *WantManager) WantBlocksFrom(ctx context.Context, ks []key.Key, peers []peer.ID) {
+	log.Infof("want blocks from %s: %s", peers, ks)
+	pm.addEntries(ctx, ks, peers, false, bsmsg.WantBlock)
+}
+
+// WantHaves asks 'peers' whether they have the blocks of 'ks'. Unlike wants
+// for blocks, they are not kept in the wantlist.
+func (pm *WantManager) WantHaves(ctx context.Context, ks []key.Key, peers []peer.ID) {
+	log.Infof("want haves from %s: %s", peers, ks)
+	pm.addEntries(ctx, ks, peers, false, bsmsg.WantHave)
 }
 
 func (pm *WantManager) CancelWants(ks []key.Key) {
-	pm.addEntries(context.TODO(), ks, true)
+	pm.addEntries(context.TODO(), ks, nil, true, bsmsg.WantBlock)
 }
 
-func (pm *WantManager) addEntries(ctx context.Context, ks []key.Key, cancel bool) {
+func (pm *WantManager) addEntries(ctx context.Context, ks []key.Key, targets []peer.ID, cancel bool, wantType bsmsg.WantType) {
 	var entries []*bsmsg.Entry
 	for i, k := range ks {
 		entries = append(entries, &bsmsg.Entry{
-			Cancel: cancel,
+			Cancel:   cancel,
+			WantType: wantType,
+			// only worth it from the few peers wants are sent to
+			SendDontHave: !cancel && len(targets) > 0,
 			Entry: wantlist.Entry{
 				Key:      k,
 				Priority: kMaxPriority - i,
@@ -91,29 +131,74 @@ func (pm *WantManager) addEntries(ctx context.Context, ks []key.Key, cancel bool
 		})
 	}
 	select {
-	case pm.incoming <- entries:
+	case pm.incoming <- &wantSet{entries: entries, targets: targets}:
 	case <-pm.ctx.Done():
 	}
 }
 
+// cancelGrace is how long blocks are still expected once they stopped being
+// wanted, as peers may have sent them before they got the cancel
+var cancelGrace = time.Minute
+
+// recentlyCancelled returns whether 'k' stopped being wanted less than
+// cancelGrace ago
+func (pm *WantManager) recentlyCancelled(k key.Key) bool {
+	pm.cancelLk.Lock()
+	defer pm.cancelLk.Unlock()
+	t, ok := pm.cancelled[k]
+	return ok && time.Since(t) < cancelGrace
+}
+
+// noteCancelled notes that 'k' stopped being wanted
+func (pm *WantManager) noteCancelled(k key.Key) {
+	pm.cancelLk.Lock()
+	defer pm.cancelLk.Unlock()
+	pm.cancelled[k] = time.Now()
+}
+
+// forgetCancelled forgets the keys noted more than cancelGrace ago
+func (pm *WantManager) forgetCancelled() {
+	pm.cancelLk.Lock()
+	defer pm.cancelLk.Unlock()
+	for k, t := range pm.cancelled {
+		if time.Since(t) >= cancelGrace {
+			delete(pm.cancelled, k)
+		}
+	}
+}
+
+// Inbound returns the limiter of the bandwidth used to receive blocks
+func (pm *WantManager) Inbound() *limiter.Limiter {
+	return pm.inbound
+}
+
 func (pm *WantManager) ConnectedPeers() []peer.ID {
 	resp := make(chan []peer.ID)
 	pm.peerReqs <- resp
 	return <-resp
 }
 
-func (pm *WantManager) SendBlock(ctx context.Context, env *engine.Envelope) {
+func (pm *WantManager) SendBlock(ctx context.Context, env *engine.Envelope) error {
 	// Blocks need to be sent synchronously to maintain proper backpressure
 	// throughout the network stack
 	defer env.Sent()
 
 	msg := bsmsg.New(false)
-	msg.AddBlock(env.Block)
-	log.Infof("Sending block %s to %s", env.Block, env.Peer)
+	if env.Block != nil {
+		msg.AddBlock(env.Block)
+		log.Infof("Sending block %s to %s", env.Block, env.Peer)
+	}
+	for _, k := range env.Haves {
+		msg.AddHave(k)
+	}
+	for _, k := range env.DontHaves {
+		msg.AddDontHave(k)
+	}
 	err := pm.network.SendMessage(ctx, env.Peer, msg)
 	if err != nil {
 		log.Infof("sendblock error: %s", err)
 	}
+	return err
 }
 
 func (pm *WantManager) startPeerHandler(p peer.ID) *msgQueue {
@@ -128,7 +213,9 @@ func (pm *WantManager) startPeerHandler(p peer.ID) *msgQueue {
 	// new peer, we will want to give them our full wantlist
 	fullwantlist := bsmsg.New(true)
 	for _, e := range pm.wl.Entries() {
-		fullwantlist.AddEntry(e.Key, e.Priority)
+		if pm.sendsTo(e.Key, p) {
+			fullwantlist.AddEntry(e.Key, e.Priority)
+		}
 	}
 	mq.out = fullwantlist
 	mq.work <- struct{}{}
@@ -197,6 +284,12 @@ func (mq *msgQueue) doWork(ctx context.Context) {
 		mq.sender = nsender
 	}
 
+	// hold back more wants until the blocks received so far are within the
+	// inbound limits. Changes to the message meanwhile are sent along.
+	if d := mq.inbound.Delay(mq.p); d > 0 && !mq.holdBack(ctx, d) {
+		return
+	}
+
 	// grab outgoing message
 	mq.outlk.Lock()
 	wlm := mq.out
@@ -208,14 +301,71 @@ func (mq *msgQueue) doWork(ctx context.Context) {
 	mq.outlk.Unlock()
 
 	// send wantlist updates
+	mq.send(wlm)
+}
+
+// holdBack waits for 'd' before more wants are sent. Cancels are sent
+// meanwhile, so that blocks we already have stop coming. Returns false if
+// the queue stopped or sending failed.
+func (mq *msgQueue) holdBack(ctx context.Context, d time.Duration) bool {
+	wait := time.After(d)
+	for {
+		if cancels := mq.takeCancels(); cancels != nil && !mq.send(cancels) {
+			return false
+		}
+
+		select {
+		case <-wait:
+			return true
+		case <-mq.work:
+			// more changes to the message
+		case <-mq.done:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// takeCancels removes the cancels from the outgoing message, and returns
+// them in a message of their own, or nil if there are none
+func (mq *msgQueue) takeCancels() bsmsg.BitSwapMessage {
+	mq.outlk.Lock()
+	defer mq.outlk.Unlock()
+	if mq.out == nil {
+		return nil
+	}
+
+	var cancels bsmsg.BitSwapMessage
+	rest := bsmsg.New(mq.out.Full())
+	for _, e := range mq.out.Wantlist() {
+		if !e.Cancel {
+			rest.AddWant(e.Key, e.Priority, e.WantType, e.SendDontHave)
+			continue
+		}
+		if cancels == nil {
+			cancels = bsmsg.New(false)
+		}
+		cancels.Cancel(e.Key)
+	}
+
+	if cancels != nil {
+		mq.out = rest
+	}
+	return cancels
+}
+
+// send sends 'wlm' to the peer, and drops the stream if that fails
+func (mq *msgQueue) send(wlm bsmsg.BitSwapMessage) bool {
 	err := mq.sender.SendMsg(wlm)
 	if err != nil {
 		log.Infof("bitswap send error: %s", err)
 		mq.sender.Close()
 		mq.sender = nil
 		// TODO: what do we do if this fails?
-		return
+		return false
 	}
+	return true
 }
 
 func (pm *WantManager) Connected(p peer.ID) {
@@ -238,23 +388,54 @@ func (pm *WantManager) Run() {
 	defer tock.Stop()
 	for {
 		select {
-		case entries := <-pm.incoming:
+		case ws := <-pm.incoming:
 
 			// add changes to our wantlist
-			for _, e := range entries {
+			for _, e := range ws.entries {
+				if e.WantType == bsmsg.WantHave {
+					// only asks whether peers have the block
+					continue
+				}
 				if e.Cancel {
+					// noted first, so that keys not in the wantlist
+					// anymore are always found there
+					if _, wanted := pm.wl.Contains(e.Key); wanted {
+						pm.noteCancelled(e.Key)
+					}
 					pm.wl.Remove(e.Key)
-				} else {
-					pm.wl.AddEntry(e.Entry)
+					delete(pm.targets, e.Key)
+					continue
 				}
+
+				_, wanted := pm.wl.Contains(e.Key)
+				switch {
+				case len(ws.targets) == 0:
+					delete(pm.targets, e.Key)
+				case !wanted:
+					pm.targets[e.Key] = ws.targets
+				case len(pm.targets[e.Key]) > 0:
+					// already wanted from some peers only
+					pm.targets[e.Key] = append(pm.targets[e.Key], ws.targets...)
+				}
+				pm.wl.AddEntry(e.Entry)
 			}
 
-			// broadcast those wantlist changes
-			for _, p := range pm.peers {
-				p.addMessage(entries)
+			if len(ws.targets) == 0 {
+				// broadcast those wantlist changes
+				for _, p := range pm.peers {
+					p.addMessage(ws.entries)
+				}
+				break
+			}
+			for _, t := range ws.targets {
+				if p, ok := pm.peers[t]; ok {
+					p.addMessage(ws.entries)
+				}
 			}
 
 		case <-tock.C:
+			pm.forgetCancelled()
+
 			// resend entire wantlist every so often (REALLY SHOULDNT BE NECESSARY)
 			var es []*bsmsg.Entry
 			for _, e := range pm.wl.Entries() {
@@ -268,12 +449,12 @@ func (pm *WantManager) Run() {
 				}
 				es = append(es, &bsmsg.Entry{Entry: e})
 			}
-			for _, p := range pm.peers {
+			for pid, p := range pm.peers {
 				p.outlk.Lock()
 				p.out = bsmsg.New(true)
 				p.outlk.Unlock()
 
-				p.addMessage(es)
+				p.addMessage(pm.entriesFor(pid, es))
 			}
 		case p := <-pm.connect:
 			pm.startPeerHandler(p)
@@ -291,11 +472,40 @@ func (pm *WantManager) Run() {
 	}
 }
 
+// sendsTo returns whether the want for 'k' is sent to 'p'
+func (pm *WantManager) sendsTo(k key.Key, p peer.ID) bool {
+	targets, ok := pm.targets[k]
+	if !ok {
+		return true
+	}
+	for _, t := range targets {
+		if t == p {
+			return true
+		}
+	}
+	return false
+}
+
+// entriesFor returns the entries of 'es' that are sent to 'p'
+func (pm *WantManager) entriesFor(p peer.ID, es []*bsmsg.Entry) []*bsmsg.Entry {
+	if len(pm.targets) == 0 {
+		return es
+	}
+	var out []*bsmsg.Entry
+	for _, e := range es {
+		if pm.sendsTo(e.Key, p) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
 func (wm *WantManager) newMsgQueue(p peer.ID) *msgQueue {
 	mq := new(msgQueue)
 	mq.done = make(chan struct{})
 	mq.work = make(chan struct{}, 1)
 	mq.network = wm.network
+	mq.inbound = wm.inbound
 	mq.p = p
 	mq.refcnt = 1
 
@@ -325,7 +535,7 @@ func (mq *msgQueue) addMessage(entries []*bsmsg.Entry) {
 		if e.Cancel {
 			mq.out.Cancel(e.Key)
 		} else {
-			mq.out.AddEntry(e.Key, e.Priority)
+			mq.out.AddWant(e.Key, e.Priority, e.WantType, e.SendDontHave)
 		}
 	}
 }
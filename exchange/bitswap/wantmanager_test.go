@@ -0,0 +1,70 @@
+package bitswap
+
+import (
+	"testing"
+	"time"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	wantlist "github.com/ipfs/go-ipfs/exchange/bitswap/wantlist"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// fakeSender hands out the messages sent to it
+type fakeSender struct {
+	sent chan bsmsg.BitSwapMessage
+}
+
+func (fs *fakeSender) SendMsg(m bsmsg.BitSwapMessage) error {
+	fs.sent <- m
+	return nil
+}
+
+func (fs *fakeSender) Close() error {
+	return nil
+}
+
+func TestInboundLimitHoldsBackWantsOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := testutil.RandPeerIDFatal(t)
+	wm := NewWantManager(ctx, nil)
+	wm.Inbound().SetLimits(0, 1)
+	wm.Inbound().Record(p, 1000)
+
+	sender := &fakeSender{sent: make(chan bsmsg.BitSwapMessage, 10)}
+	mq := wm.newMsgQueue(p)
+	mq.sender = sender
+	defer close(mq.done)
+
+	mq.addMessage([]*bsmsg.Entry{
+		{Entry: wantlist.Entry{Key: "wanted"}},
+		{Entry: wantlist.Entry{Key: "cancelled"}, Cancel: true},
+	})
+	go mq.runQueue(ctx)
+
+	expectCancel := func(k key.Key) {
+		select {
+		case m := <-sender.sent:
+			wl := m.Wantlist()
+			if len(wl) != 1 || wl[0].Key != k || !wl[0].Cancel {
+				t.Fatalf("expected only the cancel of %s, got %v", k, wl)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("cancel of %s held back", k)
+		}
+	}
+	expectCancel(key.Key("cancelled"))
+
+	// cancels added while held back go out too
+	mq.addMessage([]*bsmsg.Entry{{Entry: wantlist.Entry{Key: "later"}, Cancel: true}})
+	expectCancel(key.Key("later"))
+
+	select {
+	case m := <-sender.sent:
+		t.Fatalf("wants sent while over the inbound limit: %v", m.Wantlist())
+	case <-time.After(time.Millisecond * 100):
+	}
+}
@@ -58,13 +58,23 @@ func (bs *Bitswap) taskWorker(ctx context.Context, id int) {
 				if !ok {
 					continue
 				}
-				log.Event(ctx, "Bitswap.TaskWorker.Work", logging.LoggableMap{
+				if bs.reputation.Banned(envelope.Peer) {
+					envelope.Sent()
+					continue
+				}
+				work := logging.LoggableMap{
 					"ID":     id,
 					"Target": envelope.Peer.Pretty(),
-					"Block":  envelope.Block.Multihash().B58String(),
-				})
+				}
+				if envelope.Block != nil {
+					work["Block"] = envelope.Block.Multihash().B58String()
+				}
+				log.Event(ctx, "Bitswap.TaskWorker.Work", work)
 
-				bs.wm.SendBlock(ctx, envelope)
+				err := bs.wm.SendBlock(ctx, envelope)
+				if err == nil && envelope.Block != nil {
+					bs.engine.BlockSent(envelope.Peer, envelope.Block)
+				}
 			case <-ctx.Done():
 				return
 			}
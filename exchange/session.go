@@ -0,0 +1,49 @@
+package exchange
+
+import (
+	"sync/atomic"
+
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// Session groups requests that belong together, like those for the blocks
+// of one DAG. Exchanges may make use of it, as the peers that had the blocks
+// requested first likely have the others.
+type Session struct {
+	id   uint64
+	done <-chan struct{}
+}
+
+// ID identifies the session
+func (s *Session) ID() uint64 {
+	return s.id
+}
+
+// Done is closed when the session ends
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+var lastSessionID uint64
+
+type sessionContextKey struct{}
+
+// WithSession returns a context whose requests belong to a session, which
+// lasts until 'ctx' is done. Contexts that belong to a session already keep
+// theirs.
+func WithSession(ctx context.Context) context.Context {
+	if _, ok := SessionFromContext(ctx); ok {
+		return ctx
+	}
+	s := &Session{
+		id:   atomic.AddUint64(&lastSessionID, 1),
+		done: ctx.Done(),
+	}
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+// SessionFromContext returns the session the requests of 'ctx' belong to
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(sessionContextKey{}).(*Session)
+	return s, ok
+}
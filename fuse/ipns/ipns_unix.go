@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 
 	fuse "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse"
 	fs "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/fs"
@@ -560,6 +561,56 @@ func (dir *Directory) Rename(ctx context.Context, req *fuse.RenameRequest, newDi
 	return nil
 }
 
+// Getxattr returns the value of an extended attribute of this directory
+func (dir *Directory) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	return getxattr(dir.dir, req, resp)
+}
+
+// Listxattr lists the extended attributes of this directory
+func (dir *Directory) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	return listxattr(dir.dir, resp)
+}
+
+// Getxattr returns the value of an extended attribute of this file
+func (fi *FileNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	return getxattr(fi.fi, req, resp)
+}
+
+// Listxattr lists the extended attributes of this file
+func (fi *FileNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	return listxattr(fi.fi, resp)
+}
+
+func getxattr(fsn mfs.FSNode, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	xattrs, err := fsn.Xattrs()
+	if err != nil {
+		return err
+	}
+
+	val, ok := xattrs[req.Name]
+	if !ok {
+		return fuse.ErrNoXattr
+	}
+	resp.Xattr = val
+	return nil
+}
+
+func listxattr(fsn mfs.FSNode, resp *fuse.ListxattrResponse) error {
+	xattrs, err := fsn.Xattrs()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp.Append(names...)
+	return nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -584,6 +635,8 @@ type ipnsDirectory interface {
 	fs.NodeRemover
 	fs.NodeRenamer
 	fs.NodeStringLookuper
+	fs.NodeGetxattrer
+	fs.NodeListxattrer
 }
 
 var _ ipnsDirectory = (*Directory)(nil)
@@ -599,6 +652,8 @@ type ipnsFileNode interface {
 	fs.Node
 	fs.NodeFsyncer
 	fs.NodeOpener
+	fs.NodeGetxattrer
+	fs.NodeListxattrer
 }
 
 var _ ipnsFileNode = (*FileNode)(nil)
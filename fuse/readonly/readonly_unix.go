@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"syscall"
 
 	fuse "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse"
@@ -15,6 +16,7 @@ import (
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	lgbl "github.com/ipfs/go-ipfs/thirdparty/loggables"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
 	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
@@ -161,6 +163,41 @@ func (s *Node) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string,
 	return string(s.cached.GetData()), nil
 }
 
+// Getxattr returns the value of an extended attribute stored on this node
+func (s *Node) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	xattrs, err := ft.Xattrs(s.Nd.Data())
+	if err != nil {
+		return err
+	}
+
+	val, ok := xattrs[req.Name]
+	if !ok {
+		return fuse.ErrNoXattr
+	}
+	resp.Xattr = val
+	return nil
+}
+
+// Listxattr lists the names of the extended attributes stored on this node
+func (s *Node) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	xattrs, err := ft.Xattrs(s.Nd.Data())
+	if err != nil {
+		return err
+	}
+
+	resp.Append(sortedXattrNames(xattrs)...)
+	return nil
+}
+
+func sortedXattrNames(xattrs map[string][]byte) []string {
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (s *Node) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
 
 	k, err := s.Nd.Key()
@@ -211,6 +248,8 @@ type roNode interface {
 	fs.Node
 	fs.NodeStringLookuper
 	fs.NodeReadlinker
+	fs.NodeGetxattrer
+	fs.NodeListxattrer
 }
 
 var _ roNode = (*Node)(nil)
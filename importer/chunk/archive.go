@@ -0,0 +1,261 @@
+package chunk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	tarBlockSize = 512
+
+	// largest tar extended header kept with the header it belongs to
+	maxTarMetaSize = 64 * 1024
+
+	zipLocalHeaderSize = 30
+)
+
+var zipLocalHeaderSig = []byte("PK\x03\x04")
+
+type archiveFormat int
+
+const (
+	formatUnknown archiveFormat = iota
+	formatTar
+	formatZip
+	// not an archive, or past its entries
+	formatPlain
+)
+
+// Archive is a splitter for tar and zip streams. It puts the headers of
+// archive entries in blocks of their own, and splits the content of each
+// entry starting at its first byte, so that the same file stored in
+// different archives yields the same blocks. Anything it does not recognize
+// is split into fixed size blocks.
+type Archive struct {
+	r    *bufio.Reader
+	size int64
+	err  error
+
+	format archiveFormat
+
+	// bytes of the current entry's content left to return, and the padding
+	// that follows it
+	remaining int64
+	pad       int64
+}
+
+// NewArchive returns a splitter for the archive in 'r'. Entries are split
+// into blocks of at most 'size' bytes.
+func NewArchive(r io.Reader, size int64) *Archive {
+	return &Archive{
+		r:    bufio.NewReaderSize(r, tarBlockSize*2),
+		size: size,
+	}
+}
+
+func (a *Archive) NextBytes() ([]byte, error) {
+	if a.remaining > 0 {
+		n := a.remaining
+		if n > a.size {
+			n = a.size
+		}
+		a.remaining -= n
+		return a.read(nil, n)
+	}
+
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	if a.format == formatUnknown {
+		a.format = a.detect()
+	}
+
+	switch a.format {
+	case formatTar:
+		return a.nextTar()
+	case formatZip:
+		return a.nextZip()
+	default:
+		return a.read(nil, a.size)
+	}
+}
+
+// String returns the parameters of this splitter in the form understood by
+// FromString.
+func (a *Archive) String() string {
+	return fmt.Sprintf("tar-%d", a.size)
+}
+
+func (a *Archive) detect() archiveFormat {
+	head, _ := a.r.Peek(tarBlockSize)
+	switch {
+	case bytes.HasPrefix(head, zipLocalHeaderSig):
+		return formatZip
+	case len(head) == tarBlockSize && validTarHeader(head):
+		return formatTar
+	default:
+		return formatPlain
+	}
+}
+
+// read appends the next 'n' bytes of input to 'buf'. At the end of the
+// input, what was read is returned and io.EOF is returned after it.
+func (a *Archive) read(buf []byte, n int64) ([]byte, error) {
+	if a.err != nil {
+		if len(buf) > 0 {
+			return buf, nil
+		}
+		return nil, a.err
+	}
+
+	start := len(buf)
+	buf = append(buf, make([]byte, n)...)
+	read, err := io.ReadFull(a.r, buf[start:])
+	buf = buf[:start+read]
+
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		a.err = io.EOF
+		a.remaining = 0
+		if len(buf) == 0 {
+			return nil, io.EOF
+		}
+	default:
+		return nil, err
+	}
+	return buf, nil
+}
+
+// nextTar returns the headers up to the content of the next entry that has
+// any, preceded by the padding of the previous entry.
+func (a *Archive) nextTar() ([]byte, error) {
+	buf, err := a.read(nil, a.pad)
+	if err != nil {
+		return nil, err
+	}
+	a.pad = 0
+
+	for int64(len(buf)) < a.size {
+		head, err := a.r.Peek(tarBlockSize)
+		if err != nil || !validTarHeader(head) {
+			// the end of archive marker, or something that is not tar
+			a.format = formatPlain
+			break
+		}
+
+		buf, err = a.read(buf, tarBlockSize)
+		if err != nil {
+			return nil, err
+		}
+		head = buf[len(buf)-tarBlockSize:]
+
+		size, err := tarSize(head)
+		if err != nil {
+			a.format = formatPlain
+			break
+		}
+		pad := (tarBlockSize - size%tarBlockSize) % tarBlockSize
+
+		switch head[156] {
+		case 'x', 'g', 'L', 'K':
+			// extended headers and long names describe the next entry
+			if size <= maxTarMetaSize {
+				buf, err = a.read(buf, size+pad)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		if size > 0 {
+			a.remaining = size
+			a.pad = pad
+			break
+		}
+	}
+
+	if len(buf) == 0 {
+		return a.read(nil, a.size)
+	}
+	return buf, nil
+}
+
+// validTarHeader returns whether 'head' is a tar header with a correct
+// checksum. Blocks of zeros, which mark the end of an archive, are not.
+func validTarHeader(head []byte) bool {
+	sum, err := parseTarNumber(head[148:156])
+	if err != nil || sum == 0 {
+		return false
+	}
+
+	// the checksum is computed as if its own field held spaces
+	var total int64
+	for i, b := range head[:tarBlockSize] {
+		if i >= 148 && i < 156 {
+			b = ' '
+		}
+		total += int64(b)
+	}
+	return total == sum
+}
+
+func tarSize(head []byte) (int64, error) {
+	field := head[124:136]
+	if field[0]&0x80 != 0 {
+		// base-256 encoding, used for sizes of 8GB and over
+		var n int64
+		for _, b := range field[4:] {
+			n = n<<8 | int64(b)
+		}
+		return n, nil
+	}
+	return parseTarNumber(field)
+}
+
+func parseTarNumber(field []byte) (int64, error) {
+	s := string(bytes.Trim(field, " \x00"))
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 8, 64)
+}
+
+// nextZip returns the header of the next zip entry. Entries that don't
+// record their size up front, and the central directory after the entries,
+// are split like plain data.
+func (a *Archive) nextZip() ([]byte, error) {
+	head, err := a.r.Peek(zipLocalHeaderSize)
+	if err != nil || !bytes.HasPrefix(head, zipLocalHeaderSig) {
+		a.format = formatPlain
+		return a.read(nil, a.size)
+	}
+
+	flags := binary.LittleEndian.Uint16(head[6:])
+	size := int64(binary.LittleEndian.Uint32(head[18:]))
+	nameLen := int64(binary.LittleEndian.Uint16(head[26:]))
+	extraLen := int64(binary.LittleEndian.Uint16(head[28:]))
+
+	buf, err := a.read(nil, zipLocalHeaderSize+nameLen+extraLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if flags&0x8 != 0 || size == 0xffffffff {
+		// the size follows the content or is stored elsewhere for zip64
+		// entries, so the end of the content can't be found
+		a.format = formatPlain
+		return buf, nil
+	}
+
+	if a.err == nil {
+		a.remaining = size
+	}
+	return buf, nil
+}
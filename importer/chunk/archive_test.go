@@ -0,0 +1,160 @@
+package chunk
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+type archiveEntry struct {
+	name string
+	data []byte
+}
+
+func makeTar(t *testing.T, entries []archiveEntry) []byte {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name: e.name,
+			Mode: 0644,
+			Size: int64(len(e.data)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// makeZip writes stored entries with their sizes in the local headers,
+// which archive/zip only does for seekable outputs
+func makeZip(entries []archiveEntry) []byte {
+	buf := new(bytes.Buffer)
+	for _, e := range entries {
+		hdr := make([]byte, zipLocalHeaderSize)
+		copy(hdr, zipLocalHeaderSig)
+		binary.LittleEndian.PutUint16(hdr[4:], 10)
+		binary.LittleEndian.PutUint32(hdr[14:], crc32.ChecksumIEEE(e.data))
+		binary.LittleEndian.PutUint32(hdr[18:], uint32(len(e.data)))
+		binary.LittleEndian.PutUint32(hdr[22:], uint32(len(e.data)))
+		binary.LittleEndian.PutUint16(hdr[26:], uint16(len(e.name)))
+
+		buf.Write(hdr)
+		buf.WriteString(e.name)
+		buf.Write(e.data)
+	}
+
+	// stand in for the central directory
+	buf.WriteString("PK\x01\x02 central directory")
+	return buf.Bytes()
+}
+
+func checkArchiveSplit(t *testing.T, in []byte, shared []byte, size int) {
+	chunks := splitAll(t, NewArchive(bytes.NewReader(in), int64(size)))
+
+	var joined []byte
+	keys := make(map[string]bool)
+	for _, c := range chunks {
+		if len(c) == 0 {
+			t.Fatal("got an empty chunk")
+		}
+		if len(c) > size+maxTarMetaSize+tarBlockSize*2 {
+			t.Fatalf("chunk too large: %d", len(c))
+		}
+		joined = append(joined, c...)
+		keys[string(c)] = true
+	}
+
+	if !bytes.Equal(joined, in) {
+		t.Fatal("chunks don't add up to the input")
+	}
+
+	// the shared file must be split on its own
+	for off := 0; off < len(shared); off += size {
+		end := off + size
+		if end > len(shared) {
+			end = len(shared)
+		}
+		if !keys[string(shared[off:end])] {
+			t.Fatalf("shared file not split at %d", off)
+		}
+	}
+}
+
+func TestArchiveSplitterTar(t *testing.T) {
+	shared := randBuf(t, 10000)
+
+	a := makeTar(t, []archiveEntry{
+		{"a.txt", randBuf(t, 777)},
+		{"empty", nil},
+		{"shared", shared},
+		{"z.txt", randBuf(t, 3000)},
+	})
+	b := makeTar(t, []archiveEntry{
+		{"other/long/path/to/b.bin", randBuf(t, 1234)},
+		{"shared", shared},
+	})
+
+	checkArchiveSplit(t, a, shared, 4096)
+	checkArchiveSplit(t, b, shared, 4096)
+}
+
+func TestArchiveSplitterZip(t *testing.T) {
+	shared := randBuf(t, 10000)
+
+	a := makeZip([]archiveEntry{
+		{"a.txt", randBuf(t, 777)},
+		{"shared", shared},
+	})
+	b := makeZip([]archiveEntry{
+		{"b.txt", randBuf(t, 3001)},
+		{"c.txt", nil},
+		{"shared", shared},
+	})
+
+	checkArchiveSplit(t, a, shared, 4096)
+	checkArchiveSplit(t, b, shared, 4096)
+}
+
+func TestArchiveSplitterPlain(t *testing.T) {
+	data := randBuf(t, 10000)
+	chunks := splitAll(t, NewArchive(bytes.NewReader(data), 4096))
+	if len(chunks) != 3 || len(chunks[0]) != 4096 || len(chunks[2]) != 10000-2*4096 {
+		t.Fatal("non archive input was not split into fixed size blocks")
+	}
+
+	// a truncated archive is still returned in full
+	tr := makeTar(t, []archiveEntry{{"a", randBuf(t, 5000)}})[:3000]
+	var joined []byte
+	for _, c := range splitAll(t, NewArchive(bytes.NewReader(tr), 1024)) {
+		joined = append(joined, c...)
+	}
+	if !bytes.Equal(joined, tr) {
+		t.Fatal("truncated archive was not returned in full")
+	}
+}
+
+func TestArchiveFromString(t *testing.T) {
+	spl, err := FromString(nil, "tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spl.(*Archive).String() != "tar-262144" {
+		t.Fatalf("unexpected parameters: %s", spl.(*Archive).String())
+	}
+
+	for _, bad := range []string{"tar-100", "tar-x"} {
+		if _, err := FromString(nil, bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"gx/ipfs/QmZNVWh8LLjAavuQ2JXuFmuYH3C11xo988vSgp7UQrTRj1/go-ipfs-util"
+)
+
+func splitAll(t testing.TB, s Splitter) [][]byte {
+	var chunks [][]byte
+	for {
+		chunk, err := s.NextBytes()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestBuzhashChunking(t *testing.T) {
+	data := make([]byte, 1024*1024*16)
+	util.NewTimeSeededRand().Read(data)
+
+	chunks := splitAll(t, NewBuzhashMinMax(bytes.NewReader(data), 1024*64, 1024*256, 1024*512))
+
+	for i, c := range chunks[:len(chunks)-1] {
+		if len(c) < 1024*64 || len(c) > 1024*512 {
+			t.Fatalf("chunk %d has size %d, outside of bounds", i, len(c))
+		}
+	}
+
+	avg := len(data) / len(chunks)
+	if avg < 1024*128 || avg > 1024*384 {
+		t.Fatalf("average block size %d is far from the requested one", avg)
+	}
+
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatal("data was chunked incorrectly")
+	}
+}
+
+func TestBuzhashChunkReuse(t *testing.T) {
+	data := make([]byte, 1024*1024*16)
+	util.NewTimeSeededRand().Read(data)
+
+	seen := make(map[string]bool)
+	for _, c := range splitAll(t, NewBuzhash(bytes.NewReader(data[1000:]), 1024*256)) {
+		seen[string(c)] = true
+	}
+
+	chunks := splitAll(t, NewBuzhash(bytes.NewReader(data), 1024*256))
+	var extra int
+	for _, c := range chunks {
+		if !seen[string(c)] {
+			extra++
+		}
+	}
+
+	if extra > 2 {
+		t.Fatalf("shifting the input changed %d of %d chunks", extra, len(chunks))
+	}
+}
+
+// The boundaries produced for a given input must never change, or re-adding
+// the same data would produce different hashes.
+func TestBuzhashStableBoundaries(t *testing.T) {
+	data := make([]byte, 1024*256)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	spl, err := FromString(bytes.NewReader(data), "buzhash-4096-16384-65536")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	for _, c := range splitAll(t, spl) {
+		sizes = append(sizes, len(c))
+	}
+
+	expected := []int{4624, 15627, 12578, 10459, 10410, 12955, 16178, 14722, 6149, 40329,
+		15705, 23551, 16235, 6538, 4982, 6621, 9877, 13538, 10902, 4825, 5339}
+	if len(sizes) != len(expected) {
+		t.Fatalf("expected chunk sizes %v, got %v", expected, sizes)
+	}
+	for i := range sizes {
+		if sizes[i] != expected[i] {
+			t.Fatalf("expected chunk sizes %v, got %v", expected, sizes)
+		}
+	}
+}
+
+func TestBuzhashFromString(t *testing.T) {
+	spl, err := FromString(nil, "buzhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the recorded parameters must produce the same splitter
+	desc := spl.(*Buzhash).String()
+	again, err := FromString(nil, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.(*Buzhash).String() != desc {
+		t.Fatalf("parameters %q did not round trip", desc)
+	}
+
+	for _, bad := range []string{"buzhash-1", "buzhash-10-5-20", "buzhash-10-20-15", "buzhash-1-2"} {
+		if _, err := FromString(nil, bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func benchmarkSplitter(b *testing.B, gen SplitterGen) {
+	data := make([]byte, 1024*1024*16)
+	util.NewTimeSeededRand().Read(data)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		splitAll(b, gen(bytes.NewReader(data)))
+	}
+}
+
+func BenchmarkBuzhash(b *testing.B) {
+	benchmarkSplitter(b, func(r io.Reader) Splitter {
+		return NewBuzhash(r, 1024*256)
+	})
+}
+
+func BenchmarkRabin(b *testing.B) {
+	benchmarkSplitter(b, func(r io.Reader) Splitter {
+		return NewRabin(r, 1024*256)
+	})
+}
@@ -1,6 +1,7 @@
 package chunk
 
 import (
+	"fmt"
 	"hash/fnv"
 	"io"
 
@@ -11,6 +12,8 @@ var IpfsRabinPoly = chunker.Pol(17437180132763653)
 
 type Rabin struct {
 	r *chunker.Chunker
+
+	min, avg, max uint64
 }
 
 func NewRabin(r io.Reader, avgBlkSize uint64) *Rabin {
@@ -25,7 +28,10 @@ func NewRabinMinMax(r io.Reader, min, avg, max uint64) *Rabin {
 	ch := chunker.New(r, IpfsRabinPoly, h, avg, min, max)
 
 	return &Rabin{
-		r: ch,
+		r:   ch,
+		min: min,
+		avg: avg,
+		max: max,
 	}
 }
 
@@ -37,3 +43,9 @@ func (r *Rabin) NextBytes() ([]byte, error) {
 
 	return ch.Data, nil
 }
+
+// String returns the parameters of this splitter in the form understood by
+// FromString.
+func (r *Rabin) String() string {
+	return fmt.Sprintf("rabin-%d-%d-%d", r.min, r.avg, r.max)
+}
@@ -0,0 +1,162 @@
+package chunk
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// Hole describes a range of a file that holds no data and reads as zeros
+type Hole struct {
+	Offset int64
+	Length int64
+}
+
+// HoleSplitter is a Splitter that knows about holes in its input. Holes are
+// handed out by size only, so they can be stored without any data.
+type HoleSplitter interface {
+	Splitter
+
+	// NextChunk returns either the next chunk of data, or, if data is nil,
+	// the length of the hole at the current position.
+	NextChunk() (data []byte, hole int64, err error)
+}
+
+type sparseSplitter struct {
+	r     *countingReader
+	holes []Hole
+	gen   SplitterGen
+
+	// splitter for the data range currently being read, and the offset
+	// that range ends at
+	cur  Splitter
+	end  int64
+	last bool
+
+	// zeros left to be returned by NextBytes for the current hole
+	zeros int64
+}
+
+// NewSparseSplitter returns a HoleSplitter reading from 'r', which must be
+// positioned at the start of the file. The given holes must be sorted by
+// offset. Their bytes are skipped in 'r', everything in between is split
+// using splitters created by 'gen'.
+func NewSparseSplitter(r io.Reader, holes []Hole, gen SplitterGen) HoleSplitter {
+	return &sparseSplitter{
+		r:     &countingReader{r: r},
+		holes: holes,
+		gen:   gen,
+	}
+}
+
+func (ss *sparseSplitter) NextChunk() ([]byte, int64, error) {
+	for {
+		if ss.cur != nil {
+			b, err := ss.cur.NextBytes()
+			if err == nil {
+				return b, 0, nil
+			}
+			if err != io.EOF {
+				return nil, 0, err
+			}
+			ss.cur = nil
+
+			// stop once the input runs out, even if holes were expected
+			if ss.last || ss.r.n < ss.end {
+				return nil, 0, io.EOF
+			}
+		}
+
+		if len(ss.holes) == 0 {
+			ss.cur = ss.gen(ss.r)
+			ss.last = true
+			continue
+		}
+
+		h := ss.holes[0]
+		if h.Offset > ss.r.n {
+			// data up to the next hole
+			ss.cur = ss.gen(io.LimitReader(ss.r, h.Offset-ss.r.n))
+			ss.end = h.Offset
+			continue
+		}
+		ss.holes = ss.holes[1:]
+
+		skip := h.Offset + h.Length - ss.r.n
+		if skip <= 0 {
+			continue
+		}
+
+		// the input still contains the zeros of the hole, skip over them
+		n, err := io.CopyN(ioutil.Discard, ss.r, skip)
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		if n == 0 {
+			return nil, 0, io.EOF
+		}
+		return nil, n, nil
+	}
+}
+
+// NextBytes returns holes as runs of zeros, for callers unaware of holes
+func (ss *sparseSplitter) NextBytes() ([]byte, error) {
+	if ss.zeros == 0 {
+		b, hole, err := ss.NextChunk()
+		if err != nil || b != nil {
+			return b, err
+		}
+		ss.zeros = hole
+	}
+
+	n := ss.zeros
+	if n > DefaultBlockSize {
+		n = DefaultBlockSize
+	}
+	ss.zeros -= n
+	return make([]byte, n), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+	return n, err
+}
+
+type holeSplitter struct {
+	size int64
+}
+
+// NewHoleSplitter returns a HoleSplitter whose only output is a hole of
+// 'size' bytes.
+func NewHoleSplitter(size int64) HoleSplitter {
+	return &holeSplitter{size: size}
+}
+
+func (hs *holeSplitter) NextChunk() ([]byte, int64, error) {
+	if hs.size <= 0 {
+		return nil, 0, io.EOF
+	}
+
+	s := hs.size
+	hs.size = 0
+	return nil, s, nil
+}
+
+// NextBytes returns the hole as runs of zeros
+func (hs *holeSplitter) NextBytes() ([]byte, error) {
+	if hs.size <= 0 {
+		return nil, io.EOF
+	}
+
+	n := hs.size
+	if n > DefaultBlockSize {
+		n = DefaultBlockSize
+	}
+	hs.size -= n
+	return make([]byte, n), nil
+}
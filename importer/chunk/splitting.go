@@ -2,6 +2,7 @@
 package chunk
 
 import (
+	"fmt"
 	"io"
 
 	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
@@ -77,3 +78,9 @@ func (ss *sizeSplitterv2) NextBytes() ([]byte, error) {
 
 	return buf[:n], nil
 }
+
+// String returns the parameters of this splitter in the form understood by
+// FromString.
+func (ss *sizeSplitterv2) String() string {
+	return fmt.Sprintf("size-%d", ss.size)
+}
@@ -2,6 +2,7 @@ package chunk
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"testing"
 
@@ -104,3 +105,95 @@ func (s *clipReader) Read(buf []byte) (int, error) {
 
 	return s.r.Read(buf)
 }
+
+func TestSparseSplitter(t *testing.T) {
+	data := randBuf(t, 3000)
+	for i := 1000; i < 2500; i++ {
+		data[i] = 0
+	}
+
+	holes := []Hole{{Offset: 1000, Length: 1500}}
+	spl := NewSparseSplitter(bytes.NewReader(data), holes, SizeSplitterGen(400))
+
+	var out []byte
+	var holeSeen bool
+	for {
+		b, hole, err := spl.NextChunk()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b == nil {
+			if int64(len(out)) != holes[0].Offset || hole != holes[0].Length {
+				t.Fatalf("unexpected hole of %d bytes at %d", hole, len(out))
+			}
+			holeSeen = true
+			out = append(out, make([]byte, hole)...)
+			continue
+		}
+
+		if len(b) > 400 {
+			t.Fatalf("chunk too large: %d", len(b))
+		}
+		out = append(out, b...)
+	}
+
+	if !holeSeen {
+		t.Fatal("hole was not reported")
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("data was not split correctly")
+	}
+
+	// without hole awareness, the hole is returned as zeros
+	spl = NewSparseSplitter(bytes.NewReader(data), holes, SizeSplitterGen(400))
+	out = nil
+	for {
+		b, err := spl.NextBytes()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b...)
+	}
+
+	if !bytes.Equal(out, data) {
+		t.Fatal("zero filled data was not returned correctly")
+	}
+}
+
+func TestSplitterString(t *testing.T) {
+	cases := map[string]string{
+		"":                "size-262144",
+		"size-1000":       "size-1000",
+		"rabin":           "rabin-87381-262144-393216",
+		"rabin-300":       "rabin-100-300-450",
+		"rabin-10-50-100": "rabin-10-50-100",
+		"buzhash-300":     "buzhash-100-300-450",
+	}
+
+	for in, exp := range cases {
+		spl, err := FromString(nil, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		desc := spl.(fmt.Stringer).String()
+		if desc != exp {
+			t.Fatalf("expected %q to be described as %q, got %q", in, exp, desc)
+		}
+
+		again, err := FromString(nil, desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.(fmt.Stringer).String() != desc {
+			t.Fatalf("parameters %q did not round trip", desc)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// checkpointKey is the datastore key under which import checkpoints live
+var checkpointKey = ds.NewKey("/local/imports")
+
+// Checkpoint records the leaves of an import as they are stored, so that an
+// interrupted import can be resumed without chunking and hashing the data
+// it already stored again. Leaves are written to the datastore in segments
+// of one full layer of links each, so the cost of a checkpoint does not
+// grow with the size of the import.
+type Checkpoint struct {
+	dstore ds.Datastore
+	prefix ds.Key
+
+	// number of segments written so far
+	seq int
+
+	// leaves stored since the last segment was written
+	pending []checkpointLeaf
+
+	// leaves of a previous run, handed out again before new data is read,
+	// and the number of leaves of each segment they came from
+	restored []checkpointLeaf
+	segLens  []int
+}
+
+type checkpointLeaf struct {
+	Key  string
+	Size uint64
+}
+
+// NewCheckpoint returns the checkpoint of the import identified by 'id'
+func NewCheckpoint(d ds.Datastore, id string) *Checkpoint {
+	return &Checkpoint{
+		dstore: d,
+		prefix: checkpointKey.ChildString(id),
+	}
+}
+
+func (c *Checkpoint) segmentKey(seq int) ds.Key {
+	return c.prefix.ChildString(fmt.Sprint(seq))
+}
+
+// Load reads the leaves recorded by a previous run of this import, and
+// returns how many bytes of input they cover. Leaves are only kept up to the
+// first one that 'has' reports missing, as blocks may have been garbage
+// collected since.
+func (c *Checkpoint) Load(has func(key.Key) (bool, error)) (uint64, error) {
+	var size uint64
+	for seq := 0; ; seq++ {
+		v, err := c.dstore.Get(c.segmentKey(seq))
+		if err == ds.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		b, ok := v.([]byte)
+		if !ok {
+			return 0, fmt.Errorf("checkpoint segment %d is not bytes", seq)
+		}
+
+		var leaves []checkpointLeaf
+		if err := json.Unmarshal(b, &leaves); err != nil {
+			return 0, err
+		}
+
+		complete := true
+		for _, l := range leaves {
+			ok, err := has(key.B58KeyDecode(l.Key))
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				complete = false
+				break
+			}
+		}
+		if !complete {
+			// later segments are useless without this one
+			if err := c.clearFrom(seq); err != nil {
+				return 0, err
+			}
+			break
+		}
+
+		for _, l := range leaves {
+			size += l.Size
+		}
+		c.restored = append(c.restored, leaves...)
+		c.segLens = append(c.segLens, len(leaves))
+		c.seq = seq + 1
+	}
+
+	return size, nil
+}
+
+// Clear removes everything recorded for this import
+func (c *Checkpoint) Clear() error {
+	c.seq = 0
+	c.pending = nil
+	c.restored = nil
+	c.segLens = nil
+	return c.clearFrom(0)
+}
+
+// Skip reads the input covered by the loaded leaves from 'r', and checks that
+// it is what the leaves hold, so that a file that changed since the previous
+// run is not mixed with its leaves. Leaves are only kept up to the first one
+// that does not match. Returns the rest of the input, starting right after
+// the last leaf kept, and how many bytes were skipped.
+func (c *Checkpoint) Skip(r io.Reader) (io.Reader, uint64, error) {
+	var skipped uint64
+	for i, l := range c.restored {
+		read, ok, err := checkLeaf(l, r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			if err := c.truncate(i); err != nil {
+				return nil, 0, err
+			}
+			return io.MultiReader(read, r), skipped, nil
+		}
+		skipped += l.Size
+	}
+	return r, skipped, nil
+}
+
+// truncate drops the loaded leaves from the i-th on. The leaves kept of the
+// segment they end in are recorded again, along with the new ones.
+func (c *Checkpoint) truncate(i int) error {
+	seq, kept := 0, i
+	for kept >= c.segLens[seq] {
+		kept -= c.segLens[seq]
+		seq++
+	}
+
+	c.pending = append([]checkpointLeaf(nil), c.restored[i-kept:i]...)
+	c.restored = c.restored[:i]
+	c.segLens = c.segLens[:seq]
+	c.seq = seq
+	return c.clearFrom(seq)
+}
+
+// checkLeaf reads the input of the leaf 'l' from 'r', and returns whether the
+// leaf holds it. When it does not, what was read is returned.
+func checkLeaf(l checkpointLeaf, r io.Reader) (io.Reader, bool, error) {
+	if l.Size > uint64(BlockSizeLimit) {
+		// only holes are this large
+		zeros, rest, err := readZeros(r, l.Size)
+		if err != nil {
+			return nil, false, err
+		}
+		read := io.MultiReader(io.LimitReader(zeroReader{}, int64(zeros)), bytes.NewReader(rest))
+		if zeros < l.Size {
+			return read, false, nil
+		}
+
+		ok, err := leafIs(l, holeLeaf(l.Size))
+		return read, ok, err
+	}
+
+	buf := make([]byte, l.Size)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, false, err
+	}
+	read := bytes.NewReader(buf[:n])
+	if n < len(buf) {
+		return read, false, nil
+	}
+
+	data := NewUnixfsBlock()
+	data.SetData(buf)
+	ok, err := leafIs(l, data)
+	if ok || err != nil || !isZeros(buf) {
+		return read, ok, err
+	}
+	ok, err = leafIs(l, holeLeaf(l.Size))
+	return read, ok, err
+}
+
+func holeLeaf(size uint64) *UnixfsNode {
+	n := NewUnixfsBlock()
+	n.SetHole(size)
+	return n
+}
+
+// leafIs returns whether 'l' was recorded for the node 'n'
+func leafIs(l checkpointLeaf, n *UnixfsNode) (bool, error) {
+	nd, err := n.GetDagNode()
+	if err != nil {
+		return false, err
+	}
+	k, err := nd.Key()
+	if err != nil {
+		return false, err
+	}
+	return k.B58String() == l.Key, nil
+}
+
+// readZeros reads up to 'size' bytes from 'r' for as long as they are zeros.
+// Returns the number of zeros read, and the bytes read after them.
+func readZeros(r io.Reader, size uint64) (uint64, []byte, error) {
+	buf := make([]byte, 32*1024)
+	var zeros uint64
+	for zeros < size {
+		if left := size - zeros; left < uint64(len(buf)) {
+			buf = buf[:left]
+		}
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, nil, err
+		}
+
+		for i, b := range buf[:n] {
+			if b != 0 {
+				return zeros + uint64(i), buf[i:n], nil
+			}
+		}
+		zeros += uint64(n)
+		if n < len(buf) {
+			break
+		}
+	}
+	return zeros, nil, nil
+}
+
+func isZeros(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func (c *Checkpoint) clearFrom(seq int) error {
+	for ; ; seq++ {
+		k := c.segmentKey(seq)
+		has, err := c.dstore.Has(k)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return nil
+		}
+
+		if err := c.dstore.Delete(k); err != nil {
+			return err
+		}
+	}
+}
+
+// record notes that the leaf 'k', holding 'size' bytes of input, was stored.
+// Once a segment is full, 'commit' is called to make sure its blocks are on
+// disk before the segment is written.
+func (c *Checkpoint) record(k key.Key, size uint64, segmentLen int, commit func() error) error {
+	c.pending = append(c.pending, checkpointLeaf{Key: k.B58String(), Size: size})
+	if len(c.pending) < segmentLen {
+		return nil
+	}
+
+	if err := commit(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(c.pending)
+	if err != nil {
+		return err
+	}
+
+	if err := c.dstore.Put(c.segmentKey(c.seq), b); err != nil {
+		return err
+	}
+
+	c.seq++
+	c.pending = nil
+	return nil
+}
+
+// next returns the next leaf of a previous run, or nil if there is none left
+func (c *Checkpoint) next(dserv dag.DAGService) (*UnixfsNode, error) {
+	if len(c.restored) == 0 {
+		return nil, nil
+	}
+
+	l := c.restored[0]
+	c.restored = c.restored[1:]
+
+	// Load made sure the block is stored locally
+	nd, err := dserv.Get(context.TODO(), key.B58KeyDecode(l.Key))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUnixfsNodeFromDag(nd)
+}
+
+func (c *Checkpoint) resuming() bool {
+	return len(c.restored) > 0
+}
@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	key "github.com/ipfs/go-ipfs/blocks/key"
 	"github.com/ipfs/go-ipfs/importer/chunk"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 )
@@ -12,26 +13,53 @@ type DagBuilderHelper struct {
 	spl      chunk.Splitter
 	recvdErr error
 	nextData []byte // the next item to return.
+	nextHole int64  // size of the next hole, if the next item is one
 	maxlinks int
+	repeat   int
 	batch    *dag.Batch
+
+	// checkpoint of the import, if any, and the number of leaves it handed
+	// out that were already recorded
+	cp         *Checkpoint
+	skipRecord int
+
+	observe func(*dag.Node) error
 }
 
 type DagBuilderParams struct {
 	// Maximum number of links per intermediate node
 	Maxlinks int
 
+	// Number of subtrees of each depth in trickle dags, DefaultLayerRepeat
+	// if zero
+	LayerRepeat int
+
 	// DAGService to write blocks to (required)
 	Dagserv dag.DAGService
+
+	// Checkpoint to record stored leaves in, and to resume from (optional)
+	Checkpoint *Checkpoint
+
+	// Observe is called with every node before it is stored (optional)
+	Observe func(*dag.Node) error
 }
 
 // Generate a new DagBuilderHelper from the given params, which data source comes
 // from chunks object
 func (dbp *DagBuilderParams) New(spl chunk.Splitter) *DagBuilderHelper {
+	repeat := dbp.LayerRepeat
+	if repeat == 0 {
+		repeat = DefaultLayerRepeat
+	}
+
 	return &DagBuilderHelper{
 		dserv:    dbp.Dagserv,
 		spl:      spl,
 		maxlinks: dbp.Maxlinks,
+		repeat:   repeat,
 		batch:    dbp.Dagserv.Batch(),
+		cp:       dbp.Checkpoint,
+		observe:  dbp.Observe,
 	}
 }
 
@@ -40,7 +68,13 @@ func (dbp *DagBuilderParams) New(spl chunk.Splitter) *DagBuilderHelper {
 // it will do nothing.
 func (db *DagBuilderHelper) prepareNext() {
 	// if we already have data waiting to be consumed, we're ready
-	if db.nextData != nil {
+	if db.nextData != nil || db.nextHole > 0 {
+		return
+	}
+
+	// splitters that know about holes hand them out without data
+	if hs, ok := db.spl.(chunk.HoleSplitter); ok {
+		db.nextData, db.nextHole, _ = hs.NextChunk()
 		return
 	}
 
@@ -50,15 +84,20 @@ func (db *DagBuilderHelper) prepareNext() {
 
 // Done returns whether or not we're done consuming the incoming data.
 func (db *DagBuilderHelper) Done() bool {
+	if db.cp != nil && db.cp.resuming() {
+		return false
+	}
+
 	// ensure we have an accurate perspective on data
 	// as `done` this may be called before `next`.
 	db.prepareNext() // idempotent
-	return db.nextData == nil
+	return db.nextData == nil && db.nextHole == 0
 }
 
 // Next returns the next chunk of data to be inserted into the dag
 // if it returns nil, that signifies that the stream is at an end, and
-// that the current building operation should finish
+// that the current building operation should finish. Holes are not
+// returned by Next, use FillNodeWithData to handle them.
 func (db *DagBuilderHelper) Next() []byte {
 	db.prepareNext() // idempotent
 	d := db.nextData
@@ -93,6 +132,24 @@ func (db *DagBuilderHelper) FillNodeLayer(node *UnixfsNode) error {
 }
 
 func (db *DagBuilderHelper) FillNodeWithData(node *UnixfsNode) error {
+	// leaves stored by an earlier run of the import come first
+	if db.cp != nil && db.cp.resuming() {
+		restored, err := db.cp.next(db.dserv)
+		if err != nil {
+			return err
+		}
+		*node = *restored
+		db.skipRecord++
+		return nil
+	}
+
+	db.prepareNext()
+	if db.nextHole > 0 {
+		node.SetHole(uint64(db.nextHole))
+		db.nextHole = 0
+		return nil
+	}
+
 	data := db.Next()
 	if data == nil { // we're done!
 		return nil
@@ -112,6 +169,10 @@ func (db *DagBuilderHelper) Add(node *UnixfsNode) (*dag.Node, error) {
 		return nil, err
 	}
 
+	if err := db.observeNode(dn); err != nil {
+		return nil, err
+	}
+
 	_, err = db.dserv.Add(dn)
 	if err != nil {
 		return nil, err
@@ -124,6 +185,34 @@ func (db *DagBuilderHelper) Maxlinks() int {
 	return db.maxlinks
 }
 
+// LayerRepeat returns how many subtrees of each depth a trickle dag gets
+func (db *DagBuilderHelper) LayerRepeat() int {
+	return db.repeat
+}
+
+// observeNode hands a node about to be stored to the observer, if there is
+// one
+func (db *DagBuilderHelper) observeNode(nd *dag.Node) error {
+	if db.observe == nil {
+		return nil
+	}
+	return db.observe(nd)
+}
+
+// recordLeaf notes a stored leaf in the checkpoint, if there is one
+func (db *DagBuilderHelper) recordLeaf(k key.Key, size uint64) error {
+	if db.cp == nil {
+		return nil
+	}
+	if db.skipRecord > 0 {
+		db.skipRecord--
+		return nil
+	}
+
+	// a segment may only be written once all of its blocks are stored
+	return db.cp.record(k, size, db.maxlinks, db.batch.Commit)
+}
+
 func (db *DagBuilderHelper) Close() error {
 	return db.batch.Commit()
 }
@@ -31,6 +31,11 @@ var roughLinkSize = 34 + 8 + 5   // sha256 multihash + size + no name + protobuf
 // See calc_test.go
 var DefaultLinksPerBlock = (roughLinkBlockSize / roughLinkSize)
 
+// DefaultLayerRepeat is how many subtrees of each depth a trickle dag has.
+// Higher values increase the width of a given node, which improves seek
+// speeds.
+var DefaultLayerRepeat = 4
+
 // ErrSizeLimitExceeded signals that a block is larger than BlockSizeLimit.
 var ErrSizeLimitExceeded = fmt.Errorf("object size limit exceeded")
 
@@ -101,11 +106,18 @@ func (n *UnixfsNode) AddChild(child *UnixfsNode, db *DagBuilderHelper) error {
 		return err
 	}
 
-	_, err = db.batch.Add(childnode)
+	if err := db.observeNode(childnode); err != nil {
+		return err
+	}
+
+	k, err := db.batch.Add(childnode)
 	if err != nil {
 		return err
 	}
 
+	if child.NumChildren() == 0 {
+		return db.recordLeaf(k, child.ufmt.FileSize())
+	}
 	return nil
 }
 
@@ -119,6 +131,11 @@ func (n *UnixfsNode) SetData(data []byte) {
 	n.ufmt.Data = data
 }
 
+// SetHole turns this node into a hole of the given size
+func (n *UnixfsNode) SetHole(size uint64) {
+	n.ufmt = ft.NewHoleFSNode(size)
+}
+
 // getDagNode fills out the proper formatting for the unixfs node
 // inside of a DAG node and returns the dag node
 func (n *UnixfsNode) GetDagNode() (*dag.Node, error) {
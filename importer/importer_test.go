@@ -6,10 +6,15 @@ import (
 	"io/ioutil"
 	"testing"
 
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bal "github.com/ipfs/go-ipfs/importer/balanced"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+	trickle "github.com/ipfs/go-ipfs/importer/trickle"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
 	u "gx/ipfs/QmZNVWh8LLjAavuQ2JXuFmuYH3C11xo988vSgp7UQrTRj1/go-ipfs-util"
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 )
@@ -60,6 +65,103 @@ func TestBalancedDag(t *testing.T) {
 	}
 }
 
+func TestResumeFromCheckpoint(t *testing.T) {
+	buf := make([]byte, 512*50+100)
+	u.NewTimeSeededRand().Read(buf)
+
+	layouts := map[string]func(*h.DagBuilderHelper) (*dag.Node, error){
+		"balanced": bal.BalancedLayout,
+		"trickle":  trickle.TrickleLayout,
+	}
+
+	for name, layout := range layouts {
+		build := func(dserv dag.DAGService, data []byte, cp *h.Checkpoint) *dag.Node {
+			dbp := h.DagBuilderParams{
+				Dagserv:    dserv,
+				Maxlinks:   4,
+				Checkpoint: cp,
+			}
+
+			nd, err := layout(dbp.New(chunk.NewSizeSplitter(bytes.NewReader(data), 512)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return nd
+		}
+
+		exp, err := build(mdtest.Mock(), buf, nil).Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// store the start of the data, as an interrupted import would
+		dserv := mdtest.Mock()
+		dstore := ds.NewMapDatastore()
+		build(dserv, buf[:512*21], h.NewCheckpoint(dstore, name))
+
+		all := func(key.Key) (bool, error) { return true, nil }
+
+		// leaves are recorded a full layer at a time
+		cp := h.NewCheckpoint(dstore, name)
+		skip, err := cp.Load(all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if skip != 512*20 {
+			t.Fatalf("%s: expected to skip %d bytes, got %d", name, 512*20, skip)
+		}
+
+		nd := build(dserv, buf[skip:], cp)
+		k, err := nd.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k != exp {
+			t.Fatalf("%s: resumed import gave %s, expected %s", name, k, exp)
+		}
+
+		dr, err := uio.NewDagReader(context.Background(), nd, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(dr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, buf) {
+			t.Fatalf("%s: bad read", name)
+		}
+
+		// a missing block in the third segment drops it and all after it
+		calls := 0
+		gced := func(key.Key) (bool, error) {
+			calls++
+			return calls != 10, nil
+		}
+
+		for _, has := range []func(key.Key) (bool, error){gced, all} {
+			skip, err = h.NewCheckpoint(dstore, name).Load(has)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skip != 512*8 {
+				t.Fatalf("%s: expected to skip %d bytes, got %d", name, 512*8, skip)
+			}
+		}
+
+		if err := h.NewCheckpoint(dstore, name).Clear(); err != nil {
+			t.Fatal(err)
+		}
+		skip, err = h.NewCheckpoint(dstore, name).Load(all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if skip != 0 {
+			t.Fatalf("%s: cleared checkpoint still skips %d bytes", name, skip)
+		}
+	}
+}
+
 func BenchmarkBalancedReadSmallBlock(b *testing.B) {
 	b.StopTimer()
 	nbytes := int64(10000000)
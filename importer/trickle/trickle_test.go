@@ -31,7 +31,7 @@ func buildTestDag(ds merkledag.DAGService, spl chunk.Splitter) (*merkledag.Node,
 		return nil, err
 	}
 
-	return nd, VerifyTrickleDagStructure(nd, ds, dbp.Maxlinks, layerRepeat)
+	return nd, VerifyTrickleDagStructure(nd, ds, dbp.Maxlinks, h.DefaultLayerRepeat)
 }
 
 //Test where calls to read are smaller than the chunk size
@@ -49,6 +49,42 @@ func TestSizeBasedSplit(t *testing.T) {
 	testFileConsistency(t, bs, 31*4095)
 }
 
+func TestLayoutParams(t *testing.T) {
+	should := make([]byte, 200*512)
+	u.NewTimeSeededRand().Read(should)
+
+	for _, repeat := range []int{1, 2, 7} {
+		ds := mdtest.Mock()
+		dbp := h.DagBuilderParams{
+			Dagserv:     ds,
+			Maxlinks:    8,
+			LayerRepeat: repeat,
+		}
+
+		nd, err := TrickleLayout(dbp.New(chunk.NewSizeSplitter(bytes.NewReader(should), 512)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := VerifyTrickleDagStructure(nd, ds, 8, repeat); err != nil {
+			t.Fatalf("layer repeat %d: %s", repeat, err)
+		}
+
+		r, err := uio.NewDagReader(context.Background(), nd, ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := arrComp(out, should); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func dup(b []byte) []byte {
 	o := make([]byte, len(b))
 	copy(o, b)
@@ -445,7 +481,7 @@ func TestAppend(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = VerifyTrickleDagStructure(nnode, ds, dbp.Maxlinks, layerRepeat)
+	err = VerifyTrickleDagStructure(nnode, ds, dbp.Maxlinks, h.DefaultLayerRepeat)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -496,7 +532,7 @@ func TestMultipleAppends(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		err = VerifyTrickleDagStructure(nnode, ds, dbp.Maxlinks, layerRepeat)
+		err = VerifyTrickleDagStructure(nnode, ds, dbp.Maxlinks, h.DefaultLayerRepeat)
 		if err != nil {
 			t.Fatal(err)
 		}
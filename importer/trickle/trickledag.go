@@ -10,18 +10,13 @@ import (
 	ft "github.com/ipfs/go-ipfs/unixfs"
 )
 
-// layerRepeat specifies how many times to append a child tree of a
-// given depth. Higher values increase the width of a given node, which
-// improves seek speeds.
-const layerRepeat = 4
-
 func TrickleLayout(db *h.DagBuilderHelper) (*dag.Node, error) {
 	root := h.NewUnixfsNode()
 	if err := db.FillNodeLayer(root); err != nil {
 		return nil, err
 	}
 	for level := 1; !db.Done(); level++ {
-		for i := 0; i < layerRepeat && !db.Done(); i++ {
+		for i := 0; i < db.LayerRepeat() && !db.Done(); i++ {
 			next := h.NewUnixfsNode()
 			if err := fillTrickleRec(db, next, level); err != nil {
 				return nil, err
@@ -51,7 +46,7 @@ func fillTrickleRec(db *h.DagBuilderHelper, node *h.UnixfsNode, depth int) error
 	}
 
 	for i := 1; i < depth && !db.Done(); i++ {
-		for j := 0; j < layerRepeat && !db.Done(); j++ {
+		for j := 0; j < db.LayerRepeat() && !db.Done(); j++ {
 			next := h.NewUnixfsNode()
 			if err := fillTrickleRec(db, next, i); err != nil {
 				return err
@@ -82,7 +77,7 @@ func TrickleAppend(ctx context.Context, base *dag.Node, db *h.DagBuilderHelper)
 	}
 
 	// Get depth of this 'tree'
-	n, layerProgress := trickleDepthInfo(ufsn, db.Maxlinks())
+	n, layerProgress := trickleDepthInfo(ufsn, db.Maxlinks(), db.LayerRepeat())
 	if n == 0 {
 		// If direct blocks not filled...
 		if err := db.FillNodeLayer(ufsn); err != nil {
@@ -109,7 +104,7 @@ func TrickleAppend(ctx context.Context, base *dag.Node, db *h.DagBuilderHelper)
 
 	// Now, continue filling out tree like normal
 	for i := n; !db.Done(); i++ {
-		for j := 0; j < layerRepeat && !db.Done(); j++ {
+		for j := 0; j < db.LayerRepeat() && !db.Done(); j++ {
 			next := h.NewUnixfsNode()
 			err := fillTrickleRec(db, next, i)
 			if err != nil {
@@ -154,7 +149,7 @@ func appendFillLastChild(ctx context.Context, ufsn *h.UnixfsNode, depth int, lay
 
 	// Partially filled depth layer
 	if layerFill != 0 {
-		for ; layerFill < layerRepeat && !db.Done(); layerFill++ {
+		for ; layerFill < db.LayerRepeat() && !db.Done(); layerFill++ {
 			next := h.NewUnixfsNode()
 			err := fillTrickleRec(db, next, depth)
 			if err != nil {
@@ -178,7 +173,7 @@ func trickleAppendRec(ctx context.Context, ufsn *h.UnixfsNode, db *h.DagBuilderH
 	}
 
 	// Get depth of this 'tree'
-	n, layerProgress := trickleDepthInfo(ufsn, db.Maxlinks())
+	n, layerProgress := trickleDepthInfo(ufsn, db.Maxlinks(), db.LayerRepeat())
 	if n == 0 {
 		// If direct blocks not filled...
 		if err := db.FillNodeLayer(ufsn); err != nil {
@@ -203,7 +198,7 @@ func trickleAppendRec(ctx context.Context, ufsn *h.UnixfsNode, db *h.DagBuilderH
 
 	// Now, continue filling out tree like normal
 	for i := n; i < depth && !db.Done(); i++ {
-		for j := 0; j < layerRepeat && !db.Done(); j++ {
+		for j := 0; j < db.LayerRepeat() && !db.Done(); j++ {
 			next := h.NewUnixfsNode()
 			if err := fillTrickleRec(db, next, i); err != nil {
 				return nil, err
@@ -218,7 +213,7 @@ func trickleAppendRec(ctx context.Context, ufsn *h.UnixfsNode, db *h.DagBuilderH
 	return ufsn, nil
 }
 
-func trickleDepthInfo(node *h.UnixfsNode, maxlinks int) (int, int) {
+func trickleDepthInfo(node *h.UnixfsNode, maxlinks, layerRepeat int) (int, int) {
 	n := node.NumChildren()
 	if n < maxlinks {
 		return 0, 0
@@ -9,6 +9,7 @@ import (
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
 	"gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 )
@@ -107,11 +108,47 @@ func (n *dagService) Remove(nd *Node) error {
 	return n.Blocks.DeleteBlock(k)
 }
 
-// FetchGraph fetches all nodes that are children of the given node
+// FetchGraph fetches all nodes that are children of the given node. The
+// nodes fetched are counted by the ProgressTracker of 'ctx', if it has one.
 func FetchGraph(ctx context.Context, root *Node, serv DAGService) error {
+	// the peers that have some of the graph likely have the rest
+	ctx = exchange.WithSession(ctx)
 	return EnumerateChildrenAsync(ctx, serv, root, key.NewKeySet())
 }
 
+// ProgressTracker counts the nodes walked by FetchGraph, and their size
+type ProgressTracker struct {
+	lk    sync.Mutex
+	nodes int
+	bytes uint64
+}
+
+type progressContextKey struct{}
+
+// DeriveContext returns a context whose graph walks are counted by 'p'
+func (p *ProgressTracker) DeriveContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, p)
+}
+
+func (p *ProgressTracker) add(nd *Node) {
+	enc, err := nd.EncodeProtobuf(false)
+	if err != nil {
+		return
+	}
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.nodes++
+	p.bytes += uint64(len(enc))
+}
+
+// Value returns the number of nodes walked so far, and their size in bytes
+func (p *ProgressTracker) Value() (int, uint64) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.nodes, p.bytes
+}
+
 // FindLinks searches this nodes links for the given key,
 // returns the indexes of any links pointing to it
 func FindLinks(links []key.Key, k key.Key, start int) []int {
@@ -391,6 +428,8 @@ func EnumerateChildrenAsync(ctx context.Context, ds DAGService, root *Node, set
 	toprocess := make(chan []key.Key, 8)
 	nodes := make(chan *NodeOption, 8)
 
+	progress, _ := ctx.Value(progressContextKey{}).(*ProgressTracker)
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	defer close(toprocess)
@@ -412,6 +451,9 @@ func EnumerateChildrenAsync(ctx context.Context, ds DAGService, root *Node, set
 			}
 
 			nd := opt.Node
+			if progress != nil {
+				progress.add(nd)
+			}
 
 			// a node has been fetched
 			live--
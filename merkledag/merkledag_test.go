@@ -298,6 +298,34 @@ func TestFetchGraph(t *testing.T) {
 	}
 }
 
+func TestFetchGraphProgress(t *testing.T) {
+	dserv := dstest.Mock()
+
+	read := io.LimitReader(u.NewTimeSeededRand(), 1024*32)
+	root, err := imp.BuildDagFromReader(dserv, chunk.NewSizeSplitter(read, 512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := key.NewKeySet()
+	if err := EnumerateChildren(context.Background(), dserv, root, ks, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := new(ProgressTracker)
+	if err := FetchGraph(p.DeriveContext(context.Background()), root, dserv); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, bytes := p.Value()
+	if nodes != len(ks.Keys())+1 {
+		t.Fatalf("expected %d nodes to be counted, got %d", len(ks.Keys())+1, nodes)
+	}
+	if bytes < 32*1024 {
+		t.Fatalf("expected at least the content to be counted, got %d bytes", bytes)
+	}
+}
+
 func TestEnumerateChildren(t *testing.T) {
 	bsi := bstest.Mocks(1)
 	ds := NewDAGService(bsi[0])
@@ -131,15 +131,13 @@ func (d *Directory) cacheNode(name string, nd *dag.Node) (FSNode, error) {
 		ndir := NewDirectory(d.ctx, name, nd, d, d.dserv)
 		d.childDirs[name] = ndir
 		return ndir, nil
-	case ufspb.Data_File, ufspb.Data_Raw, ufspb.Data_Symlink:
+	case ufspb.Data_File, ufspb.Data_Raw, ufspb.Data_Symlink, ufspb.Data_Metadata:
 		nfi, err := NewFile(name, nd, d, d.dserv)
 		if err != nil {
 			return nil, err
 		}
 		d.files[name] = nfi
 		return nfi, nil
-	case ufspb.Data_Metadata:
-		return nil, ErrNotYetImplemented
 	default:
 		return nil, ErrInvalidChild
 	}
@@ -370,6 +368,54 @@ func (d *Directory) sync() error {
 	return nil
 }
 
+// Xattrs returns the extended attributes set on this directory
+func (d *Directory) Xattrs() (map[string][]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return ft.Xattrs(d.node.Data())
+}
+
+// SetXattr sets the extended attribute 'name' on this directory to 'value'
+func (d *Directory) SetXattr(name string, value []byte, sync bool) error {
+	return d.updateData(func(data []byte) ([]byte, error) {
+		return ft.SetXattr(data, name, value)
+	}, sync)
+}
+
+// RemoveXattr removes the extended attribute 'name' from this directory
+func (d *Directory) RemoveXattr(name string, sync bool) error {
+	return d.updateData(func(data []byte) ([]byte, error) {
+		return ft.RemoveXattr(data, name)
+	}, sync)
+}
+
+// updateData rewrites the unixfs data of this directory's node and propagates
+// the new node up to its parent
+func (d *Directory) updateData(f func([]byte) ([]byte, error), sync bool) error {
+	d.lock.Lock()
+	data, err := f(d.node.Data())
+	if err != nil {
+		d.lock.Unlock()
+		return err
+	}
+	d.node.SetData(data)
+
+	err = d.sync()
+	if err != nil {
+		d.lock.Unlock()
+		return err
+	}
+
+	nd, err := d.flushCurrentNode()
+	if err != nil {
+		d.lock.Unlock()
+		return err
+	}
+	d.lock.Unlock()
+
+	return d.parent.closeChild(d.name, nd, sync)
+}
+
 func (d *Directory) Path() string {
 	cur := d
 	var out string
@@ -20,6 +20,8 @@ type FileDescriptor interface {
 	io.Seeker
 
 	Truncate(int64) error
+	InsertFrom(io.Reader, int64) (int64, error)
+	RemoveRange(int64, int64) error
 	Size() (int64, error)
 	Sync() error
 	Flush() error
@@ -49,6 +51,25 @@ func (fi *fileDescriptor) Truncate(size int64) error {
 	return fi.mod.Truncate(size)
 }
 
+// InsertFrom inserts the data read from r into the file at offset 'at',
+// shifting the existing contents after it forwards
+func (fi *fileDescriptor) InsertFrom(r io.Reader, at int64) (int64, error) {
+	if fi.perms == OpenReadOnly {
+		return 0, fmt.Errorf("cannot insert on not writeable descriptor")
+	}
+	fi.hasChanges = true
+	return fi.mod.InsertFrom(r, at)
+}
+
+// RemoveRange cuts 'length' bytes out of the file starting at 'offset'
+func (fi *fileDescriptor) RemoveRange(offset, length int64) error {
+	if fi.perms == OpenReadOnly {
+		return fmt.Errorf("cannot call remove range on readonly file descriptor")
+	}
+	fi.hasChanges = true
+	return fi.mod.RemoveRange(offset, length)
+}
+
 // Write writes the given data to the file at its current offset
 func (fi *fileDescriptor) Write(b []byte) (int, error) {
 	if fi.perms == OpenReadOnly {
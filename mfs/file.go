@@ -1,6 +1,7 @@
 package mfs
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -12,6 +13,8 @@ import (
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 )
 
+var ErrMetadataReadOnly = errors.New("files wrapped in metadata can only be opened for reading")
+
 type File struct {
 	parent childCloser
 
@@ -57,6 +60,10 @@ func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
 		return nil, fmt.Errorf("symlinks not yet supported")
 	case ft.TFile, ft.TRaw:
 		// OK case
+	case ft.TMetadata:
+		if flags != OpenReadOnly {
+			return nil, ErrMetadataReadOnly
+		}
 	}
 
 	switch flags {
@@ -102,6 +109,12 @@ func (fi *File) GetNode() (*dag.Node, error) {
 }
 
 func (fi *File) Flush() error {
+	if fi.isMetadata() {
+		// files wrapped in metadata are never written to, so there
+		// is nothing to flush
+		return fi.Sync()
+	}
+
 	// open the file in fullsync mode
 	fd, err := fi.Open(OpenWriteOnly, true)
 	if err != nil {
@@ -120,6 +133,64 @@ func (fi *File) Sync() error {
 	return nil
 }
 
+// Xattrs returns the extended attributes set on this file
+func (fi *File) Xattrs() (map[string][]byte, error) {
+	fi.nodelk.Lock()
+	defer fi.nodelk.Unlock()
+	return ft.Xattrs(fi.node.Data())
+}
+
+// SetXattr sets the extended attribute 'name' on this file to 'value'
+func (fi *File) SetXattr(name string, value []byte, sync bool) error {
+	return fi.updateData(func(d []byte) ([]byte, error) {
+		return ft.SetXattr(d, name, value)
+	}, sync)
+}
+
+// RemoveXattr removes the extended attribute 'name' from this file
+func (fi *File) RemoveXattr(name string, sync bool) error {
+	return fi.updateData(func(d []byte) ([]byte, error) {
+		return ft.RemoveXattr(d, name)
+	}, sync)
+}
+
+// updateData rewrites the unixfs data of this file's node and propagates the
+// new node up to its parent
+func (fi *File) updateData(f func([]byte) ([]byte, error), sync bool) error {
+	// wait for any open descriptors to be closed
+	fi.desclock.Lock()
+	defer fi.desclock.Unlock()
+
+	fi.nodelk.Lock()
+	nd := fi.node.Copy()
+	data, err := f(nd.Data())
+	if err != nil {
+		fi.nodelk.Unlock()
+		return err
+	}
+	nd.SetData(data)
+
+	_, err = fi.dserv.Add(nd)
+	if err != nil {
+		fi.nodelk.Unlock()
+		return err
+	}
+
+	fi.node = nd
+	name := fi.name
+	parent := fi.parent
+	fi.nodelk.Unlock()
+
+	return parent.closeChild(name, nd, sync)
+}
+
+func (fi *File) isMetadata() bool {
+	fi.nodelk.Lock()
+	defer fi.nodelk.Unlock()
+	pbd, err := ft.FromBytes(fi.node.Data())
+	return err == nil && pbd.GetType() == ft.TMetadata
+}
+
 // Type returns the type FSNode this is
 func (fi *File) Type() NodeType {
 	return TFile
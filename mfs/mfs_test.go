@@ -997,3 +997,101 @@ func TestFileDescriptors(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestXattrs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds, rt := setupRoot(ctx, t)
+	rootdir := rt.GetValue().(*Directory)
+
+	d := mkdirP(t, rootdir, "a/b")
+	err := d.AddChild("afile", getRandFile(t, ds, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsn, err := DirLookup(rootdir, "/a/b/afile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fsn.SetXattr("user.sha1", []byte("abcd"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.SetXattr("user.tag", []byte("docs"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writing to the file must not lose its attributes
+	fi := fsn.(*File)
+	wfd, err := fi.Open(OpenWriteOnly, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wfd.Truncate(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = wfd.WriteAt([]byte("hello"), 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wfd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reload everything from the dag
+	rnd, err := rootdir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nrt, err := NewRoot(ctx, ds, rnd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nroot := nrt.GetValue().(*Directory)
+	for p, exp := range map[string]map[string]string{
+		"/a/b/afile": {"user.sha1": "abcd"},
+		"/a/b":       {"user.tag": "docs"},
+		"/a":         {},
+	} {
+		fsn, err := DirLookup(nroot, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		xattrs, err := fsn.Xattrs()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(xattrs) != len(exp) {
+			t.Fatalf("%s: expected %d xattrs, got %d", p, len(exp), len(xattrs))
+		}
+		for k, v := range exp {
+			if string(xattrs[k]) != v {
+				t.Fatalf("%s: wrong value for %s: %q", p, k, xattrs[k])
+			}
+		}
+	}
+
+	err = fsn.RemoveXattr("user.sha1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fsn.RemoveXattr("user.sha1", true)
+	if err != ft.ErrNoXattr {
+		t.Fatal("expected ErrNoXattr when removing missing attribute")
+	}
+}
@@ -44,6 +44,10 @@ type FSNode interface {
 	GetNode() (*dag.Node, error)
 	Flush() error
 	Type() NodeType
+
+	Xattrs() (map[string][]byte, error)
+	SetXattr(name string, value []byte, sync bool) error
+	RemoveXattr(name string, sync bool) error
 }
 
 // Root represents the root of a filesystem tree
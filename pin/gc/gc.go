@@ -19,7 +19,7 @@ var log = logging.Logger("gc")
 // - all recursively pinned blocks, plus all of their descendants (recursively)
 // - bestEffortRoots, plus all of its descendants (recursively)
 // - all directly pinned blocks
-// - all blocks utilized internally by the pinner
+// - all blocks utilized internally by the pinner, but not their descendants
 //
 // The routine then iterates over every block in the blockstore and
 // deletes any block that is not found in the marked set.
@@ -88,6 +88,8 @@ func Descendants(ctx context.Context, ds dag.DAGService, set key.KeySet, roots [
 	return nil
 }
 
+// ColoredSet returns the keys gc must keep. Pins that expired are left out,
+// even before they are removed, as the pinner no longer lists them.
 func ColoredSet(ctx context.Context, pn pin.Pinner, ds dag.DAGService, bestEffortRoots []key.Key) (key.KeySet, error) {
 	// KeySet currently implemented in memory, in the future, may be bloom filter or
 	// disk backed to conserve memory.
@@ -106,9 +108,10 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ds dag.DAGService, bestEffor
 		gcs.Add(k)
 	}
 
-	err = Descendants(ctx, ds, gcs, pn.InternalPins(), false)
-	if err != nil {
-		return nil, err
+	// the pinner lists every node it uses, and these link to the pins, so
+	// walking them would keep the pins that expired
+	for _, k := range pn.InternalPins() {
+		gcs.Add(k)
 	}
 
 	return gcs, nil
@@ -0,0 +1,88 @@
+package gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dssync "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/sync"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// setPinner lists a node linking to the pins among its internal pins, as
+// the sets of the pin root do
+type setPinner struct {
+	pin.Pinner
+	set key.Key
+}
+
+func (p *setPinner) InternalPins() []key.Key {
+	return append(p.Pinner.InternalPins(), p.set)
+}
+
+func TestGCExpiredPins(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+
+	child := mdag.NodeWithData([]byte("child"))
+	expired := mdag.NodeWithData([]byte("expired"))
+	if err := expired.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	kept := mdag.NodeWithData([]byte("kept"))
+	for _, n := range []*mdag.Node{child, expired, kept} {
+		if _, err := dserv.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pinner := pin.NewPinner(dstore, dserv)
+	if err := pinner.PinExpiring(ctx, expired, true, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinner.Pin(ctx, kept, true); err != nil {
+		t.Fatal(err)
+	}
+
+	set := new(mdag.Node)
+	for _, n := range []*mdag.Node{expired, kept} {
+		if err := set.AddNodeLink("", n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setKey, err := dserv.Add(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the expired pin is not swept yet
+	out, err := GC(ctx, bstore, &setPinner{pinner, setKey}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := make(map[key.Key]bool)
+	for k := range out {
+		removed[k] = true
+	}
+
+	for _, n := range []*mdag.Node{expired, child} {
+		k, _ := n.Key()
+		if !removed[k] {
+			t.Fatal("gc kept the graph of an expired pin")
+		}
+	}
+	keptKey, _ := kept.Key()
+	for _, k := range []key.Key{setKey, keptKey} {
+		if removed[k] {
+			t.Fatalf("gc removed %s", k)
+		}
+	}
+}
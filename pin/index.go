@@ -0,0 +1,223 @@
+package pin
+
+import (
+	"fmt"
+	"time"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	"github.com/ipfs/go-ipfs/pin/internal/pb"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dsq "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/query"
+	"gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	base32 "gx/ipfs/Qmb1DA2A9LS2wR4FFweB4uEDomFsdmnw1VLawLE1yQzudj/base32"
+)
+
+// The pin index keeps the pin state in the datastore, with an entry for
+// each pin, so that a flush only writes the pins that changed:
+//
+//	/local/pinset/version                 the version of the index
+//	/local/pinset/recursive/<key>         recursive pins
+//	/local/pinset/direct/<key>            direct pins
+//	/local/pinset/expires/<key>           the expiry of a pin
+//	/local/pinset/named/<key>/<id>        named pins, as pb.NamedPin
+//
+// Repos written before it keep the pin state in the sets of set.go, under
+// a root node, and are migrated when loaded.
+var pinsetPrefix = ds.NewKey("/local/pinset")
+
+var pinsetVersionKey = pinsetPrefix.ChildString("version")
+
+const pinsetVersion = "1"
+
+func pinsetKey(kind string, k key.Key) ds.Key {
+	return pinsetPrefix.ChildString(kind).ChildString(k.B58String())
+}
+
+// namedPinKey returns the entry of 'np'. Names and owners are free form, so
+// they are encoded.
+func namedPinKey(np NamedPin) ds.Key {
+	id := base32.RawStdEncoding.EncodeToString([]byte(np.Owner + "\x00" + np.Name))
+	return pinsetKey(linkNamed, np.Key).ChildString(id)
+}
+
+// queryPinset calls 'fn' with every entry under 'prefix'
+func queryPinset(d ds.Datastore, prefix ds.Key, keysOnly bool, fn func(e dsq.Entry) error) error {
+	res, err := d.Query(dsq.Query{
+		Prefix:   prefix.String(),
+		KeysOnly: keysOnly,
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Process().Close()
+
+	for r := range res.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		if err := fn(r.Entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryKey returns the key of the pin of the entry 'e'
+func entryKey(e dsq.Entry) (key.Key, error) {
+	k := key.B58KeyDecode(ds.NewKey(e.Key).BaseNamespace())
+	if k == "" {
+		return "", fmt.Errorf("invalid pin index entry %s", e.Key)
+	}
+	return k, nil
+}
+
+func entryBytes(e dsq.Entry) ([]byte, error) {
+	b, ok := e.Value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("pin index entry %s was not bytes", e.Key)
+	}
+	return b, nil
+}
+
+// loadIndex loads the pin state from the pin index
+func (p *pinner) loadIndex() error {
+	v, err := p.dstore.Get(pinsetVersionKey)
+	if err != nil {
+		return err
+	}
+	if b, ok := v.([]byte); !ok || string(b) != pinsetVersion {
+		return fmt.Errorf("unsupported pin index version: %v", v)
+	}
+
+	err = queryPinset(p.dstore, pinsetPrefix.ChildString(linkRecursive), true, func(e dsq.Entry) error {
+		k, err := entryKey(e)
+		if err != nil {
+			return err
+		}
+		p.recursePin.AddBlock(k)
+		p.index(k, ds.NewKey(e.Key))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot load recursive pins: %v", err)
+	}
+
+	err = queryPinset(p.dstore, pinsetPrefix.ChildString(linkDirect), true, func(e dsq.Entry) error {
+		k, err := entryKey(e)
+		if err != nil {
+			return err
+		}
+		p.directPin.AddBlock(k)
+		p.index(k, ds.NewKey(e.Key))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot load direct pins: %v", err)
+	}
+
+	err = queryPinset(p.dstore, pinsetPrefix.ChildString(linkExpires), false, func(e dsq.Entry) error {
+		k, err := entryKey(e)
+		if err != nil {
+			return err
+		}
+		buf, err := entryBytes(e)
+		if err != nil {
+			return err
+		}
+		if len(buf) != expirySize {
+			return fmt.Errorf("invalid expiry of %s", k)
+		}
+		var exp expiry
+		exp.ReadFromIdx(buf, 0)
+		p.expires[k] = time.Unix(int64(exp), 0)
+		p.index(k, ds.NewKey(e.Key))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot load pin expiries: %v", err)
+	}
+
+	err = queryPinset(p.dstore, pinsetPrefix.ChildString(linkNamed), false, func(e dsq.Entry) error {
+		buf, err := entryBytes(e)
+		if err != nil {
+			return err
+		}
+		var np pb.NamedPin
+		if err := proto.Unmarshal(buf, &np); err != nil {
+			return err
+		}
+		k := key.Key(np.GetKey())
+		p.named[k] = append(p.named[k], NamedPin{
+			Key:       k,
+			Name:      np.GetName(),
+			Owner:     np.GetOwner(),
+			Recursive: np.GetRecursive(),
+		})
+		p.index(k, ds.NewKey(e.Key))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot load named pins: %v", err)
+	}
+	return nil
+}
+
+// storePin writes the entries of the pins of 'k' to 'b', removes those of
+// the pins it no longer has, and returns the entries it has now
+func (p *pinner) storePin(b ds.Batch, k key.Key) (map[ds.Key]struct{}, error) {
+	entries := make(map[ds.Key]struct{})
+	put := func(dk ds.Key, value []byte) error {
+		entries[dk] = struct{}{}
+		return b.Put(dk, value)
+	}
+
+	if p.recursePin.HasKey(k) {
+		if err := put(pinsetKey(linkRecursive, k), []byte{}); err != nil {
+			return nil, err
+		}
+	}
+	if p.directPin.HasKey(k) {
+		if err := put(pinsetKey(linkDirect, k), []byte{}); err != nil {
+			return nil, err
+		}
+	}
+	if t, ok := p.expires[k]; ok {
+		if err := put(pinsetKey(linkExpires, k), expiry(t.Unix()).Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	for _, np := range p.named[k] {
+		data, err := proto.Marshal(&pb.NamedPin{
+			Key:       []byte(np.Key),
+			Name:      proto.String(np.Name),
+			Owner:     proto.String(np.Owner),
+			Recursive: proto.Bool(np.Recursive),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := put(namedPinKey(np), data); err != nil {
+			return nil, err
+		}
+	}
+
+	for dk := range p.indexed[k] {
+		if _, ok := entries[dk]; ok {
+			continue
+		}
+		if err := b.Delete(dk); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// index records that the entry 'dk' of the pins of 'k' is in the pin index
+func (p *pinner) index(k key.Key, dk ds.Key) {
+	entries, ok := p.indexed[k]
+	if !ok {
+		entries = make(map[ds.Key]struct{})
+		p.indexed[k] = entries
+	}
+	entries[dk] = struct{}{}
+}
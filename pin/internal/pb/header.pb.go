@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Set
+	NamedPin
 */
 package pb
 
@@ -55,5 +56,47 @@ func (m *Set) GetSeed() uint32 {
 	return 0
 }
 
+// a pin carrying a name and an owner, stored in a node of its own
+type NamedPin struct {
+	// the pinned key
+	Key              []byte  `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Name             *string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Owner            *string `protobuf:"bytes,3,opt,name=owner" json:"owner,omitempty"`
+	Recursive        *bool   `protobuf:"varint,4,opt,name=recursive" json:"recursive,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *NamedPin) Reset()         { *m = NamedPin{} }
+func (m *NamedPin) String() string { return proto.CompactTextString(m) }
+func (*NamedPin) ProtoMessage()    {}
+
+func (m *NamedPin) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *NamedPin) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *NamedPin) GetOwner() string {
+	if m != nil && m.Owner != nil {
+		return *m.Owner
+	}
+	return ""
+}
+
+func (m *NamedPin) GetRecursive() bool {
+	if m != nil && m.Recursive != nil {
+		return *m.Recursive
+	}
+	return false
+}
+
 func init() {
 }
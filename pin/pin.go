@@ -26,6 +26,8 @@ const (
 	linkDirect    = "direct"
 	linkIndirect  = "indirect"
 	linkInternal  = "internal"
+	linkNamed     = "named"
+	linkExpires   = "expires"
 	linkNotPinned = "not pinned"
 	linkAny       = "any"
 	linkAll       = "all"
@@ -84,27 +86,75 @@ type Pinner interface {
 	// be successful.
 	RemovePinWithMode(key.Key, PinMode)
 
+	// PinNamed pins a node under a name, on behalf of an owner. The node
+	// stays pinned as long as any pin on it remains.
+	PinNamed(ctx context.Context, node *mdag.Node, recursive bool, name, owner string) error
+	// UnpinNamed removes the pin of a key with the given name and owner
+	UnpinNamed(k key.Key, name, owner string) error
+	NamedPins() []NamedPin
+
+	// PinExpiring pins a node like Pin, until the given time. Pins that
+	// don't expire stay so, and an expiring pin keeps the later of its
+	// expiry times.
+	PinExpiring(ctx context.Context, node *mdag.Node, recursive bool, expires time.Time) error
+	// Expiry returns when the pin of a key expires, if it does
+	Expiry(key.Key) (time.Time, bool)
+	// RemoveExpired removes the pins that expired by the given time, and
+	// returns their keys
+	RemoveExpired(time.Time) []key.Key
+
+	// Update moves the recursive pin of one key to another, only fetching
+	// the parts of the new graph that are not shared with the old one.
+	// If unpin is false, the old key stays pinned as well.
+	Update(ctx context.Context, from, to key.Key, unpin bool) error
+
 	Flush() error
 	DirectKeys() []key.Key
 	RecursiveKeys() []key.Key
+	// InternalPins returns every node the pinner keeps its state in. They
+	// link to the pins, so gc must keep them without their descendants.
 	InternalPins() []key.Key
 }
 
+// NamedPin is a pin that carries a name and an owner, so that applications
+// pinning the same key don't remove each other's pins
+type NamedPin struct {
+	Key       key.Key
+	Name      string
+	Owner     string
+	Recursive bool
+}
+
 // pinner implements the Pinner interface
 type pinner struct {
 	lock       sync.RWMutex
 	recursePin set.BlockSet
 	directPin  set.BlockSet
 
+	// named pins, by the key they pin
+	named map[key.Key][]NamedPin
+
+	// expiry times of the direct and recursive pins that expire
+	expires map[key.Key]time.Time
+
+	// keys whose pins changed since the last flush
+	dirty map[key.Key]struct{}
+	// entries of the pins of each key in the pin index, as of the last
+	// flush, so that those to remove are known without asking the datastore
+	indexed map[key.Key]map[ds.Key]struct{}
+	// whether the pin state was loaded from a pin root, to be removed once
+	// the pin index is written
+	legacyRoot bool
+
 	// Track the keys used for storing the pinning state, so gc does
 	// not delete them.
 	internalPin map[key.Key]struct{}
 	dserv       mdag.DAGService
-	dstore      ds.Datastore
+	dstore      ds.Batching
 }
 
 // NewPinner creates a new pinner using the given datastore as a backend
-func NewPinner(dstore ds.Datastore, serv mdag.DAGService) Pinner {
+func NewPinner(dstore ds.Batching, serv mdag.DAGService) Pinner {
 
 	// Load set from given datastore...
 	rcset := set.NewSimpleBlockSet()
@@ -114,11 +164,20 @@ func NewPinner(dstore ds.Datastore, serv mdag.DAGService) Pinner {
 	return &pinner{
 		recursePin: rcset,
 		directPin:  dirset,
+		named:      make(map[key.Key][]NamedPin),
+		expires:    make(map[key.Key]time.Time),
+		dirty:      make(map[key.Key]struct{}),
+		indexed:    make(map[key.Key]map[ds.Key]struct{}),
 		dserv:      serv,
 		dstore:     dstore,
 	}
 }
 
+// touch records that the pins of 'k' changed, for the next flush
+func (p *pinner) touch(k key.Key) {
+	p.dirty[k] = struct{}{}
+}
+
 // Pin the given node, optionally recursive
 func (p *pinner) Pin(ctx context.Context, node *mdag.Node, recurse bool) error {
 	p.lock.Lock()
@@ -128,8 +187,41 @@ func (p *pinner) Pin(ctx context.Context, node *mdag.Node, recurse bool) error {
 		return err
 	}
 
+	if err := p.pin(ctx, node, k, recurse); err != nil {
+		return err
+	}
+	delete(p.expires, k)
+	return nil
+}
+
+// PinExpiring pins 'node' until 'expires'
+func (p *pinner) PinExpiring(ctx context.Context, node *mdag.Node, recurse bool, expires time.Time) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	k, err := node.Key()
+	if err != nil {
+		return err
+	}
+
+	// a key pinned for good stays pinned for good
+	prev, expiring := p.expires[k]
+	permanent := !expiring && (p.recursePin.HasKey(k) || p.directPin.HasKey(k))
+
+	if err := p.pin(ctx, node, k, recurse); err != nil {
+		return err
+	}
+	if permanent || prev.After(expires) {
+		return nil
+	}
+	p.expires[k] = expires
+	return nil
+}
+
+func (p *pinner) pin(ctx context.Context, node *mdag.Node, k key.Key, recurse bool) error {
+	p.touch(k)
 	if recurse {
-		if p.recursePin.HasKey(k) {
+		// gc ignores expired pins, so their graph may be incomplete
+		if p.recursePin.HasKey(k) && !p.expired(k) {
 			return nil
 		}
 
@@ -138,7 +230,7 @@ func (p *pinner) Pin(ctx context.Context, node *mdag.Node, recurse bool) error {
 		}
 
 		// fetch entire graph
-		err := mdag.FetchGraph(ctx, node, p.dserv)
+		err := p.fetchGraph(ctx, node)
 		if err != nil {
 			return err
 		}
@@ -150,7 +242,12 @@ func (p *pinner) Pin(ctx context.Context, node *mdag.Node, recurse bool) error {
 		}
 
 		if p.recursePin.HasKey(k) {
-			return fmt.Errorf("%s already pinned recursively", k.B58String())
+			if !p.expired(k) {
+				return fmt.Errorf("%s already pinned recursively", k.B58String())
+			}
+			// the recursive pin is gone, as the sweeper would remove it
+			p.recursePin.RemoveBlock(k)
+			delete(p.expires, k)
 		}
 
 		p.directPin.AddBlock(k)
@@ -158,33 +255,292 @@ func (p *pinner) Pin(ctx context.Context, node *mdag.Node, recurse bool) error {
 	return nil
 }
 
+type fetchedContextKey struct{}
+
+// WithFetchedGraphs returns a context telling the pinner that the graphs of
+// the nodes pinned with it are already complete locally, so that it doesn't
+// walk them again. The caller must keep gc from running in between.
+func WithFetchedGraphs(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fetchedContextKey{}, true)
+}
+
+// fetchGraph fetches the graph of 'node', unless 'ctx' tells it's local
+func (p *pinner) fetchGraph(ctx context.Context, node *mdag.Node) error {
+	if fetched, _ := ctx.Value(fetchedContextKey{}).(bool); fetched {
+		return nil
+	}
+	return mdag.FetchGraph(ctx, node, p.dserv)
+}
+
 var ErrNotPinned = fmt.Errorf("not pinned")
 
+// Update moves the recursive pin of 'from' to 'to'. The graph of 'from' is
+// complete, as it is pinned, so only the parts of the graph of 'to' that are
+// not in it are fetched. 'from' stays pinned if fetching fails.
+func (p *pinner) Update(ctx context.Context, from, to key.Key, unpin bool) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.recursePin.HasKey(from) || p.expired(from) {
+		return fmt.Errorf("%s is not pinned recursively", from)
+	}
+	if from == to {
+		return nil
+	}
+
+	fromNode, err := p.dserv.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	toNode, err := p.dserv.Get(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	if err := p.fetchChanges(ctx, fromNode, toNode, key.NewKeySet()); err != nil {
+		return err
+	}
+
+	// 'to' takes the expiry of 'from', unless it is pinned for good already
+	prev, toExpiring := p.expires[to]
+	toPermanent := !toExpiring && (p.recursePin.HasKey(to) || p.directPin.HasKey(to))
+	fromExpiry, fromExpiring := p.expires[from]
+	switch {
+	case toPermanent:
+	case !fromExpiring:
+		delete(p.expires, to)
+	case prev.Before(fromExpiry):
+		p.expires[to] = fromExpiry
+	}
+
+	p.touch(from)
+	p.touch(to)
+	p.directPin.RemoveBlock(to)
+	p.recursePin.AddBlock(to)
+	if unpin {
+		p.recursePin.RemoveBlock(from)
+		delete(p.expires, from)
+	}
+	return nil
+}
+
+// fetchChanges fetches the parts of the graph of 'to' that are not in the
+// complete graph of 'from'. The links of both are paired by name, or by
+// position when unnamed, and only those that changed are walked, so that the
+// walk is as large as the change rather than as the old graph.
+func (p *pinner) fetchChanges(ctx context.Context, from, to *mdag.Node, seen key.KeySet) error {
+	old := make(map[key.Key]struct{}, len(from.Links))
+	named := make(map[string]*mdag.Link)
+	for _, l := range from.Links {
+		old[key.Key(l.Hash)] = struct{}{}
+		if l.Name != "" {
+			named[l.Name] = l
+		}
+	}
+
+	for i, l := range to.Links {
+		k := key.Key(l.Hash)
+		if _, ok := old[k]; ok || seen.Has(k) {
+			continue
+		}
+		seen.Add(k)
+		node, err := p.dserv.Get(ctx, k)
+		if err != nil {
+			return err
+		}
+
+		var prev *mdag.Link
+		switch {
+		case l.Name != "":
+			prev = named[l.Name]
+		case i < len(from.Links) && from.Links[i].Name == "":
+			prev = from.Links[i]
+		}
+		if prev == nil {
+			// a new subtree, fetched whole
+			if err := mdag.EnumerateChildrenAsync(ctx, p.dserv, node, seen); err != nil {
+				return err
+			}
+			continue
+		}
+
+		prevNode, err := p.dserv.Get(ctx, key.Key(prev.Hash))
+		if err != nil {
+			return err
+		}
+		if err := p.fetchChanges(ctx, prevNode, node, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Unpin a given key
 func (p *pinner) Unpin(ctx context.Context, k key.Key, recursive bool) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	reason, pinned, err := p.isPinnedWithType(k, Any)
+
+	p.touch(k)
+	switch {
+	case p.recursePin.HasKey(k):
+		if !recursive {
+			return fmt.Errorf("%s is pinned recursively", k)
+		}
+		p.recursePin.RemoveBlock(k)
+		delete(p.expires, k)
+		return nil
+	case p.directPin.HasKey(k):
+		p.directPin.RemoveBlock(k)
+		delete(p.expires, k)
+		return nil
+	case len(p.named[k]) > 0:
+		return fmt.Errorf("%s is only pinned by name, remove the named pins instead", k)
+	}
+
+	reason, pinned, err := p.isPinnedWithType(k, Indirect)
 	if err != nil {
 		return err
 	}
 	if !pinned {
 		return ErrNotPinned
 	}
-	switch reason {
-	case "recursive":
-		if recursive {
-			p.recursePin.RemoveBlock(k)
+	return fmt.Errorf("%s is pinned indirectly under %s", k, reason)
+}
+
+// PinNamed pins 'node' under 'name', on behalf of 'owner'. Pinning it again
+// with the same name and owner only changes whether it is pinned
+// recursively.
+func (p *pinner) PinNamed(ctx context.Context, node *mdag.Node, recurse bool, name, owner string) error {
+	if name == "" {
+		return fmt.Errorf("named pins need a name")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	k, err := node.Key()
+	if err != nil {
+		return err
+	}
+
+	if recurse {
+		if !p.isRecursive(k) {
+			// fetch entire graph
+			if err := p.fetchGraph(ctx, node); err != nil {
+				return err
+			}
+		}
+	} else {
+		if _, err := p.dserv.Get(ctx, k); err != nil {
+			return err
+		}
+	}
+
+	p.touch(k)
+	pins := p.named[k]
+	for i, np := range pins {
+		if np.Name == name && np.Owner == owner {
+			pins[i].Recursive = recurse
 			return nil
+		}
+	}
+	p.named[k] = append(pins, NamedPin{
+		Key:       k,
+		Name:      name,
+		Owner:     owner,
+		Recursive: recurse,
+	})
+	return nil
+}
+
+// UnpinNamed removes the pin of 'k' named 'name' that belongs to 'owner'.
+// The key stays pinned if other pins on it remain.
+func (p *pinner) UnpinNamed(k key.Key, name, owner string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pins := p.named[k]
+	for i, np := range pins {
+		if np.Name != name || np.Owner != owner {
+			continue
+		}
+
+		p.touch(k)
+		pins = append(pins[:i], pins[i+1:]...)
+		if len(pins) == 0 {
+			delete(p.named, k)
 		} else {
-			return fmt.Errorf("%s is pinned recursively", k)
+			p.named[k] = pins
 		}
-	case "direct":
-		p.directPin.RemoveBlock(k)
 		return nil
-	default:
-		return fmt.Errorf("%s is pinned indirectly under %s", k, reason)
 	}
+	return ErrNotPinned
+}
+
+// NamedPins returns all named pins
+func (p *pinner) NamedPins() []NamedPin {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.namedPins()
+}
+
+func (p *pinner) namedPins() []NamedPin {
+	var out []NamedPin
+	for _, pins := range p.named {
+		out = append(out, pins...)
+	}
+	return out
+}
+
+// hasNamed returns whether 'k' has a named pin that is recursive, or direct
+func (p *pinner) hasNamed(k key.Key, recursive bool) bool {
+	for _, np := range p.named[k] {
+		if np.Recursive == recursive {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pinner) isRecursive(k key.Key) bool {
+	return (p.recursePin.HasKey(k) && !p.expired(k)) || p.hasNamed(k, true)
+}
+
+func (p *pinner) isDirect(k key.Key) bool {
+	return (p.directPin.HasKey(k) && !p.expired(k)) || p.hasNamed(k, false)
+}
+
+// expired returns whether the pin of 'k' expired, even if it wasn't removed
+// yet
+func (p *pinner) expired(k key.Key) bool {
+	t, ok := p.expires[k]
+	return ok && !t.After(time.Now())
+}
+
+// Expiry returns when the pin of 'k' expires, if it does
+func (p *pinner) Expiry(k key.Key) (time.Time, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	t, ok := p.expires[k]
+	return t, ok
+}
+
+// RemoveExpired removes the pins that expired by 'now'
+func (p *pinner) RemoveExpired(now time.Time) []key.Key {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var removed []key.Key
+	for k, t := range p.expires {
+		if t.After(now) {
+			continue
+		}
+		p.touch(k)
+		p.recursePin.RemoveBlock(k)
+		p.directPin.RemoveBlock(k)
+		delete(p.expires, k)
+		removed = append(removed, k)
+	}
+	return removed
 }
 
 func (p *pinner) isInternalPin(key key.Key) bool {
@@ -216,14 +572,14 @@ func (p *pinner) isPinnedWithType(k key.Key, mode PinMode) (string, bool, error)
 			mode, Direct, Indirect, Recursive, Internal, Any)
 		return "", false, err
 	}
-	if (mode == Recursive || mode == Any) && p.recursePin.HasKey(k) {
+	if (mode == Recursive || mode == Any) && p.isRecursive(k) {
 		return linkRecursive, true, nil
 	}
 	if mode == Recursive {
 		return "", false, nil
 	}
 
-	if (mode == Direct || mode == Any) && p.directPin.HasKey(k) {
+	if (mode == Direct || mode == Any) && p.isDirect(k) {
 		return linkDirect, true, nil
 	}
 	if mode == Direct {
@@ -238,7 +594,7 @@ func (p *pinner) isPinnedWithType(k key.Key, mode PinMode) (string, bool, error)
 	}
 
 	// Default is Indirect
-	for _, rk := range p.recursePin.GetKeys() {
+	for _, rk := range p.recursiveKeys() {
 		rnd, err := p.dserv.Get(context.Background(), rk)
 		if err != nil {
 			return "", false, err
@@ -258,6 +614,7 @@ func (p *pinner) isPinnedWithType(k key.Key, mode PinMode) (string, bool, error)
 func (p *pinner) RemovePinWithMode(key key.Key, mode PinMode) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	p.touch(key)
 	switch mode {
 	case Direct:
 		p.directPin.RemoveBlock(key)
@@ -267,19 +624,61 @@ func (p *pinner) RemovePinWithMode(key key.Key, mode PinMode) {
 		// programmer error, panic OK
 		panic("unrecognized pin type")
 	}
+	if !p.recursePin.HasKey(key) && !p.directPin.HasKey(key) {
+		delete(p.expires, key)
+	}
 }
 
 // LoadPinner loads a pinner and its keysets from the given datastore
-func LoadPinner(d ds.Datastore, dserv mdag.DAGService) (Pinner, error) {
-	p := new(pinner)
+func LoadPinner(d ds.Batching, dserv mdag.DAGService) (Pinner, error) {
+	p := NewPinner(d, dserv).(*pinner)
 
-	rootKeyI, err := d.Get(pinDatastoreKey)
+	indexed, err := d.Has(pinsetVersionKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot load pin state: %v", err)
 	}
+	if indexed {
+		if err := p.loadIndex(); err != nil {
+			return nil, fmt.Errorf("cannot load pin state: %v", err)
+		}
+		return p, nil
+	}
+
+	if err := p.loadRoot(); err != nil {
+		return nil, err
+	}
+
+	// migrate to the pin index, writing every pin
+	for _, k := range p.recursePin.GetKeys() {
+		p.touch(k)
+	}
+	for _, k := range p.directPin.GetKeys() {
+		p.touch(k)
+	}
+	for k := range p.named {
+		p.touch(k)
+	}
+	for k := range p.expires {
+		p.touch(k)
+	}
+	p.legacyRoot = true
+	if err := p.Flush(); err != nil {
+		// the pin root is kept until a flush succeeds
+		log.Errorf("cannot migrate pin state: %s", err)
+	}
+	return p, nil
+}
+
+// loadRoot loads the pin state from the sets under the pin root, where it
+// was kept before the pin index
+func (p *pinner) loadRoot() error {
+	rootKeyI, err := p.dstore.Get(pinDatastoreKey)
+	if err != nil {
+		return fmt.Errorf("cannot load pin state: %v", err)
+	}
 	rootKeyBytes, ok := rootKeyI.([]byte)
 	if !ok {
-		return nil, fmt.Errorf("cannot load pin state: %s was not bytes", pinDatastoreKey)
+		return fmt.Errorf("cannot load pin state: %s was not bytes", pinDatastoreKey)
 	}
 
 	rootKey := key.Key(rootKeyBytes)
@@ -287,9 +686,9 @@ func LoadPinner(d ds.Datastore, dserv mdag.DAGService) (Pinner, error) {
 	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
 	defer cancel()
 
-	root, err := dserv.Get(ctx, rootKey)
+	root, err := p.dserv.Get(ctx, rootKey)
 	if err != nil {
-		return nil, fmt.Errorf("cannot find pinning root object: %v", err)
+		return fmt.Errorf("cannot find pinning root object: %v", err)
 	}
 
 	internalPin := map[key.Key]struct{}{
@@ -300,89 +699,119 @@ func LoadPinner(d ds.Datastore, dserv mdag.DAGService) (Pinner, error) {
 	}
 
 	{ // load recursive set
-		recurseKeys, err := loadSet(ctx, dserv, root, linkRecursive, recordInternal)
+		recurseKeys, err := loadSet(ctx, p.dserv, root, linkRecursive, recordInternal)
 		if err != nil {
-			return nil, fmt.Errorf("cannot load recursive pins: %v", err)
+			return fmt.Errorf("cannot load recursive pins: %v", err)
 		}
 		p.recursePin = set.SimpleSetFromKeys(recurseKeys)
 	}
 
 	{ // load direct set
-		directKeys, err := loadSet(ctx, dserv, root, linkDirect, recordInternal)
+		directKeys, err := loadSet(ctx, p.dserv, root, linkDirect, recordInternal)
 		if err != nil {
-			return nil, fmt.Errorf("cannot load direct pins: %v", err)
+			return fmt.Errorf("cannot load direct pins: %v", err)
 		}
 		p.directPin = set.SimpleSetFromKeys(directKeys)
 	}
 
-	p.internalPin = internalPin
+	if _, err := root.GetNodeLink(linkNamed); err == nil {
+		// pin roots written before named pins existed have none
+		named, err := loadNamedPins(ctx, p.dserv, root, linkNamed, recordInternal)
+		if err != nil {
+			return fmt.Errorf("cannot load named pins: %v", err)
+		}
+		for _, np := range named {
+			p.named[np.Key] = append(p.named[np.Key], np)
+		}
+	}
 
-	// assign services
-	p.dserv = dserv
-	p.dstore = d
+	if _, err := root.GetNodeLink(linkExpires); err == nil {
+		expires, err := loadExpiries(ctx, p.dserv, root, linkExpires, recordInternal)
+		if err != nil {
+			return fmt.Errorf("cannot load pin expiries: %v", err)
+		}
+		p.expires = expires
+	}
 
-	return p, nil
+	p.internalPin = internalPin
+	return nil
 }
 
 // DirectKeys returns a slice containing the directly pinned keys
 func (p *pinner) DirectKeys() []key.Key {
-	return p.directPin.GetKeys()
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.withNamed(p.directPin, false)
 }
 
 // RecursiveKeys returns a slice containing the recursively pinned keys
 func (p *pinner) RecursiveKeys() []key.Key {
-	return p.recursePin.GetKeys()
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.recursiveKeys()
 }
 
-// Flush encodes and writes pinner keysets to the datastore
-func (p *pinner) Flush() error {
-	p.lock.Lock()
-	defer p.lock.Unlock()
-
-	ctx := context.TODO()
-
-	internalPin := make(map[key.Key]struct{})
-	recordInternal := func(k key.Key) {
-		internalPin[k] = struct{}{}
-	}
+func (p *pinner) recursiveKeys() []key.Key {
+	return p.withNamed(p.recursePin, true)
+}
 
-	root := &mdag.Node{}
-	{
-		n, err := storeSet(ctx, p.dserv, p.directPin.GetKeys(), recordInternal)
-		if err != nil {
-			return err
-		}
-		if err := root.AddNodeLink(linkDirect, n); err != nil {
-			return err
+// withNamed returns the keys of 's' whose pins didn't expire, and those of
+// the named pins that are recursive, or direct, that are not in it
+func (p *pinner) withNamed(s set.BlockSet, recursive bool) []key.Key {
+	var keys []key.Key
+	for _, k := range s.GetKeys() {
+		if !p.expired(k) {
+			keys = append(keys, k)
 		}
 	}
-
-	{
-		n, err := storeSet(ctx, p.dserv, p.recursePin.GetKeys(), recordInternal)
-		if err != nil {
-			return err
-		}
-		if err := root.AddNodeLink(linkRecursive, n); err != nil {
-			return err
+	for k := range p.named {
+		if p.hasNamed(k, recursive) && !s.HasKey(k) {
+			keys = append(keys, k)
 		}
 	}
+	return keys
+}
 
-	// add the empty node, its referenced by the pin sets but never created
-	_, err := p.dserv.Add(new(mdag.Node))
+// Flush writes the pins that changed since the last flush to the datastore
+func (p *pinner) Flush() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	b, err := p.dstore.Batch()
 	if err != nil {
 		return err
 	}
-
-	k, err := p.dserv.Add(root)
-	if err != nil {
+	stored := make(map[key.Key]map[ds.Key]struct{}, len(p.dirty))
+	for k := range p.dirty {
+		entries, err := p.storePin(b, k)
+		if err != nil {
+			return fmt.Errorf("cannot store pin state: %v", err)
+		}
+		stored[k] = entries
+	}
+	if err := b.Put(pinsetVersionKey, []byte(pinsetVersion)); err != nil {
 		return err
 	}
-
-	internalPin[k] = struct{}{}
-	if err := p.dstore.Put(pinDatastoreKey, []byte(k)); err != nil {
+	if err := b.Commit(); err != nil {
 		return fmt.Errorf("cannot store pin state: %v", err)
 	}
-	p.internalPin = internalPin
+	for k, entries := range stored {
+		if len(entries) == 0 {
+			delete(p.indexed, k)
+			continue
+		}
+		p.indexed[k] = entries
+	}
+	p.dirty = make(map[key.Key]struct{})
+
+	if p.legacyRoot {
+		// the pin root and its sets are garbage now
+		if err := p.dstore.Delete(pinDatastoreKey); err != nil {
+			log.Errorf("cannot remove the old pin root: %s", err)
+		}
+		p.internalPin = nil
+		p.legacyRoot = false
+	}
 	return nil
 }
 
@@ -401,12 +830,14 @@ func (p *pinner) InternalPins() []key.Key {
 func (p *pinner) PinWithMode(k key.Key, mode PinMode) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	p.touch(k)
 	switch mode {
 	case Recursive:
 		p.recursePin.AddBlock(k)
 	case Direct:
 		p.directPin.AddBlock(k)
 	}
+	delete(p.expires, k)
 }
 
 func hasChild(ds mdag.DAGService, root *mdag.Node, child key.Key) (bool, error) {
@@ -12,6 +12,7 @@ import (
 	"github.com/ipfs/go-ipfs/exchange/offline"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dsq "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/query"
 	dssync "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/sync"
 	"gx/ipfs/QmZNVWh8LLjAavuQ2JXuFmuYH3C11xo988vSgp7UQrTRj1/go-ipfs-util"
 )
@@ -232,3 +233,479 @@ func TestPinRecursiveFail(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPinFetchedGraph(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	c, ck := randNode()
+	b, _ := randNode()
+	for _, n := range []*mdag.Node{a, c} {
+		if err := n.AddNodeLinkClean("child", b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the graphs are not walked, so the missing child goes unnoticed
+	fctx := WithFetchedGraphs(ctx)
+	if err := p.Pin(fctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, ak, "fetched graph was not pinned")
+	if err := p.PinNamed(fctx, c, true, "n", "o"); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, ck, "fetched graph was not pinned by name")
+}
+
+func assertUnpinned(t *testing.T, p Pinner, k key.Key, failmsg string) {
+	_, pinned, err := p.IsPinned(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pinned {
+		t.Fatal(failmsg)
+	}
+}
+
+func TestNamedPins(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	if _, err := dserv.Add(a); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.PinNamed(ctx, a, true, "backup", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PinNamed(ctx, a, true, "backup", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.RecursiveKeys()) != 1 {
+		t.Fatal("expected the key to be pinned recursively once")
+	}
+
+	// a plain unpin must not remove the pins of others
+	if err := p.Unpin(ctx, ak, true); err == nil {
+		t.Fatal("expected unpinning a key only pinned by name to fail")
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(np.NamedPins()) != 2 {
+		t.Fatalf("expected 2 named pins after reload, got %d", len(np.NamedPins()))
+	}
+
+	if err := np.UnpinNamed(ak, "backup", "carol"); err != ErrNotPinned {
+		t.Fatal("expected the pin of another owner not to be found")
+	}
+
+	if err := np.UnpinNamed(ak, "backup", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, np, ak, "key lost its pin while another remained")
+
+	if err := np.UnpinNamed(ak, "backup", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, np, ak, "key still pinned after its last pin was removed")
+}
+
+func TestExpiringPins(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	b, bk := randNode()
+	c, ck := randNode()
+	for _, n := range []*mdag.Node{a, b, c} {
+		if _, err := dserv.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := p.PinExpiring(ctx, a, true, later); err != nil {
+		t.Fatal(err)
+	}
+	// pinning again with an earlier expiry keeps the later one
+	if err := p.PinExpiring(ctx, a, true, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, ak, "expiring pin was not pinned")
+
+	if err := p.PinExpiring(ctx, b, false, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, p, bk, "expired pin is still pinned")
+	if len(p.DirectKeys()) != 0 {
+		t.Fatal("expired pin is listed")
+	}
+
+	// a pin for good is not made to expire
+	if err := p.Pin(ctx, c, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PinExpiring(ctx, c, true, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Expiry(ck); ok {
+		t.Fatal("pin that doesn't expire was given an expiry")
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp, ok := np.Expiry(ak)
+	if !ok || exp.Unix() != later.Unix() {
+		t.Fatalf("expected expiry %s after reload, got %s", later, exp)
+	}
+
+	removed := np.RemoveExpired(time.Now())
+	if len(removed) != 1 || removed[0] != bk {
+		t.Fatalf("expected only the expired pin to be removed, got %v", removed)
+	}
+	if _, ok := np.Expiry(bk); ok {
+		t.Fatal("removed pin kept its expiry")
+	}
+	assertPinned(t, np, ak, "pin removed before it expired")
+	assertPinned(t, np, ck, "pin that doesn't expire was removed")
+}
+
+func TestDirectPinOverExpiredRecursivePin(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	if _, err := dserv.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PinExpiring(ctx, a, true, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the recursive pin expired, though it wasn't removed yet
+	if err := p.Pin(ctx, a, false); err != nil {
+		t.Fatalf("direct pin over an expired recursive pin failed: %s", err)
+	}
+	if _, pinned, _ := p.IsPinnedWithType(ak, Direct); !pinned {
+		t.Fatal("key was not pinned directly")
+	}
+	if _, ok := p.Expiry(ak); ok {
+		t.Fatal("direct pin kept the expiry of the recursive pin")
+	}
+	if removed := p.RemoveExpired(time.Now()); len(removed) != 0 {
+		t.Fatalf("direct pin was swept: %v", removed)
+	}
+	assertPinned(t, p, ak, "direct pin was lost")
+}
+
+func TestPinUpdate(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	shared, _ := randNode()
+	if _, err := dserv.Add(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	old, oldk := randNode()
+	if err := old.AddNodeLink("shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, old, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new root whose other child is missing can't be fetched
+	missing, _ := randNode()
+	broken, brokenk := randNode()
+	if err := broken.AddNodeLink("shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	if err := broken.AddNodeLinkClean("missing", missing); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(broken); err != nil {
+		t.Fatal(err)
+	}
+
+	mctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	if err := p.Update(mctx, oldk, brokenk, true); err == nil {
+		t.Fatal("expected updating to an incomplete graph to fail")
+	}
+	assertPinned(t, p, oldk, "old root lost its pin when the update failed")
+	assertUnpinned(t, p, brokenk, "incomplete graph was pinned")
+
+	// once the missing child is there, the update goes through
+	if _, err := dserv.Add(missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(ctx, oldk, brokenk, false); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, oldk, "old root was unpinned despite unpin=false")
+	assertPinned(t, p, brokenk, "new root was not pinned")
+
+	newer, newerk := randNode()
+	if err := newer.AddNodeLink("shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(newer); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(ctx, oldk, newerk, true); err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, p, oldk, "old root still pinned after the update")
+	assertPinned(t, p, newerk, "new root was not pinned")
+
+	if err := p.Update(ctx, oldk, newerk, true); err == nil {
+		t.Fatal("expected updating from an unpinned key to fail")
+	}
+}
+
+func TestPinUpdateWalksChangesOnly(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	// the unchanged subtree of the old root is not stored, so walking it
+	// would fail
+	unchanged, _ := randNode()
+	leaf, _ := randNode()
+	if err := unchanged.AddNodeLinkClean("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(unchanged); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := func(child *mdag.Node) (*mdag.Node, key.Key) {
+		sub, _ := randNode()
+		if err := sub.AddNodeLink("child", child); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dserv.Add(sub); err != nil {
+			t.Fatal(err)
+		}
+		root, _ := randNode()
+		if err := root.AddNodeLinkClean("unchanged", unchanged); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink("changed", sub); err != nil {
+			t.Fatal(err)
+		}
+		k, err := dserv.Add(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root, k
+	}
+
+	oldChild, _ := randNode()
+	if _, err := dserv.Add(oldChild); err != nil {
+		t.Fatal(err)
+	}
+	old, oldk := dir(oldChild)
+	if err := p.Pin(WithFetchedGraphs(ctx), old, true); err != nil {
+		t.Fatal(err)
+	}
+
+	newChild, _ := randNode()
+	if _, err := dserv.Add(newChild); err != nil {
+		t.Fatal(err)
+	}
+	_, newk := dir(newChild)
+
+	mctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := p.Update(mctx, oldk, newk, true); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, newk, "new root was not pinned")
+}
+
+// countingDatastore counts the entries written to it, and the lookups
+type countingDatastore struct {
+	ds.Batching
+	puts  int
+	reads int
+}
+
+func (c *countingDatastore) Put(k ds.Key, v interface{}) error {
+	c.puts++
+	return c.Batching.Put(k, v)
+}
+
+func (c *countingDatastore) Has(k ds.Key) (bool, error) {
+	c.reads++
+	return c.Batching.Has(k)
+}
+
+func (c *countingDatastore) Query(q dsq.Query) (dsq.Results, error) {
+	c.reads++
+	return c.Batching.Query(q)
+}
+
+func (c *countingDatastore) Batch() (ds.Batch, error) {
+	return &countingBatch{c}, nil
+}
+
+// countingBatch writes to its datastore right away
+type countingBatch struct {
+	c *countingDatastore
+}
+
+func (b *countingBatch) Put(k ds.Key, v interface{}) error {
+	return b.c.Put(k, v)
+}
+
+func (b *countingBatch) Delete(k ds.Key) error {
+	return b.c.Delete(k)
+}
+
+func (b *countingBatch) Commit() error {
+	return nil
+}
+
+func TestFlushIncremental(t *testing.T) {
+	dstore := &countingDatastore{Batching: dssync.MutexWrap(ds.NewMapDatastore())}
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+	var keys []key.Key
+	for i := 0; i < 100; i++ {
+		_, k := randNode()
+		p.PinWithMode(k, Direct)
+		keys = append(keys, k)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the changed pins, and the version of the index, are written,
+	// without looking up what the index has
+	dstore.puts, dstore.reads = 0, 0
+	_, k := randNode()
+	p.PinWithMode(k, Recursive)
+	p.RemovePinWithMode(keys[0], Direct)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if dstore.puts != 2 {
+		t.Fatalf("expected 2 entries to be written, got %d", dstore.puts)
+	}
+	if dstore.reads != 0 {
+		t.Fatalf("expected no lookups, got %d", dstore.reads)
+	}
+
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(np.DirectKeys()) != 99 || len(np.RecursiveKeys()) != 1 {
+		t.Fatalf("expected 99 direct and 1 recursive pins, got %d and %d",
+			len(np.DirectKeys()), len(np.RecursiveKeys()))
+	}
+	assertPinned(t, np, k, "new pin was lost")
+	if _, pinned, _ := np.IsPinned(keys[0]); pinned {
+		t.Fatal("removed pin came back")
+	}
+}
+
+func TestFlushRemovesLoadedEntries(t *testing.T) {
+	ctx := context.Background()
+	dstore := &countingDatastore{Batching: dssync.MutexWrap(ds.NewMapDatastore())}
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+	a, ak := randNode()
+	if _, err := dserv.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PinNamed(ctx, a, false, "backup", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	_, dk := randNode()
+	p.PinWithMode(dk, Direct)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the entries of a loaded pinner are removed without lookups too
+	lp, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.UnpinNamed(ak, "backup", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	lp.RemovePinWithMode(dk, Direct)
+	dstore.reads = 0
+	if err := lp.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if dstore.reads != 0 {
+		t.Fatalf("expected no lookups, got %d", dstore.reads)
+	}
+
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(np.NamedPins()) != 0 || len(np.DirectKeys()) != 0 {
+		t.Fatalf("removed pins came back: %v, %v", np.NamedPins(), np.DirectKeys())
+	}
+}
@@ -0,0 +1,162 @@
+// Package pinservice implements the client of the pin service protocol, a
+// JSON API over HTTP that nodes use to ask other nodes to pin content.
+//
+// Pins are requested under a name, unique to each client:
+//
+//	POST   <endpoint>/pins         pins the key of a PinRequest
+//	GET    <endpoint>/pins         lists the PinStatus of all pins
+//	GET    <endpoint>/pins/<name>  returns the PinStatus of a pin
+//	DELETE <endpoint>/pins/<name>  removes a pin
+//
+// Clients authenticate with a token, in an 'Authorization: Bearer <token>'
+// header.
+package pinservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// Statuses of pins
+const (
+	Queued  = "queued"
+	Pinning = "pinning"
+	Pinned  = "pinned"
+	Failed  = "failed"
+)
+
+// PinRequest asks a pin service to pin a key recursively. Without a name,
+// the key is used as the name.
+type PinRequest struct {
+	Key  string
+	Name string
+}
+
+// PinStatus is the status of a pin requested from a pin service
+type PinStatus struct {
+	Name   string
+	Key    string
+	Status string
+	Error  string `json:",omitempty"`
+}
+
+// Done returns whether the pin service is done with the pin, whether it
+// succeeded or not
+func (s *PinStatus) Done() bool {
+	return s.Status == Pinned || s.Status == Failed
+}
+
+// Client talks to a pin service
+type Client struct {
+	Endpoint string
+	Token    string
+}
+
+// NewClient returns a client of the pin service at 'endpoint'
+func NewClient(endpoint, token string) *Client {
+	return &Client{
+		Endpoint: strings.TrimRight(endpoint, "/"),
+		Token:    token,
+	}
+}
+
+// Add asks the pin service to pin 'k' under 'name'. The service pins it in
+// the background, its status tells how far it got.
+func (c *Client) Add(ctx context.Context, k key.Key, name string) (*PinStatus, error) {
+	body, err := json.Marshal(&PinRequest{Key: k.B58String(), Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	st := new(PinStatus)
+	if err := c.do(ctx, "POST", "/pins", bytes.NewReader(body), st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// Status returns the status of the pin named 'name'
+func (c *Client) Status(ctx context.Context, name string) (*PinStatus, error) {
+	st := new(PinStatus)
+	if err := c.do(ctx, "GET", pinPath(name), nil, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// Ls returns the status of every pin of the client
+func (c *Client) Ls(ctx context.Context) ([]*PinStatus, error) {
+	var sts []*PinStatus
+	if err := c.do(ctx, "GET", "/pins", nil, &sts); err != nil {
+		return nil, err
+	}
+	return sts, nil
+}
+
+// Rm asks the pin service to remove the pin named 'name'
+func (c *Client) Rm(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", pinPath(name), nil, nil)
+}
+
+// Wait polls the status of the pin named 'name' every 'interval', until the
+// pin service is done with it
+func (c *Client) Wait(ctx context.Context, name string, interval time.Duration) (*PinStatus, error) {
+	for {
+		st, err := c.Status(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if st.Done() {
+			return st, nil
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pinPath returns the path of the pin named 'name'
+func pinPath(name string) string {
+	return (&url.URL{Path: "/pins/" + name}).EscapedPath()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.Endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Cancel = ctx.Done()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("pin service: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
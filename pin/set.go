@@ -1,14 +1,10 @@
 package pin
 
 import (
-	"bytes"
-	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"hash/fnv"
-	"io"
-	"sort"
+	"time"
 	"unsafe"
 
 	"github.com/ipfs/go-ipfs/blocks/key"
@@ -18,29 +14,8 @@ import (
 	"gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 )
 
-const (
-	defaultFanout = 256
-	maxItems      = 8192
-)
-
-func randomSeed() (uint32, error) {
-	var buf [4]byte
-	if _, err := rand.Read(buf[:]); err != nil {
-		return 0, err
-	}
-	return binary.LittleEndian.Uint32(buf[:]), nil
-}
-
-func hash(seed uint32, k key.Key) uint32 {
-	var buf [4]byte
-	binary.LittleEndian.PutUint32(buf[:], seed)
-	h := fnv.New32a()
-	_, _ = h.Write(buf[:])
-	_, _ = io.WriteString(h, string(k))
-	return h.Sum32()
-}
-
-type itemIterator func() (k key.Key, data []byte, ok bool)
+// The sets are only read, to move the pins of repos written before the pin
+// index to it.
 
 type keyObserver func(key.Key)
 
@@ -57,125 +32,28 @@ type keyObserver func(key.Key)
 //       in later versions need encoding/binary.
 type refcount uint8
 
-func (r refcount) Bytes() []byte {
-	return []byte{byte(r)}
-}
-
 // readRefcount returns the idx'th refcount in []byte, which is
-// assumed to be a sequence of refcount.Bytes results.
+// assumed to be a sequence of marshaled refcounts.
 func (r *refcount) ReadFromIdx(buf []byte, idx int) {
 	*r = refcount(buf[idx])
 }
 
-type sortByHash struct {
-	links []*merkledag.Link
-	data  []byte
-}
+// expiry is the marshaled format of the expiry of a pin, the unix time in
+// seconds it expires at.
+type expiry int64
 
-func (s sortByHash) Len() int {
-	return len(s.links)
-}
+const expirySize = int(unsafe.Sizeof(expiry(0)))
 
-func (s sortByHash) Less(a, b int) bool {
-	return bytes.Compare(s.links[a].Hash, s.links[b].Hash) == -1
+func (e expiry) Bytes() []byte {
+	buf := make([]byte, expirySize)
+	binary.LittleEndian.PutUint64(buf, uint64(e))
+	return buf
 }
 
-func (s sortByHash) Swap(a, b int) {
-	s.links[a], s.links[b] = s.links[b], s.links[a]
-	if len(s.data) != 0 {
-		const n = int(unsafe.Sizeof(refcount(0)))
-		tmp := make([]byte, n)
-		copy(tmp, s.data[a*n:a*n+n])
-		copy(s.data[a*n:a*n+n], s.data[b*n:b*n+n])
-		copy(s.data[b*n:b*n+n], tmp)
-	}
-}
-
-func storeItems(ctx context.Context, dag merkledag.DAGService, estimatedLen uint64, iter itemIterator, internalKeys keyObserver) (*merkledag.Node, error) {
-	seed, err := randomSeed()
-	if err != nil {
-		return nil, err
-	}
-	n := &merkledag.Node{
-		Links: make([]*merkledag.Link, 0, defaultFanout+maxItems),
-	}
-	for i := 0; i < defaultFanout; i++ {
-		n.Links = append(n.Links, &merkledag.Link{Hash: emptyKey.ToMultihash()})
-	}
-	internalKeys(emptyKey)
-	hdr := &pb.Set{
-		Version: proto.Uint32(1),
-		Fanout:  proto.Uint32(defaultFanout),
-		Seed:    proto.Uint32(seed),
-	}
-	if err := writeHdr(n, hdr); err != nil {
-		return nil, err
-	}
-	hdrLen := len(n.Data())
-
-	if estimatedLen < maxItems {
-		// it'll probably fit
-		for i := 0; i < maxItems; i++ {
-			k, data, ok := iter()
-			if !ok {
-				// all done
-				break
-			}
-			n.Links = append(n.Links, &merkledag.Link{Hash: k.ToMultihash()})
-			n.SetData(append(n.Data(), data...))
-		}
-		// sort by hash, also swap item Data
-		s := sortByHash{
-			links: n.Links[defaultFanout:],
-			data:  n.Data()[hdrLen:],
-		}
-		sort.Stable(s)
-	}
-
-	// wasteful but simple
-	type item struct {
-		k    key.Key
-		data []byte
-	}
-	hashed := make(map[uint32][]item)
-	for {
-		k, data, ok := iter()
-		if !ok {
-			break
-		}
-		h := hash(seed, k)
-		hashed[h] = append(hashed[h], item{k, data})
-	}
-	for h, items := range hashed {
-		childIter := func() (k key.Key, data []byte, ok bool) {
-			if len(items) == 0 {
-				return "", nil, false
-			}
-			first := items[0]
-			items = items[1:]
-			return first.k, first.data, true
-		}
-		child, err := storeItems(ctx, dag, uint64(len(items)), childIter, internalKeys)
-		if err != nil {
-			return nil, err
-		}
-		size, err := child.Size()
-		if err != nil {
-			return nil, err
-		}
-		childKey, err := dag.Add(child)
-		if err != nil {
-			return nil, err
-		}
-		internalKeys(childKey)
-		l := &merkledag.Link{
-			Name: "",
-			Hash: childKey.ToMultihash(),
-			Size: size,
-		}
-		n.Links[int(h%defaultFanout)] = l
-	}
-	return n, nil
+// ReadFromIdx reads the idx'th expiry in []byte, which is assumed to be a
+// sequence of expiry.Bytes results.
+func (e *expiry) ReadFromIdx(buf []byte, idx int) {
+	*e = expiry(binary.LittleEndian.Uint64(buf[idx*expirySize:]))
 }
 
 func readHdr(n *merkledag.Node) (*pb.Set, []byte, error) {
@@ -204,18 +82,6 @@ func readHdr(n *merkledag.Node) (*pb.Set, []byte, error) {
 	return &hdr, buf, nil
 }
 
-func writeHdr(n *merkledag.Node, hdr *pb.Set) error {
-	hdrData, err := proto.Marshal(hdr)
-	if err != nil {
-		return err
-	}
-	n.SetData(make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(hdrData)))
-	written := binary.PutUvarint(n.Data(), uint64(len(hdrData)))
-	n.SetData(n.Data()[:written])
-	n.SetData(append(n.Data(), hdrData...))
-	return nil
-}
-
 type walkerFunc func(buf []byte, idx int, link *merkledag.Link) error
 
 func walkItems(ctx context.Context, dag merkledag.DAGService, n *merkledag.Node, fn walkerFunc, children keyObserver) error {
@@ -292,74 +158,57 @@ func loadMultiset(ctx context.Context, dag merkledag.DAGService, root *merkledag
 	return refcounts, nil
 }
 
-func storeSet(ctx context.Context, dag merkledag.DAGService, keys []key.Key, internalKeys keyObserver) (*merkledag.Node, error) {
-	iter := func() (k key.Key, data []byte, ok bool) {
-		if len(keys) == 0 {
-			return "", nil, false
-		}
-		first := keys[0]
-		keys = keys[1:]
-		return first, nil, true
-	}
-	n, err := storeItems(ctx, dag, uint64(len(keys)), iter, internalKeys)
+func loadExpiries(ctx context.Context, dag merkledag.DAGService, root *merkledag.Node, name string, internalKeys keyObserver) (map[key.Key]time.Time, error) {
+	l, err := root.GetNodeLink(name)
 	if err != nil {
 		return nil, err
 	}
-	k, err := dag.Add(n)
+	internalKeys(key.Key(l.Hash))
+	n, err := l.GetNode(ctx, dag)
 	if err != nil {
 		return nil, err
 	}
-	internalKeys(k)
-	return n, nil
-}
 
-func copyRefcounts(orig map[key.Key]uint64) map[key.Key]uint64 {
-	r := make(map[key.Key]uint64, len(orig))
-	for k, v := range orig {
-		r[k] = v
-	}
-	return r
-}
-
-func storeMultiset(ctx context.Context, dag merkledag.DAGService, refcounts map[key.Key]uint64, internalKeys keyObserver) (*merkledag.Node, error) {
-	// make a working copy of the refcounts
-	refcounts = copyRefcounts(refcounts)
-
-	iter := func() (k key.Key, data []byte, ok bool) {
-		// Every call of this function returns the next refcount item.
-		//
-		// This function splits out the uint64 reference counts as
-		// smaller increments, as fits in type refcount. Most of the
-		// time the refcount will fit inside just one, so this saves
-		// space.
-		//
-		// We use range here to pick an arbitrary item in the map, but
-		// not really iterate the map.
-		for k, refs := range refcounts {
-			// Max value a single multiset item can store
-			num := ^refcount(0)
-			if refs <= uint64(num) {
-				// Remaining count fits in a single item; remove the
-				// key from the map.
-				num = refcount(refs)
-				delete(refcounts, k)
-			} else {
-				// Count is too large to fit in one item, the key will
-				// repeat in some later call.
-				refcounts[k] -= uint64(num)
-			}
-			return k, num.Bytes(), true
+	expires := make(map[key.Key]time.Time)
+	walk := func(buf []byte, idx int, link *merkledag.Link) error {
+		if len(buf) < (idx+1)*expirySize {
+			return errors.New("expiry out of bounds")
 		}
-		return "", nil, false
+		var e expiry
+		e.ReadFromIdx(buf, idx)
+		expires[key.Key(link.Hash)] = time.Unix(int64(e), 0)
+		return nil
 	}
-	n, err := storeItems(ctx, dag, uint64(len(refcounts)), iter, internalKeys)
-	if err != nil {
+	if err := walkItems(ctx, dag, n, walk, internalKeys); err != nil {
 		return nil, err
 	}
-	k, err := dag.Add(n)
+	return expires, nil
+}
+
+func loadNamedPins(ctx context.Context, dag merkledag.DAGService, root *merkledag.Node, name string, internalKeys keyObserver) ([]NamedPin, error) {
+	keys, err := loadSet(ctx, dag, root, name, internalKeys)
 	if err != nil {
 		return nil, err
 	}
-	internalKeys(k)
-	return n, nil
+
+	pins := make([]NamedPin, 0, len(keys))
+	for _, k := range keys {
+		internalKeys(k)
+		n, err := dag.Get(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+
+		var np pb.NamedPin
+		if err := proto.Unmarshal(n.Data(), &np); err != nil {
+			return nil, err
+		}
+		pins = append(pins, NamedPin{
+			Key:       key.Key(np.GetKey()),
+			Name:      np.GetName(),
+			Owner:     np.GetOwner(),
+			Recursive: np.GetRecursive(),
+		})
+	}
+	return pins, nil
 }
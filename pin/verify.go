@@ -0,0 +1,142 @@
+package pin
+
+import (
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	mh "gx/ipfs/QmYf7ng2hG5XBtJA3tN34DQ2GUN5HNksEw1rLDkmr6vGku/go-multihash"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+// BadNode is a block of a pinned graph that is missing or corrupt
+type BadNode struct {
+	Key key.Key
+	Err string
+}
+
+// PinStatus is the result of the verification of a recursive pin
+type PinStatus struct {
+	Key key.Key
+	// blocks that could not be read, or could not be repaired
+	BadNodes []BadNode
+	// blocks that were missing or corrupt, and were fetched again
+	Repaired []key.Key
+}
+
+// Ok returns whether the whole graph of the pin is stored
+func (s *PinStatus) Ok() bool {
+	return len(s.BadNodes) == 0
+}
+
+// subtree is what was found under a block, kept for the pins sharing it
+type subtree struct {
+	bad      []BadNode
+	repaired []key.Key
+}
+
+type verifier struct {
+	ctx    context.Context
+	bs     bstore.Blockstore
+	dserv  mdag.DAGService
+	repair bool
+
+	checked map[key.Key]*subtree
+}
+
+// Verify checks that every block of the graphs pinned recursively by 'pn' is
+// in 'bs', and matches its hash. With 'repair', missing and corrupt blocks
+// are fetched again through 'dserv'. The status of each pin is sent on the
+// returned channel, which is closed once all are verified.
+func Verify(ctx context.Context, pn Pinner, bs bstore.Blockstore, dserv mdag.DAGService, repair bool) <-chan *PinStatus {
+	v := &verifier{
+		ctx:     ctx,
+		bs:      bs,
+		dserv:   dserv,
+		repair:  repair,
+		checked: make(map[key.Key]*subtree),
+	}
+
+	out := make(chan *PinStatus)
+	go func() {
+		defer close(out)
+		for _, k := range pn.RecursiveKeys() {
+			st := v.check(k)
+			select {
+			case out <- &PinStatus{Key: k, BadNodes: st.bad, Repaired: st.repaired}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// check verifies the block 'k' and the blocks it links to
+func (v *verifier) check(k key.Key) *subtree {
+	if st, ok := v.checked[k]; ok {
+		return st
+	}
+
+	st := new(subtree)
+	nd, err := v.node(k)
+	if err != nil && v.repair {
+		nd, err = v.fetch(k)
+		if err == nil {
+			st.repaired = append(st.repaired, k)
+		}
+	}
+	if err != nil {
+		st.bad = append(st.bad, BadNode{Key: k, Err: err.Error()})
+		v.checked[k] = st
+		return st
+	}
+
+	for _, l := range nd.Links {
+		child := v.check(key.Key(l.Hash))
+		st.bad = append(st.bad, child.bad...)
+		st.repaired = append(st.repaired, child.repaired...)
+	}
+	v.checked[k] = st
+	return st
+}
+
+// node reads the block 'k' from the blockstore, and checks its hash
+func (v *verifier) node(k key.Key) (*mdag.Node, error) {
+	b, err := v.bs.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := mh.Decode(mh.Multihash(k))
+	if err != nil {
+		return nil, err
+	}
+	sum, err := mh.Sum(b.Data(), dec.Code, dec.Length)
+	if err != nil {
+		return nil, err
+	}
+	if key.Key(sum) != k {
+		return nil, bstore.ErrHashMismatch
+	}
+
+	return mdag.DecodeProtobuf(b.Data())
+}
+
+// fetch gets the block 'k' again, dropping the stored one if it is corrupt
+func (v *verifier) fetch(k key.Key) (*mdag.Node, error) {
+	if has, _ := v.bs.Has(k); has {
+		if err := v.bs.DeleteBlock(k); err != nil {
+			return nil, err
+		}
+	}
+	nd, err := v.dserv.Get(v.ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	// not every exchange stores the blocks it fetches
+	if _, err := v.dserv.Add(nd); err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
@@ -0,0 +1,92 @@
+package pin
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	ds "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
+	dssync "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore/sync"
+	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+)
+
+func verifyAll(t *testing.T, p Pinner, bstore blockstore.Blockstore, dserv mdag.DAGService, repair bool) []*PinStatus {
+	var out []*PinStatus
+	for st := range Verify(context.Background(), p, bstore, dserv, repair) {
+		out = append(out, st)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected the status of 1 pin, got %d", len(out))
+	}
+	return out
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+
+	// blocks missing locally are fetched from 'remote'
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(remote)))
+
+	p := NewPinner(dstore, dserv)
+
+	a, _ := randNode()
+	b, _ := randNode()
+	root, rootk := randNode()
+	for _, c := range []*mdag.Node{a, b} {
+		if err := root.AddNodeLink("child", c); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dserv.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dserv.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+
+	st := verifyAll(t, p, bstore, dserv, false)
+	if st[0].Key != rootk || !st[0].Ok() {
+		t.Fatal("complete pin reported as broken")
+	}
+
+	ak, _ := a.Key()
+	bk, _ := b.Key()
+	if err := bstore.DeleteBlock(ak); err != nil {
+		t.Fatal(err)
+	}
+	// corrupt b
+	if err := dstore.Put(bk.DsKey(), []byte("not b")); err != nil {
+		t.Fatal(err)
+	}
+
+	st = verifyAll(t, p, bstore, dserv, false)
+	if st[0].Ok() || len(st[0].BadNodes) != 2 {
+		t.Fatalf("expected 2 bad nodes, got %v", st[0].BadNodes)
+	}
+	bad := map[key.Key]bool{}
+	for _, n := range st[0].BadNodes {
+		bad[n.Key] = true
+	}
+	if !bad[ak] || !bad[bk] {
+		t.Fatal("missing and corrupt blocks were not both reported")
+	}
+
+	st = verifyAll(t, p, bstore, dserv, true)
+	if !st[0].Ok() || len(st[0].Repaired) != 2 {
+		t.Fatalf("expected 2 repaired blocks, got %v, bad %v", st[0].Repaired, st[0].BadNodes)
+	}
+
+	st = verifyAll(t, p, bstore, dserv, false)
+	if !st[0].Ok() {
+		t.Fatal("repaired pin still reported as broken")
+	}
+}
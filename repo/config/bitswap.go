@@ -0,0 +1,38 @@
+package config
+
+// Bitswap contains options for the bitswap exchange.
+type Bitswap struct {
+	// Strategy decides which peers are sent blocks: "serve-all" (the
+	// default), "reciprocal" or "allowlist-only"
+	Strategy string
+
+	// MaxDebtRatio is the ratio of the bytes sent to a peer over those
+	// received from it above which the reciprocal strategy stops serving it
+	MaxDebtRatio float64
+
+	// Allowlist are the peers the allowlist-only strategy serves
+	Allowlist []string
+
+	// MaxOutRate and MaxInRate limit the bandwidth of the blocks sent and
+	// received, like "1MB" per second. MaxPeerOutRate and MaxPeerInRate
+	// limit it for each peer. Empty means no limit.
+	MaxOutRate     string
+	MaxInRate      string
+	MaxPeerOutRate string
+	MaxPeerInRate  string
+
+	// PersistLedgers keeps the ledgers of peers in the datastore, so that
+	// what they owe outlives restarts
+	PersistLedgers bool
+
+	// BanThreshold is the reputation score at which misbehaving peers are
+	// banned, 0 for the default. BanDuration is how long for, like "10m".
+	// DisableBanning bans nobody.
+	BanThreshold   float64
+	BanDuration    string
+	DisableBanning bool
+
+	// BanFilter adds the addresses of banned peers to the swarm filters
+	// while they are banned
+	BanFilter bool
+}
@@ -29,6 +29,8 @@ type Config struct {
 	SupernodeRouting SupernodeClientConfig // local node's routing servers (if SupernodeRouting enabled)
 	API              API                   // local node's API settings
 	Swarm            SwarmConfig
+	Pinning          Pinning // remote pin services, and the one this node serves
+	Bitswap          Bitswap // bitswap exchange options
 }
 
 const (
@@ -14,6 +14,8 @@ type Datastore struct {
 	StorageMax         string // in B, kB, kiB, MB, ...
 	StorageGCWatermark int64  // in percentage to multiply on StorageMax
 	GCPeriod           string // in ns, us, ms, s, m, h
+	PinSweepPeriod     string // in ns, us, ms, s, m, h
+	PinSweepGC         bool
 
 	Params          *json.RawMessage
 	NoSync          bool
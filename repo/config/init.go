@@ -58,6 +58,13 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 			ResolveCacheSize: 128,
 		},
 
+		Bitswap: Bitswap{
+			Strategy:     "serve-all",
+			MaxDebtRatio: 1,
+			BanThreshold: -100,
+			BanDuration:  "10m",
+		},
+
 		Gateway: Gateway{
 			RootRedirect: "",
 			Writable:     false,
@@ -84,6 +91,7 @@ func datastoreConfig() (Datastore, error) {
 		StorageMax:         "10GB",
 		StorageGCWatermark: 90, // 90%
 		GCPeriod:           "1h",
+		PinSweepPeriod:     "1m",
 		HashOnRead:         false,
 		BloomFilterSize:    0,
 	}, nil
@@ -0,0 +1,23 @@
+package config
+
+// Pinning contains options for pinning content on remote pin services, and
+// for serving as one.
+type Pinning struct {
+	// pin services 'ipfs pin remote' can use, by name
+	RemoteServices map[string]RemotePinService
+
+	// the pin service this node serves on its gateway
+	Service PinService
+}
+
+// RemotePinService is a pin service this node can ask to pin content.
+type RemotePinService struct {
+	Endpoint string // URL of the service, e.g. http://127.0.0.1:8080/pinservice/v0
+	Token    string // token to authenticate with
+}
+
+// PinService contains options for the pin service served on the gateway.
+type PinService struct {
+	Enabled bool
+	Tokens  map[string]string // names of the clients, by their token
+}
@@ -26,7 +26,14 @@ import (
 var log = logging.Logger("fsrepo")
 
 // version number that we are currently expecting to see
-var RepoVersion = 4
+var RepoVersion = 5
+
+// pinIndexVersion is the first version keeping pins in the pin index. The
+// pinner moves the pins of older repos to it when it loads them, so repos of
+// the version before are opened, and upgraded once their pins were moved
+// (see UpgradeVersion), keeping older programs, which would collect the
+// pinned blocks, from opening them.
+const pinIndexVersion = 5
 
 var migrationInstructions = `See https://github.com/ipfs/fs-repo-migrations/blob/master/run.md
 Sorry for the inconvenience. In the future, these will run automatically.`
@@ -93,6 +100,8 @@ type FSRepo struct {
 	lockfile io.Closer
 	config   *config.Config
 	ds       repo.Datastore
+	// version is the version of the repo on disk
+	version int
 }
 
 var _ repo.Repo = (*FSRepo)(nil)
@@ -141,7 +150,12 @@ func open(repoPath string) (repo.Repo, error) {
 		return nil, err
 	}
 
-	if RepoVersion > ver {
+	r.version = ver
+
+	// repos of the version before the pin index are upgraded by
+	// UpgradeVersion
+	upgradable := ver == pinIndexVersion-1 && RepoVersion == pinIndexVersion
+	if RepoVersion > ver && !upgradable {
 		return nil, ErrNeedMigration
 	} else if ver > RepoVersion {
 		// program version too low for existing repo
@@ -390,6 +404,30 @@ func (r *FSRepo) Close() error {
 	return nil
 }
 
+// UpgradeVersion upgrades a repo of the version before the pin index to the
+// current version, once 'movePins' moved its pins to the pin index. Repos of
+// the current version are left alone.
+func (r *FSRepo) UpgradeVersion(movePins func() error) error {
+	packageLock.Lock()
+	ver := r.version
+	packageLock.Unlock()
+	if ver == RepoVersion {
+		return nil
+	}
+
+	if err := movePins(); err != nil {
+		return err
+	}
+
+	packageLock.Lock()
+	defer packageLock.Unlock()
+	if err := mfsr.RepoPath(r.path).WriteVersion(RepoVersion); err != nil {
+		return err
+	}
+	r.version = RepoVersion
+	return nil
+}
+
 // Result when not Open is undefined. The method may panic if it pleases.
 func (r *FSRepo) Config() (*config.Config, error) {
 
@@ -2,12 +2,14 @@ package fsrepo
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/ipfs/go-ipfs/repo/config"
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 	"github.com/ipfs/go-ipfs/thirdparty/assert"
 	datastore "gx/ipfs/QmTxLSvdhwg68WJimdS6icLPhZi28aTp6b7uihC2Yb47Xk/go-datastore"
 )
@@ -117,3 +119,32 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestUpgradeVersionAfterPinIndex(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{}), t)
+	assert.Nil(mfsr.RepoPath(path).WriteVersion(pinIndexVersion-1), t)
+
+	r, err := Open(path)
+	assert.Nil(err, t, "repo before the pin index should open")
+	defer r.Close()
+	version := func() int {
+		ver, err := mfsr.RepoPath(path).Version()
+		assert.Nil(err, t)
+		return ver
+	}
+	assert.True(version() == pinIndexVersion-1, t, "repo upgraded before its pins were moved")
+
+	failed := errors.New("cannot move the pins")
+	err = r.(*FSRepo).UpgradeVersion(func() error { return failed })
+	assert.Err(err, t, "failing to move the pins should fail the upgrade")
+	assert.True(version() == pinIndexVersion-1, t, "repo upgraded although its pins were not moved")
+
+	assert.Nil(r.(*FSRepo).UpgradeVersion(func() error { return nil }), t)
+	assert.True(version() == RepoVersion, t, "repo version was not upgraded")
+
+	moved := false
+	assert.Nil(r.(*FSRepo).UpgradeVersion(func() error { moved = true; return nil }), t)
+	assert.True(!moved, t, "pins of an upgraded repo moved again")
+}
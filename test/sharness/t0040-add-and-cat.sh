@@ -65,6 +65,28 @@ test_add_cat_file() {
         test_cmp expected actual
     '
 
+    test_expect_success "ipfs add --chunker buzhash succeeds" '
+        random 1000000 42 > buzhash_file &&
+        BUZ_HASH=$(ipfs add -q --chunker buzhash buzhash_file)
+    '
+
+    test_expect_success "ipfs add --chunker buzhash is reproducible" '
+        echo $BUZ_HASH > expected &&
+        ipfs add -q --chunker buzhash-87381-262144-393216 buzhash_file > actual &&
+        test_cmp expected actual
+    '
+
+    test_expect_success "ipfs add --chunker buzhash splits on content" '
+        ipfs object links $BUZ_HASH > buzhash_links &&
+        test $(wc -l < buzhash_links) -gt 1 &&
+        PLAIN_HASH=$(ipfs add -q buzhash_file) &&
+        test "$PLAIN_HASH" != "$BUZ_HASH"
+    '
+
+    test_expect_success "ipfs add --chunker rejects bad buzhash sizes" '
+        test_must_fail ipfs add --chunker buzhash-100-50-200 mountdir/hello.txt
+    '
+
     test_expect_success "ipfs add on hidden file succeeds" '
         echo "Hello Worlds!" >mountdir/.hello.txt &&
         ipfs add mountdir/.hello.txt >actual
@@ -318,6 +340,150 @@ test_expect_success "'ipfs add -rn' output looks good" '
 	test_cmp expected actual
 '
 
+test_expect_success "'ipfs add -rn --jobs' gives the same output" '
+	ipfs add -rn --jobs 4 mountdir/moons >actual_jobs &&
+	test_cmp expected actual_jobs
+'
+
+test_expect_success "'ipfs add --jobs 0' fails" '
+	test_must_fail ipfs add -r --jobs 0 mountdir/moons
+'
+
+test_expect_success "create a tree with ignore rules" '
+	mkdir -p ignored/src ignored/build ignored/docs &&
+	echo "main" > ignored/src/main.c &&
+	echo "obj" > ignored/src/main.o &&
+	echo "out" > ignored/build/out.bin &&
+	echo "readme" > ignored/docs/README &&
+	echo "draft" > ignored/docs/draft.tmp &&
+	echo "*.tmp" > ignored/docs/.ipfsignore &&
+	echo "build/" > ignore_rules
+'
+
+test_expect_success "'ipfs add --dry-run' lists what would be added" '
+	ipfs add -r --dry-run --exclude "*.o" --ignore-rules-path ignore_rules ignored >actual &&
+	echo "ignored/docs/README" >expected &&
+	echo "ignored/docs" >>expected &&
+	echo "ignored/src/main.c" >>expected &&
+	echo "ignored/src" >>expected &&
+	echo "ignored" >>expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs add -r' leaves out ignored files" '
+	IGNORED=$(ipfs add -r -q --exclude "*.o" --exclude "build/" ignored | tail -n1) &&
+	ipfs ls $IGNORED/src >actual &&
+	grep main.c actual &&
+	test_must_fail grep main.o actual &&
+	test_must_fail ipfs ls $IGNORED/build &&
+	ipfs ls $IGNORED/docs >actual &&
+	test_must_fail grep draft.tmp actual
+'
+
+test_expect_success "'ipfs add -r' without rules only honors .ipfsignore" '
+	ALL=$(ipfs add -r -q ignored | tail -n1) &&
+	ipfs ls $ALL/src >actual &&
+	grep main.o actual &&
+	ipfs ls $ALL/docs >actual &&
+	test_must_fail grep draft.tmp actual
+'
+
+test_expect_success "'ipfs add --resume' of a completed add gives the same hash" '
+	random 5000000 11 >resumed &&
+	ipfs add -q resumed >expected &&
+	ipfs add -q --resume resumed >actual &&
+	test_cmp expected actual &&
+	ipfs add -q --resume resumed >actual &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs add --max-links' changes the dag" '
+	random 10000000 12 >layout &&
+	DEFAULT=$(ipfs add -q -n -s size-4096 layout) &&
+	NARROW=$(ipfs add -q -n -s size-4096 --max-links 8 layout) &&
+	test "$DEFAULT" != "$NARROW"
+'
+
+test_expect_success "'ipfs add --params-out' records the settings" '
+	ipfs add -q -t --max-links 8 --trickle-depth 2 -s buzhash --params-out params.json layout >expected &&
+	grep "\"Chunker\": \"buzhash-87381-262144-393216\"" params.json &&
+	grep "\"Layout\": \"trickle\"" params.json &&
+	grep "\"MaxLinks\": 8" params.json &&
+	grep "\"TrickleDepth\": 2" params.json
+'
+
+test_expect_success "'ipfs add --params-in' reproduces the root" '
+	ipfs add -q --params-in params.json layout >actual &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs add --params-in' conflicts with layout options" '
+	test_must_fail ipfs add --params-in params.json --max-links 4 layout
+'
+
+test_expect_success "'ipfs add --params-out' records the options changing the root" '
+	ipfs add -q -w --sparse --params-out params-opts.json layout >expected &&
+	grep "\"Wrap\": true" params-opts.json &&
+	grep "\"Sparse\": true" params-opts.json &&
+	ipfs add -q --params-in params-opts.json layout >actual &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs add --trickle-depth' requires --trickle" '
+	test_must_fail ipfs add --trickle-depth 2 layout
+'
+
+test_expect_success "'ipfs add -n --report' reports new content" '
+	random 1000000 17 >report_file &&
+	ipfs add -q -n --report report_file >report_out &&
+	grep "^report_file .* 0 .* 0.0%$" report_out &&
+	grep "^total: 1000000 bytes in [0-9]* blocks, 0 already stored" report_out
+'
+
+test_expect_success "'ipfs add -n --report' counts stored blocks" '
+	ipfs add -q report_file &&
+	ipfs add -q -n --report report_file >report_out &&
+	grep "^report_file .* 100.0%$" report_out &&
+	grep ", 0 new bytes$" report_out
+'
+
+test_expect_success "'ipfs add --report' can print JSON" '
+	ipfs add -q -n --report --enc=json report_file >report_out &&
+	grep "\"Name\": \"report_file\"" report_out &&
+	grep "\"Dedup\": 1" report_out
+'
+
+test_expect_success "create tarballs sharing a file" '
+	mkdir -p tarballs/one tarballs/two &&
+	random 1000000 13 >tarballs/one/shared &&
+	cp tarballs/one/shared tarballs/two/shared &&
+	random 3333 14 >tarballs/one/a &&
+	random 77777 15 >tarballs/two/b &&
+	(cd tarballs/one && tar -cf ../one.tar a shared) &&
+	(cd tarballs/two && tar -cf ../two.tar b shared) &&
+	SHARED=$(ipfs add -q -s size-262144 tarballs/one/shared)
+'
+
+test_expect_success "'ipfs add --chunker=tar' reuses the blocks of the shared file" '
+	ONE=$(ipfs add -q --chunker=tar tarballs/one.tar) &&
+	TWO=$(ipfs add -q --chunker=tar tarballs/two.tar) &&
+	ipfs refs $SHARED | sort >shared_refs &&
+	ipfs refs $ONE | sort >one_refs &&
+	ipfs refs $TWO | sort >two_refs &&
+	comm -12 one_refs two_refs >common &&
+	test_cmp shared_refs common
+'
+
+test_expect_success "'ipfs add --chunker=tar' keeps the archive intact" '
+	ipfs cat $ONE >actual &&
+	test_cmp tarballs/one.tar actual
+'
+
+test_expect_success "'ipfs add --chunker=tar --resume' fails" '
+	test_must_fail ipfs add --chunker=tar --resume tarballs/one.tar 2>resume_err &&
+	grep "cannot be used with the tar chunker" resume_err
+'
+
 test_expect_success "ipfs cat accept many hashes from built input" '
 	{ echo "$MARS"; echo "$VENUS"; } | ipfs cat >actual
 '
@@ -345,6 +511,33 @@ test_add_cat_expensive
 
 test_add_named_pipe " Post http://$API_ADDR/api/v0/add?encoding=json&progress=true&r=true&stream-channels=true:"
 
+test_expect_success "'ipfs add --from-url' adds the content at a url" '
+	random 500000 16 >webfile &&
+	WEB=$(ipfs add -q webfile) &&
+	FROM_URL=$(ipfs add -q --from-url "http://$GWAY_ADDR/ipfs/$WEB") &&
+	ipfs cat $FROM_URL >actual &&
+	test_cmp webfile actual
+'
+
+test_expect_success "ipfs file stat shows the source url" '
+	ipfs file stat $FROM_URL >stat_out &&
+	grep "^Source: http://$GWAY_ADDR/ipfs/$WEB$" stat_out &&
+	grep "^ETag: .*$WEB" stat_out
+'
+
+# the leaves of the content served are made with another chunker, so those
+# built by the urlstore are only at the url
+test_expect_success "'ipfs urlstore add' adds by reference" '
+	WEB_SMALL=$(ipfs add -q -s size-1000 webfile) &&
+	BY_REF=$(ipfs urlstore add "http://$GWAY_ADDR/ipfs/$WEB_SMALL") &&
+	ipfs cat $BY_REF >actual &&
+	test_cmp webfile actual
+'
+
+test_expect_success "'ipfs add --nocopy' requires --from-url" '
+	test_must_fail ipfs add --nocopy webfile
+'
+
 test_kill_ipfs_daemon
 
 # should work offline
@@ -362,6 +555,87 @@ test_expect_success "ipfs cat file fails" '
 
 test_add_named_pipe ""
 
+test_expect_success "ipfs add --detect-mime succeeds" '
+	mkdir -p mimedir &&
+	echo "<html><body>hello</body></html>" > mimedir/page.html &&
+	printf "\000\001\002" > mimedir/blob &&
+	MIME_ROOT=$(ipfs add -r -q --detect-mime mimedir | tail -n1)
+'
+
+test_expect_success "ipfs file stat shows the stored mime type" '
+	ipfs file stat $MIME_ROOT/page.html > stat_out &&
+	grep "^Type: File" stat_out &&
+	grep "^MimeType: text/html; charset=utf-8" stat_out &&
+	ipfs file stat $MIME_ROOT/blob > stat_out &&
+	grep "^MimeType: application/octet-stream" stat_out
+'
+
+test_expect_success "ipfs cat reads files wrapped in metadata" '
+	ipfs cat $MIME_ROOT/page.html > cat_out &&
+	test_cmp mimedir/page.html cat_out
+'
+
+test_expect_success "ipfs ls --mime prints the stored mime type" '
+	ipfs ls --mime $MIME_ROOT > ls_out &&
+	grep "page.html.*text/html" ls_out
+'
+
+test_expect_success "files added without --detect-mime have no mime type" '
+	PLAIN=$(ipfs add -q mimedir/page.html) &&
+	ipfs file stat $PLAIN > stat_out &&
+	test_must_fail grep MimeType stat_out
+'
+
+if type setfattr >/dev/null 2>&1 &&
+	touch xattr_probe && setfattr -n user.probe -v 1 xattr_probe 2>/dev/null; then
+	test_set_prereq XATTR
+fi
+
+test_expect_success XATTR "ipfs add --xattrs preserves extended attributes" '
+	echo "xattr content" > xattr_file &&
+	setfattr -n user.origin -v sharness xattr_file &&
+	XATTR_HASH=$(ipfs add --xattrs -q xattr_file) &&
+	ipfs files cp /ipfs/$XATTR_HASH /xattr_file &&
+	ipfs files getxattr /xattr_file user.origin > xattr_out &&
+	printf sharness > xattr_exp &&
+	test_cmp xattr_exp xattr_out
+'
+
+test_expect_success XATTR "ipfs add without --xattrs ignores them" '
+	PLAIN_HASH=$(ipfs add -q xattr_file) &&
+	test "$PLAIN_HASH" != "$XATTR_HASH"
+'
+
+test_expect_success "create a sparse file" '
+	truncate -s 1048576 sparse_file &&
+	echo "after the hole" >> sparse_file
+'
+
+if test "$(du -k sparse_file | cut -f1)" -lt 1024; then
+	test_set_prereq SPARSE
+fi
+
+test_expect_success SPARSE "ipfs add --sparse stores holes" '
+	SPARSE_HASH=$(ipfs add --sparse -q sparse_file) &&
+	ipfs files cp /ipfs/$SPARSE_HASH /sparse_file &&
+	ipfs files stat --holes --format="<holes>" /sparse_file > holes_out &&
+	echo 1048576 > holes_exp &&
+	test_cmp holes_exp holes_out
+'
+
+test_expect_success SPARSE "sparse files read back unchanged" '
+	ipfs cat $SPARSE_HASH > sparse_out &&
+	test_cmp sparse_file sparse_out
+'
+
+test_expect_success "ipfs add without --sparse stores the zeros" '
+	PLAIN_HASH=$(ipfs add -q sparse_file) &&
+	ipfs files cp /ipfs/$PLAIN_HASH /plain_sparse_file &&
+	ipfs files stat --holes --format="<holes>" /plain_sparse_file > holes_out &&
+	echo 0 > holes_exp &&
+	test_cmp holes_exp holes_out
+'
+
 # Test daemon in offline mode
 test_launch_ipfs_daemon --offline
 
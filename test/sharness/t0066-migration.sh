@@ -13,7 +13,7 @@ test_init_ipfs
 test_expect_success "setup mock migrations" '
 	mkdir bin &&
 	echo "#!/bin/bash" > bin/fs-repo-migrations &&
-	echo "echo 4" >> bin/fs-repo-migrations &&
+	echo "echo 5" >> bin/fs-repo-migrations &&
 	chmod +x bin/fs-repo-migrations &&
 	export PATH="$(pwd)/bin":$PATH
 '
@@ -39,14 +39,178 @@ test_pins() {
 	'
 }
 
+test_named_pins() {
+	test_expect_success "pin a hash under two names" '
+		HASH_N=$(echo "named" | ipfs add -q --pin=false) &&
+		ipfs pin add --name=photos --owner=backup $HASH_N &&
+		ipfs pin add --name=gallery $HASH_N
+	'
+
+	test_expect_success "'ipfs pin ls --name' lists the named pin" '
+		echo "$HASH_N recursive photos backup" >expected_named &&
+		ipfs pin ls --name=photos >actual_named &&
+		test_cmp expected_named actual_named
+	'
+
+	test_expect_success "'ipfs pin ls --owner' lists the pins of the owner" '
+		ipfs pin ls --owner=backup >actual_owner &&
+		test_cmp expected_named actual_owner
+	'
+
+	test_expect_success "'ipfs pin add --owner' requires --name" '
+		test_must_fail ipfs pin add --owner=backup $HASH_N
+	'
+
+	test_expect_success "'ipfs pin rm' fails on a named pin" '
+		test_must_fail ipfs pin rm $HASH_N 2>rm_err &&
+		grep "only pinned by name" rm_err
+	'
+
+	test_expect_success "removing one named pin keeps the hash pinned" '
+		ipfs pin rm --name=photos --owner=backup $HASH_N &&
+		ipfs pin ls --type=recursive >recursive_pins &&
+		grep $HASH_N recursive_pins
+	'
+
+	test_expect_success "removing the last named pin unpins the hash" '
+		ipfs pin rm --name=gallery $HASH_N &&
+		test_must_fail ipfs pin ls $HASH_N
+	'
+}
+
+test_expiring_pins() {
+	test_expect_success "pin a hash for 72 hours" '
+		HASH_X=$(echo "expiring" | ipfs add -q --pin=false) &&
+		ipfs pin add --expires-in=72h $HASH_X
+	'
+
+	test_expect_success "'ipfs pin ls' shows the remaining time" '
+		ipfs pin ls --type=recursive >expiring_pins &&
+		grep "$HASH_X recursive expires in 7[12]h" expiring_pins
+	'
+
+	test_expect_success "'ipfs pin add --expires-in' rejects bad durations" '
+		test_must_fail ipfs pin add --expires-in=soon $HASH_X &&
+		test_must_fail ipfs pin add --expires-in=-1h $HASH_X
+	'
+
+	test_expect_success "named pins can not expire" '
+		test_must_fail ipfs pin add --name=photos --expires-in=1h $HASH_X
+	'
+
+	test_expect_success "pin a hash for a second" '
+		HASH_Y=$(echo "expired" | ipfs add -q --pin=false) &&
+		ipfs pin add --expires-in=1s $HASH_Y &&
+		go-sleep 2s
+	'
+
+	test_expect_success "expired pins are not listed" '
+		ipfs pin ls --type=recursive >expired_pins &&
+		test_must_fail grep $HASH_Y expired_pins
+	'
+
+	test_expect_success "gc removes the content of expired pins" '
+		ipfs repo gc &&
+		ipfs refs local >local_refs &&
+		test_must_fail grep $HASH_Y local_refs &&
+		grep $HASH_X local_refs
+	'
+}
+
+test_pin_update() {
+	test_expect_success "create two versions of a directory" '
+		mkdir -p site &&
+		echo "shared" >site/shared &&
+		echo "v1" >site/index &&
+		HASH_V1=$(ipfs add -r -q site | tail -n1) &&
+		echo "v2" >site/index &&
+		HASH_V2=$(ipfs add -r -q --pin=false site | tail -n1)
+	'
+
+	test_expect_success "'ipfs pin update --unpin=false' keeps both pins" '
+		ipfs pin update --unpin=false $HASH_V1 $HASH_V2 &&
+		ipfs pin ls --type=recursive >update_pins &&
+		grep $HASH_V1 update_pins &&
+		grep $HASH_V2 update_pins
+	'
+
+	test_expect_success "'ipfs pin update' moves the pin" '
+		ipfs pin rm $HASH_V2 &&
+		echo "updated $HASH_V1 to $HASH_V2" >expected_update &&
+		ipfs pin update $HASH_V1 $HASH_V2 >actual_update &&
+		test_cmp expected_update actual_update &&
+		ipfs pin ls --type=recursive >update_pins &&
+		test_must_fail grep $HASH_V1 update_pins &&
+		grep $HASH_V2 update_pins
+	'
+
+	test_expect_success "'ipfs pin update' fails if the old root is not pinned" '
+		test_must_fail ipfs pin update $HASH_V1 $HASH_V2
+	'
+
+	test_expect_success "clean up the updated pin" '
+		ipfs pin rm $HASH_V2
+	'
+}
+
+test_pin_progress() {
+	test_expect_success "'ipfs pin add --progress' reports the fetched nodes" '
+		random 1000000 42 >bigfile &&
+		HASH_P=$(ipfs add -q --pin=false bigfile) &&
+		ipfs pin add --progress $HASH_P >progress_out &&
+		grep "Fetched [0-9]* nodes" progress_out &&
+		grep "pinned $HASH_P recursively" progress_out
+	'
+
+	test_expect_success "'ipfs pin add --progress' pinned the file" '
+		ipfs pin ls --type=recursive >progress_pins &&
+		grep $HASH_P progress_pins &&
+		ipfs pin rm $HASH_P
+	'
+}
+
 test_init_ipfs
 
 test_pins
 
+test_named_pins
+
+test_expiring_pins
+
+test_pin_update
+
+test_pin_progress
+
+test_expect_success "make the daemon sweep expired pins and gc" '
+	test_config_set Datastore.PinSweepPeriod "100ms" &&
+	test_config_set --json Datastore.PinSweepGC true
+'
+
 test_launch_ipfs_daemon --offline
 
 test_pins
 
+test_named_pins
+
+test_expiring_pins
+
+test_pin_update
+
+test_pin_progress
+
+test_expect_success "the daemon removes expired pins" '
+	HASH_Z=$(echo "swept" | ipfs add -q --pin=false) &&
+	ipfs pin add --expires-in=1s $HASH_Z &&
+	go-sleep 2s &&
+	test_must_fail ipfs pin rm $HASH_Z 2>sweep_err &&
+	grep "not pinned" sweep_err
+'
+
+test_expect_success "the daemon runs gc after removing expired pins" '
+	ipfs refs local >swept_refs &&
+	test_must_fail grep $HASH_Z swept_refs
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -0,0 +1,73 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs pin verify"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+H_BLOCK1=$(echo "Block 1" | ipfs add -q --pin=false)
+H_BLOCK2=$(echo "Block 2" | ipfs add -q --pin=false)
+
+BS_BLOCK1="CIQPD/CIQPDDQH5PDJTF4QSNMPFC45FQZH5MBSWCX2W254P7L7HGNHW5MQXZA.data"
+BS_BLOCK2="CIQNY/CIQNYWBOKHY7TCY7FUOBXKVJ66YRMARDT3KC7PPY6UWWPZR4YA67CKQ.data"
+
+test_expect_success "pin a directory of both blocks" '
+	mkdir dir &&
+	echo "Block 1" >dir/one &&
+	echo "Block 2" >dir/two &&
+	HASH_DIR=$(ipfs add -r -q dir | tail -n1)
+'
+
+test_verify() {
+	test_expect_success "'ipfs pin verify' is quiet when pins are complete" '
+		ipfs pin verify >verify_out &&
+		test_must_be_empty verify_out
+	'
+
+	test_expect_success "'ipfs pin verify --verbose' lists complete pins" '
+		ipfs pin verify --verbose >verify_out &&
+		grep "$HASH_DIR ok" verify_out
+	'
+
+	test_expect_success "corrupt a pinned block" '
+		cp -f "$IPFS_PATH/blocks/$BS_BLOCK1" "$IPFS_PATH/blocks/$BS_BLOCK2"
+	'
+
+	test_expect_success "'ipfs pin verify' lists the corrupt block" '
+		ipfs pin verify >verify_out &&
+		grep "$HASH_DIR broken" verify_out &&
+		grep "  $H_BLOCK2 block in storage has different hash than requested" verify_out
+	'
+
+	test_expect_success "'ipfs pin verify --repair' fails to fetch the block offline" '
+		ipfs pin verify --repair >verify_out &&
+		grep "$HASH_DIR broken" verify_out &&
+		grep "  $H_BLOCK2" verify_out
+	'
+
+	test_expect_success "'ipfs pin verify -q' writes the broken pins" '
+		echo $HASH_DIR >expected_quiet &&
+		ipfs pin verify -q >actual_quiet &&
+		test_cmp expected_quiet actual_quiet
+	'
+
+	test_expect_success "adding the block again completes the pin" '
+		echo "Block 2" | ipfs add -q --pin=false &&
+		ipfs pin verify >verify_out &&
+		test_must_be_empty verify_out
+	'
+}
+
+test_verify
+
+test_launch_ipfs_daemon --offline
+
+test_verify
+
+test_kill_ipfs_daemon
+
+test_done
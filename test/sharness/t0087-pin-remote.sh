@@ -0,0 +1,94 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs pin remote with a node serving the pin service"
+
+. lib/test-lib.sh
+
+PINSVC_PORT=5087
+PINSVC="http://127.0.0.1:$PINSVC_PORT/pinservice/v0"
+
+test_expect_success "set up an iptb cluster" '
+	iptb init -n 2 -p 0 -f --bootstrap=none
+'
+
+test_expect_success "node 1 serves the pin service" '
+	ipfsi 1 config Addresses.Gateway /ip4/127.0.0.1/tcp/$PINSVC_PORT &&
+	ipfsi 1 config --json Pinning.Service "{\"Enabled\":true,\"Tokens\":{\"secret\":\"node0\"}}"
+'
+
+test_expect_success "node 0 uses it" '
+	ipfsi 0 config --json Pinning.RemoteServices "{
+		\"node1\": {\"Endpoint\":\"$PINSVC\",\"Token\":\"secret\"},
+		\"badtoken\": {\"Endpoint\":\"$PINSVC\",\"Token\":\"wrong\"}
+	}"
+'
+
+startup_cluster 2
+
+test_expect_success "add a directory on node 0" '
+	mkdir dir &&
+	echo "remote pins are fun" > dir/a &&
+	echo "and persistent" > dir/b &&
+	HASH=$(ipfsi 0 add -r -q dir | tail -n1)
+'
+
+test_expect_success "'ipfs pin remote add --wait' succeeds" '
+	ipfsi 0 pin remote add --service=node1 --name=mydir --wait $HASH >actual &&
+	echo "$HASH pinned mydir" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "node 1 pinned it for node 0" '
+	ipfsi 1 pin ls --owner=pinservice:node0 >actual &&
+	echo "$HASH recursive mydir pinservice:node0" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "node 1 has the whole directory" '
+	ipfsi 1 cat --offline $HASH/a >actual &&
+	test_cmp dir/a actual
+'
+
+test_expect_success "'ipfs pin remote ls' lists the pin" '
+	ipfsi 0 pin remote ls --service=node1 >actual &&
+	echo "$HASH pinned mydir" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "adding the name again with another object fails" '
+	HASH_A=$(ipfsi 0 add -q dir/a) &&
+	test_must_fail ipfsi 0 pin remote add --service=node1 --name=mydir $HASH_A 2>err &&
+	grep "409" err
+'
+
+test_expect_success "'ipfs pin remote' fails with a bad token" '
+	test_must_fail ipfsi 0 pin remote ls --service=badtoken 2>err &&
+	grep "401" err
+'
+
+test_expect_success "'ipfs pin remote' fails with an unknown service" '
+	test_must_fail ipfsi 0 pin remote ls --service=nothere 2>err &&
+	grep "no pin service named nothere" err
+'
+
+test_expect_success "'ipfs pin remote rm' succeeds" '
+	ipfsi 0 pin remote rm --service=node1 mydir >actual &&
+	echo "removed mydir" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "node 1 unpinned it" '
+	ipfsi 1 pin ls --owner=pinservice:node0 >actual &&
+	test_must_be_empty actual &&
+	ipfsi 0 pin remote ls --service=node1 >actual &&
+	test_must_be_empty actual
+'
+
+test_expect_success "shut down iptb" '
+	iptb stop
+'
+
+test_done
@@ -48,6 +48,13 @@ test_expect_success "GET IPFS directory file output looks good" '
   test_cmp dir/test actual
 '
 
+test_expect_success "GET file added with --detect-mime uses stored type" '
+  echo "<html><body>hi</body></html>" >page &&
+  MIME_HASH=$(ipfs add -q --detect-mime page) &&
+  curl -si "http://127.0.0.1:$port/ipfs/$MIME_HASH" >mime_actual &&
+  grep -i "^Content-Type: text/html; charset=utf-8" mime_actual
+'
+
 test_expect_success "GET IPFS non existent file returns code expected (404)" '
   test_curl_resp_http_code "http://127.0.0.1:$port/ipfs/$HASH2/pleaseDontAddMe" "HTTP/1.1 404 Not Found"
 '
@@ -28,7 +28,12 @@ bitswap status
 	provides buffer: 0 / 256
 	blocks received: 0
 	dup blocks received: 0
+	data received: 0 B
 	dup data received: 0 B
+	haves received: 0
+	dont haves received: 0
+	rate in: 0 B/s
+	rate out: 0 B/s
 	wantlist [1 keys]
 		$NONEXIST
 	partners [0]
@@ -68,7 +73,12 @@ bitswap status
 	provides buffer: 0 / 256
 	blocks received: 0
 	dup blocks received: 0
+	data received: 0 B
 	dup data received: 0 B
+	haves received: 0
+	dont haves received: 0
+	rate in: 0 B/s
+	rate out: 0 B/s
 	wantlist [0 keys]
 	partners [0]
 EOF
@@ -83,6 +93,97 @@ test_expect_success "'ipfs bitswap wantlist -p' output looks good" '
 	test_cmp wantlist_out wantlist_p_out
 '
 
+test_expect_success "'ipfs bitswap limit' works" '
+	ipfs bitswap limit >limit_out
+'
+
+test_expect_success "'ipfs bitswap limit' output looks good" '
+	cat >unlimited <<EOF &&
+out: unlimited
+in: unlimited
+peer out: unlimited
+peer in: unlimited
+EOF
+	test_cmp unlimited limit_out
+'
+
+test_expect_success "'ipfs bitswap limit' changes limits" '
+	ipfs bitswap limit --out=1MB --peer-in=100kB/s >limit_out &&
+	cat >expected <<EOF &&
+out: 1.0 MB/s
+in: unlimited
+peer out: unlimited
+peer in: 100 kB/s
+EOF
+	test_cmp expected limit_out &&
+	ipfs bitswap limit >limit_out &&
+	test_cmp expected limit_out
+'
+
+test_expect_success "'ipfs bitswap limit' removes limits" '
+	ipfs bitswap limit --out=unlimited --peer-in=0 >limit_out &&
+	test_cmp unlimited limit_out
+'
+
+test_expect_success "'ipfs bitswap limit' fails on invalid rates" '
+	test_must_fail ipfs bitswap limit --in=fast 2>limit_err &&
+	grep "invalid rate" limit_err
+'
+
+test_expect_success "'ipfs bitswap ledger' works" '
+	ipfs bitswap ledger "$PEERID" >ledger_out
+'
+
+test_expect_success "'ipfs bitswap ledger' output looks good" '
+	cat >expected <<EOF &&
+Ledger for $PEERID
+Debt ratio:	0.000000
+Exchanges:	0
+Bytes sent:	0
+Bytes received:	0
+Blocks sent:	0
+Blocks received:	0
+Wantlist size:	0
+EOF
+	test_cmp expected ledger_out
+'
+
+test_expect_success "'ipfs bitswap ledger --all' shows no ledgers" '
+	ipfs bitswap ledger --all >ledger_all_out &&
+	test_must_be_empty ledger_all_out
+'
+
+test_expect_success "'ipfs bitswap ledger' needs a peer or --all" '
+	test_must_fail ipfs bitswap ledger &&
+	test_must_fail ipfs bitswap ledger --all "$PEERID"
+'
+
+test_expect_success "'ipfs bitswap reputation' shows no misbehaving peers" '
+	ipfs bitswap reputation >reputation_out &&
+	test_must_be_empty reputation_out
+'
+
+test_expect_success "'ipfs bitswap reputation' works" '
+	ipfs bitswap reputation "$PEERID" >reputation_out
+'
+
+test_expect_success "'ipfs bitswap reputation' output looks good" '
+	cat >expected <<EOF &&
+Reputation of $PEERID
+Score:	0.00
+Unwanted blocks:	0
+Malformed messages:	0
+Want floods:	0
+EOF
+	test_cmp expected reputation_out
+'
+
+test_expect_success "'ipfs bitswap reputation --unban' fails on peers not banned" '
+	test_must_fail ipfs bitswap reputation --unban &&
+	test_must_fail ipfs bitswap reputation --unban "$PEERID" 2>unban_err &&
+	grep "is not banned" unban_err
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -413,6 +413,46 @@ test_files_api() {
 		ipfs files rm /cats
 	'
 
+	# test inserting and cutting
+	test_expect_success "create a new file" '
+		echo "ipfs cool" | ipfs files write --create /cats
+	'
+
+	test_expect_success "can insert into file" '
+		printf "is super " | ipfs files write --insert -o 5 /cats
+	'
+
+	test_expect_success "output looks good" '
+		ipfs files read /cats > file_out &&
+		echo "ipfs is super cool" > file_exp &&
+		test_cmp file_exp file_out
+	'
+
+	test_expect_success "can cut range out of file" '
+		ipfs files cut -o 8 -n 6 /cats
+	'
+
+	test_expect_success "output looks good" '
+		ipfs files read /cats > file_out &&
+		echo "ipfs is cool" > file_exp &&
+		test_cmp file_exp file_out
+	'
+
+	test_expect_success "cut without count removes the rest of the file" '
+		ipfs files cut -o 4 /cats &&
+		ipfs files read /cats > file_out &&
+		printf "ipfs" > file_exp &&
+		test_cmp file_exp file_out
+	'
+
+	test_expect_success "cannot cut past end of file" '
+		test_expect_code 1 ipfs files cut -o 50 /cats
+	'
+
+	test_expect_success "cleanup" '
+		ipfs files rm /cats
+	'
+
 	# test flush flags
 	test_expect_success "mkdir --flush works" '
 		ipfs files mkdir --flush --parents /flushed/deep
@@ -478,6 +518,87 @@ test_files_api() {
 		ipfs files rm -r /foobar &&
 		ipfs files rm -r /adir
 	'
+
+	# test extended attributes
+	test_expect_success "can set xattrs on a file and a directory" '
+		ipfs files mkdir /xdir &&
+		echo "hello" | ipfs files write --create /xdir/xfile &&
+		ipfs files setxattr /xdir/xfile user.a foo &&
+		ipfs files setxattr /xdir/xfile user.b bar &&
+		ipfs files setxattr /xdir user.c baz
+	'
+
+	test_expect_success "can read back xattrs" '
+		ipfs files getxattr /xdir/xfile user.a > xattr_out &&
+		printf foo > xattr_exp &&
+		test_cmp xattr_exp xattr_out &&
+		ipfs files getxattr /xdir user.c > xattr_out &&
+		printf baz > xattr_exp &&
+		test_cmp xattr_exp xattr_out
+	'
+
+	test_expect_success "can list xattrs" '
+		ipfs files listxattr /xdir/xfile > xattr_out &&
+		printf "user.a\nuser.b\n" > xattr_exp &&
+		test_cmp xattr_exp xattr_out
+	'
+
+	test_expect_success "xattrs survive writes to the file" '
+		echo "more" | ipfs files write -o 6 /xdir/xfile &&
+		ipfs files getxattr /xdir/xfile user.b > xattr_out &&
+		printf bar > xattr_exp &&
+		test_cmp xattr_exp xattr_out
+	'
+
+	test_expect_success "can remove xattrs" '
+		ipfs files rmxattr /xdir/xfile user.a &&
+		ipfs files listxattr /xdir/xfile > xattr_out &&
+		echo user.b > xattr_exp &&
+		test_cmp xattr_exp xattr_out
+	'
+
+	test_expect_success "getting a missing xattr fails" '
+		test_expect_code 1 ipfs files getxattr /xdir/xfile user.a
+	'
+
+	test_expect_success "clean up xattr dir" '
+		ipfs files rm -r /xdir
+	'
+
+	test_expect_success "writing past the end of a file leaves a hole" '
+		echo "after the hole" | ipfs files write --create --offset 1000000 /sparse &&
+		ipfs files stat --holes --format="<size> <holes>" /sparse > holes_out &&
+		echo "1000015 1000000" > holes_exp &&
+		test_cmp holes_exp holes_out
+	'
+
+	test_expect_success "holes read back as zeros" '
+		ipfs files read --count 4 /sparse | od -An -tx1 > holes_out &&
+		echo " 00 00 00 00" > holes_exp &&
+		test_cmp holes_exp holes_out &&
+		ipfs files read --offset 1000000 /sparse > holes_out &&
+		echo "after the hole" > holes_exp &&
+		test_cmp holes_exp holes_out
+	'
+
+	test_expect_success "writing into a hole shrinks it" '
+		echo "middle" | ipfs files write --offset 500 /sparse &&
+		ipfs files stat --holes --format="<holes>" /sparse > holes_out &&
+		echo 999993 > holes_exp &&
+		test_cmp holes_exp holes_out &&
+		ipfs files read --offset 500 --count 7 /sparse > holes_out &&
+		echo "middle" > holes_exp &&
+		test_cmp holes_exp holes_out
+	'
+
+	test_expect_success "stat shows holes when asked" '
+		ipfs files stat --holes /sparse > holes_out &&
+		grep "Holes: 999993" holes_out
+	'
+
+	test_expect_success "clean up sparse file" '
+		ipfs files rm /sparse
+	'
 }
 
 # test offline and online
@@ -5,6 +5,7 @@ package unixfs
 
 import (
 	"errors"
+	"sort"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	pb "github.com/ipfs/go-ipfs/unixfs/pb"
@@ -22,6 +23,7 @@ const (
 var ErrMalformedFileFormat = errors.New("malformed data in file format")
 var ErrInvalidDirLocation = errors.New("found directory node in unexpected place")
 var ErrUnrecognizedType = errors.New("unrecognized node type")
+var ErrNoXattr = errors.New("no such extended attribute")
 
 func FromBytes(data []byte) (*pb.Data, error) {
 	pbdata := new(pb.Data)
@@ -51,6 +53,18 @@ func FilePBData(data []byte, totalsize uint64) []byte {
 	return data
 }
 
+// HolePBData returns the data for a hole of 'size' bytes. A hole is a file
+// node with neither data nor children, whose content reads as zeros.
+func HolePBData(size uint64) []byte {
+	return FilePBData(nil, size)
+}
+
+// IsHole returns whether the given unixfs data describes a hole
+func IsHole(pbn *pb.Data) bool {
+	return pbn.GetType() == pb.Data_File && len(pbn.Data) == 0 &&
+		len(pbn.Blocksizes) == 0 && pbn.GetFilesize() > 0
+}
+
 // Returns Bytes that represent a Directory
 func FolderPBData() []byte {
 	pbfile := new(pb.Data)
@@ -114,7 +128,7 @@ func DataSize(data []byte) (uint64, error) {
 	switch pbdata.GetType() {
 	case pb.Data_Directory:
 		return 0, errors.New("Cant get data size of directory!")
-	case pb.Data_File:
+	case pb.Data_File, pb.Data_Metadata:
 		return pbdata.GetFilesize(), nil
 	case pb.Data_Raw:
 		return uint64(len(pbdata.GetData())), nil
@@ -134,6 +148,9 @@ type FSNode struct {
 
 	// node type of this node
 	Type pb.Data_DataType
+
+	// extended attributes of this node
+	Xattrs map[string][]byte
 }
 
 func FSNodeFromBytes(b []byte) (*FSNode, error) {
@@ -148,9 +165,20 @@ func FSNodeFromBytes(b []byte) (*FSNode, error) {
 	n.blocksizes = pbn.Blocksizes
 	n.subtotal = pbn.GetFilesize() - uint64(len(n.Data))
 	n.Type = pbn.GetType()
+	n.Xattrs = xattrsFromPB(pbn)
 	return n, nil
 }
 
+// NewHoleFSNode returns an FSNode for a hole of 'size' bytes
+func NewHoleFSNode(size uint64) *FSNode {
+	return &FSNode{Type: TFile, subtotal: size}
+}
+
+// IsHole returns whether this node is a hole
+func (n *FSNode) IsHole() bool {
+	return n.Type == TFile && len(n.Data) == 0 && len(n.blocksizes) == 0 && n.subtotal > 0
+}
+
 // AddBlockSize adds the size of the next child block of this node
 func (n *FSNode) AddBlockSize(s uint64) {
 	n.subtotal += s
@@ -168,6 +196,7 @@ func (n *FSNode) GetBytes() ([]byte, error) {
 	pbn.Filesize = proto.Uint64(uint64(len(n.Data)) + n.subtotal)
 	pbn.Blocksizes = n.blocksizes
 	pbn.Data = n.Data
+	pbn.Xattrs = xattrsToPB(n.Xattrs)
 	return proto.Marshal(pbn)
 }
 
@@ -179,9 +208,130 @@ func (n *FSNode) NumChildren() int {
 	return len(n.blocksizes)
 }
 
+// Xattrs returns the extended attributes stored in the given unixfs data
+func Xattrs(data []byte) (map[string][]byte, error) {
+	pbn, err := FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return xattrsFromPB(pbn), nil
+}
+
+// SetXattr returns a copy of the given unixfs data with the extended attribute
+// 'name' set to 'value'
+func SetXattr(data []byte, name string, value []byte) ([]byte, error) {
+	return updateXattrs(data, func(xattrs map[string][]byte) error {
+		xattrs[name] = value
+		return nil
+	})
+}
+
+// RemoveXattr returns a copy of the given unixfs data without the extended
+// attribute 'name'
+func RemoveXattr(data []byte, name string) ([]byte, error) {
+	return updateXattrs(data, func(xattrs map[string][]byte) error {
+		if _, ok := xattrs[name]; !ok {
+			return ErrNoXattr
+		}
+		delete(xattrs, name)
+		return nil
+	})
+}
+
+// CopyXattrs returns a copy of the unixfs data 'to' carrying the extended
+// attributes of 'from'
+func CopyXattrs(from, to []byte) ([]byte, error) {
+	xattrs, err := Xattrs(from)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(xattrs) == 0 {
+		cur, err := Xattrs(to)
+		if err != nil {
+			return nil, err
+		}
+
+		// nothing to change, avoid reencoding
+		if len(cur) == 0 {
+			return to, nil
+		}
+	}
+
+	return updateXattrs(to, func(m map[string][]byte) error {
+		for k := range m {
+			delete(m, k)
+		}
+		for k, v := range xattrs {
+			m[k] = v
+		}
+		return nil
+	})
+}
+
+func updateXattrs(data []byte, f func(map[string][]byte) error) ([]byte, error) {
+	pbn, err := FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := xattrsFromPB(pbn)
+	if xattrs == nil {
+		xattrs = make(map[string][]byte)
+	}
+
+	err = f(xattrs)
+	if err != nil {
+		return nil, err
+	}
+
+	pbn.Xattrs = xattrsToPB(xattrs)
+	return proto.Marshal(pbn)
+}
+
+func xattrsFromPB(pbn *pb.Data) map[string][]byte {
+	if len(pbn.GetXattrs()) == 0 {
+		return nil
+	}
+
+	out := make(map[string][]byte)
+	for _, x := range pbn.GetXattrs() {
+		out[x.GetName()] = x.GetValue()
+	}
+	return out
+}
+
+// xattrsToPB serializes the attributes sorted by name so that equal sets of
+// attributes always hash the same
+func xattrsToPB(xattrs map[string][]byte) []*pb.XAttr {
+	if len(xattrs) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(xattrs))
+	for name := range xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]*pb.XAttr, len(names))
+	for i, name := range names {
+		out[i] = &pb.XAttr{
+			Name:  proto.String(name),
+			Value: xattrs[name],
+		}
+	}
+	return out
+}
+
 type Metadata struct {
 	MimeType string
 	Size     uint64
+
+	// URL the content was added from, and the entity tag the server gave it
+	SourceURL string
+	ETag      string
 }
 
 func MetadataFromBytes(b []byte) (*Metadata, error) {
@@ -201,12 +351,21 @@ func MetadataFromBytes(b []byte) (*Metadata, error) {
 	}
 	md := new(Metadata)
 	md.MimeType = pbm.GetMimeType()
+	md.SourceURL = pbm.GetSourceURL()
+	md.ETag = pbm.GetETag()
+	md.Size = pbd.GetFilesize()
 	return md, nil
 }
 
 func (m *Metadata) Bytes() ([]byte, error) {
 	pbm := new(pb.Metadata)
 	pbm.MimeType = &m.MimeType
+	if m.SourceURL != "" {
+		pbm.SourceURL = proto.String(m.SourceURL)
+	}
+	if m.ETag != "" {
+		pbm.ETag = proto.String(m.ETag)
+	}
 	return proto.Marshal(pbm)
 }
 
@@ -37,3 +37,70 @@ func TestFSNode(t *testing.T) {
 		t.Fatal("Datasize calculations incorrect!")
 	}
 }
+
+func TestXattrs(t *testing.T) {
+	data := FilePBData([]byte("hello"), 5)
+
+	data, err := SetXattr(data, "user.sha1", []byte("abcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = SetXattr(data, "user.origin", []byte("archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xattrs, err := Xattrs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(xattrs) != 2 || string(xattrs["user.sha1"]) != "abcd" || string(xattrs["user.origin"]) != "archive" {
+		t.Fatalf("got wrong xattrs: %v", xattrs)
+	}
+
+	// the rest of the node must be left alone
+	fsn, err := FSNodeFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fsn.Type != TFile || string(fsn.Data) != "hello" || fsn.FileSize() != 5 {
+		t.Fatal("node data was changed by setting xattrs")
+	}
+
+	// attributes must survive a round trip through FSNode
+	rt, err := fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rt) != string(data) {
+		t.Fatal("FSNode round trip lost xattrs")
+	}
+
+	data, err = RemoveXattr(data, "user.sha1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = RemoveXattr(data, "user.sha1")
+	if err != ErrNoXattr {
+		t.Fatal("expected ErrNoXattr removing missing attribute")
+	}
+
+	nd, err := CopyXattrs(data, FilePBData(nil, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xattrs, err = Xattrs(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(xattrs) != 1 || string(xattrs["user.origin"]) != "archive" {
+		t.Fatalf("got wrong xattrs after copy: %v", xattrs)
+	}
+}
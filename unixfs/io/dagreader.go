@@ -10,6 +10,7 @@ import (
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 	"gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 
+	exchange "github.com/ipfs/go-ipfs/exchange"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
@@ -47,6 +48,9 @@ type DagReader struct {
 
 	// context cancel for children
 	cancel func()
+
+	// mime type recorded in a wrapping metadata node, if any
+	mimeType string
 }
 
 type ReadSeekCloser interface {
@@ -76,11 +80,20 @@ func NewDagReader(ctx context.Context, n *mdag.Node, serv mdag.DAGService) (*Dag
 		if len(n.Links) == 0 {
 			return nil, errors.New("incorrectly formatted metadata object")
 		}
+		md, err := ft.MetadataFromBytes(n.Data())
+		if err != nil {
+			return nil, err
+		}
 		child, err := n.Links[0].GetNode(ctx, serv)
 		if err != nil {
 			return nil, err
 		}
-		return NewDagReader(ctx, child, serv)
+		dr, err := NewDagReader(ctx, child, serv)
+		if err != nil {
+			return nil, err
+		}
+		dr.mimeType = md.MimeType
+		return dr, nil
 	case ftpb.Data_Symlink:
 		return nil, ErrCantReadSymlinks
 	default:
@@ -90,11 +103,21 @@ func NewDagReader(ctx context.Context, n *mdag.Node, serv mdag.DAGService) (*Dag
 
 func NewDataFileReader(ctx context.Context, n *mdag.Node, pb *ftpb.Data, serv mdag.DAGService) *DagReader {
 	fctx, cancel := context.WithCancel(ctx)
+	// the blocks of a file are likely found at the same peers
+	fctx = exchange.WithSession(fctx)
 	promises := mdag.GetDAG(fctx, serv, n)
+
+	var buf ReadSeekCloser
+	if ft.IsHole(pb) {
+		buf = newHoleReader(int64(pb.GetFilesize()))
+	} else {
+		buf = NewRSNCFromBytes(pb.GetData())
+	}
+
 	return &DagReader{
 		node:     n,
 		serv:     serv,
-		buf:      NewRSNCFromBytes(pb.GetData()),
+		buf:      buf,
 		promises: promises,
 		ctx:      fctx,
 		cancel:   cancel,
@@ -146,6 +169,12 @@ func (dr *DagReader) Size() uint64 {
 	return dr.pbdata.GetFilesize()
 }
 
+// MimeType returns the mime type stored in the metadata node wrapping this
+// file, or the empty string if there was none.
+func (dr *DagReader) MimeType() string {
+	return dr.mimeType
+}
+
 // Read reads data from the DAG structured file
 func (dr *DagReader) Read(b []byte) (int, error) {
 	return dr.CtxReadFull(dr.ctx, b)
@@ -224,6 +253,18 @@ func (dr *DagReader) Seek(offset int64, whence int) (int64, error) {
 		// Grab cached protobuf object (solely to make code look cleaner)
 		pb := dr.pbdata
 
+		// holes have no children to skip through
+		if ft.IsHole(pb) {
+			dr.buf.Close()
+			dr.buf = newHoleReader(int64(pb.GetFilesize()))
+			n, err := dr.buf.Seek(offset, os.SEEK_SET)
+			if err != nil {
+				return -1, err
+			}
+			dr.offset = n
+			return n, nil
+		}
+
 		// left represents the number of bytes remaining to seek to (from beginning)
 		left := offset
 		if int64(len(pb.Data)) >= offset {
@@ -291,3 +332,67 @@ func NewRSNCFromBytes(b []byte) ReadSeekCloser {
 }
 
 func (r *readSeekNopCloser) Close() error { return nil }
+
+// holeReader reads the zeros of a hole
+type holeReader struct {
+	size int64
+	off  int64
+}
+
+func newHoleReader(size int64) ReadSeekCloser {
+	return &holeReader{size: size}
+}
+
+func (hr *holeReader) Read(b []byte) (int, error) {
+	if hr.off >= hr.size {
+		return 0, io.EOF
+	}
+
+	if left := hr.size - hr.off; int64(len(b)) > left {
+		b = b[:left]
+	}
+	for i := range b {
+		b[i] = 0
+	}
+	hr.off += int64(len(b))
+	return len(b), nil
+}
+
+func (hr *holeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+	case os.SEEK_CUR:
+		offset += hr.off
+	case os.SEEK_END:
+		offset += hr.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("Invalid offset")
+	}
+
+	hr.off = offset
+	return offset, nil
+}
+
+func (hr *holeReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	zeros := make([]byte, 32*1024)
+	for hr.off < hr.size {
+		chunk := zeros
+		if left := hr.size - hr.off; int64(len(chunk)) > left {
+			chunk = chunk[:left]
+		}
+
+		n, err := w.Write(chunk)
+		total += int64(n)
+		hr.off += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (hr *holeReader) Close() error { return nil }
@@ -0,0 +1,44 @@
+package io
+
+import (
+	"gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
+)
+
+// HoleSize returns the number of bytes of the file rooted at 'n' that are
+// stored as holes. Every block of the file has to be fetched to find them.
+func HoleSize(ctx context.Context, n *mdag.Node, serv mdag.DAGService) (uint64, error) {
+	pb, err := ft.FromBytes(n.Data())
+	if err != nil {
+		return 0, err
+	}
+
+	switch pb.GetType() {
+	case ftpb.Data_Directory:
+		return 0, ErrIsDir
+	case ftpb.Data_Symlink:
+		return 0, nil
+	}
+
+	if ft.IsHole(pb) {
+		return pb.GetFilesize(), nil
+	}
+
+	var total uint64
+	for _, lnk := range n.Links {
+		child, err := lnk.GetNode(ctx, serv)
+		if err != nil {
+			return 0, err
+		}
+
+		s, err := HoleSize(ctx, child, serv)
+		if err != nil {
+			return 0, err
+		}
+		total += s
+	}
+	return total, nil
+}
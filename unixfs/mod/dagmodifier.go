@@ -11,18 +11,21 @@ import (
 	context "gx/ipfs/QmZy2y8t9zQH2a1b8q2ZSLKp17ATuJoCNxxyMFG5qFExpt/go-net/context"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	bal "github.com/ipfs/go-ipfs/importer/balanced"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	help "github.com/ipfs/go-ipfs/importer/helpers"
 	trickle "github.com/ipfs/go-ipfs/importer/trickle"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
 	logging "gx/ipfs/QmNQynaz7qfriSUJkiEZUrm2Wen1u3Kj9goZzWtrPyu7XR/go-log"
 )
 
 var ErrSeekFail = errors.New("failed to seek properly")
 var ErrSeekEndNotImpl = errors.New("SEEK_END currently not implemented")
 var ErrUnrecognizedWhence = errors.New("unrecognized whence")
+var ErrInvalidRange = errors.New("invalid range for file")
 
 // 2MB
 var writebufferSize = 1 << 21
@@ -88,22 +91,10 @@ func (dm *DagModifier) WriteAt(b []byte, offset int64) (int, error) {
 	return dm.Write(b)
 }
 
-// A reader that just returns zeros
-type zeroReader struct{}
-
-func (zr zeroReader) Read(b []byte) (int, error) {
-	for i := range b {
-		b[i] = 0
-	}
-	return len(b), nil
-}
-
-// expandSparse grows the file with zero blocks of 4096
-// A small blocksize is chosen to aid in deduplication
+// expandSparse grows the file by a hole of the given size, so that no
+// zeros have to be stored
 func (dm *DagModifier) expandSparse(size int64) error {
-	r := io.LimitReader(zeroReader{}, size)
-	spl := chunk.NewSizeSplitter(r, 4096)
-	nnode, err := dm.appendData(dm.curNode, spl)
+	nnode, err := dm.appendData(dm.curNode, chunk.NewHoleSplitter(size))
 	if err != nil {
 		return err
 	}
@@ -214,6 +205,10 @@ func (dm *DagModifier) modifyDag(node *mdag.Node, offset uint64, data io.Reader)
 
 	// If we've reached a leaf node.
 	if len(node.Links) == 0 {
+		if ft.IsHole(f) {
+			return dm.writeHole(node, f, offset, data)
+		}
+
 		n, err := data.Read(f.Data[offset:])
 		if err != nil && err != io.EOF {
 			return "", false, err
@@ -278,6 +273,107 @@ func (dm *DagModifier) modifyDag(node *mdag.Node, offset uint64, data io.Reader)
 	return k, done, err
 }
 
+// writeHole writes the data in 'data' over the hole 'node' starting at
+// 'offset'. Only the written range is stored, the rest of the hole is kept
+// as smaller holes on either side of it.
+func (dm *DagModifier) writeHole(node *mdag.Node, pbn *ftpb.Data, offset uint64, data io.Reader) (key.Key, bool, error) {
+	size := pbn.GetFilesize()
+	if offset >= size {
+		k, err := node.Key()
+		return k, false, err
+	}
+
+	buf := new(bytes.Buffer)
+	n, err := io.CopyN(buf, data, int64(size-offset))
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+
+	if n == 0 {
+		k, err := node.Key()
+		return k, true, err
+	}
+
+	written, err := dm.buildSubtree(buf)
+	if err != nil {
+		return "", false, err
+	}
+
+	nnode, err := dm.holeAround(offset, written, size-offset-uint64(n))
+	if err != nil {
+		return "", false, err
+	}
+
+	k, err := dm.dagserv.Add(nnode)
+	if err != nil {
+		return "", false, err
+	}
+
+	return k, uint64(n) < size-offset, nil
+}
+
+// holeAround returns a file node made of a hole of 'before' bytes, the file
+// 'nd' and a hole of 'after' bytes.
+func (dm *DagModifier) holeAround(before uint64, nd *mdag.Node, after uint64) (*mdag.Node, error) {
+	nnode := new(mdag.Node)
+	ndata := &ft.FSNode{Type: ft.TFile}
+
+	addChild := func(child *mdag.Node) error {
+		_, err := dm.dagserv.Add(child)
+		if err != nil {
+			return err
+		}
+
+		childsize, err := ft.DataSize(child.Data())
+		if err != nil {
+			return err
+		}
+
+		ndata.AddBlockSize(childsize)
+		return nnode.AddNodeLinkClean("", child)
+	}
+
+	if before > 0 {
+		if err := addChild(mdag.NodeWithData(ft.HolePBData(before))); err != nil {
+			return nil, err
+		}
+	}
+	if nd != nil {
+		if err := addChild(nd); err != nil {
+			return nil, err
+		}
+	}
+	if after > 0 {
+		if err := addChild(mdag.NodeWithData(ft.HolePBData(after))); err != nil {
+			return nil, err
+		}
+	}
+
+	d, err := ndata.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	nnode.SetData(d)
+	return nnode, nil
+}
+
+// buildSubtree lays out everything read from r as a balanced file. It
+// returns nil if r is empty.
+func (dm *DagModifier) buildSubtree(r io.Reader) (*mdag.Node, error) {
+	dbp := &help.DagBuilderParams{
+		Dagserv:  dm.dagserv,
+		Maxlinks: help.DefaultLinksPerBlock,
+	}
+
+	db := dbp.New(dm.splitter(r))
+	if db.Done() {
+		return nil, nil
+	}
+
+	return bal.BalancedLayout(db)
+}
+
 // appendData appends the blocks from the given chan to the end of this dag
 func (dm *DagModifier) appendData(node *mdag.Node, spl chunk.Splitter) (*mdag.Node, error) {
 	dbp := &help.DagBuilderParams{
@@ -285,6 +381,26 @@ func (dm *DagModifier) appendData(node *mdag.Node, spl chunk.Splitter) (*mdag.No
 		Maxlinks: help.DefaultLinksPerBlock,
 	}
 
+	// a file that is a single hole has no children to append next to,
+	// turn the hole into the first child
+	pbn, err := ft.FromBytes(node.Data())
+	if err != nil {
+		return nil, err
+	}
+	if ft.IsHole(pbn) {
+		nnode, err := dm.holeAround(pbn.GetFilesize(), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := ft.CopyXattrs(node.Data(), nnode.Data())
+		if err != nil {
+			return nil, err
+		}
+		nnode.SetData(d)
+		node = nnode
+	}
+
 	return trickle.TrickleAppend(dm.ctx, node, dbp.New(spl))
 }
 
@@ -412,11 +528,19 @@ func (dm *DagModifier) Truncate(size int64) error {
 		return dm.expandSparse(int64(size) - realSize)
 	}
 
+	olddata := dm.curNode.Data()
 	nnode, err := dagTruncate(dm.ctx, dm.curNode, uint64(size), dm.dagserv)
 	if err != nil {
 		return err
 	}
 
+	// the root is rebuilt from scratch, carry its extended attributes over
+	d, err := ft.CopyXattrs(olddata, nnode.Data())
+	if err != nil {
+		return err
+	}
+	nnode.SetData(d)
+
 	_, err = dm.dagserv.Add(nnode)
 	if err != nil {
 		return err
@@ -435,6 +559,11 @@ func dagTruncate(ctx context.Context, nd *mdag.Node, size uint64, ds mdag.DAGSer
 			return nil, err
 		}
 
+		if ft.IsHole(pbn) {
+			nd.SetData(ft.HolePBData(size))
+			return nd, nil
+		}
+
 		nd.SetData(ft.WrapData(pbn.Data[:size]))
 		return nd, nil
 	}
@@ -497,3 +626,345 @@ func dagTruncate(ctx context.Context, nd *mdag.Node, size uint64, ds mdag.DAGSer
 
 	return nd, nil
 }
+
+// InsertAt inserts the given bytes into the file at 'offset', shifting all
+// data after it forwards.
+func (dm *DagModifier) InsertAt(b []byte, offset int64) (int, error) {
+	n, err := dm.InsertFrom(bytes.NewReader(b), offset)
+	return int(n), err
+}
+
+// InsertFrom inserts everything read from r into the file at 'offset'. Unlike
+// WriteAt, existing data is not overwritten, and only the subtree holding the
+// byte at 'offset' is rebuilt.
+func (dm *DagModifier) InsertFrom(r io.Reader, offset int64) (int64, error) {
+	if offset < 0 {
+		return 0, ErrInvalidRange
+	}
+
+	err := dm.Sync()
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := dm.Size()
+	if err != nil {
+		return 0, err
+	}
+
+	// Inserting at (or past) the end of the file is just an append
+	if offset >= size {
+		// If we have an active reader, kill it
+		if dm.read != nil {
+			dm.read = nil
+			dm.readCancel()
+		}
+
+		_, err := dm.Seek(offset, os.SEEK_SET)
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := io.Copy(dm, r)
+		if err != nil {
+			return n, err
+		}
+
+		return n, dm.Sync()
+	}
+
+	cr := &countReader{r: r}
+	nnode, err := dm.splice(dm.curNode, uint64(offset), 0, cr)
+	if err != nil {
+		return cr.n, err
+	}
+
+	return cr.n, dm.replaceRoot(nnode)
+}
+
+// RemoveRange removes 'length' bytes starting at 'offset' from the file,
+// shifting all data after the range backwards.
+func (dm *DagModifier) RemoveRange(offset, length int64) error {
+	if offset < 0 || length < 0 {
+		return ErrInvalidRange
+	}
+
+	err := dm.Sync()
+	if err != nil {
+		return err
+	}
+
+	size, err := dm.Size()
+	if err != nil {
+		return err
+	}
+
+	if offset > size {
+		return ErrInvalidRange
+	}
+
+	// Cutting off the tail of the file is just a truncate
+	if offset+length >= size {
+		return dm.Truncate(offset)
+	}
+
+	if length == 0 {
+		return nil
+	}
+
+	nnode, err := dm.splice(dm.curNode, uint64(offset), uint64(length), nil)
+	if err != nil {
+		return err
+	}
+
+	return dm.replaceRoot(nnode)
+}
+
+// replaceRoot stores the given node and makes it the new root of the file
+func (dm *DagModifier) replaceRoot(nd *mdag.Node) error {
+	// If we have an active reader, kill it
+	if dm.read != nil {
+		dm.read = nil
+		dm.readCancel()
+	}
+
+	if nd == nil {
+		nd = mdag.NodeWithData(ft.FilePBData(nil, 0))
+	}
+
+	// the root is rebuilt from scratch, carry its extended attributes over
+	d, err := ft.CopyXattrs(dm.curNode.Data(), nd.Data())
+	if err != nil {
+		return err
+	}
+	nd.SetData(d)
+
+	_, err = dm.dagserv.Add(nd)
+	if err != nil {
+		return err
+	}
+
+	dm.curNode = nd
+	return nil
+}
+
+// splice replaces 'dellen' bytes at 'offset' within the file rooted at 'nd'
+// with the contents of 'ins'. Only the children overlapping the edit are
+// rewritten, every other link is carried over untouched. A nil node is
+// returned when nothing is left of 'nd'.
+func (dm *DagModifier) splice(nd *mdag.Node, offset, dellen uint64, ins io.Reader) (*mdag.Node, error) {
+	pbn, err := ft.FromBytes(nd.Data())
+	if err != nil {
+		return nil, err
+	}
+
+	// If we've reached a leaf node.
+	if len(nd.Links) == 0 {
+		if ft.IsHole(pbn) {
+			return dm.spliceHole(pbn, offset, dellen, ins)
+		}
+		return dm.rechunkLeaf(pbn, offset, dellen, ins)
+	}
+
+	blocksizes := pbn.GetBlocksizes()
+	if len(blocksizes) != len(nd.Links) {
+		return nil, ft.ErrMalformedFileFormat
+	}
+
+	nnode := new(mdag.Node)
+	ndata := &ft.FSNode{Type: pbn.GetType()}
+	end := offset + dellen
+
+	var cur uint64
+	for i, bs := range blocksizes {
+		start := cur
+		cur += bs
+
+		var touched bool
+		if ins != nil {
+			// data is inserted into the child holding the byte at 'offset'
+			touched = offset >= start && offset < cur
+		} else {
+			touched = offset < cur && end > start
+		}
+
+		if !touched {
+			nnode.AddRawLink(nd.Links[i].Name, nd.Links[i])
+			ndata.AddBlockSize(bs)
+			continue
+		}
+
+		// child lies entirely within the removed range, drop it
+		if ins == nil && offset <= start && end >= cur {
+			continue
+		}
+
+		child, err := nd.Links[i].GetNode(dm.ctx, dm.dagserv)
+		if err != nil {
+			return nil, err
+		}
+
+		lo, hi := start, cur
+		if offset > lo {
+			lo = offset
+		}
+		if end < hi {
+			hi = end
+		}
+
+		nchild, err := dm.splice(child, lo-start, hi-lo, ins)
+		if err != nil {
+			return nil, err
+		}
+
+		// the inserted data has been consumed by this child
+		ins = nil
+
+		if nchild == nil {
+			continue
+		}
+
+		_, err = dm.dagserv.Add(nchild)
+		if err != nil {
+			return nil, err
+		}
+
+		childsize, err := ft.DataSize(nchild.Data())
+		if err != nil {
+			return nil, err
+		}
+
+		err = nnode.AddNodeLinkClean("", nchild)
+		if err != nil {
+			return nil, err
+		}
+		ndata.AddBlockSize(childsize)
+	}
+
+	if ndata.NumChildren() == 0 {
+		return nil, nil
+	}
+
+	d, err := ndata.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	nnode.SetData(d)
+	return nnode, nil
+}
+
+// spliceHole applies an edit to a hole. Removing from a hole shrinks it,
+// inserted data is placed between what is left of the hole on either side.
+func (dm *DagModifier) spliceHole(pbn *ftpb.Data, offset, dellen uint64, ins io.Reader) (*mdag.Node, error) {
+	size := pbn.GetFilesize()
+	if offset > size {
+		return nil, ft.ErrMalformedFileFormat
+	}
+	end := offset + dellen
+	if end > size {
+		end = size
+	}
+
+	var inserted *mdag.Node
+	if ins != nil {
+		var err error
+		inserted, err = dm.buildSubtree(ins)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if inserted == nil {
+		left := size - (end - offset)
+		if left == 0 {
+			return nil, nil
+		}
+		return mdag.NodeWithData(ft.HolePBData(left)), nil
+	}
+
+	return dm.holeAround(offset, inserted, size-end)
+}
+
+// rechunkLeaf applies an edit to the data of a single leaf and runs the result
+// back through a content defined splitter. Small edits come back as a single
+// block of the same type, larger insertions are laid out as a balanced subtree
+// that takes the place of the old leaf.
+func (dm *DagModifier) rechunkLeaf(pbn *ftpb.Data, offset, dellen uint64, ins io.Reader) (*mdag.Node, error) {
+	data := pbn.GetData()
+	if offset > uint64(len(data)) {
+		return nil, ft.ErrMalformedFileFormat
+	}
+	end := offset + dellen
+	if end > uint64(len(data)) {
+		end = uint64(len(data))
+	}
+
+	parts := []io.Reader{bytes.NewReader(data[:offset])}
+	if ins != nil {
+		parts = append(parts, ins)
+	}
+	parts = append(parts, bytes.NewReader(data[end:]))
+
+	spl := chunk.NewRabin(io.MultiReader(parts...), uint64(chunk.DefaultBlockSize))
+
+	first, err := spl.NextBytes()
+	switch err {
+	case nil:
+	case io.EOF:
+		// nothing left of this leaf
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	second, err := spl.NextBytes()
+	switch err {
+	case nil:
+	case io.EOF:
+		if pbn.GetType() == ft.TFile {
+			return mdag.NodeWithData(ft.FilePBData(first, uint64(len(first)))), nil
+		}
+		return mdag.NodeWithData(ft.WrapData(first)), nil
+	default:
+		return nil, err
+	}
+
+	dbp := &help.DagBuilderParams{
+		Dagserv:  dm.dagserv,
+		Maxlinks: help.DefaultLinksPerBlock,
+	}
+
+	return bal.BalancedLayout(dbp.New(&prefixSplitter{
+		chunks: [][]byte{first, second},
+		spl:    spl,
+	}))
+}
+
+// prefixSplitter hands out chunks that have already been read before
+// resuming the underlying splitter
+type prefixSplitter struct {
+	chunks [][]byte
+	spl    chunk.Splitter
+}
+
+func (ps *prefixSplitter) NextBytes() ([]byte, error) {
+	if len(ps.chunks) > 0 {
+		b := ps.chunks[0]
+		ps.chunks = ps.chunks[1:]
+		return b, nil
+	}
+	return ps.spl.NextBytes()
+}
+
+// countReader counts the number of bytes read through it
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+	return n, err
+}
@@ -548,6 +548,256 @@ func TestSeekPastEndWrite(t *testing.T) {
 	}
 }
 
+func TestSparseHoles(t *testing.T) {
+	dserv := getMockDagServ(t)
+	_, n := getNode(t, dserv, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dagmod, err := NewDagModifier(ctx, n, dserv, sizeSplitterGen(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holeSize := 1 << 20
+	buf := make([]byte, holeSize+1000)
+	u.NewTimeSeededRand().Read(buf[holeSize:])
+
+	_, err = dagmod.WriteAt(buf[holeSize:], int64(holeSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := dagmod.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holes, err := uio.HoleSize(ctx, nd, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if holes != uint64(holeSize) {
+		t.Fatalf("expected %d bytes of holes, got %d", holeSize, holes)
+	}
+
+	// write into the middle of the hole
+	u.NewTimeSeededRand().Read(buf[5000:6000])
+	_, err = dagmod.WriteAt(buf[5000:6000], 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err = dagmod.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holes, err = uio.HoleSize(ctx, nd, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if holes != uint64(holeSize-1000) {
+		t.Fatalf("expected %d bytes of holes, got %d", holeSize-1000, holes)
+	}
+
+	_, err = dagmod.Seek(0, os.SEEK_SET)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(dagmod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = arrComp(out, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// truncating into the hole keeps the rest of it
+	err = dagmod.Truncate(3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err = dagmod.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holes, err = uio.HoleSize(ctx, nd, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if holes != 3000 {
+		t.Fatalf("expected 3000 bytes of holes, got %d", holes)
+	}
+
+	size, err := dagmod.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != 3000 {
+		t.Fatalf("expected size 3000, got %d", size)
+	}
+}
+
+func TestInsertAt(t *testing.T) {
+	dserv := getMockDagServ(t)
+	b, n := getNode(t, dserv, 50000)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dagmod, err := NewDagModifier(ctx, n, dserv, sizeSplitterGen(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, off := range []int{0, 1000, 25000, len(b) - 1, len(b)} {
+		ins := make([]byte, 3000)
+		u.NewTimeSeededRand().Read(ins)
+
+		nins, err := dagmod.InsertAt(ins, int64(off))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nins != len(ins) {
+			t.Fatalf("inserted wrong amount: %d != %d", nins, len(ins))
+		}
+
+		exp := make([]byte, 0, len(b)+len(ins))
+		exp = append(exp, b[:off]...)
+		exp = append(exp, ins...)
+		b = append(exp, b[off:]...)
+
+		size, err := dagmod.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != int64(len(b)) {
+			t.Fatalf("size was incorrect: %d != %d", size, len(b))
+		}
+
+		_, err = dagmod.Seek(0, os.SEEK_SET)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := ioutil.ReadAll(dagmod)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = arrComp(out, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInsertKeepsUntouchedBlocks(t *testing.T) {
+	dserv := getMockDagServ(t)
+	_, n := getNode(t, dserv, 50000)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dagmod, err := NewDagModifier(ctx, n, dserv, sizeSplitterGen(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dagmod.InsertAt([]byte("hello"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := dagmod.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nd.Links) != len(n.Links) {
+		t.Fatalf("expected %d links, got %d", len(n.Links), len(nd.Links))
+	}
+
+	// only the first leaf should have been rewritten
+	if nd.Links[0].Hash.B58String() == n.Links[0].Hash.B58String() {
+		t.Fatal("expected first child to change")
+	}
+	for i := 1; i < len(nd.Links); i++ {
+		if nd.Links[i].Hash.B58String() != n.Links[i].Hash.B58String() {
+			t.Fatalf("child %d changed", i)
+		}
+	}
+}
+
+func TestRemoveRange(t *testing.T) {
+	dserv := getMockDagServ(t)
+	b, n := getNode(t, dserv, 50000)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dagmod, err := NewDagModifier(ctx, n, dserv, sizeSplitterGen(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cuts := []struct {
+		off, length int
+	}{
+		{0, 10},       // start of first block
+		{100, 200},    // within a single block
+		{1000, 12000}, // across many blocks
+		{400, 224},    // across a block boundary
+		{30000, 0},    // nothing
+		{20000, 100000},
+	}
+
+	for _, c := range cuts {
+		err := dagmod.RemoveRange(int64(c.off), int64(c.length))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		end := c.off + c.length
+		if end > len(b) {
+			end = len(b)
+		}
+		b = append(b[:c.off:c.off], b[end:]...)
+
+		size, err := dagmod.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != int64(len(b)) {
+			t.Fatalf("size was incorrect: %d != %d", size, len(b))
+		}
+
+		_, err = dagmod.Seek(0, os.SEEK_SET)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := ioutil.ReadAll(dagmod)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = arrComp(out, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = dagmod.RemoveRange(int64(len(b)+1), 1)
+	if err != ErrInvalidRange {
+		t.Fatal("expected error removing past end of file")
+	}
+}
+
 func BenchmarkDagmodWrite(b *testing.B) {
 	b.StopTimer()
 	dserv := getMockDagServ(b)
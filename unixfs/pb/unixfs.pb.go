@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Data
+	XAttr
 	Metadata
 */
 package unixfs_pb
@@ -68,6 +69,7 @@ type Data struct {
 	Data             []byte         `protobuf:"bytes,2,opt" json:"Data,omitempty"`
 	Filesize         *uint64        `protobuf:"varint,3,opt,name=filesize" json:"filesize,omitempty"`
 	Blocksizes       []uint64       `protobuf:"varint,4,rep,name=blocksizes" json:"blocksizes,omitempty"`
+	Xattrs           []*XAttr       `protobuf:"bytes,5,rep,name=xattrs" json:"xattrs,omitempty"`
 	XXX_unrecognized []byte         `json:"-"`
 }
 
@@ -103,8 +105,41 @@ func (m *Data) GetBlocksizes() []uint64 {
 	return nil
 }
 
+func (m *Data) GetXattrs() []*XAttr {
+	if m != nil {
+		return m.Xattrs
+	}
+	return nil
+}
+
+type XAttr struct {
+	Name             *string `protobuf:"bytes,1,req" json:"Name,omitempty"`
+	Value            []byte  `protobuf:"bytes,2,opt" json:"Value,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *XAttr) Reset()         { *m = XAttr{} }
+func (m *XAttr) String() string { return proto.CompactTextString(m) }
+func (*XAttr) ProtoMessage()    {}
+
+func (m *XAttr) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *XAttr) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
 type Metadata struct {
 	MimeType         *string `protobuf:"bytes,1,req" json:"MimeType,omitempty"`
+	SourceURL        *string `protobuf:"bytes,2,opt" json:"SourceURL,omitempty"`
+	ETag             *string `protobuf:"bytes,3,opt" json:"ETag,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -119,6 +154,20 @@ func (m *Metadata) GetMimeType() string {
 	return ""
 }
 
+func (m *Metadata) GetSourceURL() string {
+	if m != nil && m.SourceURL != nil {
+		return *m.SourceURL
+	}
+	return ""
+}
+
+func (m *Metadata) GetETag() string {
+	if m != nil && m.ETag != nil {
+		return *m.ETag
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterEnum("unixfs.pb.Data_DataType", Data_DataType_name, Data_DataType_value)
 }